@@ -0,0 +1,167 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The all-in-one binary runs the KCD controller and webhook in a single process.
+// It exists to make demos and kind-based tutorials trivially runnable; production
+// deployments should keep using the split controller and webhook binaries.
+package main
+
+import (
+	"context"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/defaults"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/policyguard"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/preview"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery"
+	deliveryconfig "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	policystatereconciler "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/policystate"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/watchscope"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/signals"
+	"knative.dev/pkg/webhook"
+	"knative.dev/pkg/webhook/certificates"
+	"knative.dev/pkg/webhook/resourcesemantics"
+	"knative.dev/pkg/webhook/resourcesemantics/defaulting"
+	"knative.dev/pkg/webhook/resourcesemantics/validation"
+
+	deliveryv1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	defaultconfig "knative.dev/serving/pkg/apis/config"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	configurationinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/configuration"
+	revisioninformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/revision"
+
+	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
+	policyinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policy"
+	policystate "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+)
+
+var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
+	servingv1.SchemeGroupVersion.WithKind("Route"):              &defaults.ContinuousDeploymentRoute{},
+	deliveryv1alpha1.SchemeGroupVersion.WithKind("Policy"):      &deliveryv1alpha1.Policy{},
+	deliveryv1alpha1.SchemeGroupVersion.WithKind("PolicyState"): &deliveryv1alpha1.PolicyState{},
+}
+
+// validationTypes additionally covers workloads whose policy annotations should
+// be checked at admission time; these must not be in the defaulting map because
+// KCD has no defaults to apply to them
+var validationTypes = func() map[schema.GroupVersionKind]resourcesemantics.GenericCRD {
+	m := map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
+		servingv1.SchemeGroupVersion.WithKind("Configuration"): &policyguard.GuardedConfiguration{},
+		servingv1.SchemeGroupVersion.WithKind("Service"):       &policyguard.GuardedService{},
+	}
+	for gvk, crd := range types {
+		m[gvk] = crd
+	}
+	// PolicyState and Route get guarded wrappers here so that validation can
+	// consult listers and the requesting user; defaulting keeps the plain types
+	m[deliveryv1alpha1.SchemeGroupVersion.WithKind("PolicyState")] = &policyguard.GuardedPolicyState{}
+	m[servingv1.SchemeGroupVersion.WithKind("Route")] = &policyguard.GuardedRoute{}
+	return m
+}()
+
+func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	// Decorate contexts with the current state of the config.
+	store := defaultconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
+	store.WatchConfigs(cmw)
+
+	// the delivery config carries the namespace allow/deny lists, which the
+	// Route defaulting must honor so an out-of-scope namespace is never touched
+	deliveryStore := deliveryconfig.NewStore(logging.FromContext(ctx).Named("delivery-config-store"))
+	deliveryStore.WatchConfigs(cmw)
+
+	return defaulting.NewAdmissionController(ctx,
+
+		// Name of the resource webhook.
+		"webhook.continuous-delivery.knative.dev",
+
+		// The path on which to serve the webhook.
+		"/defaulting",
+
+		// The resources to default.
+		types,
+
+		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
+		func(c context.Context) context.Context {
+			inf := policystate.Get(ctx)
+			clt := deliveryclient.Get(ctx)
+			c = context.WithValue(c, policystate.Key{}, inf)
+			c = context.WithValue(c, deliveryclient.Key{}, clt)
+			c = deliveryStore.ToContext(c)
+			return c
+		},
+
+		// Whether to disallow unknown fields.
+		true,
+	)
+}
+
+func newValidationAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	// Decorate contexts with the current state of the config.
+	store := defaultconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
+	store.WatchConfigs(cmw)
+
+	return validation.NewAdmissionController(ctx,
+
+		// Name of the resource webhook.
+		"validation.webhook.continuous-delivery.knative.dev",
+
+		// The path on which to serve the webhook.
+		"/resource-validation",
+
+		// The resources to validate.
+		validationTypes,
+
+		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
+		func(c context.Context) context.Context {
+			c = context.WithValue(c, policyinformer.Key{}, policyinformer.Get(ctx))
+			c = context.WithValue(c, revisioninformer.Key{}, revisioninformer.Get(ctx))
+			c = context.WithValue(c, policystate.Key{}, policystate.Get(ctx))
+			return c
+		},
+
+		// Whether to disallow unknown fields.
+		true,
+
+		// Reject deleting Policies that live Configurations still reference.
+		map[schema.GroupVersionKind]validation.Callback{
+			deliveryv1alpha1.SchemeGroupVersion.WithKind("Policy"): policyguard.NewCallback(configurationinformer.Get(ctx).Lister()),
+		},
+	)
+}
+
+func main() {
+	// Set up a signal context with our webhook options; the certificates controller
+	// below provisions a self-signed cert into the secret so that no external
+	// certificate tooling is needed for a demo cluster.
+	// an optional WATCH_NAMESPACE scopes all informers to one namespace; see
+	// the watchscope package for the caveats around cross-namespace Policies
+	ctx := webhook.WithOptions(watchscope.FromEnv(signals.NewContext()), webhook.Options{
+		ServiceName: "continuous-delivery-webhook",
+		Port:        webhook.PortFromEnv(8443),
+		SecretName:  "continuous-delivery-webhook-certs",
+	})
+
+	sharedmain.WebhookMainWithContext(ctx,
+		"continuous-delivery",
+		certificates.NewController,
+		newDefaultingAdmissionController,
+		newValidationAdmissionController,
+		preview.NewAdmissionController,
+		delivery.NewController,
+		policystatereconciler.NewController)
+}
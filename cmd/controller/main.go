@@ -18,10 +18,17 @@ import (
 	"flag"
 
 	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/policystate"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/watchscope"
 	"knative.dev/pkg/injection/sharedmain"
+	"knative.dev/pkg/signals"
 )
 
 func main() {
 	flag.Parse()
-	sharedmain.Main("continuous-delivery", delivery.NewController)
+	// an optional WATCH_NAMESPACE scopes all informers to one namespace, so the
+	// controller does not cache every Revision and Route on a large cluster
+	sharedmain.MainWithContext(watchscope.FromEnv(signals.NewContext()), "continuous-delivery",
+		delivery.NewController,
+		policystate.NewController)
 }
@@ -0,0 +1,248 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kcd is a small operator CLI for Knative Continuous Delivery.
+//
+//	kcd explain <namespace>/<configuration>
+//
+// prints the policy in effect for the Configuration, the rollout's current
+// stage and traffic split, why the percentages are what they are, and the
+// exact time of the next change.
+//
+//	kcd convert <manifest.yaml>
+//
+// translates existing gradual-rollout configuration (a Knative workload with
+// a rollout-duration annotation, or an Argo Rollouts canary manifest) into
+// the equivalent Policy YAML on stdout.
+//
+//	kcd simulate <policy.yaml>
+//	kcd simulate <namespace>/<policy>
+//
+// prints the rollout timeline the Policy produces: every traffic split in
+// order, with the rollout-time offset and wall-clock moment each one takes
+// effect at. The Policy comes from a local manifest ("-" for stdin) or is
+// fetched from the cluster.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"knative.dev/serving/pkg/apis/serving"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingclientset "knative.dev/serving/pkg/client/clientset/versioned"
+	"sigs.k8s.io/yaml"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	deliveryclientset "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/convert"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/explain"
+	reconcilerdelivery "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery"
+)
+
+const usage = "usage: kcd explain <namespace>/<configuration> | kcd convert <manifest.yaml> | kcd simulate <policy.yaml>|<namespace>/<policy>"
+
+func main() {
+	if len(os.Args) < 2 {
+		fatalf(usage)
+	}
+	switch os.Args[1] {
+	case "explain":
+		runExplain(os.Args[2:])
+	case "convert":
+		runConvert(os.Args[2:])
+	case "simulate":
+		runSimulate(os.Args[2:])
+	default:
+		fatalf(usage)
+	}
+}
+
+// runConvert reads a manifest ("-" for stdin) and prints the equivalent Policy
+func runConvert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fatalf("usage: kcd convert <manifest.yaml>")
+	}
+	var doc []byte
+	var err error
+	if flags.Arg(0) == "-" {
+		doc, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		doc, err = ioutil.ReadFile(flags.Arg(0))
+	}
+	if err != nil {
+		fatalf("failed to read the manifest: %v", err)
+	}
+	policy, err := convert.Convert(doc)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	out, err := yaml.Marshal(policy)
+	if err != nil {
+		fatalf("failed to render the Policy: %v", err)
+	}
+	os.Stdout.Write(out)
+}
+
+// runSimulate prints the rollout timeline a Policy produces: the Policy comes
+// from a local manifest ("-" for stdin, or a path that exists) or, for a
+// <namespace>/<name> argument, from the cluster
+func runSimulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	kubeconfig := flags.String("kubeconfig", "", "path to a kubeconfig; defaults to the usual lookup")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fatalf("usage: kcd simulate <policy.yaml> | kcd simulate <namespace>/<policy>")
+	}
+	arg := flags.Arg(0)
+
+	policy := &v1alpha1.Policy{}
+	if _, err := os.Stat(arg); arg == "-" || err == nil {
+		var doc []byte
+		if arg == "-" {
+			doc, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			doc, err = ioutil.ReadFile(arg)
+		}
+		if err != nil {
+			fatalf("failed to read the Policy manifest: %v", err)
+		}
+		if err := yaml.Unmarshal(doc, policy); err != nil {
+			fatalf("failed to parse the Policy manifest: %v", err)
+		}
+	} else {
+		parts := strings.SplitN(arg, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			fatalf("the Policy must be a manifest path or <namespace>/<name>, got %q", arg)
+		}
+		loading := clientcmd.NewDefaultClientConfigLoadingRules()
+		if *kubeconfig != "" {
+			loading.ExplicitPath = *kubeconfig
+		}
+		restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			fatalf("failed to load a kubeconfig: %v", err)
+		}
+		dc, err := deliveryclientset.NewForConfig(restConfig)
+		if err != nil {
+			fatalf("failed to build the delivery client: %v", err)
+		}
+		policy, err = dc.DeliveryV1alpha1().Policies(parts[0]).Get(parts[1], metav1.GetOptions{})
+		if err != nil {
+			fatalf("failed to get Policy %s: %v", arg, err)
+		}
+	}
+
+	name := policy.Name
+	if name == "" {
+		name = arg
+	}
+	start := time.Now()
+	fmt.Printf("Rollout timeline for Policy %s (started now):\n", name)
+	for _, step := range reconcilerdelivery.Simulate(policy) {
+		fmt.Printf("  %+6ds  %3d%%  %s\n", step.OffsetSeconds, step.Percent,
+			start.Add(time.Duration(step.OffsetSeconds)*time.Second).UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("Offsets count rollout time; pauses, time windows and gates delay the wall-clock moments.\n")
+}
+
+func runExplain(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	kubeconfig := flags.String("kubeconfig", "", "path to a kubeconfig; defaults to the usual lookup")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fatalf("usage: kcd explain <namespace>/<configuration>")
+	}
+	parts := strings.SplitN(flags.Arg(0), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		fatalf("the Configuration must be given as <namespace>/<name>, got %q", flags.Arg(0))
+	}
+	namespace, name := parts[0], parts[1]
+
+	loading := clientcmd.NewDefaultClientConfigLoadingRules()
+	if *kubeconfig != "" {
+		loading.ExplicitPath = *kubeconfig
+	}
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		fatalf("failed to load a kubeconfig: %v", err)
+	}
+	sc, err := servingclientset.NewForConfig(restConfig)
+	if err != nil {
+		fatalf("failed to build the serving client: %v", err)
+	}
+	dc, err := deliveryclientset.NewForConfig(restConfig)
+	if err != nil {
+		fatalf("failed to build the delivery client: %v", err)
+	}
+
+	cfg, err := sc.ServingV1().Configurations(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("failed to get Configuration %s/%s: %v", namespace, name, err)
+	}
+	route, err := sc.ServingV1().Routes(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("failed to get Route %s/%s: %v", namespace, name, err)
+	}
+	ps, err := dc.DeliveryV1alpha1().PolicyStates(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		fatalf("no rollout state for %s/%s (is continuous delivery managing it?): %v", namespace, name, err)
+	}
+
+	// the winning Policy is resolved from what the reconciler recorded; the
+	// explanation degrades gracefully when it cannot be fetched (e.g. a
+	// ConfigMap-backed policy document)
+	var policy *v1alpha1.Policy
+	if full := ps.Status.PolicyName; full != "" {
+		policyNamespace, policyName := namespace, full
+		if s := strings.SplitN(full, "/", 2); len(s) == 2 {
+			policyNamespace, policyName = s[0], s[1]
+		}
+		policy, _ = dc.DeliveryV1alpha1().Policies(policyNamespace).Get(policyName, metav1.GetOptions{})
+	}
+
+	revisionList, err := sc.ServingV1().Revisions(namespace).List(metav1.ListOptions{
+		LabelSelector: serving.ConfigurationLabelKey + "=" + name,
+	})
+	if err != nil {
+		fatalf("failed to list Revisions of %s/%s: %v", namespace, name, err)
+	}
+	revisions := make([]*servingv1.Revision, 0, len(revisionList.Items))
+	for i := range revisionList.Items {
+		revisions = append(revisions, &revisionList.Items[i])
+	}
+
+	report := &explain.Report{
+		Config:      cfg,
+		Route:       route,
+		PolicyState: ps,
+		Policy:      policy,
+		Revisions:   revisions,
+		Now:         time.Now(),
+	}
+	report.Render(os.Stdout)
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
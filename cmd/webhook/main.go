@@ -18,6 +18,9 @@ import (
 	"context"
 
 	"github.com/googleinterns/knative-continuous-delivery/pkg/defaults"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/policyguard"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/preview"
+	deliveryconfig "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
@@ -31,23 +34,57 @@ import (
 	"knative.dev/pkg/webhook/resourcesemantics/validation"
 
 	deliveryv1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/system"
 	defaultconfig "knative.dev/serving/pkg/apis/config"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	configurationinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/configuration"
+	revisioninformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/revision"
+	"knative.dev/serving/pkg/network"
 
 	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
+	policyinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policy"
 	policystate "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
 )
 
 var types = map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
-	servingv1.SchemeGroupVersion.WithKind("Route"):         &defaults.ContinuousDeploymentRoute{},
-	deliveryv1alpha1.SchemeGroupVersion.WithKind("Policy"): &deliveryv1alpha1.Policy{},
+	servingv1.SchemeGroupVersion.WithKind("Route"):              &defaults.ContinuousDeploymentRoute{},
+	servingv1.SchemeGroupVersion.WithKind("Service"):            &defaults.ContinuousDeliveryService{},
+	deliveryv1alpha1.SchemeGroupVersion.WithKind("Policy"):      &deliveryv1alpha1.Policy{},
+	deliveryv1alpha1.SchemeGroupVersion.WithKind("PolicyState"): &deliveryv1alpha1.PolicyState{},
 }
 
+// validationTypes additionally covers workloads whose policy annotations should
+// be checked at admission time; these must not be in the defaulting map because
+// KCD has no defaults to apply to them
+var validationTypes = func() map[schema.GroupVersionKind]resourcesemantics.GenericCRD {
+	m := map[schema.GroupVersionKind]resourcesemantics.GenericCRD{
+		servingv1.SchemeGroupVersion.WithKind("Configuration"): &policyguard.GuardedConfiguration{},
+		servingv1.SchemeGroupVersion.WithKind("Service"):       &policyguard.GuardedService{},
+	}
+	for gvk, crd := range types {
+		m[gvk] = crd
+	}
+	// PolicyState, Route and Service get guarded wrappers here so that validation
+	// can consult listers and the requesting user; defaulting keeps its own types
+	m[deliveryv1alpha1.SchemeGroupVersion.WithKind("PolicyState")] = &policyguard.GuardedPolicyState{}
+	m[servingv1.SchemeGroupVersion.WithKind("Route")] = &policyguard.GuardedRoute{}
+	m[servingv1.SchemeGroupVersion.WithKind("Service")] = &policyguard.GuardedService{}
+	return m
+}()
+
 func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
 	// Decorate contexts with the current state of the config.
 	store := defaultconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
 	store.WatchConfigs(cmw)
 
+	// the delivery config carries the namespace allow/deny lists, which the
+	// Route defaulting must honor so an out-of-scope namespace is never touched
+	deliveryStore := deliveryconfig.NewStore(logging.FromContext(ctx).Named("delivery-config-store"))
+	deliveryStore.WatchConfigs(cmw)
+
 	return defaulting.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -65,6 +102,9 @@ func newDefaultingAdmissionController(ctx context.Context, cmw configmap.Watcher
 			clt := deliveryclient.Get(ctx)
 			c = context.WithValue(c, policystate.Key{}, inf)
 			c = context.WithValue(c, deliveryclient.Key{}, clt)
+			// Service defaulting reads the Namespace's default policy annotation
+			c = context.WithValue(c, kubeclient.Key{}, kubeclient.Get(ctx))
+			c = deliveryStore.ToContext(c)
 			return c
 		},
 
@@ -78,6 +118,20 @@ func newValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 	store := defaultconfig.NewStore(logging.FromContext(ctx).Named("config-store"))
 	store.WatchConfigs(cmw)
 
+	// the delivery config carries the cluster limits (e.g. the maximum rollout
+	// duration) that Policy validation enforces
+	deliveryStore := deliveryconfig.NewStore(logging.FromContext(ctx).Named("delivery-config-store"))
+	deliveryStore.WatchConfigs(cmw)
+
+	// detect the networking layer once at startup so that Policy validation can
+	// reject traffic features the layer does not support
+	var caps networking.Capabilities
+	if cm, err := kubeclient.Get(ctx).CoreV1().ConfigMaps(system.Namespace()).Get(network.ConfigName, metav1.GetOptions{}); err == nil {
+		caps = networking.FromConfigMap(cm)
+	} else {
+		logging.FromContext(ctx).Infof("Failed to read %s, skipping capability detection: %v", network.ConfigName, err)
+	}
+
 	return validation.NewAdmissionController(ctx,
 
 		// Name of the resource webhook.
@@ -87,15 +141,27 @@ func newValidationAdmissionController(ctx context.Context, cmw configmap.Watcher
 		"/resource-validation",
 
 		// The resources to validate.
-		types,
+		validationTypes,
 
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
-		func(ctx context.Context) context.Context {
-			return ctx
+		func(c context.Context) context.Context {
+			if caps != nil {
+				c = networking.WithCapabilities(c, caps)
+			}
+			c = context.WithValue(c, policyinformer.Key{}, policyinformer.Get(ctx))
+			c = context.WithValue(c, revisioninformer.Key{}, revisioninformer.Get(ctx))
+			c = context.WithValue(c, policystate.Key{}, policystate.Get(ctx))
+			c = deliveryStore.ToContext(c)
+			return c
 		},
 
 		// Whether to disallow unknown fields.
 		true,
+
+		// Reject deleting Policies that live Configurations still reference.
+		map[schema.GroupVersionKind]validation.Callback{
+			deliveryv1alpha1.SchemeGroupVersion.WithKind("Policy"): policyguard.NewCallback(configurationinformer.Get(ctx).Lister()),
+		},
 	)
 }
 
@@ -111,5 +177,6 @@ func main() {
 		"continuous-delivery-webhook",
 		certificates.NewController,
 		newDefaultingAdmissionController,
-		newValidationAdmissionController)
+		newValidationAdmissionController,
+		preview.NewAdmissionController)
 }
@@ -4,6 +4,10 @@ go 1.14
 
 require (
 	github.com/google/go-cmp v0.5.0
+	go.opencensus.io v0.22.4
+	go.uber.org/zap v1.14.1
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
+	k8s.io/api v0.18.1
 	k8s.io/apimachinery v0.18.5
 	k8s.io/client-go v11.0.1-0.20190805182717-6502b5e7b1b5+incompatible
 	k8s.io/code-generator v0.18.0
@@ -11,6 +15,7 @@ require (
 	knative.dev/pkg v0.0.0-20200702222342-ea4d6e985ba0
 	knative.dev/serving v0.16.0
 	knative.dev/test-infra v0.0.0-20200630141629-15f40fe97047
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseOverrideThreshold parses the value of an OverrideThresholdKey
+// annotation; both the validation webhook and the reconciler use it, so a
+// value the webhook accepted is guaranteed to parse again later
+func ParseOverrideThreshold(v string) (int, error) {
+	threshold, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, fmt.Errorf("threshold override %q is not an integer", v)
+	}
+	if threshold <= 0 {
+		return 0, fmt.Errorf("threshold override must be positive, got %d", threshold)
+	}
+	return threshold, nil
+}
+
+// ParseOverrideStages parses the value of an OverrideStagesKey annotation into
+// the stage percentages it lists; the percentages must be in [0, 99] and in
+// strictly increasing order, mirroring what Policy stage validation enforces
+func ParseOverrideStages(v string) ([]int, error) {
+	parts := strings.Split(v, ",")
+	percents := make([]int, 0, len(parts))
+	prev := -1
+	for _, part := range parts {
+		percent, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("stage override %q is not a list of integers", v)
+		}
+		if percent < 0 || percent > 99 {
+			return nil, fmt.Errorf("stage override percentages must be in [0, 99], got %d", percent)
+		}
+		if percent <= prev {
+			return nil, fmt.Errorf("stage override percentages must be strictly increasing, but %d <= %d", percent, prev)
+		}
+		percents = append(percents, percent)
+		prev = percent
+	}
+	return percents, nil
+}
@@ -14,10 +14,110 @@
 
 package delivery
 
+import "strings"
+
 const (
 	// GroupName is the group name for knative labels and annotations
 	GroupName = "delivery.knative.dev"
 
 	// PolicyNameKey is the key for annotations that specify rollout policies
 	PolicyNameKey = GroupName + "/policy"
+
+	// PolicyConfigMapPrefix marks a PolicyNameKey annotation value that points at
+	// a ConfigMap holding a policy document ("configmap:name" or
+	// "configmap:namespace/name") instead of a Policy resource; this is for
+	// environments where creating custom resources is gated but ConfigMaps are not
+	PolicyConfigMapPrefix = "configmap:"
+
+	// DefaultPolicyKey is the key for an annotation on a Namespace naming the
+	// Policy that newly created Services in that namespace inherit; the
+	// defaulting webhook copies it into the Service's PolicyNameKey annotation
+	// unless the Service already names a policy of its own
+	DefaultPolicyKey = GroupName + "/default-policy"
+
+	// RouteOwnerKey is the key for the annotation the reconciler stamps on
+	// Routes it manages when it is the sole writer of Route specs (the
+	// "reconciler" route ownership mode); other controllers and tooling must
+	// leave the spec of an annotated Route alone
+	RouteOwnerKey = GroupName + "/route-owner"
+
+	// RouteOwnerValue is the value of RouteOwnerKey on managed Routes
+	RouteOwnerValue = "knative-continuous-delivery"
+
+	// ServingRolloutDurationKey is Knative Serving's native gradual-rollout
+	// annotation; both mechanisms rewriting the same traffic targets would
+	// oscillate, so a workload carrying it is never managed by this controller
+	ServingRolloutDurationKey = "serving.knative.dev/rollout-duration"
+
+	// AnalysisExclusionsKey is the key for annotations that list time ranges
+	// (comma-separated "start/end" pairs in RFC 3339) during which analysis
+	// gates should ignore observed data, e.g. a scheduled load test
+	AnalysisExclusionsKey = GroupName + "/analysis-exclusions"
+
+	// RolloutStatusKey is the key for the annotation the reconciler writes onto
+	// a Configuration while a rollout is in flight; the value is a small JSON
+	// document with the current stage, candidate percent, and next-stage ETA
+	RolloutStatusKey = GroupName + "/rollout-status"
+
+	// OnFailureKey is the key for annotations that override, per Configuration,
+	// what a failed rollout does with traffic; valid values are the same
+	// "hold", "rollback" and "abort" accepted by the Policy onFailure field
+	OnFailureKey = GroupName + "/on-failure"
+
+	// HoldsMinScaleKey marks a Revision whose autoscaling minScale annotation
+	// was placed by the delivery reconciler to keep the candidate warm during a
+	// rollout; only marked annotations are removed when the rollout ends, so a
+	// minScale the user set themselves is never touched
+	HoldsMinScaleKey = GroupName + "/holds-min-scale"
+
+	// OriginalMinScaleKey remembers, on a Revision whose autoscaling minScale
+	// the reconciler is scaling in proportion to its traffic share, the value
+	// the user originally declared; it is restored when the rollout ends
+	OriginalMinScaleKey = GroupName + "/original-min-scale"
+
+	// OverrideThresholdKey is the key for annotations that override, per
+	// Configuration, the threshold of every rollout stage; the value is a
+	// positive integer interpreted like the Policy's defaultThreshold, so one
+	// rollout can be sped up or slowed down without forking the shared Policy
+	OverrideThresholdKey = GroupName + "/override-threshold"
+
+	// OverrideStagesKey is the key for annotations that override, per
+	// Configuration, the Policy's rollout stages; the value is a
+	// comma-separated list of increasing percentages in [0, 99], each stage
+	// using the (possibly overridden) default threshold
+	OverrideStagesKey = GroupName + "/override-stages"
+
+	// StartAtKey is the key for annotations that schedule the first traffic
+	// shift of a rollout: until the given RFC 3339 time (e.g.
+	// "2024-06-01T09:00:00-07:00") the candidate holds at 0%, reachable only
+	// through its tag URL, and the stage ladder starts counting from that moment
+	StartAtKey = GroupName + "/start-at"
+
+	// WinnerKey is the key for annotations that end an A/B experiment by naming
+	// the winning Revision; the reconciler routes 100% of traffic to it and the
+	// losing arm is retired
+	WinnerKey = GroupName + "/winner"
+
+	// BaselineForKey labels a Configuration that the reconciler spawned as a
+	// fresh copy of another Configuration's stable Revision, so that compare
+	// gates can judge the candidate against equally cold instances; the value
+	// is the name of the Configuration the baseline belongs to
+	BaselineForKey = GroupName + "/baseline-for"
+
+	// SafeToDeleteKey marks a Revision the reconciler is done with: it serves no
+	// traffic and its rollout leftovers have been cleaned up. The annotation is
+	// advisory; KCD never deletes user workloads, external garbage collection
+	// decides whether to act on it
+	SafeToDeleteKey = GroupName + "/safe-to-delete"
+
+	// EnabledKey is the key for annotations that opt a workload out of
+	// continuous delivery; setting it to "false" releases the Route back to the
+	// normal Serving reconciler without uninstalling KCD
+	EnabledKey = GroupName + "/enabled"
 )
+
+// Disabled reports whether the annotations opt the workload out of continuous
+// delivery; only an explicit "false" disables, anything else leaves KCD on
+func Disabled(annotations map[string]string) bool {
+	return strings.EqualFold(annotations[EnabledKey], "false")
+}
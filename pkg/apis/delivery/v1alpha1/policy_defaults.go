@@ -16,6 +16,8 @@ package v1alpha1
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"knative.dev/pkg/logging"
 )
@@ -25,4 +27,94 @@ import (
 // SetDefaults implements apis.Defaultable
 func (p *Policy) SetDefaults(ctx context.Context) {
 	logging.FromContext(ctx).Infof("SetDefaults called for %v", *p)
+	if p.Spec.Mode == "" {
+		p.Spec.Mode = "time"
+	}
+	if p.Spec.ThresholdUnit == "" {
+		p.Spec.ThresholdUnit = ThresholdUnitSeconds
+	}
+	p.Spec.expandGenerator()
+	p.Spec.normalizeStages()
+}
+
+// expandGenerator materializes the Generator shorthand into concrete Stages
+// it's a no-op when no Generator is given or when Stages are spelled out explicitly
+// invalid Generator values are left untouched so that validation can reject them
+func (ps *PolicySpec) expandGenerator() {
+	g := ps.Generator
+	if g == nil || len(ps.Stages) > 0 {
+		return
+	}
+	var interval *int
+	if g.Interval != "" {
+		d, err := time.ParseDuration(g.Interval)
+		if err != nil {
+			return
+		}
+		seconds := int(d / time.Second)
+		interval = &seconds
+	}
+	stageThreshold := func() *int {
+		if interval == nil {
+			return nil
+		}
+		t := *interval
+		return &t
+	}
+	// blue/green is a single dark-launch soak: the candidate is reachable only
+	// through its tag URL for the soak period, then the Route flips to 100% at
+	// once, skipping all intermediate percentages
+	if g.Type == "bluegreen" {
+		soak := stageThreshold()
+		if soak == nil {
+			if ps.DefaultThreshold <= 0 {
+				return
+			}
+			t := ps.DefaultThreshold
+			soak = &t
+		}
+		ps.Stages = []Stage{{Percent: 0, Threshold: soak}}
+		return
+	}
+	if g.Step <= 0 {
+		return
+	}
+	switch g.Type {
+	case "linear":
+		for percent := 0; percent < 100; percent += g.Step {
+			ps.Stages = append(ps.Stages, Stage{Percent: percent, Threshold: stageThreshold()})
+		}
+	case "exponential":
+		ps.Stages = append(ps.Stages, Stage{Percent: 0, Threshold: stageThreshold()})
+		for percent := g.Step; percent < 100; percent *= 2 {
+			ps.Stages = append(ps.Stages, Stage{Percent: percent, Threshold: stageThreshold()})
+		}
+	}
+}
+
+// normalizeStages rewrites Stages into the canonical form that validation and
+// the reconciler rely on: stages sorted by percent, the implicit leading 0%
+// stage spelled out, and omitted thresholds on non-zero stages filled in from
+// DefaultThreshold
+// the leading 0% stage only gets a threshold when one was given explicitly,
+// because an explicit threshold there means a dark-launch soak period
+func (ps *PolicySpec) normalizeStages() {
+	if len(ps.Stages) == 0 {
+		return
+	}
+	sort.SliceStable(ps.Stages, func(i, j int) bool {
+		return ps.Stages[i].Percent < ps.Stages[j].Percent
+	})
+	if ps.Stages[0].Percent != 0 {
+		ps.Stages = append([]Stage{{Percent: 0}}, ps.Stages...)
+	}
+	if ps.DefaultThreshold <= 0 {
+		return // leave the missing DefaultThreshold for validation to reject
+	}
+	for i := range ps.Stages[1:] {
+		if ps.Stages[i+1].Threshold == nil && ps.Stages[i+1].After == nil {
+			t := ps.DefaultThreshold
+			ps.Stages[i+1].Threshold = &t
+		}
+	}
 }
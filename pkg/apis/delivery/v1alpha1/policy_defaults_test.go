@@ -37,7 +37,7 @@ func TestPolicyDefaulting(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "time",
 				DefaultThreshold: 50,
-				Stages:           []Stage{{10, intptr(20)}, {20, intptr(30)}, {50, nil}},
+				Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(20)}, {Percent: 20, Threshold: intptr(30)}, {Percent: 50, Threshold: intptr(40)}},
 			},
 		},
 		want: &Policy{
@@ -47,8 +47,190 @@ func TestPolicyDefaulting(t *testing.T) {
 			},
 			Spec: PolicySpec{
 				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
 				DefaultThreshold: 50,
-				Stages:           []Stage{{10, intptr(20)}, {20, intptr(30)}, {50, nil}},
+				Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(20)}, {Percent: 20, Threshold: intptr(30)}, {Percent: 50, Threshold: intptr(40)}},
+			},
+		},
+	}, {
+		name: "stages are normalized into canonical form",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				DefaultThreshold: 50,
+				Stages:           []Stage{{Percent: 20, Threshold: intptr(30)}, {Percent: 10}},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				// stages get sorted, the implicit 0% stage is spelled out, and omitted
+				// thresholds are filled in; the 0% stage keeps its nil threshold because
+				// an explicit threshold there would mean a dark-launch soak
+				Stages: []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(50)}, {Percent: 20, Threshold: intptr(30)}},
+			},
+		},
+	}, {
+		name: "missing DefaultThreshold is left for validation",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:   "time",
+				Stages: []Stage{{Percent: 0}, {Percent: 50}},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:          "time",
+				ThresholdUnit: ThresholdUnitSeconds,
+				Stages:        []Stage{{Percent: 0}, {Percent: 50}},
+			},
+		},
+	}, {
+		name: "linear generator is expanded into stages",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "linear", Step: 25, Interval: "2m"},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "linear", Step: 25, Interval: "2m"},
+				Stages:           []Stage{{Percent: 0, Threshold: intptr(120)}, {Percent: 25, Threshold: intptr(120)}, {Percent: 50, Threshold: intptr(120)}, {Percent: 75, Threshold: intptr(120)}},
+			},
+		},
+	}, {
+		name: "exponential generator is expanded into stages",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "exponential", Step: 10},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "exponential", Step: 10},
+				Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(50)}, {Percent: 20, Threshold: intptr(50)}, {Percent: 40, Threshold: intptr(50)}, {Percent: 80, Threshold: intptr(50)}},
+			},
+		},
+	}, {
+		name: "bluegreen generator is a single dark-launch soak",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "bluegreen", Interval: "10m"},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "bluegreen", Interval: "10m"},
+				Stages:           []Stage{{Percent: 0, Threshold: intptr(600)}},
+			},
+		},
+	}, {
+		name: "bluegreen generator without interval soaks for the default threshold",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "bluegreen"},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "bluegreen"},
+				Stages:           []Stage{{Percent: 0, Threshold: intptr(50)}},
+			},
+		},
+	}, {
+		name: "explicit stages take precedence over generator",
+		in: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "linear", Step: 10},
+				Stages:           []Stage{{Percent: 0}, {Percent: 50, Threshold: intptr(30)}},
+			},
+		},
+		want: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: "default",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				ThresholdUnit:    ThresholdUnitSeconds,
+				DefaultThreshold: 50,
+				Generator:        &Generator{Type: "linear", Step: 10},
+				Stages:           []Stage{{Percent: 0}, {Percent: 50, Threshold: intptr(30)}},
 			},
 		},
 	}}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"testing"
+)
+
+// TestCompatibleWith pins down the version-skew contract between the controller
+// (which writes PolicyState specs) and the webhook (which reads them) during
+// rolling upgrades of KCD itself
+func TestCompatibleWith(t *testing.T) {
+	tests := []struct {
+		name          string
+		writerVersion int // what the spec was stamped with
+		readerVersion int // what the reader understands up to
+		want          bool
+	}{{
+		name:          "same version",
+		writerVersion: PolicyStateFormatVersion,
+		readerVersion: PolicyStateFormatVersion,
+		want:          true,
+	}, {
+		name:          "legacy spec without a format version reads as v1",
+		writerVersion: 0,
+		readerVersion: PolicyStateFormatVersion,
+		want:          true,
+	}, {
+		name:          "new reader accepts an old writer",
+		writerVersion: PolicyStateFormatVersion,
+		readerVersion: PolicyStateFormatVersion + 1,
+		want:          true,
+	}, {
+		name:          "old reader refuses a newer writer",
+		writerVersion: PolicyStateFormatVersion + 1,
+		readerVersion: PolicyStateFormatVersion,
+		want:          false,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := &PolicyStateSpec{FormatVersion: test.writerVersion}
+			if got := spec.CompatibleWith(test.readerVersion); got != test.want {
+				t.Errorf("CompatibleWith(%d) on a v%d spec = %v, want %v", test.readerVersion, test.writerVersion, got, test.want)
+			}
+		})
+	}
+}
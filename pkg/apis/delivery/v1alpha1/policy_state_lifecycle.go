@@ -15,6 +15,9 @@
 package v1alpha1
 
 import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
 )
@@ -43,9 +46,119 @@ func (pss *PolicyStateStatus) InitializeConditions() {
 	policyStateCondSet.Manage(pss).InitializeConditions()
 }
 
+// MarkRouteConfigured sets the condition value to true once the Route spec
+// carries the desired traffic split
+func (pss *PolicyStateStatus) MarkRouteConfigured() {
+	policyStateCondSet.Manage(pss).MarkTrue(PolicyStateConditionRouteConfigured)
+}
+
 // MarkRouteNotConfigured sets the condition value to false
 func (pss *PolicyStateStatus) MarkRouteNotConfigured(name string) {
 	policyStateCondSet.Manage(pss).MarkFalse(PolicyStateConditionRouteConfigured,
 		"RouteNotConfigured",
 		"Failed to write Route spec to Route %q", name)
 }
+
+// MarkFrozen records that the cluster-wide rollout freeze is holding this rollout
+func (pss *PolicyStateStatus) MarkFrozen() {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionFrozen,
+		Status:   corev1.ConditionTrue,
+		Severity: apis.ConditionSeverityInfo,
+		Reason:   "RolloutsFrozen",
+		Message:  "Rollouts are frozen cluster-wide; holding the current traffic split",
+	})
+}
+
+// MarkProgressDeadlineExceeded records that the rollout failed to advance within the
+// Policy's progress deadline
+func (pss *PolicyStateStatus) MarkProgressDeadlineExceeded(deadline int) {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionProgressing,
+		Status:   corev1.ConditionFalse,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   "ProgressDeadlineExceeded",
+		Message:  fmt.Sprintf("Rollout was unable to advance for more than %d seconds", deadline),
+	})
+}
+
+// MarkStageHookFailed records that a stage's verification Job failed, which
+// permanently stops the rollout from advancing
+func (pss *PolicyStateStatus) MarkStageHookFailed(hookName string) {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionProgressing,
+		Status:   corev1.ConditionFalse,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   "StageHookFailed",
+		Message:  fmt.Sprintf("Verification hook %q failed; rollout will not advance", hookName),
+	})
+}
+
+// MarkStalled records that the rollout has gone far longer than its stage
+// threshold predicts without a stage transition; unlike a progress deadline
+// failure this is an observation, not a verdict, and clears once the rollout
+// moves again
+func (pss *PolicyStateStatus) MarkStalled(seconds int) {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionProgressing,
+		Status:   corev1.ConditionFalse,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   "Stalled",
+		Message:  fmt.Sprintf("Rollout has not advanced a stage for %d seconds", seconds),
+	})
+}
+
+// IsStalled reports whether the rollout currently carries the Stalled marker
+func (pss *PolicyStateStatus) IsStalled() bool {
+	cond := policyStateCondSet.Manage(pss).GetCondition(PolicyStateConditionProgressing)
+	return cond != nil && cond.Reason == "Stalled"
+}
+
+// ClearStalled clears the Stalled marker once the rollout advances again;
+// terminal Progressing failures set by other markers are left untouched
+func (pss *PolicyStateStatus) ClearStalled() {
+	if pss.IsStalled() {
+		policyStateCondSet.Manage(pss).ClearCondition(PolicyStateConditionProgressing)
+	}
+}
+
+// MarkRevisionStuckDeploying records that the candidate Revision has been stuck
+// in Ready=Unknown beyond the deploy grace period
+func (pss *PolicyStateStatus) MarkRevisionStuckDeploying(name string) {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionRevisionDeployed,
+		Status:   corev1.ConditionUnknown,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   "RevisionStuckDeploying",
+		Message:  fmt.Sprintf("Revision %q has been deploying for an unusually long time", name),
+	})
+}
+
+// MarkRevisionDeployed clears the RevisionDeployed condition once the candidate
+// Revision finishes deploying
+func (pss *PolicyStateStatus) MarkRevisionDeployed() {
+	policyStateCondSet.Manage(pss).ClearCondition(PolicyStateConditionRevisionDeployed)
+}
+
+// MarkNotOperational records a translated API error together with a remediation
+// hint; the reason distinguishes the failure classes (RBACMisconfigured,
+// PolicyMissing, WebhookUnavailable, ...)
+func (pss *PolicyStateStatus) MarkNotOperational(reason, message string) {
+	policyStateCondSet.Manage(pss).SetCondition(apis.Condition{
+		Type:     PolicyStateConditionOperational,
+		Status:   corev1.ConditionFalse,
+		Severity: apis.ConditionSeverityWarning,
+		Reason:   reason,
+		Message:  message,
+	})
+}
+
+// MarkOperational clears the Operational condition after a clean reconcile
+func (pss *PolicyStateStatus) MarkOperational() {
+	policyStateCondSet.Manage(pss).ClearCondition(PolicyStateConditionOperational)
+}
+
+// MarkUnfrozen clears the Frozen condition after the cluster-wide freeze is lifted
+func (pss *PolicyStateStatus) MarkUnfrozen() {
+	policyStateCondSet.Manage(pss).ClearCondition(PolicyStateConditionFrozen)
+}
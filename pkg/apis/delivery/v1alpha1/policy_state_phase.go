@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+// The rollout phases recorded in PolicyState.Status.Phase. The phase is a
+// single explicit field maintained by the reconciler through guarded
+// transitions, so consumers (CLI, notifications, metrics, catalog feeds) can
+// key off it instead of re-inferring rollout state from traffic splits and
+// timestamps.
+const (
+	// PhasePending means a new Revision exists but is not yet receiving traffic
+	PhasePending = "Pending"
+	// PhaseProgressing means the rollout is advancing through its stages
+	PhaseProgressing = "Progressing"
+	// PhasePaused means the rollout is held without advancing its stage timer
+	// (cluster-wide freeze, or the candidate Revision turned unready)
+	PhasePaused = "Paused"
+	// PhaseStalled means the candidate Revision cannot finish deploying
+	PhaseStalled = "Stalled"
+	// PhaseRollingBack means traffic is being returned to the stable Revision
+	PhaseRollingBack = "RollingBack"
+	// PhaseCompleted means the rollout finished and the Route follows the
+	// latest Revision again
+	PhaseCompleted = "Completed"
+	// PhaseAborted means the rollout failed terminally
+	PhaseAborted = "Aborted"
+)
+
+// validPhaseTransitions defines the rollout state machine: each phase lists
+// the phases it may move to; terminal phases only restart via a new rollout
+var validPhaseTransitions = map[string][]string{
+	PhasePending:     {PhaseProgressing, PhasePaused, PhaseStalled, PhaseAborted},
+	PhaseProgressing: {PhasePending, PhasePaused, PhaseStalled, PhaseRollingBack, PhaseCompleted, PhaseAborted},
+	PhasePaused:      {PhasePending, PhaseProgressing, PhaseStalled, PhaseRollingBack, PhaseCompleted, PhaseAborted},
+	PhaseStalled:     {PhasePending, PhaseProgressing, PhasePaused, PhaseRollingBack, PhaseAborted},
+	PhaseRollingBack: {PhaseAborted},
+	PhaseCompleted:   {PhasePending, PhaseProgressing},
+	PhaseAborted:     {PhasePending, PhaseProgressing},
+}
+
+// TransitionPhase moves the rollout to phase if the state machine allows it and
+// reports whether the stored phase changed (so callers know to persist); an
+// empty stored phase accepts any transition, covering objects written before
+// the phase field existed
+func (pss *PolicyStateStatus) TransitionPhase(phase string) bool {
+	current := pss.Phase
+	if current == phase {
+		return false
+	}
+	if current != "" && !phaseAllowed(current, phase) {
+		return false
+	}
+	pss.Phase = phase
+	return true
+}
+
+func phaseAllowed(from, to string) bool {
+	for _, next := range validPhaseTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
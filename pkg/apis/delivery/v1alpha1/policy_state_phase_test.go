@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import "testing"
+
+func TestTransitionPhase(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      string
+		to        string
+		want      bool
+		wantPhase string
+	}{{
+		name:      "empty phase accepts anything",
+		from:      "",
+		to:        PhaseProgressing,
+		want:      true,
+		wantPhase: PhaseProgressing,
+	}, {
+		name:      "same phase is a no-op",
+		from:      PhaseProgressing,
+		to:        PhaseProgressing,
+		want:      false,
+		wantPhase: PhaseProgressing,
+	}, {
+		name:      "progressing can pause",
+		from:      PhaseProgressing,
+		to:        PhasePaused,
+		want:      true,
+		wantPhase: PhasePaused,
+	}, {
+		name:      "progressing can complete",
+		from:      PhaseProgressing,
+		to:        PhaseCompleted,
+		want:      true,
+		wantPhase: PhaseCompleted,
+	}, {
+		name:      "completed cannot abort",
+		from:      PhaseCompleted,
+		to:        PhaseAborted,
+		want:      false,
+		wantPhase: PhaseCompleted,
+	}, {
+		name:      "aborted restarts on a new rollout",
+		from:      PhaseAborted,
+		to:        PhaseProgressing,
+		want:      true,
+		wantPhase: PhaseProgressing,
+	}, {
+		name:      "rolling back only aborts",
+		from:      PhaseRollingBack,
+		to:        PhaseCompleted,
+		want:      false,
+		wantPhase: PhaseRollingBack,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pss := &PolicyStateStatus{PolicyStateStatusFields: PolicyStateStatusFields{Phase: test.from}}
+			if got := pss.TransitionPhase(test.to); got != test.want {
+				t.Errorf("TransitionPhase(%q) = %v, want %v", test.to, got, test.want)
+			}
+			if pss.Phase != test.wantPhase {
+				t.Errorf("Phase = %q, want %q", pss.Phase, test.wantPhase)
+			}
+		})
+	}
+}
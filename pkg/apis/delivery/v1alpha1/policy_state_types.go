@@ -46,20 +46,86 @@ type PolicyState struct {
 var (
 	// Check that the type conforms to the duck Knative Resource shape.
 	_ duckv1.KRShaped = (*PolicyState)(nil)
+
+	// Check that PolicyState may be validated and defaulted.
+	_ apis.Validatable = (*PolicyState)(nil)
+	_ apis.Defaultable = (*PolicyState)(nil)
 )
 
 const (
 	// PolicyStateConditionRouteConfigured is set to false if any failure prevents
 	// PolicyState.Spec from being written to Route.Spec
 	PolicyStateConditionRouteConfigured apis.ConditionType = "RouteConfigured"
+
+	// PolicyStateConditionFrozen is set to true while the cluster-wide rollout freeze
+	// holds this rollout at its current traffic split
+	PolicyStateConditionFrozen apis.ConditionType = "Frozen"
+
+	// PolicyStateConditionProgressing is set to false when the rollout fails to
+	// advance within the Policy's progress deadline
+	PolicyStateConditionProgressing apis.ConditionType = "Progressing"
+
+	// PolicyStateConditionRevisionDeployed is set to Unknown while the candidate
+	// Revision has been sitting in Ready=Unknown beyond the deploy grace period
+	// (image pull backoff, unschedulable pods, ...); it is cleared once the
+	// Revision finishes deploying
+	PolicyStateConditionRevisionDeployed apis.ConditionType = "RevisionDeployed"
+
+	// PolicyStateConditionOperational is set to false when a reconcile fails with
+	// a recognizable API error (missing RBAC, dangling Policy reference, webhook
+	// outage); the reason and message carry a remediation hint, so the failure is
+	// diagnosable from the PolicyState instead of only from controller logs
+	PolicyStateConditionOperational apis.ConditionType = "Operational"
 )
 
+// PolicyStateFormatVersion is the version of the PolicyState spec semantics
+// contract shared by the controller (writer) and the webhook (reader)
+// bump it whenever the meaning of the spec changes in a way an older reader
+// would misinterpret, so that rolling upgrades degrade to holding the Route
+// at its current split instead of corrupting it
+const PolicyStateFormatVersion = 1
+
 // PolicyStateSpec holds the desired routing spec computed by reconciler
 // Should be set by reconciler, and set by webhook to write Route appropriately
 type PolicyStateSpec struct {
+	// FormatVersion records which version of the spec semantics contract this
+	// spec was written under; readers must not interpret a spec written under
+	// a newer contract than they understand
+	// 0 is read as version 1, for specs written before this field existed
+	// +optional
+	FormatVersion int `json:"formatVersion,omitempty"`
+
 	// Traffic specifies how to distribute traffic over a collection of
 	// revisions and configurations.
 	Traffic []v1.TrafficTarget `json:"traffic,omitempty"`
+
+	// PinnedPolicy is the snapshot of the winning Policy's spec taken when the
+	// current rollout started; it is only populated for Policies with the
+	// "Pinned" update strategy, and the rollout follows it until it stabilizes
+	// +optional
+	PinnedPolicy *PolicySpec `json:"pinnedPolicy,omitempty"`
+
+	// AdoptedPolicy is the snapshot of the winning Policy's spec this
+	// Configuration currently follows; it is only populated for Policies with a
+	// self-canary window, and holds the pre-edit spec until this Configuration's
+	// turn in the adoption window comes
+	// +optional
+	AdoptedPolicy *PolicySpec `json:"adoptedPolicy,omitempty"`
+
+	// AdoptedGeneration is the Policy metadata.generation that AdoptedPolicy
+	// was snapshotted from
+	// +optional
+	AdoptedGeneration int64 `json:"adoptedGeneration,omitempty"`
+}
+
+// CompatibleWith reports whether a reader that understands contract versions
+// up to maxVersion can safely interpret this spec
+func (pss *PolicyStateSpec) CompatibleWith(maxVersion int) bool {
+	version := pss.FormatVersion
+	if version == 0 {
+		version = 1
+	}
+	return version <= maxVersion
 }
 
 // PolicyStateStatusFields holds the fields of PolicyState's status that
@@ -72,9 +138,201 @@ type PolicyStateStatusFields struct {
 	// optional because when a rollout is completed there is no more future updates to be done
 	NextUpdateTimestamp *metav1.Time `json:"nextUpdateTimestamp,omitempty"`
 
-	// Traffic describes the current routing spec that the webhook has enforced
-	// If this doesn't agree with Spec.Traffic, then the webhook SetDefaults must set them to agree with each other
+	// Traffic describes the current routing spec that has been enforced
+	// the reconciler mirrors Spec.Traffic here when it applies a traffic change
 	Traffic []v1.TrafficTarget `json:"traffic,omitempty"`
+
+	// FreezeStartTime records when the reconciler first observed the global rollout freeze
+	// it is cleared when the freeze is lifted, after folding the frozen duration into PauseOffsetSeconds
+	// +optional
+	FreezeStartTime *metav1.Time `json:"freezeStartTime,omitempty"`
+
+	// PauseOffsetSeconds accumulates the total time this rollout has spent frozen
+	// the reconciler subtracts it from elapsed time so that unfreezing resumes the
+	// rollout with the remaining thresholds intact instead of skipping ahead
+	// +optional
+	PauseOffsetSeconds int `json:"pauseOffsetSeconds,omitempty"`
+
+	// RollbackStartTime records when a gradual rollback started walking traffic
+	// back to the stable Revision; the rollback stages are measured from it
+	// +optional
+	RollbackStartTime *metav1.Time `json:"rollbackStartTime,omitempty"`
+
+	// Phase is the rollout's position in the delivery state machine, one of
+	// "Pending", "Progressing", "Paused", "Stalled", "RollingBack",
+	// "Completed", "Aborted"; it is maintained exclusively through
+	// TransitionPhase so that only defined transitions occur
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// PendingReason explains why a Pending rollout is not receiving traffic
+	// yet, such as queueing behind the cluster's concurrency budget; it is
+	// empty once the rollout is admitted
+	// +optional
+	PendingReason string `json:"pendingReason,omitempty"`
+
+	// RolloutPriority is the priority of the Policy driving the current
+	// rollout, recorded here so that rollouts contending for the concurrency
+	// budget can be ranked without resolving each other's Policies
+	// +optional
+	RolloutPriority int `json:"rolloutPriority,omitempty"`
+
+	// SlicePhase records whether a time-sliced stage is currently serving the
+	// candidate ("on") or parking it at 0% ("off"); it is empty outside of
+	// time-sliced stages
+	// +optional
+	SlicePhase string `json:"slicePhase,omitempty"`
+
+	// CandidateURL is the tagged preview URL of the candidate Revision, so that it
+	// can be verified directly while it still serves only a sliver of mainstream traffic
+	// +optional
+	CandidateURL string `json:"candidateURL,omitempty"`
+
+	// PolicySource records which source won the policy resolution for this rollout,
+	// one of "annotation", "label-selector", "namespace-default", "cluster-default"
+	// +optional
+	PolicySource string `json:"policySource,omitempty"`
+
+	// PolicyName is the namespace/name of the winning Policy, so that conflicting
+	// policy sources are debuggable from the PolicyState alone
+	// +optional
+	PolicyName string `json:"policyName,omitempty"`
+
+	// TriggeredBy records the user or service account that deployed the candidate
+	// Revision, taken from the creator/lastModifier annotations stamped by the
+	// Serving webhook; notifications and audits use it to attribute the rollout
+	// +optional
+	TriggeredBy string `json:"triggeredBy,omitempty"`
+
+	// AssignmentSeed is the hashing seed for this rollout, derived from the rollout
+	// identity and the Policy's AssignmentSalt; user-bucketing rollout modes use it
+	// to assign requests to the candidate, and recording it here lets experiment
+	// platforms reproduce exactly which buckets saw the canary
+	// +optional
+	AssignmentSeed string `json:"assignmentSeed,omitempty"`
+
+	// MeasuredRevisionName names the Revision the SLI fields below refer to
+	// the fields are reset whenever a newer Revision enters the rollout
+	// +optional
+	MeasuredRevisionName string `json:"measuredRevisionName,omitempty"`
+
+	// FirstTrafficTime records when the candidate Revision first received mainstream traffic
+	// +optional
+	FirstTrafficTime *metav1.Time `json:"firstTrafficTime,omitempty"`
+
+	// FullTrafficTime records when the candidate Revision reached 100% of traffic
+	// +optional
+	FullTrafficTime *metav1.Time `json:"fullTrafficTime,omitempty"`
+
+	// TimeToFirstTrafficSeconds measures the delivery latency from the candidate
+	// Revision becoming Ready until it first received traffic
+	// +optional
+	TimeToFirstTrafficSeconds int `json:"timeToFirstTrafficSeconds,omitempty"`
+
+	// TimeToFullTrafficSeconds measures the delivery latency from the candidate
+	// Revision becoming Ready until it owned 100% of traffic
+	// +optional
+	TimeToFullTrafficSeconds int `json:"timeToFullTrafficSeconds,omitempty"`
+
+	// CompareStagePercent is the stage the compare-gate counters below belong
+	// to; entering a different stage resets them
+	// +optional
+	CompareStagePercent int `json:"compareStagePercent,omitempty"`
+
+	// ComparePassStreak counts consecutive passing compare evaluations at the
+	// current stage
+	// +optional
+	ComparePassStreak int `json:"comparePassStreak,omitempty"`
+
+	// CompareFailures counts compare evaluations below the marginal mark at
+	// the current stage
+	// +optional
+	CompareFailures int `json:"compareFailures,omitempty"`
+
+	// HookFailurePercent is the stage the hook failure counter below belongs
+	// to; entering a different stage resets it
+	// +optional
+	HookFailurePercent int `json:"hookFailurePercent,omitempty"`
+
+	// HookFailures counts failed verification hook runs at the current stage,
+	// including ones that were retried under the stage's failure limit
+	// +optional
+	HookFailures int `json:"hookFailures,omitempty"`
+
+	// StageSnapshots records one entry per stage the candidate Revision passed
+	// through, so failed promotions and rollbacks can be audited after the fact;
+	// only the most recent snapshots are retained to bound the object size
+	// +optional
+	StageSnapshots []StageSnapshot `json:"stageSnapshots,omitempty"`
+
+	// CompletionReport summarizes the rollout once the candidate reaches 100%
+	// of traffic; it survives until the next rollout starts, so the last
+	// delivery can be inspected after its working state has been cleared
+	// +optional
+	CompletionReport *RolloutReport `json:"completionReport,omitempty"`
+}
+
+// StageSnapshot captures what the reconciler knew about the candidate Revision
+// when it entered a stage; the metric fields stay zero until metric-based
+// rollout modes land and start evaluating real traffic
+type StageSnapshot struct {
+	// Percent is the candidate's traffic share at this stage
+	Percent int `json:"percent"`
+
+	// EvaluatedAt records when the reconciler moved the candidate to this stage
+	// +optional
+	EvaluatedAt *metav1.Time `json:"evaluatedAt,omitempty"`
+
+	// RequestCount is the number of requests the candidate served during the
+	// previous stage
+	// +optional
+	RequestCount int64 `json:"requestCount,omitempty"`
+
+	// ErrorCount is the number of those requests that failed
+	// +optional
+	ErrorCount int64 `json:"errorCount,omitempty"`
+
+	// LatencyP50Millis is the candidate's median response latency in
+	// milliseconds during the previous stage
+	// +optional
+	LatencyP50Millis int64 `json:"latencyP50Millis,omitempty"`
+
+	// LatencyP99Millis is the candidate's 99th percentile response latency in
+	// milliseconds during the previous stage
+	// +optional
+	LatencyP99Millis int64 `json:"latencyP99Millis,omitempty"`
+}
+
+// RolloutReport is the summary written when a rollout completes: where traffic
+// came from and went, how long it took, and how eventful the ride was
+type RolloutReport struct {
+	// FromRevisionName is the stable Revision traffic moved away from
+	// +optional
+	FromRevisionName string `json:"fromRevisionName,omitempty"`
+
+	// ToRevisionName is the Revision the rollout delivered
+	ToRevisionName string `json:"toRevisionName"`
+
+	// DurationSeconds is the total time from the Revision becoming ready until
+	// it owned 100% of traffic, pauses included
+	DurationSeconds int `json:"durationSeconds"`
+
+	// Stages is how many distinct traffic stages the candidate passed through
+	Stages int `json:"stages"`
+
+	// PausedSeconds is the total time the rollout spent paused by freezes or
+	// holding gates
+	// +optional
+	PausedSeconds int `json:"pausedSeconds,omitempty"`
+
+	// Rollbacks counts the stage transitions that moved traffic backwards
+	// before the rollout eventually completed
+	// +optional
+	Rollbacks int `json:"rollbacks,omitempty"`
+
+	// CompletedAt records when the candidate reached 100% of traffic
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
 }
 
 // PolicyStateStatus communicates the observed state of the PolicyState
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"knative.dev/pkg/apis"
+)
+
+// Validate implements apis.Validatable
+// PolicyState specs are normally written only by the reconciler, but they can be
+// hand-edited (or corrupted), and the defaulting webhook copies Spec.Traffic into
+// the Route verbatim; these checks keep a broken split from reaching the Route
+func (ps *PolicyState) Validate(ctx context.Context) *apis.FieldError {
+	var err *apis.FieldError
+	// an empty spec means the rollout hasn't been computed yet, which is fine
+	if len(ps.Spec.Traffic) == 0 {
+		return nil
+	}
+	total := int64(0)
+	seen := make(map[string]bool)
+	for i, t := range ps.Spec.Traffic {
+		if t.RevisionName == "" && t.ConfigurationName == "" {
+			err = err.Also(apis.ErrMissingOneOf("revisionName", "configurationName").ViaFieldIndex("spec.traffic", i))
+			continue
+		}
+		if t.RevisionName != "" && t.ConfigurationName != "" {
+			err = err.Also(apis.ErrMultipleOneOf("revisionName", "configurationName").ViaFieldIndex("spec.traffic", i))
+			continue
+		}
+		// a configurationName target is only meaningful when it tracks the latest Revision
+		if t.ConfigurationName != "" && (t.LatestRevision == nil || !*t.LatestRevision) {
+			err = err.Also(apis.ErrGeneric("A configurationName target must set latestRevision to true", "latestRevision").ViaFieldIndex("spec.traffic", i))
+		}
+		name := t.RevisionName
+		if name == "" {
+			name = t.ConfigurationName
+		}
+		if seen[name] {
+			err = err.Also(apis.ErrGeneric(fmt.Sprintf("Traffic target %q is listed more than once", name), "spec.traffic"))
+		}
+		seen[name] = true
+		if t.Percent == nil {
+			err = err.Also(apis.ErrMissingField("percent").ViaFieldIndex("spec.traffic", i))
+			continue
+		}
+		if *t.Percent < 0 || *t.Percent > 100 {
+			err = err.Also(apis.ErrOutOfBoundsValue(*t.Percent, 0, 100, "percent").ViaFieldIndex("spec.traffic", i))
+			continue
+		}
+		total += *t.Percent
+	}
+	if err == nil && total != 100 {
+		err = err.Also(apis.ErrGeneric(fmt.Sprintf("Traffic percentages must sum to 100, got %d", total), "spec.traffic"))
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestPolicyStateValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		ps   *PolicyState
+		want *apis.FieldError
+	}{{
+		name: "empty spec is ok",
+		ps:   policyStateWithTraffic(),
+		want: nil,
+	}, {
+		name: "well-formed split is ok",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(90)},
+			v1.TrafficTarget{RevisionName: "R2", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(10)},
+		),
+		want: nil,
+	}, {
+		name: "latest revision target is ok",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{ConfigurationName: "test", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)},
+		),
+		want: nil,
+	}, {
+		name: "percentages must sum to 100",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(90)},
+			v1.TrafficTarget{RevisionName: "R2", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(20)},
+		),
+		want: apis.ErrGeneric("Traffic percentages must sum to 100, got 110", "spec.traffic"),
+	}, {
+		name: "duplicate targets are rejected",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(50)},
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(50)},
+		),
+		want: apis.ErrGeneric(fmt.Sprintf("Traffic target %q is listed more than once", "R1"), "spec.traffic"),
+	}, {
+		name: "targets must name a Revision or a Configuration",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{LatestRevision: ptr.Bool(false), Percent: ptr.Int64(100)},
+		),
+		want: apis.ErrMissingOneOf("revisionName", "configurationName").ViaFieldIndex("spec.traffic", 0),
+	}, {
+		name: "targets must not name both a Revision and a Configuration",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", ConfigurationName: "test", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)},
+		),
+		want: apis.ErrMultipleOneOf("revisionName", "configurationName").ViaFieldIndex("spec.traffic", 0),
+	}, {
+		name: "percent is mandatory",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false)},
+		),
+		want: apis.ErrMissingField("percent").ViaFieldIndex("spec.traffic", 0),
+	}, {
+		name: "percent must be within bounds",
+		ps: policyStateWithTraffic(
+			v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(101)},
+		),
+		want: apis.ErrOutOfBoundsValue(101, 0, 100, "percent").ViaFieldIndex("spec.traffic", 0),
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.ps.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Errorf("wrong validation result (-want, +got) %v", diff)
+			}
+		})
+	}
+}
+
+func policyStateWithTraffic(traffic ...v1.TrafficTarget) *PolicyState {
+	return &PolicyState{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "test",
+		},
+		Spec: PolicyStateSpec{Traffic: traffic},
+	}
+}
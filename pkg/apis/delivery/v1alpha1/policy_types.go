@@ -15,6 +15,7 @@
 package v1alpha1
 
 import (
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
 
@@ -63,6 +64,21 @@ type PolicySpec struct {
 	// The interpretation of DefaultThreshold depends on the value of Mode
 	DefaultThreshold int `json:"defaultThreshold"`
 
+	// ThresholdUnit names the unit every threshold value in this Policy is
+	// expressed in for time-based policies: "seconds" (the default when empty),
+	// "minutes" or "hours"; spelling the unit out avoids the classic 60x
+	// mistake of writing minutes into a bare-seconds field
+	// +optional
+	ThresholdUnit string `json:"thresholdUnit,omitempty"`
+
+	// JitterPercent spreads stage transitions across rollouts that share this
+	// Policy: each rollout's counted time is stretched or shrunk by a
+	// deterministic per-Revision factor within plus or minus this percentage,
+	// so hundreds of Configurations do not hit a stage boundary in the same
+	// instant and hammer the API server and ingress
+	// +optional
+	JitterPercent *int `json:"jitterPercent,omitempty"`
+
 	// Stages specifies the traffic percentages that the NEW Revision is expected to have
 	// at successive rollout stages; the list of integers must start at 0
 	// all entries must be in the range [0, 100), and must be sorted in increasing order
@@ -71,7 +87,336 @@ type PolicySpec struct {
 	// In addition to the traffic percentages, each stage can OPTIONALLY specify its own threshold
 	// this gives greater flexibility to policy design
 	// The threshold value for stage N is the value that must be achieved BEFORE moving to stage N+1
+	// A leading 0-percent stage with an explicit threshold is a dark launch: the candidate
+	// soaks at 0% mainstream traffic (reachable via its tag URL) for that long
 	Stages []Stage `json:"stages,omitempty"`
+
+	// Generator is an optional shorthand for Stages; when Stages is empty, the defaulting
+	// webhook expands Generator into a concrete list of Stages, so that simple ramps don't
+	// have to be written out by hand
+	// +optional
+	Generator *Generator `json:"generator,omitempty"`
+
+	// Windows restricts WHEN stage promotions are allowed to happen; outside of all
+	// windows the rollout holds its current traffic split and resumes when a window opens
+	// An empty list means promotions may happen at any time
+	// +optional
+	Windows []TimeWindow `json:"windows,omitempty"`
+
+	// ProgressDeadlineSeconds bounds how long a rollout may sit unable to advance
+	// (unhealthy candidate, failing gates, ...) before it is declared failed
+	// This field is optional; if not specified, a stalled rollout waits indefinitely
+	// +optional
+	ProgressDeadlineSeconds *int `json:"progressDeadlineSeconds,omitempty"`
+
+	// RollbackOnFailure, when true, sends all traffic back to the stable Revision
+	// once the rollout is declared failed instead of just holding the current split
+	// +optional
+	RollbackOnFailure bool `json:"rollbackOnFailure,omitempty"`
+
+	// OnFailure makes the failure response explicit: "hold" keeps the current
+	// split and waits for an operator, "rollback" sends all traffic back to the
+	// stable Revision, "abort" ends the rollout at the current split
+	// This field is optional; if not specified, RollbackOnFailure decides between
+	// "rollback" and "abort"; Configurations can override it with the
+	// delivery.knative.dev/on-failure annotation
+	// +optional
+	OnFailure string `json:"onFailure,omitempty"`
+
+	// GradualRollback, when true, returns traffic to the stable Revision by
+	// walking the forward Stages in reverse instead of snapping back to 100%
+	// at once; the stable Revision may have scaled down during the rollout,
+	// and an instant snapback would cold-start it under the full load
+	// +optional
+	GradualRollback bool `json:"gradualRollback,omitempty"`
+
+	// RollbackStages spells out the descending traffic percentages a rollback
+	// walks through, with the same threshold semantics as Stages; the final
+	// 0% for the candidate is implicit and should NOT be listed
+	// Specifying RollbackStages implies GradualRollback
+	// This field is optional; if not specified, GradualRollback mirrors Stages
+	// +optional
+	RollbackStages []Stage `json:"rollbackStages,omitempty"`
+
+	// VerifyDependencies, when true, checks before each traffic increase that the
+	// ConfigMaps and Secrets referenced by the candidate Revision still exist, so
+	// that a Revision whose dependencies were deleted after it was created does
+	// not get promoted any further
+	// +optional
+	VerifyDependencies bool `json:"verifyDependencies,omitempty"`
+
+	// MaxUnavailablePercent caps the combined traffic share that Revisions not
+	// fully Ready may hold in any single Route update; if the candidate degrades
+	// mid-transition its share is clamped down to the cap, with the difference
+	// going back to the stable Revision
+	// This field is optional; if not specified, unready Revisions keep whatever
+	// share the stage arithmetic assigns them
+	// +optional
+	MaxUnavailablePercent *int `json:"maxUnavailablePercent,omitempty"`
+
+	// ReplicaParityPercent requires the candidate Revision to have at least this
+	// percent of the stable Revision's ready pod count before it receives its
+	// first non-zero traffic percent, so a single canary pod is not immediately
+	// handed a traffic share that took the stable Revision many pods to serve
+	// This field is optional; if not specified, first traffic is not gated on pod counts
+	// +optional
+	ReplicaParityPercent *int `json:"replicaParityPercent,omitempty"`
+
+	// MaxActiveRevisions caps how many Revisions may hold traffic at once; Route
+	// percentages are whole numbers, so beyond 100 live Revisions older ones
+	// would silently be squeezed to zero anyway
+	// When a new rollout would exceed the cap, the oldest mid-rollout Revisions
+	// are evicted deterministically and their share returns to the stable
+	// Revision
+	// This field is optional; if not specified, the pool is unbounded; must be
+	// at least 2 (stable plus candidate) when set
+	// +optional
+	MaxActiveRevisions *int `json:"maxActiveRevisions,omitempty"`
+
+	// MinStablePercent guarantees the stable (oldest) Revision at least this
+	// percent of traffic until the candidate reaches the final stage, so many
+	// concurrent candidates cannot collectively starve the stable Revision
+	// below a safe serving capacity; a candidate entering its final stage is
+	// exempt, otherwise no rollout could ever complete
+	// This field is optional; if not specified, the stable Revision holds only
+	// whatever the stage arithmetic leaves over
+	// +optional
+	MinStablePercent *int `json:"minStablePercent,omitempty"`
+
+	// CandidateMinScale keeps the candidate Revision warm during the rollout by
+	// pinning autoscaling.knative.dev/minScale to this value; at 1% traffic a
+	// candidate can otherwise scale to zero and add cold-start latency exactly
+	// while it is being judged
+	// The pin is removed once the rollout ends; a minScale annotation the user
+	// set themselves is never touched
+	// This field is optional; if not specified, the candidate scales as usual
+	// +optional
+	CandidateMinScale *int `json:"candidateMinScale,omitempty"`
+
+	// TransferMinScale, when true, scales the minScale annotations that the
+	// stable and candidate Revisions declare in proportion to their traffic
+	// shares while the rollout runs, so reserved capacity follows the traffic
+	// split instead of double-provisioning both Revisions in full; the declared
+	// values are restored when the rollout ends
+	// +optional
+	TransferMinScale bool `json:"transferMinScale,omitempty"`
+
+	// Experiment runs the two newest candidate Revisions as an A/B experiment:
+	// each candidate progresses on its own track with its own stage table and
+	// traffic tag, instead of both climbing the shared Stages ladder
+	// A track holds its final percentage until a winner is named through the
+	// delivery.knative.dev/winner annotation on the Configuration; an arm whose
+	// Revision turns unhealthy is eliminated and stops receiving traffic
+	// This field is optional; if not specified, all candidates share Stages
+	// +optional
+	Experiment *ExperimentConfig `json:"experiment,omitempty"`
+
+	// SLO gates promotions on an error budget instead of raw error-rate
+	// thresholds: the reconciler computes the candidate's budget burn rate over
+	// the window and fails the rollout (honoring OnFailure) when the burn
+	// exceeds the allowed multiple
+	// The burn rate is computed from the request and error counts recorded in
+	// the PolicyState's stage snapshots, so until metric-based modes start
+	// filling those in, the gate passes for lack of data
+	// +optional
+	SLO *SLOConfig `json:"slo,omitempty"`
+
+	// MetricProvider selects and configures the metrics backend that supplies
+	// per-Revision samples to the compare gates; different Policies may point
+	// at different backends
+	// This field is optional; if not specified, the compare gates have no data
+	// source and pass
+	// +optional
+	MetricProvider *MetricProviderConfig `json:"metricProvider,omitempty"`
+
+	// SpawnBaseline deploys a fresh copy of the stable Revision (the
+	// "baseline") for the duration of the rollout, and compare gates judge the
+	// candidate against the baseline instead of the long-warmed stable
+	// instances; this removes the warm-cache advantage the stable Revision
+	// would otherwise have in the comparison
+	// The baseline Configuration is deleted when the rollout ends
+	// +optional
+	SpawnBaseline bool `json:"spawnBaseline,omitempty"`
+
+	// AssignmentSalt is mixed into the hashing seed that user-bucketing rollout
+	// modes will use to assign requests to the candidate; the derived seed is
+	// recorded in the PolicyState status so experiment platforms can reproduce
+	// exactly which buckets saw the canary
+	// This field is optional; if not specified, the seed is derived from the
+	// rollout identity alone
+	// +optional
+	AssignmentSalt string `json:"assignmentSalt,omitempty"`
+
+	// Selector makes this Policy apply to every Configuration in its namespace
+	// whose labels match, without requiring a per-Configuration annotation
+	// Labels set on a Knative Service propagate to its Configuration, so a
+	// selector binds matching Services the same way
+	// An annotation-selected Policy always takes precedence over selector ones
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Priority resolves conflicts between selector Policies that match the same
+	// Configuration: the highest Priority wins, and ties break to the Policy with
+	// the alphabetically first name
+	// Priority only ranks Policies within the same source; the sources themselves
+	// are ranked annotation > selector > namespace default > cluster default
+	// It also ranks rollouts contending for the cluster's concurrency budget:
+	// a freed slot goes to the waiting rollout with the highest Priority, and
+	// equal priorities are admitted in arrival order
+	// +optional
+	Priority int `json:"priority,omitempty"`
+
+	// UpdateStrategy controls what happens to in-flight rollouts when this Policy
+	// is edited: "Live" (the default) re-evaluates them against the edited spec
+	// immediately, while "Pinned" lets an in-flight rollout finish with the spec
+	// it started with and only applies the edit to new rollouts
+	// +optional
+	UpdateStrategy string `json:"updateStrategy,omitempty"`
+
+	// SelfCanarySeconds staggers edits of this Policy across the Configurations
+	// bound to it: each Configuration adopts the edited spec at a deterministic
+	// point inside this window instead of all at once, so a bad edit changes a
+	// few rollouts before it changes every one; without it edits apply everywhere
+	// immediately
+	// +optional
+	SelfCanarySeconds *int `json:"selfCanarySeconds,omitempty"`
+
+	// Notifications configures which rollout events this Policy announces and
+	// where each severity is delivered; without it no notifications are sent
+	// +optional
+	Notifications *NotificationConfig `json:"notifications,omitempty"`
+}
+
+// NotificationConfig controls which rollout events produce notifications and
+// where each severity is delivered
+type NotificationConfig struct {
+	// Scope selects which rollout events notify
+	// Possible values are: "All" (the default; every stage transition plus
+	// completions and failures) and "EndsOnly" (only completions and failures),
+	// which keeps teams with frequent deploys from drowning in stage chatter
+	// +optional
+	Scope string `json:"scope,omitempty"`
+
+	// InfoURL receives informational notifications (stage transitions and
+	// rollout completions) as JSON POSTs
+	// +optional
+	InfoURL string `json:"infoURL,omitempty"`
+
+	// PagingURL receives failure notifications that warrant waking a human
+	// This field is optional; if not specified, failures fall back to InfoURL
+	// +optional
+	PagingURL string `json:"pagingURL,omitempty"`
+}
+
+const (
+	// NotifyScopeAll announces every stage transition, completion, and failure
+	NotifyScopeAll = "All"
+
+	// NotifyScopeEndsOnly suppresses stage transitions and only announces
+	// completions and failures
+	NotifyScopeEndsOnly = "EndsOnly"
+)
+
+const (
+	// UpdateStrategyLive makes in-flight rollouts follow Policy edits immediately
+	UpdateStrategyLive = "Live"
+
+	// UpdateStrategyPinned lets in-flight rollouts finish with the Policy spec
+	// they started with, snapshotted into the PolicyState
+	UpdateStrategyPinned = "Pinned"
+)
+
+const (
+	// FailureHold keeps the current traffic split when the rollout fails and
+	// waits for an operator (or a recovered candidate) instead of moving traffic
+	FailureHold = "hold"
+
+	// FailureRollback sends all traffic back to the stable Revision when the
+	// rollout fails
+	FailureRollback = "rollback"
+
+	// FailureAbort ends the rollout terminally at its current traffic split
+	FailureAbort = "abort"
+)
+
+// ValidFailureResponse reports whether v is a recognized onFailure value
+func ValidFailureResponse(v string) bool {
+	return v == FailureHold || v == FailureRollback || v == FailureAbort
+}
+
+// SupportedMode reports whether this controller build can drive rollouts under
+// the given Policy mode; "request" and "error" stay unsupported until their
+// metric providers are wired in
+func SupportedMode(mode string) bool {
+	return mode == "time"
+}
+
+const (
+	// ThresholdUnitSeconds interprets threshold values as seconds, the
+	// historical default
+	ThresholdUnitSeconds = "seconds"
+
+	// ThresholdUnitMinutes interprets threshold values as minutes
+	ThresholdUnitMinutes = "minutes"
+
+	// ThresholdUnitHours interprets threshold values as hours
+	ThresholdUnitHours = "hours"
+)
+
+// ThresholdUnitFactor returns how many seconds one unit of this spec's
+// threshold values is worth; an empty unit means bare seconds, and an
+// unrecognized one (left for validation to reject) counts as seconds too
+func (ps *PolicySpec) ThresholdUnitFactor() int {
+	switch ps.ThresholdUnit {
+	case ThresholdUnitMinutes:
+		return 60
+	case ThresholdUnitHours:
+		return 3600
+	default:
+		return 1
+	}
+}
+
+// TimeWindow describes a recurring window of time during which rollout stages may advance
+type TimeWindow struct {
+	// Days lists abbreviated days of the week ("Sun" through "Sat") that the window covers
+	// This field is optional; if not specified, the window applies to every day
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// Start is the inclusive beginning of the window in 24-hour "HH:MM" form
+	Start string `json:"start"`
+
+	// End is the exclusive end of the window in 24-hour "HH:MM" form; must be after Start
+	End string `json:"end"`
+
+	// TimeZone is the IANA time zone name used to interpret Start and End (e.g. "America/New_York")
+	// This field is optional; if not specified, times are interpreted as UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Generator describes a rule for mechanically producing the Stages of a Policy
+type Generator struct {
+	// Type selects the shape of the generated rollout curve
+	// Possible values are: "linear", "exponential", "bluegreen"
+	// "bluegreen" generates a single dark-launch soak: the candidate receives
+	// no mainstream traffic (it stays reachable through its tag URL) for the
+	// Interval, then the Route flips to 100% at once; hook or analysis gates
+	// for manual approval can be added by spelling the 0-stage out instead
+	Type string `json:"type"`
+
+	// Step is the percent increment between successive stages for the "linear" type;
+	// for the "exponential" type it is the percent of the first non-zero stage, which
+	// then doubles at every subsequent stage
+	// The "bluegreen" type has no percentage ramp and ignores Step
+	// +optional
+	Step int `json:"step,omitempty"`
+
+	// Interval is the amount of time spent in each generated stage (e.g. "2m")
+	// This field is optional; if not specified, generated stages fall back to DefaultThreshold
+	// +optional
+	Interval string `json:"interval,omitempty"`
 }
 
 // Stage specifies a single rollout stage
@@ -82,11 +427,226 @@ type Stage struct {
 	// Threshold tells the condition for progressing to the next rollout stage
 	// This field is optional; if not specified, then the threshold value defaults to PolicySpec.DefaultThreshold
 	Threshold *int `json:"threshold,omitempty"`
+
+	// After is a readable alternative to Threshold for time-based policies: the
+	// stage holds for this duration (e.g. "5m") before the next one takes over,
+	// independent of the spec's threshold unit
+	// This field is optional and mutually exclusive with Threshold
+	// +optional
+	After *metav1.Duration `json:"after,omitempty"`
+
+	// SlicePeriodSeconds alternates the candidate between this stage's percent
+	// and 0% on the given cadence while the stage holds; interleaving canary
+	// and control time slices improves statistical comparability for
+	// low-traffic services
+	// Experimental
+	// +optional
+	SlicePeriodSeconds *int `json:"slicePeriodSeconds,omitempty"`
+
+	// MirrorPercent asks the ingress layer to duplicate that percentage of live
+	// requests to the candidate Revision while this stage holds; the mirrored
+	// responses are discarded, so the candidate sees production load without
+	// serving any of it
+	// This requires the "mirroring" feature gate and an ingress layer that
+	// supports request mirroring (e.g. Istio)
+	// +optional
+	MirrorPercent *int `json:"mirrorPercent,omitempty"`
+
+	// HeaderRouting routes requests carrying a matching header or cookie to the
+	// candidate Revision while this stage holds, independent of the stage's
+	// percentage; this enables employee-only or opt-in canaries before any
+	// percentage ramp begins
+	// This requires an ingress layer that supports header routing
+	// +optional
+	HeaderRouting *HeaderRouting `json:"headerRouting,omitempty"`
+
+	// Hooks lists verification Jobs (smoke tests, integration tests, load tests, ...)
+	// that must all complete successfully before traffic may advance past this stage
+	// This field is optional; a stage without hooks advances on its threshold alone
+	// +optional
+	Hooks []StageHook `json:"hooks,omitempty"`
+
+	// Analysis optionally points at an external HTTP endpoint (Kayenta, a custom ML
+	// check, a ticketing system, ...) that must approve promotion out of this stage
+	// +optional
+	Analysis *AnalysisGate `json:"analysis,omitempty"`
+
+	// Compare judges the candidate by scoring it against the stable Revision
+	// over the same window instead of absolute thresholds, which break when
+	// overall traffic is bursty; promotion out of this stage requires the
+	// canary score to reach the gate's pass mark
+	// +optional
+	Compare *CompareGate `json:"compare,omitempty"`
+
+	// FailureLimit lets this stage's verification hooks fail and be retried up
+	// to the given number of times before the rollout is declared failed; a
+	// failed hook Job is deleted so the next evaluation runs it again
+	// This field is optional; without it the first hook failure is terminal
+	// +optional
+	FailureLimit *int `json:"failureLimit,omitempty"`
+}
+
+// ExperimentConfig describes an A/B experiment between two candidate Revisions
+type ExperimentConfig struct {
+	// Tracks are the independent ladders the candidate Revisions follow;
+	// exactly two must be listed, and the older candidate takes the first
+	// track so assignment stays deterministic across reconciles
+	Tracks []ExperimentTrack `json:"tracks"`
+}
+
+// ExperimentTrack is one arm of an experiment: a tag plus its own stage table
+type ExperimentTrack struct {
+	// Tag names the track; it is placed on the arm's traffic target, so each
+	// arm keeps a stable tagged URL for the experiment platform to probe
+	Tag string `json:"tag"`
+
+	// Stages is the track's stage table; percentages must be increasing and in
+	// [1, 99], and the two tracks' final percentages must leave the stable
+	// Revision at least 1% of traffic
+	// Unlike the Policy's Stages there is no implicit promotion to 100: the
+	// track holds its final percentage until a winner is chosen
+	Stages []Stage `json:"stages"`
+}
+
+// SLOConfig expresses the rollout gate as a service level objective: an
+// availability target over a rolling window, plus the budget burn multiple
+// above which the candidate is considered to be failing the objective
+// Availability is expressed in hundredths of a percent to keep the API free of
+// floating point values
+type SLOConfig struct {
+	// TargetAvailabilityPermyriad is the availability objective in hundredths
+	// of a percent (9990 means 99.90%); must be in the range [1, 9999]
+	TargetAvailabilityPermyriad int `json:"targetAvailabilityPermyriad"`
+
+	// WindowSeconds is the rolling window over which the error budget is
+	// computed; must be positive
+	WindowSeconds int `json:"windowSeconds"`
+
+	// MaxBurnRate is the multiple of the steady burn rate (spending exactly the
+	// budget over the window) above which promotions are blocked
+	// This field is optional; if not specified, any burn beyond the steady rate
+	// counts as a breach
+	// +optional
+	MaxBurnRate int `json:"maxBurnRate,omitempty"`
+}
+
+// HeaderRouting describes a request attribute that routes a request to the candidate
+// exactly one of Header or Cookie must be set
+type HeaderRouting struct {
+	// Header names the request header to match (e.g. "X-Canary")
+	// +optional
+	Header string `json:"header,omitempty"`
+
+	// Cookie names the cookie to match instead of a header
+	// +optional
+	Cookie string `json:"cookie,omitempty"`
+
+	// Value is the exact value that the header or cookie must carry
+	Value string `json:"value"`
+}
+
+// MetricProviderConfig points a Policy at the metrics backend its compare
+// gates sample from
+type MetricProviderConfig struct {
+	// Type selects the backend
+	// Possible values are: "prometheus" (PromQL over HTTP), "datadog" (the
+	// Datadog query API), and "otlp" (a store fed by OTLP that exposes the
+	// generic sample endpoint)
+	Type string `json:"type"`
+
+	// URL is the backend's query endpoint (e.g. "http://prometheus:9090",
+	// "https://api.datadoghq.com", or the generic store's sample URL)
+	URL string `json:"url"`
+
+	// APIKeySecret names a Secret in the workload's namespace holding the
+	// backend credentials under the "api-key" (and, for Datadog, "app-key")
+	// entries; backends that need no credential leave it empty
+	// +optional
+	APIKeySecret string `json:"apiKeySecret,omitempty"`
+}
+
+// CompareGate scores the candidate against the stable Revision over the same
+// window, Kayenta-style: error rates and latency distributions of the two
+// Revisions are compared and folded into a canary score from 0 (much worse
+// than stable) to 100 (no worse than stable)
+type CompareGate struct {
+	// WindowSeconds is how far back the compared metric samples reach; must be positive
+	WindowSeconds int `json:"windowSeconds"`
+
+	// PassScore is the minimum canary score that approves promotion out of the
+	// stage; must be in the range [1, 100]
+	PassScore int `json:"passScore"`
+
+	// MarginalScore fails the rollout outright when the score drops below it,
+	// while scores between MarginalScore and PassScore hold the stage and retry
+	// This field is optional; if not specified, any score below PassScore holds
+	// +optional
+	MarginalScore int `json:"marginalScore,omitempty"`
+
+	// IntervalSeconds is how long the gate waits between evaluations while it
+	// holds the stage
+	// This field is optional; if not specified, held evaluations retry on the
+	// analysis recheck cadence
+	// +optional
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// ConsecutivePasses is how many passing scores in a row the gate requires
+	// before the stage may advance; any non-passing score resets the streak
+	// This field is optional; if not specified, one passing score suffices
+	// +optional
+	ConsecutivePasses int `json:"consecutivePasses,omitempty"`
+
+	// AllowedFailures is how many scores below the marginal mark the stage
+	// tolerates before the rollout is failed, so a single noisy sample does not
+	// flap the rollout
+	// This field is optional; if not specified, the first such score fails
+	// +optional
+	AllowedFailures int `json:"allowedFailures,omitempty"`
+}
+
+// AnalysisGate points at an external HTTP endpoint that approves stage promotions
+type AnalysisGate struct {
+	// URL is the endpoint that receives a POST with the rollout context each time
+	// the stage wants to advance; the stage only advances on a 200 "pass" response
+	URL string `json:"url"`
+}
+
+// StageHook describes a single verification Job that gates promotion out of the
+// stage that declares it
+type StageHook struct {
+	// Name identifies the hook within its stage; it is folded into the name of the
+	// Job that the reconciler creates, so it must be a valid DNS label fragment
+	Name string `json:"name"`
+
+	// Template is the spec of the Job to run once the stage's threshold is reached
+	// the rollout holds at the current stage until the Job completes, and is declared
+	// failed if the Job fails
+	Template batchv1.JobSpec `json:"template"`
 }
 
 // PolicyStatusFields is the fields in PolicyStatus
-// This is empty for now because nothing is needed here
-type PolicyStatusFields struct{}
+type PolicyStatusFields struct {
+	// BoundConfigurations lists the Configurations ("namespace/name", sorted)
+	// that currently resolve to this Policy, so operators can judge the blast
+	// radius before editing a shared Policy
+	// +optional
+	BoundConfigurations []string `json:"boundConfigurations,omitempty"`
+
+	// ActiveRollouts is the number of bound Configurations whose rollout has
+	// not stabilized yet
+	// +optional
+	ActiveRollouts int `json:"activeRollouts,omitempty"`
+
+	// ObservedSpecGeneration is the newest metadata.generation whose spec edit
+	// the controller has observed
+	// +optional
+	ObservedSpecGeneration int64 `json:"observedSpecGeneration,omitempty"`
+
+	// SpecChangeTime is when that edit was first observed; self-canary adoption
+	// windows are measured from this instant
+	// +optional
+	SpecChangeTime *metav1.Time `json:"specChangeTime,omitempty"`
+}
 
 // PolicyStatus holds info about the current traffic behavior
 type PolicyStatus struct {
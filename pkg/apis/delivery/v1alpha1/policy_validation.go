@@ -16,8 +16,15 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
+	"net/url"
+	"time"
 
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
+	"knative.dev/pkg/kmp"
 	"knative.dev/pkg/logging"
 )
 
@@ -27,38 +34,411 @@ import (
 func (p *Policy) Validate(ctx context.Context) *apis.FieldError {
 	logging.FromContext(ctx).Infof("Validate called for %v", *p)
 	var err *apis.FieldError
+	// on update, Mode is immutable: an in-flight rollout's stage arithmetic was
+	// computed under one metric, and reinterpreting it under another would make
+	// the elapsed progress meaningless
+	if apis.IsInUpdate(ctx) {
+		if original, ok := apis.GetBaseline(ctx).(*Policy); ok && original != nil {
+			err = err.Also(p.checkImmutableFields(original))
+		}
+	}
 	// validate that the mode value must be "time" ("request" and "error" not supported for now)
-	if p.Spec.Mode != "time" {
+	if !SupportedMode(p.Spec.Mode) {
 		err = err.Also(apis.ErrInvalidValue(p.Spec.Mode, "spec.mode"))
 	}
+	// validate that the optional threshold unit is a known value
+	if u := p.Spec.ThresholdUnit; u != "" && u != ThresholdUnitSeconds && u != ThresholdUnitMinutes && u != ThresholdUnitHours {
+		err = err.Also(apis.ErrInvalidValue(u, "spec.thresholdUnit"))
+	}
+	// validate that the optional jitter is a sensible percentage; past 50% the
+	// shared schedule deforms more than it spreads
+	if p.Spec.JitterPercent != nil && (*p.Spec.JitterPercent < 1 || *p.Spec.JitterPercent > 50) {
+		err = err.Also(apis.ErrOutOfBoundsValue(*p.Spec.JitterPercent, 1, 50, "spec.jitterPercent"))
+	}
 	// validate that the defaultThreshold must be present and positive
 	if p.Spec.DefaultThreshold <= 0 {
 		err = err.Also(apis.ErrGeneric("DefaultThreshold value is mandatory and must be a positive integer", "spec.defaultThreshold"))
 	}
+	// validate the optional generator shorthand
+	if g := p.Spec.Generator; g != nil {
+		if g.Type != "linear" && g.Type != "exponential" && g.Type != "bluegreen" {
+			err = err.Also(apis.ErrInvalidValue(g.Type, "spec.generator.type"))
+		}
+		// blue/green has no percentage ramp, so Step does not apply to it
+		if g.Type != "bluegreen" && (g.Step < 1 || g.Step > 99) {
+			err = err.Also(apis.ErrOutOfBoundsValue(g.Step, 1, 99, "spec.generator.step"))
+		}
+		if g.Interval != "" {
+			if _, perr := time.ParseDuration(g.Interval); perr != nil {
+				err = err.Also(apis.ErrInvalidValue(g.Interval, "spec.generator.interval"))
+			}
+		}
+		if err != nil {
+			return err // defaulting couldn't have expanded a broken generator, so stage checks would only add noise
+		}
+	}
+	// reject traffic features that the detected networking layer cannot provide
+	if caps := networking.CapabilitiesFromContext(ctx); caps != nil {
+		for _, feature := range p.requestedCapabilities() {
+			if !caps.Supports(feature) {
+				err = err.Also(apis.ErrGeneric(fmt.Sprintf("The underlying networking layer does not support %q", feature), "spec"))
+			}
+		}
+	}
+	// validate that the optional unavailability cap is a sensible percentage
+	if p.Spec.MaxUnavailablePercent != nil && (*p.Spec.MaxUnavailablePercent < 0 || *p.Spec.MaxUnavailablePercent > 100) {
+		err = err.Also(apis.ErrOutOfBoundsValue(*p.Spec.MaxUnavailablePercent, 0, 100, "spec.maxUnavailablePercent"))
+	}
+	// validate that the optional replica parity requirement is a sensible percentage
+	if p.Spec.ReplicaParityPercent != nil && (*p.Spec.ReplicaParityPercent < 1 || *p.Spec.ReplicaParityPercent > 100) {
+		err = err.Also(apis.ErrOutOfBoundsValue(*p.Spec.ReplicaParityPercent, 1, 100, "spec.replicaParityPercent"))
+	}
+	// validate that the optional pool cap leaves room for stable plus candidate
+	if p.Spec.MaxActiveRevisions != nil && *p.Spec.MaxActiveRevisions < 2 {
+		err = err.Also(apis.ErrGeneric("MaxActiveRevisions must be at least 2 (the stable and candidate Revisions)", "spec.maxActiveRevisions"))
+	}
+	// validate that the optional stable traffic floor leaves room for candidates
+	if p.Spec.MinStablePercent != nil && (*p.Spec.MinStablePercent < 1 || *p.Spec.MinStablePercent > 99) {
+		err = err.Also(apis.ErrOutOfBoundsValue(*p.Spec.MinStablePercent, 1, 99, "spec.minStablePercent"))
+	}
+	// validate that the optional candidate scale floor is positive
+	if p.Spec.CandidateMinScale != nil && *p.Spec.CandidateMinScale < 1 {
+		err = err.Also(apis.ErrGeneric("CandidateMinScale must be a positive number of pods", "spec.candidateMinScale"))
+	}
+	// validate the optional notification configuration
+	if n := p.Spec.Notifications; n != nil {
+		if n.Scope != "" && n.Scope != NotifyScopeAll && n.Scope != NotifyScopeEndsOnly {
+			err = err.Also(apis.ErrInvalidValue(n.Scope, "spec.notifications.scope"))
+		}
+		if n.InfoURL == "" && n.PagingURL == "" {
+			err = err.Also(apis.ErrMissingOneOf("spec.notifications.infoURL", "spec.notifications.pagingURL"))
+		}
+		for field, value := range map[string]string{"spec.notifications.infoURL": n.InfoURL, "spec.notifications.pagingURL": n.PagingURL} {
+			if value == "" {
+				continue
+			}
+			if u, perr := url.ParseRequestURI(value); perr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				err = err.Also(apis.ErrInvalidValue(value, field))
+			}
+		}
+	}
+	// validate that the optional update strategy is a known value
+	if s := p.Spec.UpdateStrategy; s != "" && s != UpdateStrategyLive && s != UpdateStrategyPinned {
+		err = err.Also(apis.ErrInvalidValue(s, "spec.updateStrategy"))
+	}
+	// validate that the optional failure response is a known value
+	if f := p.Spec.OnFailure; f != "" && !ValidFailureResponse(f) {
+		err = err.Also(apis.ErrInvalidValue(f, "spec.onFailure"))
+	}
+	// validate that the optional self-canary window is positive
+	if p.Spec.SelfCanarySeconds != nil && *p.Spec.SelfCanarySeconds <= 0 {
+		err = err.Also(apis.ErrGeneric("Self-canary window must be a positive number of seconds", "spec.selfCanarySeconds"))
+	}
+	// validate that the optional label selector is well-formed
+	if p.Spec.Selector != nil {
+		if _, serr := metav1.LabelSelectorAsSelector(p.Spec.Selector); serr != nil {
+			err = err.Also(apis.ErrInvalidValue(p.Spec.Selector.String(), "spec.selector"))
+		}
+	}
+	// validate that the optional progress deadline is positive
+	if p.Spec.ProgressDeadlineSeconds != nil && *p.Spec.ProgressDeadlineSeconds <= 0 {
+		err = err.Also(apis.ErrGeneric("Progress deadline must be a positive number of seconds", "spec.progressDeadlineSeconds"))
+	}
+	// validate the optional promotion windows
+	for i, w := range p.Spec.Windows {
+		err = err.Also(w.validate().ViaFieldIndex("spec.windows", i))
+	}
+	// validate the optional experiment configuration
+	if e := p.Spec.Experiment; e != nil {
+		err = err.Also(e.validate())
+	}
+	// validate the optional metric provider
+	if m := p.Spec.MetricProvider; m != nil {
+		if m.Type != "prometheus" && m.Type != "datadog" && m.Type != "otlp" {
+			err = err.Also(apis.ErrInvalidValue(m.Type, "spec.metricProvider.type"))
+		}
+		if u, perr := url.ParseRequestURI(m.URL); perr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			err = err.Also(apis.ErrInvalidValue(m.URL, "spec.metricProvider.url"))
+		}
+		if m.Type == "datadog" && m.APIKeySecret == "" {
+			err = err.Also(apis.ErrMissingField("spec.metricProvider.apiKeySecret"))
+		}
+	}
+	// validate the optional SLO gate
+	if s := p.Spec.SLO; s != nil {
+		err = err.Also(s.validate())
+	}
+	// validate the optional rollback stages: same per-stage rules as Stages, but
+	// the percentages must descend since traffic is walking back to stable
+	prevDown := 100
+	for i, s := range p.Spec.RollbackStages {
+		if s.Percent >= prevDown {
+			err = err.Also(apis.ErrGeneric(
+				fmt.Sprintf("Rollback percentages must be in decreasing order, but %d >= %d", s.Percent, prevDown),
+				"percent").ViaFieldIndex("spec.rollbackStages", i))
+		}
+		err = err.Also(s.validate().ViaFieldIndex("spec.rollbackStages", i))
+		prevDown = s.Percent
+	}
 	// validate that there is at least 1 stage
 	if len(p.Spec.Stages) < 1 {
 		err = err.Also(apis.ErrGeneric("There must be at least one rollout stage in a Policy", "spec.stages"))
 		return err // no need for further checking
 	}
-	// validate all stages and check:
-	// (1) all percents are in increasing order
-	// (2) all percents are within range [0, 100)
-	// (3) the optional threshold, if specified, must be a positive integer
+	// validate all stages, reporting the exact stage index for every problem and
+	// collecting all of them instead of stopping at the first, so that a large
+	// policy can be fixed in one round trip
 	prev := 0
-	for _, s := range p.Spec.Stages {
+	for i, s := range p.Spec.Stages {
 		if s.Percent < prev {
-			err = err.Also(apis.ErrGeneric("Rollout percentages must be in increasing order", "spec.stages"))
-			break
+			err = err.Also(apis.ErrGeneric(
+				fmt.Sprintf("Rollout percentages must be in increasing order, but %d < %d", s.Percent, prev),
+				"percent").ViaFieldIndex("spec.stages", i))
 		}
-		if s.Percent < 0 || s.Percent >= 100 {
-			err = err.Also(apis.ErrOutOfBoundsValue(s.Percent, 0, 99, "spec.stages"))
-			break
+		err = err.Also(s.validate().ViaFieldIndex("spec.stages", i))
+		prev = s.Percent
+	}
+	// reject a planned rollout longer than the cluster maximum, so one
+	// fat-fingered threshold cannot schedule a months-long rollout
+	if cfg := config.FromContext(ctx); cfg != nil && cfg.Delivery != nil && cfg.Delivery.MaxRolloutDuration > 0 {
+		if planned := p.Spec.plannedDuration(); planned > cfg.Delivery.MaxRolloutDuration {
+			err = err.Also(apis.ErrGeneric(
+				fmt.Sprintf("The stages add up to %v of counted rollout time, more than the cluster maximum %v", planned, cfg.Delivery.MaxRolloutDuration),
+				"spec.stages"))
 		}
-		if s.Threshold != nil && *s.Threshold <= 0 {
-			err = err.Also(apis.ErrGeneric("Optional threshold value must be a positive integer", "spec.stages"))
-			break
+	}
+	return err
+}
+
+// plannedDuration sums the counted time the rollout's stages take before the
+// implicit 100% stage: the dark-launch soak when the first stage declares one,
+// then every stage's explicit duration or threshold (or the default) exactly
+// as the reconciler's stage arithmetic walks them
+func (ps *PolicySpec) plannedDuration() time.Duration {
+	factor := ps.ThresholdUnitFactor()
+	total := time.Duration(0)
+	for i, s := range ps.Stages {
+		if i == 0 && s.Percent == 0 && s.Threshold == nil && s.After == nil {
+			continue // a plain leading 0-stage has no soak
+		}
+		switch {
+		case s.After != nil:
+			total += s.After.Duration
+		case s.Threshold != nil:
+			total += time.Duration(*s.Threshold*factor) * time.Second
+		case i > 0:
+			total += time.Duration(ps.DefaultThreshold*factor) * time.Second
+		}
+	}
+	return total
+}
+
+// validate checks an experiment configuration: exactly two tracks with distinct
+// tags, each with an increasing stage table, and final percentages that leave
+// the stable Revision at least 1% of traffic
+func (e *ExperimentConfig) validate() *apis.FieldError {
+	var err *apis.FieldError
+	if len(e.Tracks) != 2 {
+		return apis.ErrGeneric("An experiment needs exactly two tracks", "spec.experiment.tracks")
+	}
+	finalSum := 0
+	tags := map[string]bool{}
+	for i, track := range e.Tracks {
+		if track.Tag == "" {
+			err = err.Also(apis.ErrMissingField("tag").ViaFieldIndex("spec.experiment.tracks", i))
+		} else if tags[track.Tag] {
+			err = err.Also(apis.ErrGeneric(
+				fmt.Sprintf("Track tags must be distinct, %q is reused", track.Tag),
+				"tag").ViaFieldIndex("spec.experiment.tracks", i))
+		}
+		tags[track.Tag] = true
+		if len(track.Stages) == 0 {
+			err = err.Also(apis.ErrMissingField("stages").ViaFieldIndex("spec.experiment.tracks", i))
+			continue
+		}
+		prev := 0
+		for j, s := range track.Stages {
+			if s.Percent <= prev {
+				err = err.Also(apis.ErrGeneric(
+					fmt.Sprintf("Track percentages must be positive and increasing, but %d <= %d", s.Percent, prev),
+					"percent").ViaFieldIndex("stages", j).ViaFieldIndex("spec.experiment.tracks", i))
+			}
+			err = err.Also(s.validate().ViaFieldIndex("stages", j).ViaFieldIndex("spec.experiment.tracks", i))
+			prev = s.Percent
+		}
+		finalSum += track.Stages[len(track.Stages)-1].Percent
+	}
+	if finalSum > 99 {
+		err = err.Also(apis.ErrGeneric(
+			fmt.Sprintf("Experiment tracks must leave the stable Revision at least 1%% of traffic, final stages sum to %d", finalSum),
+			"spec.experiment.tracks"))
+	}
+	return err
+}
+
+// validate checks an SLO gate: the availability target must leave a non-empty
+// error budget, the window must be positive, and the burn multiple non-negative
+func (s *SLOConfig) validate() *apis.FieldError {
+	var err *apis.FieldError
+	if s.TargetAvailabilityPermyriad < 1 || s.TargetAvailabilityPermyriad > 9999 {
+		err = err.Also(apis.ErrOutOfBoundsValue(s.TargetAvailabilityPermyriad, 1, 9999, "spec.slo.targetAvailabilityPermyriad"))
+	}
+	if s.WindowSeconds <= 0 {
+		err = err.Also(apis.ErrGeneric("SLO window must be a positive number of seconds", "spec.slo.windowSeconds"))
+	}
+	if s.MaxBurnRate < 0 {
+		err = err.Also(apis.ErrGeneric("SLO burn rate multiple cannot be negative", "spec.slo.maxBurnRate"))
+	}
+	return err
+}
+
+// validate checks a single Stage for well-formedness; all paths are relative to
+// the stage so that the caller can anchor them at the right index
+func (s *Stage) validate() *apis.FieldError {
+	var err *apis.FieldError
+	if s.Percent < 0 || s.Percent >= 100 {
+		err = err.Also(apis.ErrOutOfBoundsValue(s.Percent, 0, 99, "percent"))
+	}
+	if s.Threshold != nil && *s.Threshold <= 0 {
+		err = err.Also(apis.ErrGeneric(
+			fmt.Sprintf("Threshold must be a positive integer, got %d", *s.Threshold), "threshold"))
+	}
+	if s.After != nil && s.Threshold != nil {
+		err = err.Also(apis.ErrMultipleOneOf("threshold", "after"))
+	}
+	if s.After != nil && s.After.Duration <= 0 {
+		err = err.Also(apis.ErrInvalidValue(s.After.Duration.String(), "after"))
+	}
+	if s.SlicePeriodSeconds != nil && *s.SlicePeriodSeconds <= 0 {
+		err = err.Also(apis.ErrGeneric(
+			fmt.Sprintf("SlicePeriodSeconds must be a positive integer, got %d", *s.SlicePeriodSeconds), "slicePeriodSeconds"))
+	}
+	if s.MirrorPercent != nil && (*s.MirrorPercent < 1 || *s.MirrorPercent > 100) {
+		err = err.Also(apis.ErrOutOfBoundsValue(*s.MirrorPercent, 1, 100, "mirrorPercent"))
+	}
+	if s.HeaderRouting != nil {
+		err = err.Also(s.HeaderRouting.validate().ViaField("headerRouting"))
+	}
+	for i, h := range s.Hooks {
+		if h.Name == "" {
+			err = err.Also(apis.ErrMissingField("name").ViaFieldIndex("hooks", i))
+		}
+	}
+	if s.FailureLimit != nil && *s.FailureLimit < 0 {
+		err = err.Also(apis.ErrGeneric(
+			fmt.Sprintf("FailureLimit cannot be negative, got %d", *s.FailureLimit), "failureLimit"))
+	}
+	if s.Analysis != nil {
+		if u, perr := url.ParseRequestURI(s.Analysis.URL); perr != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			err = err.Also(apis.ErrInvalidValue(s.Analysis.URL, "analysis.url"))
+		}
+	}
+	if c := s.Compare; c != nil {
+		if c.WindowSeconds <= 0 {
+			err = err.Also(apis.ErrGeneric("Compare window must be a positive number of seconds", "compare.windowSeconds"))
+		}
+		if c.PassScore < 1 || c.PassScore > 100 {
+			err = err.Also(apis.ErrOutOfBoundsValue(c.PassScore, 1, 100, "compare.passScore"))
+		}
+		if c.MarginalScore < 0 || c.MarginalScore > c.PassScore {
+			err = err.Also(apis.ErrGeneric(
+				fmt.Sprintf("MarginalScore must be between 0 and PassScore, got %d", c.MarginalScore), "compare.marginalScore"))
+		}
+		for field, value := range map[string]int{"compare.intervalSeconds": c.IntervalSeconds,
+			"compare.consecutivePasses": c.ConsecutivePasses, "compare.allowedFailures": c.AllowedFailures} {
+			if value < 0 {
+				err = err.Also(apis.ErrGeneric(fmt.Sprintf("value cannot be negative, got %d", value), field))
+			}
+		}
+	}
+	return err
+}
+
+// validate checks that exactly one of Header or Cookie is set along with a value
+func (h *HeaderRouting) validate() *apis.FieldError {
+	var err *apis.FieldError
+	if h.Header == "" && h.Cookie == "" {
+		err = err.Also(apis.ErrMissingOneOf("header", "cookie"))
+	}
+	if h.Header != "" && h.Cookie != "" {
+		err = err.Also(apis.ErrMultipleOneOf("header", "cookie"))
+	}
+	if h.Value == "" {
+		err = err.Also(apis.ErrMissingField("value"))
+	}
+	return err
+}
+
+// checkImmutableFields rejects updates that change the Policy's immutable fields
+func (p *Policy) checkImmutableFields(original *Policy) *apis.FieldError {
+	if original.Spec.Mode == p.Spec.Mode {
+		return nil
+	}
+	diff, err := kmp.ShortDiff(original.Spec.Mode, p.Spec.Mode)
+	if err != nil {
+		return &apis.FieldError{
+			Message: "Failed to diff Policy",
+			Paths:   []string{"spec.mode"},
+			Details: err.Error(),
+		}
+	}
+	return &apis.FieldError{
+		Message: "Immutable fields changed (-old +new)",
+		Paths:   []string{"spec.mode"},
+		Details: diff,
+	}
+}
+
+// requestedCapabilities lists the networking-layer features this Policy relies on
+// plain percentage splitting is always available and doesn't count as a capability;
+// stage types that need more (mirroring, header routing, ...) must add entries here
+func (p *Policy) requestedCapabilities() []networking.Capability {
+	mirroring, headerRouting := false, false
+	for _, s := range p.Spec.Stages {
+		mirroring = mirroring || s.MirrorPercent != nil
+		headerRouting = headerRouting || s.HeaderRouting != nil
+	}
+	var caps []networking.Capability
+	if mirroring {
+		caps = append(caps, networking.CapabilityMirroring)
+	}
+	if headerRouting {
+		caps = append(caps, networking.CapabilityHeaderRouting)
+	}
+	return caps
+}
+
+// ClockFormat is the layout used to parse TimeWindow Start and End values
+const ClockFormat = "15:04"
+
+// validDays holds the accepted day-of-week abbreviations for TimeWindow.Days
+var validDays = map[string]bool{
+	"Sun": true, "Mon": true, "Tue": true, "Wed": true, "Thu": true, "Fri": true, "Sat": true,
+}
+
+// validate checks a single TimeWindow for well-formedness
+func (w *TimeWindow) validate() *apis.FieldError {
+	var err *apis.FieldError
+	for _, d := range w.Days {
+		if !validDays[d] {
+			err = err.Also(apis.ErrInvalidValue(d, "days"))
+		}
+	}
+	start, serr := time.Parse(ClockFormat, w.Start)
+	if serr != nil {
+		err = err.Also(apis.ErrInvalidValue(w.Start, "start"))
+	}
+	end, eerr := time.Parse(ClockFormat, w.End)
+	if eerr != nil {
+		err = err.Also(apis.ErrInvalidValue(w.End, "end"))
+	}
+	if serr == nil && eerr == nil && !end.After(start) {
+		err = err.Also(apis.ErrGeneric("Window end must be after window start", "start", "end"))
+	}
+	if w.TimeZone != "" {
+		if _, lerr := time.LoadLocation(w.TimeZone); lerr != nil {
+			err = err.Also(apis.ErrInvalidValue(w.TimeZone, "timeZone"))
 		}
-		prev = s.Percent
 	}
 	return err
 }
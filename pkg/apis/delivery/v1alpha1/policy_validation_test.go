@@ -17,10 +17,13 @@ package v1alpha1
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
 )
 
 // knative.dev/pkg/ptr library doesn't have Int, so we need to implement it here
@@ -43,7 +46,7 @@ func TestPolicyValidation(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "time",
 				DefaultThreshold: 100,
-				Stages:           []Stage{{0, nil}},
+				Stages:           []Stage{{Percent: 0}},
 			},
 		},
 		want: nil,
@@ -57,10 +60,25 @@ func TestPolicyValidation(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "unknown",
 				DefaultThreshold: 100,
-				Stages:           []Stage{{0, nil}},
+				Stages:           []Stage{{Percent: 0}},
 			},
 		},
 		want: apis.ErrInvalidValue("unknown", "spec.mode"),
+	}, {
+		name: "invalid threshold unit",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				ThresholdUnit:    "fortnights",
+				Stages:           []Stage{{Percent: 0}},
+			},
+		},
+		want: apis.ErrInvalidValue("fortnights", "spec.thresholdUnit"),
 	}, {
 		name: "defaultThreshold missing",
 		p: &Policy{
@@ -70,7 +88,7 @@ func TestPolicyValidation(t *testing.T) {
 			},
 			Spec: PolicySpec{
 				Mode:   "time",
-				Stages: []Stage{{0, nil}},
+				Stages: []Stage{{Percent: 0}},
 			},
 		},
 		want: apis.ErrGeneric("DefaultThreshold value is mandatory and must be a positive integer", "spec.defaultThreshold"),
@@ -98,10 +116,11 @@ func TestPolicyValidation(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "time",
 				DefaultThreshold: 100,
-				Stages:           []Stage{{0, nil}, {70, nil}, {50, nil}, {30, nil}},
+				Stages:           []Stage{{Percent: 0}, {Percent: 70}, {Percent: 50}, {Percent: 30}},
 			},
 		},
-		want: apis.ErrGeneric("Rollout percentages must be in increasing order", "spec.stages"),
+		want: apis.ErrGeneric("Rollout percentages must be in increasing order, but 50 < 70", "percent").ViaFieldIndex("spec.stages", 2).
+			Also(apis.ErrGeneric("Rollout percentages must be in increasing order, but 30 < 50", "percent").ViaFieldIndex("spec.stages", 3)),
 	}, {
 		name: "out of bounds percentage value",
 		p: &Policy{
@@ -112,10 +131,10 @@ func TestPolicyValidation(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "time",
 				DefaultThreshold: 100,
-				Stages:           []Stage{{0, nil}, {101, nil}},
+				Stages:           []Stage{{Percent: 0}, {Percent: 101}},
 			},
 		},
-		want: apis.ErrOutOfBoundsValue(101, 0, 99, "spec.stages"),
+		want: apis.ErrOutOfBoundsValue(101, 0, 99, "percent").ViaFieldIndex("spec.stages", 1),
 	}, {
 		name: "invalid optional threshold value",
 		p: &Policy{
@@ -126,10 +145,200 @@ func TestPolicyValidation(t *testing.T) {
 			Spec: PolicySpec{
 				Mode:             "time",
 				DefaultThreshold: 100,
-				Stages:           []Stage{{0, nil}, {50, intptr(-1)}},
+				Stages:           []Stage{{Percent: 0}, {Percent: 50, Threshold: intptr(-1)}},
+			},
+		},
+		want: apis.ErrGeneric("Threshold must be a positive integer, got -1", "threshold").ViaFieldIndex("spec.stages", 1),
+	}, {
+		name: "negative failure limit",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}, {Percent: 50, FailureLimit: intptr(-1)}},
+			},
+		},
+		want: apis.ErrGeneric("FailureLimit cannot be negative, got -1", "failureLimit").ViaFieldIndex("spec.stages", 1),
+	}, {
+		name: "threshold and after are mutually exclusive",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages: []Stage{{Percent: 0},
+					{Percent: 50, Threshold: intptr(60), After: &metav1.Duration{Duration: time.Minute}}},
+			},
+		},
+		want: apis.ErrMultipleOneOf("threshold", "after").ViaFieldIndex("spec.stages", 1),
+	}, {
+		name: "non-positive after duration",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}, {Percent: 50, After: &metav1.Duration{Duration: -time.Minute}}},
+			},
+		},
+		want: apis.ErrInvalidValue("-1m0s", "after").ViaFieldIndex("spec.stages", 1),
+	}, {
+		name: "multiple stage errors are all collected",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0, Threshold: intptr(0)}, {Percent: 50, HeaderRouting: &HeaderRouting{Header: "X-Canary"}}},
+			},
+		},
+		want: apis.ErrGeneric("Threshold must be a positive integer, got 0", "threshold").ViaFieldIndex("spec.stages", 0).
+			Also(apis.ErrMissingField("value").ViaField("headerRouting").ViaFieldIndex("spec.stages", 1)),
+	}, {
+		name: "invalid generator type",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Generator:        &Generator{Type: "quadratic", Step: 10},
 			},
 		},
-		want: apis.ErrGeneric("Optional threshold value must be a positive integer", "spec.stages"),
+		want: apis.ErrInvalidValue("quadratic", "spec.generator.type"),
+	}, {
+		name: "generator step out of bounds",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Generator:        &Generator{Type: "linear", Step: 0},
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(0, 1, 99, "spec.generator.step"),
+	}, {
+		name: "generator interval is not a duration",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Generator:        &Generator{Type: "linear", Step: 10, Interval: "sometime"},
+			},
+		},
+		want: apis.ErrInvalidValue("sometime", "spec.generator.interval"),
+	}, {
+		name: "invalid onFailure value",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+				OnFailure:        "retry",
+			},
+		},
+		want: apis.ErrInvalidValue("retry", "spec.onFailure"),
+	}, {
+		name: "maxActiveRevisions below two",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:               "time",
+				DefaultThreshold:   100,
+				Stages:             []Stage{{Percent: 0}},
+				MaxActiveRevisions: intptr(1),
+			},
+		},
+		want: apis.ErrGeneric("MaxActiveRevisions must be at least 2 (the stable and candidate Revisions)", "spec.maxActiveRevisions"),
+	}, {
+		name: "minStablePercent out of bounds",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+				MinStablePercent: intptr(100),
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(100, 1, 99, "spec.minStablePercent"),
+	}, {
+		name: "rollbackStages must descend",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+				RollbackStages:   []Stage{{Percent: 10}, {Percent: 50}},
+			},
+		},
+		want: apis.ErrGeneric("Rollback percentages must be in decreasing order, but 50 >= 10", "percent").ViaFieldIndex("spec.rollbackStages", 1),
+	}, {
+		name: "slo availability target out of bounds",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+				SLO:              &SLOConfig{TargetAvailabilityPermyriad: 10000, WindowSeconds: 3600},
+			},
+		},
+		want: apis.ErrOutOfBoundsValue(10000, 1, 9999, "spec.slo.targetAvailabilityPermyriad"),
+	}, {
+		name: "slo window must be positive",
+		p: &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             "time",
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+				SLO:              &SLOConfig{TargetAvailabilityPermyriad: 9990},
+			},
+		},
+		want: apis.ErrGeneric("SLO window must be a positive number of seconds", "spec.slo.windowSeconds"),
 	}}
 
 	for _, test := range tests {
@@ -142,3 +351,83 @@ func TestPolicyValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestPolicyImmutableFields(t *testing.T) {
+	base := func(mode string) *Policy {
+		return &Policy{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test",
+			},
+			Spec: PolicySpec{
+				Mode:             mode,
+				DefaultThreshold: 100,
+				Stages:           []Stage{{Percent: 0}},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		original *Policy
+		updated  *Policy
+		want     *apis.FieldError
+	}{{
+		name:     "unchanged mode is fine",
+		original: base("time"),
+		updated:  base("time"),
+		want:     nil,
+	}, {
+		name:     "changing mode is rejected",
+		original: base("time"),
+		updated:  base("request"),
+		want: &apis.FieldError{
+			Message: "Immutable fields changed (-old +new)",
+			Paths:   []string{"spec.mode"},
+			Details: "{string}:\n\t-: \"time\"\n\t+: \"request\"\n",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinUpdate(context.Background(), test.original)
+			got := test.updated.checkImmutableFields(test.original)
+			if !cmp.Equal(test.want.Error(), got.Error()) {
+				t.Errorf("checkImmutableFields (-want, +got) = %v",
+					cmp.Diff(test.want.Error(), got.Error()))
+			}
+			// the full Validate path must surface the same rejection
+			if verr := test.updated.Validate(ctx); (verr == nil) != (got == nil) {
+				t.Errorf("Validate and checkImmutableFields disagree: %v vs %v", verr, got)
+			}
+		})
+	}
+}
+
+func TestMaxRolloutDuration(t *testing.T) {
+	p := &Policy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "test",
+		},
+		Spec: PolicySpec{
+			Mode:             "time",
+			DefaultThreshold: 3600,
+			Stages:           []Stage{{Percent: 10, Threshold: intptr(30)}, {Percent: 50}},
+		},
+	}
+	bound := func(d time.Duration) context.Context {
+		return config.ToContext(context.Background(), &config.Config{Delivery: &config.Delivery{MaxRolloutDuration: d}})
+	}
+
+	// the stages plan 30s + 3600s of counted time
+	if err := p.Validate(bound(time.Hour)); err == nil {
+		t.Error("a rollout longer than the cluster maximum passed validation")
+	}
+	if err := p.Validate(bound(2 * time.Hour)); err != nil {
+		t.Errorf("a rollout within the cluster maximum was rejected: %v", err)
+	}
+	// no delivery config in the context means no bound
+	if err := p.Validate(context.Background()); err != nil {
+		t.Errorf("a rollout with no configured maximum was rejected: %v", err)
+	}
+}
@@ -19,16 +19,143 @@
 package v1alpha1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnalysisGate) DeepCopyInto(out *AnalysisGate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnalysisGate.
+func (in *AnalysisGate) DeepCopy() *AnalysisGate {
+	if in == nil {
+		return nil
+	}
+	out := new(AnalysisGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompareGate) DeepCopyInto(out *CompareGate) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompareGate.
+func (in *CompareGate) DeepCopy() *CompareGate {
+	if in == nil {
+		return nil
+	}
+	out := new(CompareGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExperimentConfig) DeepCopyInto(out *ExperimentConfig) {
+	*out = *in
+	if in.Tracks != nil {
+		in, out := &in.Tracks, &out.Tracks
+		*out = make([]ExperimentTrack, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExperimentConfig.
+func (in *ExperimentConfig) DeepCopy() *ExperimentConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ExperimentConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExperimentTrack) DeepCopyInto(out *ExperimentTrack) {
+	*out = *in
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]Stage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExperimentTrack.
+func (in *ExperimentTrack) DeepCopy() *ExperimentTrack {
+	if in == nil {
+		return nil
+	}
+	out := new(ExperimentTrack)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HeaderRouting) DeepCopyInto(out *HeaderRouting) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HeaderRouting.
+func (in *HeaderRouting) DeepCopy() *HeaderRouting {
+	if in == nil {
+		return nil
+	}
+	out := new(HeaderRouting)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricProviderConfig) DeepCopyInto(out *MetricProviderConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricProviderConfig.
+func (in *MetricProviderConfig) DeepCopy() *MetricProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationConfig) DeepCopyInto(out *NotificationConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationConfig.
+func (in *NotificationConfig) DeepCopy() *NotificationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 	return
 }
@@ -84,9 +211,116 @@ func (in *PolicyList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Generator) DeepCopyInto(out *Generator) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Generator.
+func (in *Generator) DeepCopy() *Generator {
+	if in == nil {
+		return nil
+	}
+	out := new(Generator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolicySpec) DeepCopyInto(out *PolicySpec) {
 	*out = *in
+	if in.JitterPercent != nil {
+		in, out := &in.JitterPercent, &out.JitterPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.Stages != nil {
+		in, out := &in.Stages, &out.Stages
+		*out = make([]Stage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Generator != nil {
+		in, out := &in.Generator, &out.Generator
+		*out = new(Generator)
+		**out = **in
+	}
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]TimeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int)
+		**out = **in
+	}
+	if in.RollbackStages != nil {
+		in, out := &in.RollbackStages, &out.RollbackStages
+		*out = make([]Stage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaxUnavailablePercent != nil {
+		in, out := &in.MaxUnavailablePercent, &out.MaxUnavailablePercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.ReplicaParityPercent != nil {
+		in, out := &in.ReplicaParityPercent, &out.ReplicaParityPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxActiveRevisions != nil {
+		in, out := &in.MaxActiveRevisions, &out.MaxActiveRevisions
+		*out = new(int)
+		**out = **in
+	}
+	if in.MinStablePercent != nil {
+		in, out := &in.MinStablePercent, &out.MinStablePercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.CandidateMinScale != nil {
+		in, out := &in.CandidateMinScale, &out.CandidateMinScale
+		*out = new(int)
+		**out = **in
+	}
+	if in.Experiment != nil {
+		in, out := &in.Experiment, &out.Experiment
+		*out = new(ExperimentConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SLO != nil {
+		in, out := &in.SLO, &out.SLO
+		*out = new(SLOConfig)
+		**out = **in
+	}
+	if in.MetricProvider != nil {
+		in, out := &in.MetricProvider, &out.MetricProvider
+		*out = new(MetricProviderConfig)
+		**out = **in
+	}
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationConfig)
+		**out = **in
+	}
+	if in.SelfCanarySeconds != nil {
+		in, out := &in.SelfCanarySeconds, &out.SelfCanarySeconds
+		*out = new(int)
+		**out = **in
+	}
 	return
 }
 
@@ -171,6 +405,16 @@ func (in *PolicyStateSpec) DeepCopyInto(out *PolicyStateSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.PinnedPolicy != nil {
+		in, out := &in.PinnedPolicy, &out.PinnedPolicy
+		*out = new(PolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdoptedPolicy != nil {
+		in, out := &in.AdoptedPolicy, &out.AdoptedPolicy
+		*out = new(PolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -216,6 +460,34 @@ func (in *PolicyStateStatusFields) DeepCopyInto(out *PolicyStateStatusFields) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.FreezeStartTime != nil {
+		in, out := &in.FreezeStartTime, &out.FreezeStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RollbackStartTime != nil {
+		in, out := &in.RollbackStartTime, &out.RollbackStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FirstTrafficTime != nil {
+		in, out := &in.FirstTrafficTime, &out.FirstTrafficTime
+		*out = (*in).DeepCopy()
+	}
+	if in.FullTrafficTime != nil {
+		in, out := &in.FullTrafficTime, &out.FullTrafficTime
+		*out = (*in).DeepCopy()
+	}
+	if in.StageSnapshots != nil {
+		in, out := &in.StageSnapshots, &out.StageSnapshots
+		*out = make([]StageSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CompletionReport != nil {
+		in, out := &in.CompletionReport, &out.CompletionReport
+		*out = new(RolloutReport)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -233,7 +505,7 @@ func (in *PolicyStateStatusFields) DeepCopy() *PolicyStateStatusFields {
 func (in *PolicyStatus) DeepCopyInto(out *PolicyStatus) {
 	*out = *in
 	in.Status.DeepCopyInto(&out.Status)
-	out.PolicyStatusFields = in.PolicyStatusFields
+	in.PolicyStatusFields.DeepCopyInto(&out.PolicyStatusFields)
 	return
 }
 
@@ -250,6 +522,15 @@ func (in *PolicyStatus) DeepCopy() *PolicyStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolicyStatusFields) DeepCopyInto(out *PolicyStatusFields) {
 	*out = *in
+	if in.BoundConfigurations != nil {
+		in, out := &in.BoundConfigurations, &out.BoundConfigurations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SpecChangeTime != nil {
+		in, out := &in.SpecChangeTime, &out.SpecChangeTime
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -262,3 +543,160 @@ func (in *PolicyStatusFields) DeepCopy() *PolicyStatusFields {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutReport) DeepCopyInto(out *RolloutReport) {
+	*out = *in
+	if in.CompletedAt != nil {
+		in, out := &in.CompletedAt, &out.CompletedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutReport.
+func (in *RolloutReport) DeepCopy() *RolloutReport {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SLOConfig) DeepCopyInto(out *SLOConfig) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SLOConfig.
+func (in *SLOConfig) DeepCopy() *SLOConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SLOConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Stage) DeepCopyInto(out *Stage) {
+	*out = *in
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(int)
+		**out = **in
+	}
+	if in.After != nil {
+		in, out := &in.After, &out.After
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.SlicePeriodSeconds != nil {
+		in, out := &in.SlicePeriodSeconds, &out.SlicePeriodSeconds
+		*out = new(int)
+		**out = **in
+	}
+	if in.MirrorPercent != nil {
+		in, out := &in.MirrorPercent, &out.MirrorPercent
+		*out = new(int)
+		**out = **in
+	}
+	if in.HeaderRouting != nil {
+		in, out := &in.HeaderRouting, &out.HeaderRouting
+		*out = new(HeaderRouting)
+		**out = **in
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = make([]StageHook, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Analysis != nil {
+		in, out := &in.Analysis, &out.Analysis
+		*out = new(AnalysisGate)
+		**out = **in
+	}
+	if in.Compare != nil {
+		in, out := &in.Compare, &out.Compare
+		*out = new(CompareGate)
+		**out = **in
+	}
+	if in.FailureLimit != nil {
+		in, out := &in.FailureLimit, &out.FailureLimit
+		*out = new(int)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Stage.
+func (in *Stage) DeepCopy() *Stage {
+	if in == nil {
+		return nil
+	}
+	out := new(Stage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageHook) DeepCopyInto(out *StageHook) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageHook.
+func (in *StageHook) DeepCopy() *StageHook {
+	if in == nil {
+		return nil
+	}
+	out := new(StageHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StageSnapshot) DeepCopyInto(out *StageSnapshot) {
+	*out = *in
+	if in.EvaluatedAt != nil {
+		in, out := &in.EvaluatedAt, &out.EvaluatedAt
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StageSnapshot.
+func (in *StageSnapshot) DeepCopy() *StageSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(StageSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeWindow) DeepCopyInto(out *TimeWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeWindow.
+func (in *TimeWindow) DeepCopy() *TimeWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeWindow)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,267 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package catalog serves a read-only JSON feed of active rollouts for service
+// catalogs such as Backstage: each item carries a stable ID, the owning user,
+// the current stage and percent, and a coarse health verdict, so catalog
+// plugins can render rollout state without talking to the Kubernetes API
+package catalog
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/serving/pkg/apis/serving"
+	servinglisters "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+const (
+	// DefaultAddr is the address the feed server listens on
+	DefaultAddr = ":8484"
+	// FeedPath is the path that serves the rollout feed
+	FeedPath = "/rollouts"
+	// TokenEnvVar names the environment variable holding the bearer token that
+	// feed consumers must present; the feed stays disabled when it is unset
+	TokenEnvVar = "CATALOG_FEED_TOKEN"
+
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// the possible values of RolloutSummary.Health
+const (
+	// HealthOK means the rollout is progressing normally
+	HealthOK = "ok"
+	// HealthDegraded means some condition is unhappy but the rollout has not failed
+	HealthDegraded = "degraded"
+	// HealthFailed means the rollout missed its progress deadline
+	HealthFailed = "failed"
+)
+
+// RolloutSummary is one feed item describing an active rollout
+type RolloutSummary struct {
+	// ID is stable across reconciles: the namespace/name of the Configuration
+	ID string `json:"id"`
+
+	// Service and Namespace identify the workload being rolled out
+	Service   string `json:"service"`
+	Namespace string `json:"namespace"`
+
+	// Owner is the user or service account that deployed the candidate Revision
+	// +optional
+	Owner string `json:"owner,omitempty"`
+
+	// Policy is the namespace/name of the Policy driving the rollout
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// Phase is the rollout's position in the delivery state machine,
+	// e.g. "Progressing" or "Paused"
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// Stage is the human-readable stage position, e.g. "2/4"
+	// +optional
+	Stage string `json:"stage,omitempty"`
+
+	// Percent is the candidate Revision's current share of traffic
+	Percent int `json:"percent"`
+
+	// Health is one of "ok", "degraded", "failed"
+	Health string `json:"health"`
+
+	// LastUpdated is the RFC3339 time of the rollout's latest observed change
+	// +optional
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+// RolloutFeed is one page of the feed
+type RolloutFeed struct {
+	Items []RolloutSummary `json:"items"`
+
+	// Continue is the token to pass as ?continue= to fetch the next page;
+	// it is empty on the last page
+	// +optional
+	Continue string `json:"continue,omitempty"`
+}
+
+// handler serves the feed from informer caches, so requests never hit the API server
+type handler struct {
+	token               string
+	policystateLister   pslisters.PolicyStateLister
+	configurationLister servinglisters.ConfigurationLister
+}
+
+// NewHandler returns the http.Handler serving the rollout feed, authenticated
+// by the given bearer token
+func NewHandler(token string, psl pslisters.PolicyStateLister, cfgl servinglisters.ConfigurationLister) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(FeedPath, &handler{token: token, policystateLister: psl, configurationLister: cfgl})
+	return mux
+}
+
+// NewServer wraps the feed handler in an http.Server listening on addr
+func NewServer(addr, token string, psl pslisters.PolicyStateLister, cfgl servinglisters.ConfigurationLister) *http.Server {
+	return &http.Server{Addr: addr, Handler: NewHandler(token, psl, cfgl)}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	items, err := h.summaries()
+	if err != nil {
+		http.Error(w, "failed to list rollouts", http.StatusInternalServerError)
+		return
+	}
+	feed := paginate(items, r.URL.Query().Get("continue"), pageSize(r.URL.Query().Get("limit")))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		http.Error(w, "failed to encode feed", http.StatusInternalServerError)
+	}
+}
+
+// authorized checks the bearer token in constant time
+func (h *handler) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(h.token)) == 1
+}
+
+// summaries builds one feed item per PolicyState with a pending update, i.e.
+// per rollout that is currently in flight, sorted by ID for stable pagination
+func (h *handler) summaries() ([]RolloutSummary, error) {
+	pss, err := h.policystateLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	items := make([]RolloutSummary, 0, len(pss))
+	for _, ps := range pss {
+		if ps.Status.NextUpdateTimestamp == nil {
+			continue
+		}
+		item := RolloutSummary{
+			ID:          ps.Namespace + "/" + ps.Name,
+			Service:     ps.Name,
+			Namespace:   ps.Namespace,
+			Owner:       ps.Status.TriggeredBy,
+			Policy:      ps.Status.PolicyName,
+			Phase:       ps.Status.Phase,
+			Health:      healthOf(ps),
+			LastUpdated: lastUpdated(ps),
+		}
+		if cfg, err := h.configurationLister.Configurations(ps.Namespace).Get(ps.Name); err == nil {
+			if item.Owner == "" {
+				item.Owner = cfg.Annotations[serving.CreatorAnnotation]
+			}
+			// the reconciler maintains a compact progress annotation on the
+			// Configuration; reuse its stage arithmetic instead of redoing it
+			var progress struct {
+				Stage   string `json:"stage"`
+				Percent int    `json:"percent"`
+			}
+			if raw := cfg.Annotations[delivery.RolloutStatusKey]; raw != "" {
+				if err := json.Unmarshal([]byte(raw), &progress); err == nil {
+					item.Stage = progress.Stage
+					item.Percent = progress.Percent
+				}
+			}
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items, nil
+}
+
+// healthOf folds the PolicyState's conditions into a coarse verdict: a missed
+// progress deadline is a failure, any other unhappy condition is degradation
+func healthOf(ps *v1alpha1.PolicyState) string {
+	health := HealthOK
+	for _, cond := range ps.Status.Conditions {
+		if !cond.IsFalse() {
+			continue
+		}
+		if cond.Type == v1alpha1.PolicyStateConditionProgressing {
+			return HealthFailed
+		}
+		health = HealthDegraded
+	}
+	return health
+}
+
+// lastUpdated reports the latest condition transition on the PolicyState
+func lastUpdated(ps *v1alpha1.PolicyState) string {
+	var latest time.Time
+	for _, cond := range ps.Status.Conditions {
+		if t := cond.LastTransitionTime.Inner.Time; t.After(latest) {
+			latest = t
+		}
+	}
+	if latest.IsZero() {
+		return ""
+	}
+	return latest.UTC().Format(time.RFC3339)
+}
+
+// paginate slices the sorted items into one page; the continue token is the ID
+// of the last item on the previous page, so a page boundary survives items
+// appearing or disappearing between requests
+func paginate(items []RolloutSummary, continueToken string, limit int) *RolloutFeed {
+	start := 0
+	if continueToken != "" {
+		start = sort.Search(len(items), func(i int) bool { return items[i].ID > continueToken })
+	}
+	feed := &RolloutFeed{Items: []RolloutSummary{}}
+	if start >= len(items) {
+		return feed
+	}
+	end := start + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	feed.Items = items[start:end]
+	if end < len(items) {
+		feed.Continue = items[end-1].ID
+	}
+	return feed
+}
+
+// pageSize parses the ?limit= parameter, clamped to a sane range
+func pageSize(raw string) int {
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return defaultPageSize
+	}
+	if n > maxPageSize {
+		return maxPageSize
+	}
+	return n
+}
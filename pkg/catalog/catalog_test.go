@@ -0,0 +1,169 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package catalog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servinglisters "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+const testToken = "feed-token"
+
+func feedServer(t *testing.T, pss []*v1alpha1.PolicyState, cfgs []*v1.Configuration) *httptest.Server {
+	t.Helper()
+	psIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, ps := range pss {
+		psIndexer.Add(ps)
+	}
+	cfgIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, cfg := range cfgs {
+		cfgIndexer.Add(cfg)
+	}
+	server := httptest.NewServer(NewHandler(testToken, pslisters.NewPolicyStateLister(psIndexer), servinglisters.NewConfigurationLister(cfgIndexer)))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fetchFeed(t *testing.T, url, token string) (*http.Response, *RolloutFeed) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	feed := &RolloutFeed{}
+	if err := json.NewDecoder(resp.Body).Decode(feed); err != nil {
+		t.Fatalf("failed to decode feed: %v", err)
+	}
+	return resp, feed
+}
+
+func activePolicyState(namespace, name string) *v1alpha1.PolicyState {
+	return &v1alpha1.PolicyState{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Status: v1alpha1.PolicyStateStatus{PolicyStateStatusFields: v1alpha1.PolicyStateStatusFields{
+			NextUpdateTimestamp: &metav1.Time{Time: time.Now()},
+		}},
+	}
+}
+
+func TestFeedAuth(t *testing.T) {
+	server := feedServer(t, nil, nil)
+	if resp, _ := fetchFeed(t, server.URL+FeedPath, ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no token: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp, _ := fetchFeed(t, server.URL+FeedPath, "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp, _ := fetchFeed(t, server.URL+FeedPath, testToken); resp.StatusCode != http.StatusOK {
+		t.Errorf("good token: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestFeedSummaries(t *testing.T) {
+	transition := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	active := activePolicyState("default", "svc")
+	active.Status.PolicyName = "default/pol"
+	active.Status.Phase = v1alpha1.PhaseProgressing
+	active.Status.TriggeredBy = "user@example.com"
+	active.Status.Conditions = duckv1.Conditions{{
+		Type:               v1alpha1.PolicyStateConditionRevisionDeployed,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: apis.VolatileTime{Inner: metav1.Time{Time: transition}},
+	}}
+	// a PolicyState without a pending update is a finished rollout: not in the feed
+	stable := &v1alpha1.PolicyState{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "done"}}
+	cfg := &v1.Configuration{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "svc",
+		Annotations: map[string]string{delivery.RolloutStatusKey: `{"stage":"2/4","percent":10}`},
+	}}
+
+	server := feedServer(t, []*v1alpha1.PolicyState{active, stable}, []*v1.Configuration{cfg})
+	_, feed := fetchFeed(t, server.URL+FeedPath, testToken)
+	want := []RolloutSummary{{
+		ID:          "default/svc",
+		Service:     "svc",
+		Namespace:   "default",
+		Owner:       "user@example.com",
+		Policy:      "default/pol",
+		Phase:       v1alpha1.PhaseProgressing,
+		Stage:       "2/4",
+		Percent:     10,
+		Health:      HealthDegraded,
+		LastUpdated: "2020-07-01T12:00:00Z",
+	}}
+	if diff := cmp.Diff(want, feed.Items); diff != "" {
+		t.Errorf("wrong feed items (-want, +got): %s", diff)
+	}
+	if feed.Continue != "" {
+		t.Errorf("Continue = %q, want empty on the last page", feed.Continue)
+	}
+}
+
+func TestFeedPagination(t *testing.T) {
+	pss := []*v1alpha1.PolicyState{
+		activePolicyState("default", "a"),
+		activePolicyState("default", "b"),
+		activePolicyState("default", "c"),
+	}
+	server := feedServer(t, pss, nil)
+
+	_, page1 := fetchFeed(t, server.URL+FeedPath+"?limit=2", testToken)
+	if len(page1.Items) != 2 || page1.Continue != "default/b" {
+		t.Fatalf("page 1 = %d items, continue %q; want 2 items, continue %q", len(page1.Items), page1.Continue, "default/b")
+	}
+	_, page2 := fetchFeed(t, server.URL+FeedPath+"?limit=2&continue="+page1.Continue, testToken)
+	if len(page2.Items) != 1 || page2.Items[0].ID != "default/c" || page2.Continue != "" {
+		t.Errorf("page 2 = %+v, want only default/c and no continue", page2)
+	}
+}
+
+func TestHealthOf(t *testing.T) {
+	ps := activePolicyState("default", "svc")
+	if got := healthOf(ps); got != HealthOK {
+		t.Errorf("no conditions: health = %q, want %q", got, HealthOK)
+	}
+	ps.Status.Conditions = duckv1.Conditions{{Type: v1alpha1.PolicyStateConditionProgressing, Status: corev1.ConditionFalse}}
+	if got := healthOf(ps); got != HealthFailed {
+		t.Errorf("deadline missed: health = %q, want %q", got, HealthFailed)
+	}
+}
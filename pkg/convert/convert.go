@@ -0,0 +1,195 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package convert turns existing gradual-rollout configuration — Knative
+// Serving rollout-duration annotations and Argo Rollouts canary steps — into
+// the equivalent KCD Policy, so migrating teams do not have to rewrite their
+// progressive delivery setup by hand.
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// RolloutDurationAnnotation is the Knative Serving annotation that asks for a
+// gradual traffic shift spread over the given duration
+const RolloutDurationAnnotation = "serving.knative.dev/rollout-duration"
+
+// rolloutDurationSteps is how many equal stages a rollout-duration annotation
+// is translated into; Serving shifts continuously, ten steps of ten percent is
+// the closest staged equivalent
+const rolloutDurationSteps = 10
+
+// manifest is the subset of an input document the converter inspects: enough
+// of an Argo Rollout to read its canary steps, and enough of a Knative
+// workload to read its annotations
+type manifest struct {
+	Kind     string            `json:"kind"`
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Template *struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		} `json:"template"`
+		Strategy struct {
+			Canary *struct {
+				Steps []argoStep `json:"steps"`
+			} `json:"canary"`
+		} `json:"strategy"`
+	} `json:"spec"`
+}
+
+// argoStep mirrors the two Argo Rollouts canary step types the converter
+// understands; Duration is raw because Argo accepts both "30s" and plain 30
+type argoStep struct {
+	SetWeight *int `json:"setWeight"`
+	Pause     *struct {
+		Duration json.RawMessage `json:"duration"`
+	} `json:"pause"`
+}
+
+// Convert inspects a YAML manifest and returns the equivalent Policy: an Argo
+// Rollout is translated step by step, and any other kind is searched for a
+// rollout-duration annotation
+func Convert(doc []byte) (*v1alpha1.Policy, error) {
+	var m manifest
+	if err := yaml.Unmarshal(doc, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest: %v", err)
+	}
+	if m.Kind == "Rollout" {
+		if m.Spec.Strategy.Canary == nil {
+			return nil, fmt.Errorf("only canary Rollouts can be converted, this one has no canary strategy")
+		}
+		return fromArgoSteps(m.Metadata, m.Spec.Strategy.Canary.Steps)
+	}
+	if d, ok := m.Metadata.Annotations[RolloutDurationAnnotation]; ok {
+		return fromRolloutDuration(m.Metadata, d)
+	}
+	if t := m.Spec.Template; t != nil {
+		if d, ok := t.Metadata.Annotations[RolloutDurationAnnotation]; ok {
+			return fromRolloutDuration(m.Metadata, d)
+		}
+	}
+	return nil, fmt.Errorf("nothing to convert: not an Argo Rollout and no %s annotation", RolloutDurationAnnotation)
+}
+
+// fromRolloutDuration translates a Serving rollout-duration into ten equal
+// stages whose thresholds sum to the requested duration
+func fromRolloutDuration(meta metav1.ObjectMeta, value string) (*v1alpha1.Policy, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid %s value %q", RolloutDurationAnnotation, value)
+	}
+	perStage := int(d.Seconds()) / rolloutDurationSteps
+	if perStage < 1 {
+		perStage = 1
+	}
+	stages := make([]v1alpha1.Stage, 0, rolloutDurationSteps-1)
+	for percent := 10; percent < 100; percent += 10 {
+		stages = append(stages, v1alpha1.Stage{Percent: percent})
+	}
+	return newPolicy(meta, v1alpha1.PolicySpec{
+		Mode:             "time",
+		DefaultThreshold: perStage,
+		Stages:           stages,
+	}), nil
+}
+
+// fromArgoSteps translates setWeight/pause canary steps: each setWeight opens
+// a stage, and the pauses that follow it become the stage's threshold; a
+// stage without a pause advances after one second, matching Argo's immediate
+// progression
+func fromArgoSteps(meta metav1.ObjectMeta, steps []argoStep) (*v1alpha1.Policy, error) {
+	var stages []v1alpha1.Stage
+	for i, step := range steps {
+		switch {
+		case step.SetWeight != nil:
+			if *step.SetWeight >= 100 {
+				continue // KCD's final 100% stage is implicit
+			}
+			stages = append(stages, v1alpha1.Stage{Percent: *step.SetWeight})
+		case step.Pause != nil:
+			if len(stages) == 0 {
+				return nil, fmt.Errorf("step %d pauses before any setWeight", i)
+			}
+			seconds, err := pauseSeconds(step.Pause.Duration)
+			if err != nil {
+				return nil, fmt.Errorf("step %d: %v", i, err)
+			}
+			last := &stages[len(stages)-1]
+			if last.Threshold == nil {
+				last.Threshold = new(int)
+			}
+			*last.Threshold += seconds
+		default:
+			return nil, fmt.Errorf("step %d is neither setWeight nor pause; convert it by hand", i)
+		}
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("the canary strategy has no setWeight steps")
+	}
+	return newPolicy(meta, v1alpha1.PolicySpec{
+		Mode:             "time",
+		DefaultThreshold: 1,
+		Stages:           stages,
+	}), nil
+}
+
+// pauseSeconds parses an Argo pause duration, which may be a bare number of
+// seconds or a duration string; an absent duration means pause forever, which
+// has no KCD equivalent
+func pauseSeconds(raw json.RawMessage) (int, error) {
+	if len(raw) == 0 {
+		return 0, fmt.Errorf("an indefinite pause has no Policy equivalent; use a promotion window or a hold instead")
+	}
+	s := strings.Trim(string(raw), `"`)
+	if n, err := strconv.Atoi(s); err == nil {
+		if n <= 0 {
+			return 0, fmt.Errorf("pause duration must be positive, got %d", n)
+		}
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid pause duration %q", s)
+	}
+	seconds := int(d.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds, nil
+}
+
+// newPolicy wraps a spec in a Policy named after the source object
+func newPolicy(meta metav1.ObjectMeta, spec v1alpha1.PolicySpec) *v1alpha1.Policy {
+	return &v1alpha1.Policy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "Policy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      meta.Name,
+			Namespace: meta.Namespace,
+		},
+		Spec: spec,
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+func intptr(x int) *int {
+	return &x
+}
+
+func TestConvertRolloutDuration(t *testing.T) {
+	doc := []byte(`
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: hello
+  namespace: default
+  annotations:
+    serving.knative.dev/rollout-duration: "600s"
+`)
+	policy, err := Convert(doc)
+	if err != nil {
+		t.Fatalf("Convert() = %v", err)
+	}
+	if policy.Name != "hello" || policy.Namespace != "default" {
+		t.Errorf("Policy is named %s/%s, want default/hello", policy.Namespace, policy.Name)
+	}
+	if policy.Spec.DefaultThreshold != 60 {
+		t.Errorf("DefaultThreshold = %d, want 60", policy.Spec.DefaultThreshold)
+	}
+	if len(policy.Spec.Stages) != 9 || policy.Spec.Stages[0].Percent != 10 || policy.Spec.Stages[8].Percent != 90 {
+		t.Errorf("unexpected stages: %v", policy.Spec.Stages)
+	}
+}
+
+func TestConvertRolloutDurationOnTemplate(t *testing.T) {
+	doc := []byte(`
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: hello
+spec:
+  template:
+    metadata:
+      annotations:
+        serving.knative.dev/rollout-duration: "30s"
+`)
+	policy, err := Convert(doc)
+	if err != nil {
+		t.Fatalf("Convert() = %v", err)
+	}
+	if policy.Spec.DefaultThreshold != 3 {
+		t.Errorf("DefaultThreshold = %d, want 3", policy.Spec.DefaultThreshold)
+	}
+}
+
+func TestConvertArgoRollout(t *testing.T) {
+	doc := []byte(`
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: checkout
+  namespace: shop
+spec:
+  strategy:
+    canary:
+      steps:
+      - setWeight: 20
+      - pause: {duration: 60}
+      - pause: {duration: "2m"}
+      - setWeight: 50
+      - setWeight: 100
+`)
+	policy, err := Convert(doc)
+	if err != nil {
+		t.Fatalf("Convert() = %v", err)
+	}
+	want := []v1alpha1.Stage{
+		{Percent: 20, Threshold: intptr(180)},
+		{Percent: 50},
+	}
+	if diff := cmp.Diff(want, policy.Spec.Stages); diff != "" {
+		t.Errorf("stages are incorrect (-want, +got): %s", diff)
+	}
+	if policy.Name != "checkout" || policy.Namespace != "shop" {
+		t.Errorf("Policy is named %s/%s, want shop/checkout", policy.Namespace, policy.Name)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{{
+		name: "nothing convertible",
+		doc:  "kind: Service\nmetadata:\n  name: plain",
+	}, {
+		name: "indefinite pause",
+		doc: `kind: Rollout
+metadata:
+  name: r
+spec:
+  strategy:
+    canary:
+      steps:
+      - setWeight: 10
+      - pause: {}
+`,
+	}, {
+		name: "pause before any weight",
+		doc: `kind: Rollout
+metadata:
+  name: r
+spec:
+  strategy:
+    canary:
+      steps:
+      - pause: {duration: 10}
+`,
+	}, {
+		name: "rollout without canary strategy",
+		doc:  "kind: Rollout\nmetadata:\n  name: r",
+	}, {
+		name: "bad rollout-duration",
+		doc: `kind: Service
+metadata:
+  name: s
+  annotations:
+    serving.knative.dev/rollout-duration: "soon"
+`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Convert([]byte(test.doc)); err == nil {
+				t.Error("expected an error, got none")
+			}
+		})
+	}
+}
@@ -19,13 +19,50 @@ import (
 
 	"knative.dev/pkg/logging"
 
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
 	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
 	policystateinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/tracing"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
 	"knative.dev/pkg/apis"
+	"knative.dev/pkg/metrics"
 	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
+var (
+	// enforcementSkipCount counts Route updates that went through without the
+	// computed traffic split being re-injected because the PolicyState could
+	// not be read; every skip is a window in which the Serving reconciler may
+	// reset traffic to 100% latest, so a non-zero rate deserves an alert
+	enforcementSkipCount = stats.Int64(
+		"route_enforcement_skips",
+		"Number of Route updates admitted without re-injecting the computed traffic split",
+		stats.UnitDimensionless)
+
+	// enforcementSkipNamespaceKey tags skips with the Route's namespace
+	enforcementSkipNamespaceKey = tag.MustNewKey("namespace_name")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: enforcementSkipCount.Description(),
+		Measure:     enforcementSkipCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{enforcementSkipNamespaceKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ContinuousDeploymentRoute is a wrapper around Route for setting Continuous Deployment values
@@ -42,27 +79,103 @@ var (
 // SetDefaults implements apis.Defaultable
 func (cdr *ContinuousDeploymentRoute) SetDefaults(ctx context.Context) {
 	logger := logging.FromContext(ctx)
+	// the opt-out annotation releases the Route back to the Serving reconciler;
+	// the delivery reconciler cleans the PolicyState up on its own schedule, so
+	// the webhook must stop writing even while the PolicyState still exists
+	if delivery.Disabled(cdr.Annotations) {
+		logger.Infof("Continuous delivery is disabled for %s/%s, leaving the Route unchanged", cdr.Namespace, cdr.Name)
+		return
+	}
+	// a namespace outside the configured allow/deny lists is never touched,
+	// even while a leftover PolicyState still exists
+	if cfg := config.FromContext(ctx); cfg != nil && !cfg.Delivery.NamespaceAllowed(cdr.Namespace) {
+		logger.Infof("Namespace %s is outside the configured scope, leaving the Route unchanged", cdr.Namespace)
+		return
+	}
+	// in the reconciler-owned installation mode the reconciler is the sole
+	// writer of Route specs and this webhook stands down entirely; clusters
+	// that cannot run mutating webhooks on Routes need not install it at all
+	if cfg := config.FromContext(ctx); cfg != nil && cfg.Delivery.ReconcilerOwnsRoutes() {
+		logger.Infof("Route ownership mode is %q, leaving the Route to the reconciler", config.RouteOwnershipReconciler)
+		return
+	}
 	policyStateInformer := policystateinformer.Get(ctx)
 	policyStateLister := policyStateInformer.Lister()
 	ps, err := policyStateLister.PolicyStates(cdr.Namespace).Get(cdr.Name)
 	if err != nil {
-		return
+		// an informer miss is not proof that the workload is unmanaged: a
+		// cold or lagging cache here would admit this update unenforced and
+		// let the Serving reconciler reset traffic to 100% latest, so
+		// double-check against the API server before giving up
+		ps, err = cdr.fetchPolicyStateDirect(ctx)
+		if apierrs.IsNotFound(err) {
+			return
+		}
+		if err != nil {
+			logger.Warnf("Could not read PolicyState %s/%s, admitting the Route update unenforced: %v", cdr.Namespace, cdr.Name, err)
+			if tctx, terr := tag.New(ctx, tag.Insert(enforcementSkipNamespaceKey, cdr.Namespace)); terr == nil {
+				metrics.Record(tctx, enforcementSkipCount.M(1))
+			}
+			return
+		}
 	}
 	logger.Infof("Received PolicyState %v", *ps)
 
-	cdr.copyRouteSpec(ps)
+	// during a rolling upgrade of KCD the controller may briefly be newer than
+	// this webhook; a spec written under a newer semantics contract must not be
+	// interpreted, so hold the Route at its current split until the webhook
+	// catches up
+	if !ps.Spec.CompatibleWith(v1alpha1.PolicyStateFormatVersion) {
+		logger.Infof("PolicyState %s/%s spec has format version %d, newer than the supported %d; leaving the Route unchanged",
+			ps.Namespace, ps.Name, ps.Spec.FormatVersion, v1alpha1.PolicyStateFormatVersion)
+		return
+	}
 
-	// update PolicyState status field
-	ps.Status.Traffic = ps.Spec.Traffic
-	_, err = deliveryclient.Get(ctx).DeliveryV1alpha1().PolicyStates(cdr.Namespace).Update(ps)
-	if err != nil {
-		logger.Infof("Failed to update PolicyState")
+	// continue the reconciler's stage transition trace, so the Route mutation
+	// shows up as a child span of the decision that produced it
+	if sc, ok := tracing.Extract(ps); ok {
+		var span *trace.Span
+		_, span = trace.StartSpanWithRemoteParent(ctx, "kcd.route-mutation", sc)
+		defer span.End()
 	}
+
+	// admission only computes the Route spec; the status mirror of the
+	// enforced split is written by the reconciler, so admission latency never
+	// depends on an API write and a failed write cannot pass silently here
+	cdr.copyRouteSpec(ps)
+}
+
+// fetchPolicyStateDirect reads the Route's PolicyState from the API server,
+// retrying transient errors in place; the webhook cannot requeue an admission
+// request, so a short in-place backoff is the only retry it gets
+func (cdr *ContinuousDeploymentRoute) fetchPolicyStateDirect(ctx context.Context) (*v1alpha1.PolicyState, error) {
+	var ps *v1alpha1.PolicyState
+	client := deliveryclient.Get(ctx)
+	err := retry.OnError(retry.DefaultBackoff, retriableReadError, func() error {
+		var err error
+		ps, err = client.DeliveryV1alpha1().PolicyStates(cdr.Namespace).Get(cdr.Name, metav1.GetOptions{})
+		return err
+	})
+	return ps, err
+}
+
+// retriableReadError reports whether a read failure is worth retrying in
+// place: transient API server hiccups resolve on their own, while a NotFound
+// is a real answer and anything else needs the caller's attention
+func retriableReadError(err error) bool {
+	return apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) || apierrs.IsTooManyRequests(err) ||
+		apierrs.IsServiceUnavailable(err) || apierrs.IsInternalError(err)
 }
 
 func (cdr *ContinuousDeploymentRoute) copyRouteSpec(ps *v1alpha1.PolicyState) {
+	// rebuild every target through the shared constructors, so the webhook and
+	// the reconciler stay on one code path for Serving's defaulting rules
+	traffic := make([]servingv1.TrafficTarget, len(ps.Spec.Traffic))
+	for i, t := range ps.Spec.Traffic {
+		traffic[i] = traffictarget.Normalize(t)
+	}
 	cdr.Spec = servingv1.RouteSpec{
-		Traffic: ps.Spec.Traffic,
+		Traffic: traffic,
 	}
 }
 
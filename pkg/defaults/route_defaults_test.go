@@ -16,8 +16,11 @@ package defaults
 
 import (
 	"context"
+	"errors"
 	"testing"
 
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
@@ -101,3 +104,23 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestRetriableReadError(t *testing.T) {
+	gr := v1.Resource("routes")
+	retriable := []error{
+		apierrs.NewServerTimeout(gr, "get", 1),
+		apierrs.NewTimeoutError("timeout", 1),
+		apierrs.NewTooManyRequests("slow down", 1),
+		apierrs.NewServiceUnavailable("down"),
+		apierrs.NewInternalError(errors.New("boom")),
+	}
+	for _, err := range retriable {
+		if !retriableReadError(err) {
+			t.Errorf("retriableReadError(%v) = false, want true", err)
+		}
+	}
+	// a NotFound is a real answer, not a hiccup
+	if retriableReadError(apierrs.NewNotFound(gr, "test")) {
+		t.Error("retriableReadError(NotFound) = true, want false")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defaults
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/logging"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ContinuousDeliveryService is a wrapper around Service that defaults the
+// rollout policy annotation from the Service's Namespace, so a team can opt a
+// whole namespace into progressive delivery without annotating every Service
+type ContinuousDeliveryService struct {
+	servingv1.Service `json:",inline"`
+}
+
+var (
+	// Check that the wrapper service can be defaulted.
+	_ apis.Defaultable = (*ContinuousDeliveryService)(nil)
+	_ apis.Validatable = (*ContinuousDeliveryService)(nil)
+)
+
+// SetDefaults implements apis.Defaultable
+func (cds *ContinuousDeliveryService) SetDefaults(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+	// only creations inherit the namespace default; adding the annotation to a
+	// Service that has lived without it would start a rollout the user never
+	// asked for
+	if apis.IsInUpdate(ctx) {
+		return
+	}
+	// an explicit policy choice on the Service always wins over the namespace
+	if cds.Annotations[delivery.PolicyNameKey] != "" {
+		return
+	}
+	if delivery.Disabled(cds.Annotations) {
+		logger.Infof("Continuous delivery is disabled for %s/%s, leaving the Service unchanged", cds.Namespace, cds.Name)
+		return
+	}
+	if cfg := config.FromContext(ctx); cfg != nil && !cfg.Delivery.NamespaceAllowed(cds.Namespace) {
+		logger.Infof("Namespace %s is outside the configured scope, leaving the Service unchanged", cds.Namespace)
+		return
+	}
+	ns, err := kubeclient.Get(ctx).CoreV1().Namespaces().Get(cds.Namespace, metav1.GetOptions{})
+	if err != nil {
+		logger.Infof("Failed to read namespace %s, skipping policy defaulting: %v", cds.Namespace, err)
+		return
+	}
+	policy := ns.Annotations[delivery.DefaultPolicyKey]
+	if policy == "" {
+		return
+	}
+	if cds.Annotations == nil {
+		cds.Annotations = map[string]string{}
+	}
+	cds.Annotations[delivery.PolicyNameKey] = policy
+	logger.Infof("Defaulted Service %s/%s to the namespace policy %q", cds.Namespace, cds.Name, policy)
+}
+
+// Validate returns nil due to no need for validation
+func (cds *ContinuousDeliveryService) Validate(ctx context.Context) *apis.FieldError {
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package defaults
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"knative.dev/pkg/apis"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+func TestServiceSetDefaults(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "default",
+			Annotations: map[string]string{delivery.DefaultPolicyKey: "team-policy"},
+		},
+	}
+	bare := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "bare"},
+	}
+
+	service := func(ns string, annotations map[string]string) *ContinuousDeliveryService {
+		return &ContinuousDeliveryService{servingv1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Namespace:   ns,
+				Annotations: annotations,
+			},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		in         *ContinuousDeliveryService
+		inUpdate   bool
+		wantPolicy string
+	}{{
+		name:       "new Service inherits the namespace default policy",
+		in:         service("default", nil),
+		wantPolicy: "team-policy",
+	}, {
+		name:       "an explicit policy on the Service wins",
+		in:         service("default", map[string]string{delivery.PolicyNameKey: "mine"}),
+		wantPolicy: "mine",
+	}, {
+		name:       "opted-out Service is left alone",
+		in:         service("default", map[string]string{delivery.EnabledKey: "false"}),
+		wantPolicy: "",
+	}, {
+		name:       "namespace without a default policy",
+		in:         service("bare", nil),
+		wantPolicy: "",
+	}, {
+		name:       "updates never inject the annotation",
+		in:         service("default", nil),
+		inUpdate:   true,
+		wantPolicy: "",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := kubefake.NewSimpleClientset(namespace, bare)
+			ctx := context.WithValue(context.Background(), kubeclient.Key{}, client)
+			if test.inUpdate {
+				ctx = apis.WithinUpdate(ctx, test.in.DeepCopy())
+			}
+			test.in.SetDefaults(ctx)
+			if got := test.in.Annotations[delivery.PolicyNameKey]; got != test.wantPolicy {
+				t.Errorf("policy annotation = %q, want %q", got, test.wantPolicy)
+			}
+		})
+	}
+}
@@ -22,6 +22,31 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContinuousDeliveryService) DeepCopyInto(out *ContinuousDeliveryService) {
+	*out = *in
+	in.Service.DeepCopyInto(&out.Service)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContinuousDeliveryService.
+func (in *ContinuousDeliveryService) DeepCopy() *ContinuousDeliveryService {
+	if in == nil {
+		return nil
+	}
+	out := new(ContinuousDeliveryService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ContinuousDeliveryService) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ContinuousDeploymentRoute) DeepCopyInto(out *ContinuousDeploymentRoute) {
 	*out = *in
@@ -0,0 +1,192 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package explain renders a human-readable account of a Configuration's
+// rollout: the policy in effect, the current stage, why the percentages are
+// what they are, and when the next change happens. It works entirely from the
+// status data the reconciler already records, so it never guesses.
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// Report collects everything needed to explain one Configuration's rollout;
+// the caller fetches the objects, Render only reads them
+type Report struct {
+	Config      *servingv1.Configuration
+	Route       *servingv1.Route
+	PolicyState *v1alpha1.PolicyState
+	Policy      *v1alpha1.Policy // nil when the winning policy is not retrievable
+	Revisions   []*servingv1.Revision
+	Now         time.Time
+}
+
+// Render writes the explanation to w
+func (r *Report) Render(w io.Writer) {
+	fmt.Fprintf(w, "Configuration: %s/%s\n", r.Config.Namespace, r.Config.Name)
+	phase := r.PolicyState.Status.Phase
+	if phase == "" {
+		phase = "Unknown"
+	}
+	fmt.Fprintf(w, "Phase:         %s\n", phase)
+	if name := r.PolicyState.Status.PolicyName; name != "" {
+		fmt.Fprintf(w, "Policy:        %s (%s)\n", name, r.PolicyState.Status.PolicySource)
+	}
+	if st := r.rolloutStatus(); st != nil && st.Stage != "" {
+		fmt.Fprintf(w, "Stage:         %s (candidate at %d%%)\n", st.Stage, st.Percent)
+	}
+	if ts := r.PolicyState.Status.NextUpdateTimestamp; ts != nil {
+		fmt.Fprintf(w, "Next change:   %s (in %s)\n",
+			ts.Time.UTC().Format(time.RFC3339), ts.Time.Sub(r.Now).Round(time.Second))
+	} else {
+		fmt.Fprintf(w, "Next change:   none scheduled\n")
+	}
+
+	fmt.Fprintf(w, "\nTraffic:\n")
+	for _, t := range r.Route.Spec.Traffic {
+		percent := int64(0)
+		if t.Percent != nil {
+			percent = *t.Percent
+		}
+		switch {
+		case t.LatestRevision != nil && *t.LatestRevision:
+			fmt.Fprintf(w, "  latest ready Revision  %3d%% (stable, rollout finished)\n", percent)
+		case t.RevisionName == r.Config.Status.LatestReadyRevisionName:
+			fmt.Fprintf(w, "  %s  %3d%% (candidate%s)\n", t.RevisionName, percent, r.readyFor(t.RevisionName))
+		default:
+			fmt.Fprintf(w, "  %s  %3d%%%s\n", t.RevisionName, percent, r.readyFor(t.RevisionName))
+		}
+	}
+
+	r.renderSchedule(w)
+}
+
+// renderSchedule explains the stage arithmetic: the cumulative seconds each
+// percentage unlocks at, and how much counted time the candidate has
+func (r *Report) renderSchedule(w io.Writer) {
+	spec := r.effectiveSpec()
+	if spec == nil {
+		return
+	}
+	fmt.Fprintf(w, "\nSchedule (cumulative seconds of counted time):\n")
+	factor := spec.ThresholdUnitFactor()
+	cumulative := 0
+	for i, s := range spec.Stages {
+		// the first stage starts immediately unless it is a dark launch with
+		// its own soak period; later stages add their explicit duration or
+		// threshold (or the default) on top of everything before them
+		if i > 0 || (s.Percent == 0 && (s.Threshold != nil || s.After != nil)) {
+			switch {
+			case s.After != nil:
+				cumulative += int(s.After.Duration / time.Second)
+			case s.Threshold != nil:
+				cumulative += *s.Threshold * factor
+			case i > 0:
+				cumulative += spec.DefaultThreshold * factor
+			}
+		}
+		fmt.Fprintf(w, "  %3d%% from %ds\n", s.Percent, cumulative)
+	}
+	last := len(spec.Stages) - 1
+	if last < 0 || spec.Stages[last].Percent != 0 || spec.Stages[last].Threshold != nil || spec.Stages[last].After != nil {
+		if last >= 0 {
+			cumulative += spec.DefaultThreshold * factor
+		}
+		fmt.Fprintf(w, "  100%% from %ds\n", cumulative)
+	}
+	if rev := r.revision(r.Config.Status.LatestReadyRevisionName); rev != nil {
+		if t := readyTime(rev); !t.IsZero() {
+			paused := time.Duration(r.PolicyState.Status.PauseOffsetSeconds) * time.Second
+			counted := r.Now.Sub(t) - paused
+			fmt.Fprintf(w, "Candidate %s has %s of counted time (%s paused)\n",
+				rev.Name, counted.Round(time.Second), paused)
+		}
+	}
+}
+
+// effectiveSpec returns the PolicySpec the rollout actually follows: the
+// pinned or adopted snapshot when one exists, the live Policy otherwise
+func (r *Report) effectiveSpec() *v1alpha1.PolicySpec {
+	if s := r.PolicyState.Spec.PinnedPolicy; s != nil {
+		return s
+	}
+	if s := r.PolicyState.Spec.AdoptedPolicy; s != nil {
+		return s
+	}
+	if r.Policy != nil {
+		return &r.Policy.Spec
+	}
+	return nil
+}
+
+// rolloutStatus parses the rollout-status annotation the reconciler keeps on
+// the Configuration; nil when the annotation is absent or unreadable
+func (r *Report) rolloutStatus() *struct {
+	Stage   string `json:"stage"`
+	Percent int    `json:"percent"`
+} {
+	raw, ok := r.Config.Annotations[delivery.RolloutStatusKey]
+	if !ok {
+		return nil
+	}
+	st := &struct {
+		Stage   string `json:"stage"`
+		Percent int    `json:"percent"`
+	}{}
+	if err := json.Unmarshal([]byte(raw), st); err != nil {
+		return nil
+	}
+	return st
+}
+
+// readyFor renders how long the named Revision has been ready, or "" when that
+// cannot be determined
+func (r *Report) readyFor(name string) string {
+	rev := r.revision(name)
+	if rev == nil {
+		return ""
+	}
+	t := readyTime(rev)
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf(", ready for %s", r.Now.Sub(t).Round(time.Second))
+}
+
+func (r *Report) revision(name string) *servingv1.Revision {
+	for _, rev := range r.Revisions {
+		if rev.Name == name {
+			return rev
+		}
+	}
+	return nil
+}
+
+// readyTime returns when the Revision last became Ready, or the zero time
+func readyTime(rev *servingv1.Revision) time.Time {
+	cond := rev.Status.GetCondition(servingv1.RevisionConditionReady)
+	if cond == nil || cond.Status != "True" {
+		return time.Time{}
+	}
+	return cond.LastTransitionTime.Inner.Time
+}
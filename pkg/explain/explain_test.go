@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"knative.dev/pkg/ptr"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	next := metav1.NewTime(now.Add(80 * time.Second))
+	readyAt := apis.VolatileTime{Inner: metav1.NewTime(now.Add(-40 * time.Second))}
+
+	report := &Report{
+		Config: &servingv1.Configuration{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "hello",
+				Annotations: map[string]string{
+					delivery.RolloutStatusKey: `{"stage":"2/4","percent":10}`,
+				},
+			},
+			Status: servingv1.ConfigurationStatus{
+				ConfigurationStatusFields: servingv1.ConfigurationStatusFields{LatestReadyRevisionName: "hello-00002"},
+			},
+		},
+		Route: &servingv1.Route{
+			Spec: servingv1.RouteSpec{Traffic: []servingv1.TrafficTarget{
+				{RevisionName: "hello-00001", Percent: ptr.Int64(90), LatestRevision: ptr.Bool(false)},
+				{RevisionName: "hello-00002", Percent: ptr.Int64(10), LatestRevision: ptr.Bool(false)},
+			}},
+		},
+		PolicyState: &v1alpha1.PolicyState{
+			Status: v1alpha1.PolicyStateStatus{PolicyStateStatusFields: v1alpha1.PolicyStateStatusFields{
+				Phase:               v1alpha1.PhaseProgressing,
+				PolicyName:          "default/canary",
+				PolicySource:        "annotation",
+				NextUpdateTimestamp: &next,
+				PauseOffsetSeconds:  5,
+			}},
+		},
+		Policy: &v1alpha1.Policy{Spec: v1alpha1.PolicySpec{
+			Mode:             "time",
+			DefaultThreshold: 60,
+			Stages:           []v1alpha1.Stage{{Percent: 0}, {Percent: 10}, {Percent: 50}},
+		}},
+		Revisions: []*servingv1.Revision{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "hello-00002"},
+			Status: servingv1.RevisionStatus{Status: duckv1.Status{Conditions: duckv1.Conditions{{
+				Type:               apis.ConditionType(servingv1.RevisionConditionReady),
+				Status:             "True",
+				LastTransitionTime: readyAt,
+			}}}},
+		}},
+		Now: now,
+	}
+
+	var b strings.Builder
+	report.Render(&b)
+	out := b.String()
+
+	for _, want := range []string{
+		"Configuration: default/hello",
+		"Phase:         Progressing",
+		"Policy:        default/canary (annotation)",
+		"Stage:         2/4 (candidate at 10%)",
+		"Next change:   2020-07-01T12:01:20Z (in 1m20s)",
+		"hello-00002   10% (candidate, ready for 40s)",
+		"hello-00001   90%",
+		"  10% from 60s",
+		" 50% from 120s",
+		"100% from 180s",
+		"Candidate hello-00002 has 35s of counted time (5s paused)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Render output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestEffectiveSpec(t *testing.T) {
+	pinned := &v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 10}
+	live := &v1alpha1.Policy{Spec: v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 20}}
+
+	r := &Report{PolicyState: &v1alpha1.PolicyState{}, Policy: live}
+	if got := r.effectiveSpec(); got.DefaultThreshold != 20 {
+		t.Errorf("expected the live Policy spec, got %v", got)
+	}
+	r.PolicyState.Spec.PinnedPolicy = pinned
+	if got := r.effectiveSpec(); got.DefaultThreshold != 10 {
+		t.Errorf("expected the pinned snapshot, got %v", got)
+	}
+	r = &Report{PolicyState: &v1alpha1.PolicyState{}}
+	if got := r.effectiveSpec(); got != nil {
+		t.Errorf("expected nil without any policy, got %v", got)
+	}
+}
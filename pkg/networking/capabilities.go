@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package networking detects which networking layer backs Knative on this cluster
+// and exposes the traffic features that the layer supports, so that Policies asking
+// for unsupported features can be rejected up front instead of silently no-oping
+package networking
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/serving/pkg/network"
+)
+
+// Capability names a single traffic feature of the underlying networking layer
+type Capability string
+
+const (
+	// CapabilityMirroring is the ability to shadow traffic to a second Revision
+	CapabilityMirroring Capability = "mirroring"
+	// CapabilityHeaderRouting is the ability to route requests on header/cookie values
+	CapabilityHeaderRouting Capability = "header-routing"
+	// CapabilitySessionAffinity is the ability to pin a client to one Revision
+	CapabilitySessionAffinity Capability = "session-affinity"
+)
+
+// known ingress class names; Istio's is exported by knative.dev/serving already
+const (
+	KourierIngressClassName = "kourier.ingress.networking.knative.dev"
+	ContourIngressClassName = "contour.ingress.networking.knative.dev"
+)
+
+// Capabilities is the set of traffic features supported by a networking layer
+type Capabilities map[Capability]bool
+
+// Supports determines whether the capability set includes the given feature
+func (c Capabilities) Supports(feature Capability) bool {
+	return c[feature]
+}
+
+// ForIngressClass returns the capability set of a known ingress class
+// unrecognized classes are assumed to support only plain percentage splitting
+func ForIngressClass(class string) Capabilities {
+	switch class {
+	case network.IstioIngressClassName:
+		return Capabilities{
+			CapabilityMirroring:       true,
+			CapabilityHeaderRouting:   true,
+			CapabilitySessionAffinity: true,
+		}
+	case ContourIngressClassName:
+		return Capabilities{
+			CapabilityHeaderRouting: true,
+		}
+	case KourierIngressClassName:
+		return Capabilities{}
+	default:
+		return Capabilities{}
+	}
+}
+
+// FromConfigMap detects the ingress class from the config-network ConfigMap
+// and returns the matching capability set
+func FromConfigMap(configMap *corev1.ConfigMap) Capabilities {
+	return ForIngressClass(configMap.Data[network.DefaultIngressClassKey])
+}
+
+type capsKey struct{}
+
+// WithCapabilities attaches a capability set to the context
+func WithCapabilities(ctx context.Context, c Capabilities) context.Context {
+	return context.WithValue(ctx, capsKey{}, c)
+}
+
+// CapabilitiesFromContext extracts the capability set from the context
+// it returns nil when detection never ran, in which case callers should
+// skip capability checks rather than reject everything
+func CapabilitiesFromContext(ctx context.Context) Capabilities {
+	if c, ok := ctx.Value(capsKey{}).(Capabilities); ok {
+		return c
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networking
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/serving/pkg/network"
+)
+
+func TestForIngressClass(t *testing.T) {
+	var tests = []struct {
+		name    string
+		class   string
+		feature Capability
+		want    bool
+	}{
+		{name: "istio supports mirroring", class: network.IstioIngressClassName, feature: CapabilityMirroring, want: true},
+		{name: "istio supports header routing", class: network.IstioIngressClassName, feature: CapabilityHeaderRouting, want: true},
+		{name: "contour supports header routing", class: ContourIngressClassName, feature: CapabilityHeaderRouting, want: true},
+		{name: "contour does not support mirroring", class: ContourIngressClassName, feature: CapabilityMirroring, want: false},
+		{name: "kourier supports nothing extra", class: KourierIngressClassName, feature: CapabilitySessionAffinity, want: false},
+		{name: "unknown class supports nothing extra", class: "mystery.ingress.example.com", feature: CapabilityMirroring, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ForIngressClass(tt.class).Supports(tt.feature); got != tt.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromConfigMap(t *testing.T) {
+	cm := &corev1.ConfigMap{
+		Data: map[string]string{
+			network.DefaultIngressClassKey: network.IstioIngressClassName,
+		},
+	}
+	if !FromConfigMap(cm).Supports(CapabilityMirroring) {
+		t.Errorf("expected istio-backed cluster to support mirroring")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networking
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// Like the mirror annotations, the header-route annotations are the contract
+// between KCD and the ingress layer: the reconciler records which header or
+// cookie value should send a request to the candidate, and an ingress-specific
+// translator renders the matching routing rule
+const (
+	// HeaderRouteHeaderAnnotationKey names the request header to match
+	HeaderRouteHeaderAnnotationKey = delivery.GroupName + "/route-header"
+
+	// HeaderRouteCookieAnnotationKey names the cookie to match instead of a header
+	HeaderRouteCookieAnnotationKey = delivery.GroupName + "/route-cookie"
+
+	// HeaderRouteValueAnnotationKey is the exact value the header or cookie must carry
+	HeaderRouteValueAnnotationKey = delivery.GroupName + "/route-value"
+
+	// HeaderRouteRevisionAnnotationKey names the Revision that matching requests go to
+	HeaderRouteRevisionAnnotationKey = delivery.GroupName + "/route-revision"
+)
+
+// HeaderRoute describes the desired attribute-based routing rule for a Route:
+// requests whose Header (or Cookie) carries Value are sent to RevisionName
+// regardless of the percentage split
+type HeaderRoute struct {
+	Header       string
+	Cookie       string
+	Value        string
+	RevisionName string
+}
+
+// SetHeaderRoute records the header-routing rule in the object's annotations
+func SetHeaderRoute(obj metav1.Object, route HeaderRoute) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	delete(annotations, HeaderRouteHeaderAnnotationKey)
+	delete(annotations, HeaderRouteCookieAnnotationKey)
+	if route.Header != "" {
+		annotations[HeaderRouteHeaderAnnotationKey] = route.Header
+	} else {
+		annotations[HeaderRouteCookieAnnotationKey] = route.Cookie
+	}
+	annotations[HeaderRouteValueAnnotationKey] = route.Value
+	annotations[HeaderRouteRevisionAnnotationKey] = route.RevisionName
+	obj.SetAnnotations(annotations)
+}
+
+// ClearHeaderRoute removes any header-routing rule from the object's annotations
+func ClearHeaderRoute(obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	delete(annotations, HeaderRouteHeaderAnnotationKey)
+	delete(annotations, HeaderRouteCookieAnnotationKey)
+	delete(annotations, HeaderRouteValueAnnotationKey)
+	delete(annotations, HeaderRouteRevisionAnnotationKey)
+	obj.SetAnnotations(annotations)
+}
+
+// GetHeaderRoute extracts the header-routing rule from the object's annotations
+// it returns nil when no well-formed rule is recorded
+func GetHeaderRoute(obj metav1.Object) *HeaderRoute {
+	annotations := obj.GetAnnotations()
+	name, ok := annotations[HeaderRouteRevisionAnnotationKey]
+	if !ok || name == "" {
+		return nil
+	}
+	route := &HeaderRoute{
+		Header:       annotations[HeaderRouteHeaderAnnotationKey],
+		Cookie:       annotations[HeaderRouteCookieAnnotationKey],
+		Value:        annotations[HeaderRouteValueAnnotationKey],
+		RevisionName: name,
+	}
+	if route.Header == "" && route.Cookie == "" {
+		return nil
+	}
+	return route
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networking
+
+import (
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// The mirror annotations are the contract between KCD and the ingress layer:
+// the reconciler records the desired shadow-traffic configuration on the Route,
+// and an ingress-specific translator (e.g. one that renders Istio VirtualService
+// mirror rules) turns the annotations into actual request duplication
+const (
+	// MirrorRevisionAnnotationKey names the Revision that mirrored requests are sent to
+	MirrorRevisionAnnotationKey = delivery.GroupName + "/mirror-revision"
+
+	// MirrorPercentAnnotationKey is the percentage of live requests to duplicate
+	MirrorPercentAnnotationKey = delivery.GroupName + "/mirror-percent"
+)
+
+// MirrorTarget describes the desired shadow-traffic configuration for a Route:
+// Percent of live requests are duplicated to RevisionName, and the duplicated
+// responses are discarded without affecting the originals
+type MirrorTarget struct {
+	RevisionName string
+	Percent      int
+}
+
+// SetMirror records the mirror target in the object's annotations
+func SetMirror(obj metav1.Object, target MirrorTarget) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[MirrorRevisionAnnotationKey] = target.RevisionName
+	annotations[MirrorPercentAnnotationKey] = strconv.Itoa(target.Percent)
+	obj.SetAnnotations(annotations)
+}
+
+// ClearMirror removes any mirror target from the object's annotations
+func ClearMirror(obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	delete(annotations, MirrorRevisionAnnotationKey)
+	delete(annotations, MirrorPercentAnnotationKey)
+	obj.SetAnnotations(annotations)
+}
+
+// GetMirror extracts the mirror target from the object's annotations
+// it returns nil when no well-formed mirror target is recorded
+func GetMirror(obj metav1.Object) *MirrorTarget {
+	annotations := obj.GetAnnotations()
+	name, ok := annotations[MirrorRevisionAnnotationKey]
+	if !ok || name == "" {
+		return nil
+	}
+	percent, err := strconv.Atoi(annotations[MirrorPercentAnnotationKey])
+	if err != nil {
+		return nil
+	}
+	return &MirrorTarget{RevisionName: name, Percent: percent}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/apis"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	policyinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policy"
+	listers "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GuardedConfiguration wraps Configuration so that the validation webhook can
+// reject policy annotations that name Policies that do not exist, instead of
+// letting the reconciler discover the dangling reference later
+type GuardedConfiguration struct {
+	servingv1.Configuration `json:",inline"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GuardedService wraps Service for the same annotation check as
+// GuardedConfiguration; the annotation propagates from the Service down to its
+// Configuration, so catching it here gives the earliest possible feedback
+type GuardedService struct {
+	servingv1.Service `json:",inline"`
+}
+
+var (
+	_ apis.Defaultable = (*GuardedConfiguration)(nil)
+	_ apis.Validatable = (*GuardedConfiguration)(nil)
+	_ apis.Defaultable = (*GuardedService)(nil)
+	_ apis.Validatable = (*GuardedService)(nil)
+)
+
+// SetDefaults implements apis.Defaultable; the wrapper only validates
+func (gc *GuardedConfiguration) SetDefaults(ctx context.Context) {}
+
+// Validate implements apis.Validatable
+func (gc *GuardedConfiguration) Validate(ctx context.Context) *apis.FieldError {
+	return missingPolicy(policyinformer.Get(ctx).Lister(), gc.Namespace, gc.Annotations).
+		Also(unsupportedPolicyMode(policyinformer.Get(ctx).Lister(), gc.Namespace, gc.Annotations)).
+		Also(invalidOnFailure(gc.Annotations)).
+		Also(invalidOverrides(gc.Annotations)).
+		Also(invalidStartAt(gc.Annotations)).
+		Also(conflictingRolloutDuration(gc.Annotations))
+}
+
+// SetDefaults implements apis.Defaultable; the wrapper only validates
+func (gs *GuardedService) SetDefaults(ctx context.Context) {}
+
+// Validate implements apis.Validatable
+func (gs *GuardedService) Validate(ctx context.Context) *apis.FieldError {
+	return missingPolicy(policyinformer.Get(ctx).Lister(), gs.Namespace, gs.Annotations).
+		Also(unsupportedPolicyMode(policyinformer.Get(ctx).Lister(), gs.Namespace, gs.Annotations)).
+		Also(invalidOnFailure(gs.Annotations)).
+		Also(invalidOverrides(gs.Annotations)).
+		Also(invalidStartAt(gs.Annotations)).
+		Also(conflictingRolloutDuration(gs.Annotations))
+}
+
+// missingPolicy returns a FieldError when the policy annotation names a Policy
+// that does not exist; annotation values follow the same "name" or
+// "namespace/name" convention that the reconciler uses to look Policies up
+func missingPolicy(lister listers.PolicyLister, namespace string, annotations map[string]string) *apis.FieldError {
+	name, ok := annotations[delivery.PolicyNameKey]
+	if !ok {
+		return nil
+	}
+	// ConfigMap-backed policy documents are parsed and validated by the
+	// controller; there is no Policy resource to look up here
+	if strings.HasPrefix(name, delivery.PolicyConfigMapPrefix) {
+		return nil
+	}
+	policyNamespace := namespace
+	if s := strings.SplitN(name, "/", 2); len(s) > 1 {
+		policyNamespace = s[0]
+		name = s[1]
+	}
+	if _, err := lister.Policies(policyNamespace).Get(name); apierrs.IsNotFound(err) {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("no Policy named %q exists in namespace %q", name, policyNamespace),
+			Paths:   []string{"metadata.annotations." + delivery.PolicyNameKey},
+		}
+	}
+	return nil
+}
+
+// unsupportedPolicyMode returns a FieldError when the policy annotation names a
+// Policy whose mode this controller build cannot drive; such Policies can
+// predate mode validation or come from a newer build, and without this check
+// the reference is only discovered when the rollout silently fails to advance
+func unsupportedPolicyMode(lister listers.PolicyLister, namespace string, annotations map[string]string) *apis.FieldError {
+	name, ok := annotations[delivery.PolicyNameKey]
+	if !ok || strings.HasPrefix(name, delivery.PolicyConfigMapPrefix) {
+		return nil
+	}
+	policyNamespace := namespace
+	if s := strings.SplitN(name, "/", 2); len(s) > 1 {
+		policyNamespace = s[0]
+		name = s[1]
+	}
+	// a dangling reference is missingPolicy's finding, not this check's
+	p, err := lister.Policies(policyNamespace).Get(name)
+	if err != nil {
+		return nil
+	}
+	if !v1alpha1.SupportedMode(p.Spec.Mode) {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("Policy %s/%s has mode %q, which this controller does not support", policyNamespace, name, p.Spec.Mode),
+			Paths:   []string{"metadata.annotations." + delivery.PolicyNameKey},
+		}
+	}
+	return nil
+}
+
+// invalidOnFailure returns a FieldError when the per-Configuration failure
+// response annotation is present but not one of the recognized values
+func invalidOnFailure(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[delivery.OnFailureKey]
+	if !ok || v1alpha1.ValidFailureResponse(v) {
+		return nil
+	}
+	return apis.ErrInvalidValue(v, "metadata.annotations."+delivery.OnFailureKey)
+}
+
+// invalidStartAt returns a FieldError when the scheduled-start annotation is
+// present but not an RFC 3339 timestamp; the reconciler treats an unparseable
+// schedule as no schedule, so this is the only place a typo gets caught
+func invalidStartAt(annotations map[string]string) *apis.FieldError {
+	v, ok := annotations[delivery.StartAtKey]
+	if !ok {
+		return nil
+	}
+	if _, perr := time.Parse(time.RFC3339, v); perr != nil {
+		return apis.ErrInvalidValue(v, "metadata.annotations."+delivery.StartAtKey)
+	}
+	return nil
+}
+
+// conflictingRolloutDuration returns a FieldError when a workload asks both
+// for a continuous-delivery Policy and for Knative Serving's native
+// rollout-duration feature; the two mechanisms rewrite the same traffic
+// targets and oscillate if both run, so the combination is refused outright
+// a workload that opts out of continuous delivery may use the native feature
+func conflictingRolloutDuration(annotations map[string]string) *apis.FieldError {
+	if _, ok := annotations[delivery.ServingRolloutDurationKey]; !ok {
+		return nil
+	}
+	if _, ok := annotations[delivery.PolicyNameKey]; !ok || delivery.Disabled(annotations) {
+		return nil
+	}
+	return &apis.FieldError{
+		Message: fmt.Sprintf("%s cannot be combined with a continuous-delivery Policy; remove one of the two annotations", delivery.ServingRolloutDurationKey),
+		Paths: []string{
+			"metadata.annotations." + delivery.ServingRolloutDurationKey,
+			"metadata.annotations." + delivery.PolicyNameKey,
+		},
+	}
+}
+
+// invalidOverrides returns a FieldError when a per-Configuration rollout
+// override annotation is present but does not parse; rejecting it here means
+// the reconciler only ever sees override values it can act on
+func invalidOverrides(annotations map[string]string) *apis.FieldError {
+	var err *apis.FieldError
+	if v, ok := annotations[delivery.OverrideThresholdKey]; ok {
+		if _, perr := delivery.ParseOverrideThreshold(v); perr != nil {
+			err = err.Also(apis.ErrInvalidValue(v, "metadata.annotations."+delivery.OverrideThresholdKey))
+		}
+	}
+	if v, ok := annotations[delivery.OverrideStagesKey]; ok {
+		if _, perr := delivery.ParseOverrideStages(v); perr != nil {
+			err = err.Also(apis.ErrInvalidValue(v, "metadata.annotations."+delivery.OverrideStagesKey))
+		}
+	}
+	return err
+}
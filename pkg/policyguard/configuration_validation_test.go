@@ -0,0 +1,216 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"testing"
+
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	listers "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMissingPolicy(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(&v1alpha1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "default"}})
+	indexer.Add(&v1alpha1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "platform"}})
+	lister := listers.NewPolicyLister(indexer)
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name:      "no annotation passes",
+		namespace: "default",
+	}, {
+		name:        "existing Policy in same namespace passes",
+		namespace:   "default",
+		annotations: map[string]string{delivery.PolicyNameKey: "canary"},
+	}, {
+		name:        "existing Policy in explicit namespace passes",
+		namespace:   "default",
+		annotations: map[string]string{delivery.PolicyNameKey: "platform/shared"},
+	}, {
+		name:        "unknown Policy name is rejected",
+		namespace:   "default",
+		annotations: map[string]string{delivery.PolicyNameKey: "no-such-policy"},
+		wantErr:     true,
+	}, {
+		name:        "existing name in the wrong namespace is rejected",
+		namespace:   "default",
+		annotations: map[string]string{delivery.PolicyNameKey: "other/canary"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := missingPolicy(lister, test.namespace, test.annotations)
+			if gotErr := got != nil; gotErr != test.wantErr {
+				t.Errorf("missingPolicy() = %v, wantErr: %v", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnsupportedPolicyMode(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(&v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "canary", Namespace: "default"},
+		Spec:       v1alpha1.PolicySpec{Mode: "time"},
+	})
+	indexer.Add(&v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "errors", Namespace: "default"},
+		Spec:       v1alpha1.PolicySpec{Mode: "error"},
+	})
+	lister := listers.NewPolicyLister(indexer)
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name: "no annotation passes",
+	}, {
+		name:        "time-based Policy passes",
+		annotations: map[string]string{delivery.PolicyNameKey: "canary"},
+	}, {
+		name:        "unsupported mode is rejected",
+		annotations: map[string]string{delivery.PolicyNameKey: "errors"},
+		wantErr:     true,
+	}, {
+		name:        "dangling reference is left to missingPolicy",
+		annotations: map[string]string{delivery.PolicyNameKey: "no-such-policy"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := unsupportedPolicyMode(lister, "default", test.annotations)
+			if gotErr := got != nil; gotErr != test.wantErr {
+				t.Errorf("unsupportedPolicyMode() = %v, wantErr: %v", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvalidOnFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name: "no annotation passes",
+	}, {
+		name:        "recognized value passes",
+		annotations: map[string]string{delivery.OnFailureKey: v1alpha1.FailureHold},
+	}, {
+		name:        "unknown value is rejected",
+		annotations: map[string]string{delivery.OnFailureKey: "retry"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := invalidOnFailure(test.annotations)
+			if gotErr := got != nil; gotErr != test.wantErr {
+				t.Errorf("invalidOnFailure() = %v, wantErr: %v", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestInvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name: "no annotation passes",
+	}, {
+		name: "well-formed overrides pass",
+		annotations: map[string]string{
+			delivery.OverrideThresholdKey: "30",
+			delivery.OverrideStagesKey:    "5, 25, 50",
+		},
+	}, {
+		name:        "non-numeric threshold is rejected",
+		annotations: map[string]string{delivery.OverrideThresholdKey: "fast"},
+		wantErr:     true,
+	}, {
+		name:        "non-positive threshold is rejected",
+		annotations: map[string]string{delivery.OverrideThresholdKey: "0"},
+		wantErr:     true,
+	}, {
+		name:        "non-increasing stages are rejected",
+		annotations: map[string]string{delivery.OverrideStagesKey: "50,25"},
+		wantErr:     true,
+	}, {
+		name:        "out-of-range stage is rejected",
+		annotations: map[string]string{delivery.OverrideStagesKey: "5,100"},
+		wantErr:     true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := invalidOverrides(test.annotations)
+			if gotErr := got != nil; gotErr != test.wantErr {
+				t.Errorf("invalidOverrides() = %v, wantErr: %v", got, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConflictingRolloutDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{{
+		name: "no annotation passes",
+	}, {
+		name:        "native rollout-duration alone passes",
+		annotations: map[string]string{delivery.ServingRolloutDurationKey: "380s"},
+	}, {
+		name:        "a Policy alone passes",
+		annotations: map[string]string{delivery.PolicyNameKey: "canary"},
+	}, {
+		name: "combining both is rejected",
+		annotations: map[string]string{
+			delivery.ServingRolloutDurationKey: "380s",
+			delivery.PolicyNameKey:             "canary",
+		},
+		wantErr: true,
+	}, {
+		name: "an opted-out workload may use the native feature",
+		annotations: map[string]string{
+			delivery.ServingRolloutDurationKey: "380s",
+			delivery.PolicyNameKey:             "canary",
+			delivery.EnabledKey:                "false",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := conflictingRolloutDuration(test.annotations)
+			if gotErr := got != nil; gotErr != test.wantErr {
+				t.Errorf("conflictingRolloutDuration() = %v, wantErr: %v", got, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policyguard blocks the deletion of Policies that live Configurations
+// still reference, so that rollouts don't end up pointing at a Policy that no
+// longer exists and erroring forever
+package policyguard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/webhook"
+	"knative.dev/pkg/webhook/resourcesemantics/validation"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	listers "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NewCallback builds the validation-webhook callback that rejects DELETE
+// requests for Policies that are still referenced by Configurations
+func NewCallback(lister listers.ConfigurationLister) validation.Callback {
+	return validation.NewCallback(func(ctx context.Context, u *unstructured.Unstructured) error {
+		p := &v1alpha1.Policy{}
+		raw, err := u.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, p); err != nil {
+			return err
+		}
+		cfgs, err := lister.Configurations(p.Namespace).List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		if refs := referencingConfigurations(p, cfgs); len(refs) > 0 {
+			return fmt.Errorf("Policy %s/%s is still referenced by Configurations: %s",
+				p.Namespace, p.Name, strings.Join(refs, ", "))
+		}
+		return nil
+	}, webhook.Delete)
+}
+
+// referencingConfigurations lists the names of Configurations that reference the
+// Policy, either explicitly through the policy annotation or implicitly by
+// matching its label selector; the result is sorted for deterministic messages
+func referencingConfigurations(p *v1alpha1.Policy, cfgs []*servingv1.Configuration) []string {
+	var selector labels.Selector
+	if p.Spec.Selector != nil {
+		if s, err := metav1.LabelSelectorAsSelector(p.Spec.Selector); err == nil {
+			selector = s
+		}
+	}
+	var refs []string
+	for _, cfg := range cfgs {
+		if name, ok := cfg.Annotations[delivery.PolicyNameKey]; ok {
+			if name == p.Name {
+				refs = append(refs, cfg.Name)
+			}
+			continue // an annotation overrides any selector match
+		}
+		if selector != nil && selector.Matches(labels.Set(cfg.Labels)) {
+			refs = append(refs, cfg.Name)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
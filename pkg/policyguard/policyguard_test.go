@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func guardConfig(name string, annotations, labels map[string]string) *servingv1.Configuration {
+	return &servingv1.Configuration{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        name,
+		Annotations: annotations,
+		Labels:      labels,
+	}}
+}
+
+func TestReferencingConfigurations(t *testing.T) {
+	annotated := guardConfig("annotated", map[string]string{delivery.PolicyNameKey: "canary"}, nil)
+	other := guardConfig("other", map[string]string{delivery.PolicyNameKey: "different"}, nil)
+	labelled := guardConfig("labelled", nil, map[string]string{"team": "search"})
+	plain := guardConfig("plain", nil, nil)
+
+	selectorPolicy := &v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary"},
+		Spec: v1alpha1.PolicySpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "search"}},
+		},
+	}
+	plainPolicy := &v1alpha1.Policy{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary"}}
+
+	var tests = []struct {
+		name   string
+		policy *v1alpha1.Policy
+		cfgs   []*servingv1.Configuration
+		want   []string
+	}{
+		{name: "annotation reference", policy: plainPolicy,
+			cfgs: []*servingv1.Configuration{annotated, other, plain}, want: []string{"annotated"}},
+		{name: "selector reference", policy: selectorPolicy,
+			cfgs: []*servingv1.Configuration{labelled, plain}, want: []string{"labelled"}},
+		{name: "annotation overrides selector", policy: selectorPolicy,
+			cfgs: []*servingv1.Configuration{guardConfig("both", map[string]string{delivery.PolicyNameKey: "different"}, map[string]string{"team": "search"})}},
+		{name: "no references", policy: plainPolicy,
+			cfgs: []*servingv1.Configuration{other, plain}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := referencingConfigurations(tt.policy, tt.cfgs)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("referencing Configurations are incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/system"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	revisioninformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/revision"
+	servinglisters "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// controllerServiceAccount is the ServiceAccount the KCD controller runs as;
+// it is the only identity allowed to write PolicyState specs
+const controllerServiceAccount = "controller"
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GuardedPolicyState wraps PolicyState so that the validation webhook can
+// reject traffic targets that name Revisions that do not exist, and spec
+// writes from anyone other than the controller; the defaulting webhook copies
+// Spec.Traffic into Routes verbatim, so a hand-written spec would otherwise
+// flow straight into user traffic
+type GuardedPolicyState struct {
+	v1alpha1.PolicyState `json:",inline"`
+}
+
+var (
+	_ apis.Defaultable = (*GuardedPolicyState)(nil)
+	_ apis.Validatable = (*GuardedPolicyState)(nil)
+)
+
+// SetDefaults implements apis.Defaultable
+func (gps *GuardedPolicyState) SetDefaults(ctx context.Context) {
+	gps.PolicyState.SetDefaults(ctx)
+}
+
+// Validate implements apis.Validatable
+func (gps *GuardedPolicyState) Validate(ctx context.Context) *apis.FieldError {
+	err := gps.PolicyState.Validate(ctx)
+	err = err.Also(unknownRevisions(revisioninformer.Get(ctx).Lister(), gps.Namespace, gps.Spec.Traffic))
+	return err.Also(specWriteRestricted(ctx, gps))
+}
+
+// unknownRevisions returns a FieldError for every pinned traffic target whose
+// Revision does not exist in the PolicyState's namespace; configurationName
+// targets are resolved by the Route itself and are left alone
+func unknownRevisions(lister servinglisters.RevisionLister, namespace string, traffic []servingv1.TrafficTarget) *apis.FieldError {
+	var err *apis.FieldError
+	for i, t := range traffic {
+		if t.RevisionName == "" {
+			continue
+		}
+		if _, getErr := lister.Revisions(namespace).Get(t.RevisionName); apierrs.IsNotFound(getErr) {
+			err = err.Also((&apis.FieldError{
+				Message: fmt.Sprintf("no Revision named %q exists in namespace %q", t.RevisionName, namespace),
+				Paths:   []string{"revisionName"},
+			}).ViaFieldIndex("spec.traffic", i))
+		}
+	}
+	return err
+}
+
+// specWriteRestricted rejects spec writes from anyone other than the
+// controller's ServiceAccount; requests without user info (unit tests, dry
+// runs through the client) and writes that leave the spec unchanged pass
+func specWriteRestricted(ctx context.Context, gps *GuardedPolicyState) *apis.FieldError {
+	ui := apis.GetUserInfo(ctx)
+	if ui == nil {
+		return nil
+	}
+	if apis.IsInUpdate(ctx) {
+		original, ok := apis.GetBaseline(ctx).(*GuardedPolicyState)
+		if ok && original != nil && equality.Semantic.DeepEqual(original.Spec, gps.Spec) {
+			return nil
+		}
+	} else if len(gps.Spec.Traffic) == 0 {
+		// creating an empty shell (the reconciler fills it in later) is harmless
+		return nil
+	}
+	if writtenByController(ui) {
+		return nil
+	}
+	return &apis.FieldError{
+		Message: fmt.Sprintf("PolicyState specs may only be written by %s, not %q", controllerUsername(), ui.Username),
+		Paths:   []string{"spec"},
+	}
+}
+
+// controllerUsername is the admission username of the controller's ServiceAccount
+func controllerUsername() string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", system.Namespace(), controllerServiceAccount)
+}
+
+// writtenByController reports whether the requesting user is the controller
+func writtenByController(ui *authenticationv1.UserInfo) bool {
+	return ui.Username == controllerUsername()
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"context"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/ptr"
+	_ "knative.dev/pkg/system/testing"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servinglisters "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+func TestUnknownRevisions(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(&servingv1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "R1", Namespace: "default"}})
+	lister := servinglisters.NewRevisionLister(indexer)
+
+	tests := []struct {
+		name    string
+		traffic []servingv1.TrafficTarget
+		wantErr bool
+	}{{
+		name: "existing Revision passes",
+		traffic: []servingv1.TrafficTarget{
+			{RevisionName: "R1", Percent: ptr.Int64(100)},
+		},
+	}, {
+		name: "configurationName targets are left alone",
+		traffic: []servingv1.TrafficTarget{
+			{ConfigurationName: "C", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)},
+		},
+	}, {
+		name: "unknown Revision name is rejected",
+		traffic: []servingv1.TrafficTarget{
+			{RevisionName: "R1", Percent: ptr.Int64(50)},
+			{RevisionName: "no-such-revision", Percent: ptr.Int64(50)},
+		},
+		wantErr: true,
+	}, {
+		name: "existing name in the wrong namespace is rejected",
+		traffic: []servingv1.TrafficTarget{
+			{RevisionName: "R1", Percent: ptr.Int64(100)},
+		},
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			namespace := "default"
+			if test.name == "existing name in the wrong namespace is rejected" {
+				namespace = "other"
+			}
+			err := unknownRevisions(lister, namespace, test.traffic)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("wantErr = %v, but got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSpecWriteRestricted(t *testing.T) {
+	controllerUser := "system:serviceaccount:knative-testing:controller"
+	withSpec := func(percent int64) *GuardedPolicyState {
+		return &GuardedPolicyState{PolicyState: v1alpha1.PolicyState{
+			ObjectMeta: metav1.ObjectMeta{Name: "R", Namespace: "default"},
+			Spec: v1alpha1.PolicyStateSpec{Traffic: []servingv1.TrafficTarget{
+				{RevisionName: "R1", Percent: ptr.Int64(percent)},
+			}},
+		}}
+	}
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		ps      *GuardedPolicyState
+		wantErr bool
+	}{{
+		name: "no user info passes",
+		ctx:  context.Background(),
+		ps:   withSpec(100),
+	}, {
+		name: "controller may write the spec",
+		ctx:  apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: controllerUser}),
+		ps:   withSpec(100),
+	}, {
+		name: "anyone may create an empty shell",
+		ctx:  apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: "user@example.com"}),
+		ps:   &GuardedPolicyState{PolicyState: v1alpha1.PolicyState{ObjectMeta: metav1.ObjectMeta{Name: "R", Namespace: "default"}}},
+	}, {
+		name:    "other users cannot create a populated spec",
+		ctx:     apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: "user@example.com"}),
+		ps:      withSpec(100),
+		wantErr: true,
+	}, {
+		name: "other users may update when the spec is unchanged",
+		ctx: apis.WithinUpdate(
+			apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: "user@example.com"}),
+			withSpec(100)),
+		ps: withSpec(100),
+	}, {
+		name: "other users cannot change the spec",
+		ctx: apis.WithinUpdate(
+			apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: "user@example.com"}),
+			withSpec(100)),
+		ps:      withSpec(40),
+		wantErr: true,
+	}, {
+		name: "controller may change the spec",
+		ctx: apis.WithinUpdate(
+			apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: controllerUser}),
+			withSpec(100)),
+		ps: withSpec(40),
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := specWriteRestricted(test.ctx, test.ps)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("wantErr = %v, but got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"knative.dev/pkg/apis"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	policystateinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+	listers "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// GuardedRoute wraps Route so that the validation webhook can reject manual
+// traffic edits while a rollout is in progress; the reconciler owns
+// Spec.Traffic for the duration of a rollout and would silently stomp the
+// edit on its next pass, so rejecting up front is the kinder failure
+type GuardedRoute struct {
+	servingv1.Route `json:",inline"`
+}
+
+var (
+	_ apis.Defaultable = (*GuardedRoute)(nil)
+	_ apis.Validatable = (*GuardedRoute)(nil)
+)
+
+// SetDefaults implements apis.Defaultable; the wrapper only validates
+func (gr *GuardedRoute) SetDefaults(ctx context.Context) {}
+
+// Validate implements apis.Validatable
+func (gr *GuardedRoute) Validate(ctx context.Context) *apis.FieldError {
+	return manualTrafficEdit(ctx, gr, policystateinformer.Get(ctx).Lister())
+}
+
+// manualTrafficEdit returns a FieldError when someone other than the
+// controller changes the traffic of a Route whose PolicyState shows a rollout
+// in progress; Routes without a PolicyState are not under KCD management and
+// pass untouched
+func manualTrafficEdit(ctx context.Context, gr *GuardedRoute, lister listers.PolicyStateLister) *apis.FieldError {
+	ui := apis.GetUserInfo(ctx)
+	if ui == nil || !apis.IsInUpdate(ctx) || writtenByController(ui) {
+		return nil
+	}
+	ps, err := lister.PolicyStates(gr.Namespace).Get(gr.Name)
+	if err != nil || ps.Status.NextUpdateTimestamp == nil {
+		return nil
+	}
+	original, ok := apis.GetBaseline(ctx).(*GuardedRoute)
+	if !ok || original == nil || equality.Semantic.DeepEqual(original.Spec.Traffic, gr.Spec.Traffic) {
+		return nil
+	}
+	return &apis.FieldError{
+		Message: fmt.Sprintf("Route %q is mid-rollout under continuous delivery; manual traffic edits would be overwritten on the next reconcile, so they are rejected until the rollout finishes", gr.Name),
+		Paths:   []string{"spec.traffic"},
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyguard
+
+import (
+	"context"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/ptr"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	listers "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+func TestManualTrafficEdit(t *testing.T) {
+	activePS := &v1alpha1.PolicyState{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Status: v1alpha1.PolicyStateStatus{PolicyStateStatusFields: v1alpha1.PolicyStateStatusFields{
+			NextUpdateTimestamp: &metav1.Time{},
+		}},
+	}
+	idlePS := &v1alpha1.PolicyState{ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "default"}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(activePS)
+	indexer.Add(idlePS)
+	lister := listers.NewPolicyStateLister(indexer)
+
+	routeWithTraffic := func(name string, percent int64) *GuardedRoute {
+		return &GuardedRoute{Route: servingv1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: servingv1.RouteSpec{Traffic: []servingv1.TrafficTarget{
+				{RevisionName: "R1", Percent: ptr.Int64(percent)},
+			}},
+		}}
+	}
+	user := apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{Username: "user@example.com"})
+	controller := apis.WithUserInfo(context.Background(), &authenticationv1.UserInfo{
+		Username: "system:serviceaccount:knative-testing:controller",
+	})
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		route   *GuardedRoute
+		wantErr bool
+	}{{
+		name:  "creation passes",
+		ctx:   user,
+		route: routeWithTraffic("app", 100),
+	}, {
+		name:  "unmanaged Route passes",
+		ctx:   apis.WithinUpdate(user, routeWithTraffic("unmanaged", 100)),
+		route: routeWithTraffic("unmanaged", 40),
+	}, {
+		name:  "managed Route without an active rollout passes",
+		ctx:   apis.WithinUpdate(user, routeWithTraffic("idle", 100)),
+		route: routeWithTraffic("idle", 40),
+	}, {
+		name:  "unchanged traffic mid-rollout passes",
+		ctx:   apis.WithinUpdate(user, routeWithTraffic("app", 100)),
+		route: routeWithTraffic("app", 100),
+	}, {
+		name:  "controller may change traffic mid-rollout",
+		ctx:   apis.WithinUpdate(controller, routeWithTraffic("app", 100)),
+		route: routeWithTraffic("app", 40),
+	}, {
+		name:    "manual traffic edit mid-rollout is rejected",
+		ctx:     apis.WithinUpdate(user, routeWithTraffic("app", 100)),
+		route:   routeWithTraffic("app", 40),
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := manualTrafficEdit(test.ctx, test.route, lister)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("wantErr = %v, but got %v", test.wantErr, err)
+			}
+		})
+	}
+}
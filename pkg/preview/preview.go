@@ -0,0 +1,110 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preview serves a dry-run admission endpoint: given a Route, it returns
+// the patch that KCD's defaulting webhook would apply from the current
+// PolicyState, without persisting anything; this lets tooling diff a Route
+// against the traffic KCD would enforce before applying changes
+package preview
+
+import (
+	"context"
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+	"knative.dev/pkg/webhook"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	policystateinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+)
+
+// Path is the webhook subpath that serves preview requests
+const Path = "/preview"
+
+// reconciler implements the AdmissionController for the preview endpoint
+type reconciler struct {
+	webhook.StatelessAdmissionImpl
+	pkgreconciler.LeaderAwareFuncs
+
+	lister pslisters.PolicyStateLister
+}
+
+var _ controller.Reconciler = (*reconciler)(nil)
+var _ pkgreconciler.LeaderAware = (*reconciler)(nil)
+var _ webhook.AdmissionController = (*reconciler)(nil)
+var _ webhook.StatelessAdmissionController = (*reconciler)(nil)
+
+// NewAdmissionController constructs the controller backing the /preview endpoint
+func NewAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	wh := &reconciler{
+		lister: policystateinformer.Get(ctx).Lister(),
+	}
+	return controller.NewImpl(wh, logging.FromContext(ctx), "PreviewWebhook")
+}
+
+// Reconcile implements controller.Reconciler
+// unlike the defaulting controller there is no webhook configuration to keep in
+// sync: the endpoint is called directly by tooling, never by the apiserver
+func (ac *reconciler) Reconcile(ctx context.Context, key string) error {
+	return nil
+}
+
+// Path implements AdmissionController
+func (ac *reconciler) Path() string {
+	return Path
+}
+
+// Admit implements AdmissionController; it computes the same patch as the
+// defaulting webhook but has no side effects: the PolicyState status is left
+// untouched and nothing is written to the cluster
+func (ac *reconciler) Admit(ctx context.Context, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	route := &servingv1.Route{}
+	if err := json.Unmarshal(request.Object.Raw, route); err != nil {
+		return webhook.MakeErrorStatus("cannot decode Route: %v", err)
+	}
+	namespace := route.Namespace
+	if namespace == "" {
+		namespace = request.Namespace
+	}
+	ps, err := ac.lister.PolicyStates(namespace).Get(route.Name)
+	if err != nil {
+		// no PolicyState means KCD would leave this Route alone
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	after := route.DeepCopy()
+	after.Spec.Traffic = ps.Spec.Traffic
+	patch, err := duck.CreatePatch(route, after)
+	if err != nil {
+		return webhook.MakeErrorStatus("cannot compute patch: %v", err)
+	}
+	patchBytes, err := patch.MarshalJSON()
+	if err != nil {
+		return webhook.MakeErrorStatus("cannot encode patch: %v", err)
+	}
+	logging.FromContext(ctx).Infof("Preview for %s/%s: %s", namespace, route.Name, string(patchBytes))
+	return &admissionv1.AdmissionResponse{
+		Patch:   patchBytes,
+		Allowed: true,
+		PatchType: func() *admissionv1.PatchType {
+			pt := admissionv1.PatchTypeJSONPatch
+			return &pt
+		}(),
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/pkg/ptr"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func previewRequest(t *testing.T, route *servingv1.Route) *admissionv1.AdmissionRequest {
+	raw, err := json.Marshal(route)
+	if err != nil {
+		t.Fatalf("cannot marshal Route: %v", err)
+	}
+	return &admissionv1.AdmissionRequest{
+		Namespace: route.Namespace,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+}
+
+func previewLister(states ...*v1alpha1.PolicyState) pslisters.PolicyStateLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, ps := range states {
+		indexer.Add(ps)
+	}
+	return pslisters.NewPolicyStateLister(indexer)
+}
+
+func TestAdmit(t *testing.T) {
+	route := &servingv1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: servingv1.RouteSpec{Traffic: []servingv1.TrafficTarget{{
+			ConfigurationName: "test",
+			LatestRevision:    ptr.Bool(true),
+			Percent:           ptr.Int64(100),
+		}}},
+	}
+	ps := &v1alpha1.PolicyState{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: v1alpha1.PolicyStateSpec{Traffic: []servingv1.TrafficTarget{{
+			RevisionName:   "R1",
+			LatestRevision: ptr.Bool(false),
+			Percent:        ptr.Int64(90),
+		}, {
+			RevisionName:   "R2",
+			LatestRevision: ptr.Bool(false),
+			Percent:        ptr.Int64(10),
+		}}},
+	}
+
+	t.Run("returns the traffic KCD would enforce", func(t *testing.T) {
+		ac := &reconciler{lister: previewLister(ps)}
+		resp := ac.Admit(context.Background(), previewRequest(t, route))
+		if !resp.Allowed {
+			t.Fatalf("preview request was rejected: %v", resp.Result)
+		}
+		if !strings.Contains(string(resp.Patch), "R1") || !strings.Contains(string(resp.Patch), "R2") {
+			t.Errorf("patch should rewrite traffic to R1/R2, got %s", string(resp.Patch))
+		}
+	})
+
+	t.Run("leaves unmanaged Routes alone", func(t *testing.T) {
+		ac := &reconciler{lister: previewLister()}
+		resp := ac.Admit(context.Background(), previewRequest(t, route))
+		if !resp.Allowed {
+			t.Fatalf("preview request was rejected: %v", resp.Result)
+		}
+		if resp.Patch != nil {
+			t.Errorf("expected no patch for an unmanaged Route, got %s", string(resp.Patch))
+		}
+	})
+
+	t.Run("rejects undecodable payloads", func(t *testing.T) {
+		ac := &reconciler{lister: previewLister(ps)}
+		resp := ac.Admit(context.Background(), &admissionv1.AdmissionRequest{
+			Object: runtime.RawExtension{Raw: []byte("not json")},
+		})
+		if resp.Allowed {
+			t.Error("expected an undecodable payload to be rejected")
+		}
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// rolloutActor identifies who deployed the candidate Revision, using the
+// creator/lastModifier annotations that the Serving webhook stamps on its
+// resources: the Revision's recorded creator when present, otherwise whoever
+// last modified (or created) the Configuration that stamped the Revision out
+// the empty string means the actor could not be determined
+func rolloutActor(cfg *v1.Configuration, rev *v1.Revision) string {
+	if rev != nil {
+		if actor := rev.Annotations[serving.CreatorAnnotation]; actor != "" {
+			return actor
+		}
+	}
+	if actor := cfg.Annotations[serving.UpdaterAnnotation]; actor != "" {
+		return actor
+	}
+	return cfg.Annotations[serving.CreatorAnnotation]
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestRolloutActor(t *testing.T) {
+	config := func(annotations map[string]string) *v1.Configuration {
+		return &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default", Annotations: annotations}}
+	}
+	revision := func(annotations map[string]string) *v1.Revision {
+		return &v1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "R2", Namespace: "default", Annotations: annotations}}
+	}
+	tests := []struct {
+		name string
+		cfg  *v1.Configuration
+		rev  *v1.Revision
+		want string
+	}{{
+		name: "revision creator wins",
+		cfg:  config(map[string]string{serving.UpdaterAnnotation: "modifier@example.com"}),
+		rev:  revision(map[string]string{serving.CreatorAnnotation: "deployer@example.com"}),
+		want: "deployer@example.com",
+	}, {
+		name: "falls back to configuration lastModifier",
+		cfg:  config(map[string]string{serving.CreatorAnnotation: "creator@example.com", serving.UpdaterAnnotation: "modifier@example.com"}),
+		rev:  revision(nil),
+		want: "modifier@example.com",
+	}, {
+		name: "falls back to configuration creator",
+		cfg:  config(map[string]string{serving.CreatorAnnotation: "creator@example.com"}),
+		rev:  nil,
+		want: "creator@example.com",
+	}, {
+		name: "unknown actor is the empty string",
+		cfg:  config(nil),
+		rev:  revision(nil),
+		want: "",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rolloutActor(test.cfg, test.rev); got != test.want {
+				t.Errorf("rolloutActor = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
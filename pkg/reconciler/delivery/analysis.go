@@ -0,0 +1,161 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// AnalysisRequest is the rollout context POSTed to a stage's analysis gate
+type AnalysisRequest struct {
+	Namespace      string            `json:"namespace"`
+	Configuration  string            `json:"configuration"`
+	Revision       string            `json:"revision"`
+	CurrentPercent int               `json:"currentPercent"`
+	ElapsedSeconds int               `json:"elapsedSeconds"`
+	Exclusions     []ExclusionWindow `json:"exclusions,omitempty"`
+}
+
+// ExclusionWindow is a time range whose samples the analysis engine should
+// discard, e.g. because a scheduled load test was running
+type ExclusionWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// gateOnAnalysis holds the rollout at its current stage until the stage's external
+// analysis endpoint approves the promotion; like the hook gate this reuses the
+// freeze bookkeeping, so the stage timer is paused while approval is pending and
+// the progress deadline eventually fails a rollout that never gets approved
+func (c *Reconciler) gateOnAnalysis(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		return false, nil
+	}
+	current := currentPercent(route, revName)
+	gate := stageAnalysis(policy, current)
+	if gate == nil {
+		return false, nil
+	}
+	// only consult the endpoint once the stage timer wants to advance past this stage
+	pause := pauseOffset(ps)
+	if ps.Status.FreezeStartTime != nil {
+		pause += c.clock.Since(ps.Status.FreezeStartTime.Time)
+	}
+	elapsed := jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pause))
+	if computeNewPercentExplicit(policy, elapsed) <= current {
+		return false, nil
+	}
+	pass, err := c.postAnalysis(gate.URL, &AnalysisRequest{
+		Namespace:      cfg.Namespace,
+		Configuration:  cfg.Name,
+		Revision:       revName,
+		CurrentPercent: current,
+		ElapsedSeconds: int(elapsed / time.Second),
+		Exclusions:     parseExclusions(ctx, cfg.Annotations[delivery.AnalysisExclusionsKey]),
+	})
+	if err != nil {
+		return false, err
+	}
+	if pass {
+		logging.FromContext(ctx).Infof("Analysis gate for stage %d passed", current)
+		return false, nil
+	}
+	if deadlineExceeded(policy, ps, c.clock) {
+		return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+	}
+	return true, c.holdForAnalysis(ctx, cfg, ps, current)
+}
+
+// holdForAnalysis pauses the stage timer until the analysis endpoint approves
+func (c *Reconciler) holdForAnalysis(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, percent int) error {
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("Analysis gate for stage %d has not passed yet, holding the current traffic split", percent)
+	c.followup(cfg, AnalysisRecheck)
+	return nil
+}
+
+// postAnalysis sends the rollout context to the analysis endpoint and interprets
+// the response; only a 200 response whose body reads "pass" approves the promotion
+func (c *Reconciler) postAnalysis(url string, req *AnalysisRequest) (bool, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.httpclient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == "pass", nil
+}
+
+// parseExclusions interprets the analysis exclusions annotation: a
+// comma-separated list of "start/end" RFC 3339 pairs; malformed or empty ranges
+// are logged and skipped so that one bad entry doesn't discard the rest
+func parseExclusions(ctx context.Context, annotation string) []ExclusionWindow {
+	if annotation == "" {
+		return nil
+	}
+	var windows []ExclusionWindow
+	for _, entry := range strings.Split(annotation, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, "/", 2)
+		if len(parts) != 2 {
+			logging.FromContext(ctx).Infof("Skipping malformed analysis exclusion %q", entry)
+			continue
+		}
+		start, serr := time.Parse(time.RFC3339, parts[0])
+		end, eerr := time.Parse(time.RFC3339, parts[1])
+		if serr != nil || eerr != nil || !end.After(start) {
+			logging.FromContext(ctx).Infof("Skipping malformed analysis exclusion %q", entry)
+			continue
+		}
+		windows = append(windows, ExclusionWindow{Start: start, End: end})
+	}
+	return windows
+}
+
+// stageAnalysis returns the analysis gate declared by the stage at the given traffic percent
+func stageAnalysis(policy *Policy, percent int) *v1alpha1.AnalysisGate {
+	for _, s := range policy.Stages {
+		if s.Percent == percent {
+			return s.Analysis
+		}
+	}
+	return nil
+}
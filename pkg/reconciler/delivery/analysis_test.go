@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPostAnalysis(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{name: "endpoint approves", status: http.StatusOK, body: "pass", want: true},
+		{name: "pass with surrounding whitespace", status: http.StatusOK, body: "  pass\n", want: true},
+		{name: "endpoint declines", status: http.StatusOK, body: "fail", want: false},
+		{name: "non-200 response is not approval", status: http.StatusInternalServerError, body: "pass", want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got AnalysisRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("analysis gate must be consulted with POST, got %v", r.Method)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("request body is not valid JSON: %v", err)
+				}
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			c := &Reconciler{httpclient: server.Client()}
+			req := &AnalysisRequest{Namespace: "default", Configuration: "test", Revision: "R2", CurrentPercent: 10, ElapsedSeconds: 61}
+			pass, err := c.postAnalysis(server.URL, req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pass != test.want {
+				t.Errorf("wrong answer (got %v, want %v)", pass, test.want)
+			}
+			if diff := cmp.Diff(*req, got); diff != "" {
+				t.Errorf("rollout context was not delivered intact (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseExclusions(t *testing.T) {
+	window := func(start, end string) ExclusionWindow {
+		s, _ := time.Parse(time.RFC3339, start)
+		e, _ := time.Parse(time.RFC3339, end)
+		return ExclusionWindow{Start: s, End: e}
+	}
+	tests := []struct {
+		name       string
+		annotation string
+		want       []ExclusionWindow
+	}{
+		{name: "empty annotation yields no exclusions", annotation: "", want: nil},
+		{name: "single range", annotation: "2020-08-01T10:00:00Z/2020-08-01T12:00:00Z",
+			want: []ExclusionWindow{window("2020-08-01T10:00:00Z", "2020-08-01T12:00:00Z")}},
+		{name: "multiple ranges with whitespace", annotation: "2020-08-01T10:00:00Z/2020-08-01T12:00:00Z, 2020-08-02T00:00:00Z/2020-08-02T01:00:00Z",
+			want: []ExclusionWindow{window("2020-08-01T10:00:00Z", "2020-08-01T12:00:00Z"), window("2020-08-02T00:00:00Z", "2020-08-02T01:00:00Z")}},
+		{name: "malformed entry is skipped", annotation: "not-a-range,2020-08-01T10:00:00Z/2020-08-01T12:00:00Z",
+			want: []ExclusionWindow{window("2020-08-01T10:00:00Z", "2020-08-01T12:00:00Z")}},
+		{name: "inverted range is skipped", annotation: "2020-08-01T12:00:00Z/2020-08-01T10:00:00Z", want: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseExclusions(context.Background(), test.annotation)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("exclusion windows are incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
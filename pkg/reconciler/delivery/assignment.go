@@ -0,0 +1,30 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// assignmentSeed derives the hashing seed for user-bucketing rollout modes
+// the seed is a pure function of the Policy's salt and the rollout identity, so
+// the same rollout always buckets users the same way, and recording the seed in
+// the PolicyState status lets experiment platforms reproduce the assignment
+func assignmentSeed(salt, namespace, revisionName string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s/%s", salt, namespace, revisionName)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
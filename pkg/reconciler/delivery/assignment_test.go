@@ -0,0 +1,36 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import "testing"
+
+func TestAssignmentSeed(t *testing.T) {
+	base := assignmentSeed("", "default", "R1")
+	if again := assignmentSeed("", "default", "R1"); again != base {
+		t.Errorf("seed is not reproducible: %s != %s", again, base)
+	}
+	if len(base) != 16 {
+		t.Errorf("seed %q should be 16 hex characters", base)
+	}
+	if salted := assignmentSeed("experiment-42", "default", "R1"); salted == base {
+		t.Errorf("salt should change the seed, but got %s twice", base)
+	}
+	if other := assignmentSeed("", "default", "R2"); other == base {
+		t.Errorf("a new Revision should change the seed, but got %s twice", base)
+	}
+	if other := assignmentSeed("", "prod", "R1"); other == base {
+		t.Errorf("a different namespace should change the seed, but got %s twice", base)
+	}
+}
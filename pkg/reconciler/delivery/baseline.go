@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/resources"
+)
+
+// ensureBaseline creates the rollout's baseline Configuration if it does not
+// exist yet: a fresh copy of the stable Revision whose instances are as cold as
+// the candidate's, so compare gates judge like against like
+func (c *Reconciler) ensureBaseline(ctx context.Context, cfg *v1.Configuration, revisionMap map[string]*v1.Revision) error {
+	stable := oldestRevision(revisionMap)
+	if stable == nil || stable.Name == cfg.Status.LatestReadyRevisionName {
+		return nil
+	}
+	name := resources.BaselineName(cfg)
+	if _, err := c.configurationLister.Configurations(cfg.Namespace).Get(name); err == nil {
+		return nil
+	} else if !apierrs.IsNotFound(err) {
+		return err
+	}
+	logging.FromContext(ctx).Infof("Spawning baseline Configuration %s from stable Revision %s", name, stable.Name)
+	_, err := c.client.ServingV1().Configurations(cfg.Namespace).Create(resources.MakeBaseline(cfg, stable))
+	if apierrs.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// cleanupBaseline deletes the rollout's baseline Configuration once the rollout
+// has ended; a rollout that never spawned one is a no-op
+func (c *Reconciler) cleanupBaseline(ctx context.Context, cfg *v1.Configuration) error {
+	name := resources.BaselineName(cfg)
+	if _, err := c.configurationLister.Configurations(cfg.Namespace).Get(name); apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Infof("Deleting baseline Configuration %s, the rollout has ended", name)
+	err := c.client.ServingV1().Configurations(cfg.Namespace).Delete(name, nil)
+	if apierrs.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// baselineRevision returns the baseline's latest ready Revision, or "" while
+// the baseline does not exist or has not become ready yet
+func (c *Reconciler) baselineRevision(cfg *v1.Configuration) string {
+	baseline, err := c.configurationLister.Configurations(cfg.Namespace).Get(resources.BaselineName(cfg))
+	if err != nil {
+		return ""
+	}
+	return baseline.Status.LatestReadyRevisionName
+}
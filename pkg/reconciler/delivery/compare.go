@@ -0,0 +1,216 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// MetricSample is what the comparison engine knows about one Revision over a
+// window: its traffic volume, failures, and latency distribution
+type MetricSample struct {
+	Requests         int64
+	Errors           int64
+	LatencyP50Millis int64
+	LatencyP99Millis int64
+}
+
+// MetricsProvider supplies per-Revision metric samples to the comparison
+// engine; the provider shipping with a metrics backend integration plugs in
+// here, and tests substitute a fake
+type MetricsProvider interface {
+	Sample(namespace, revision string, window time.Duration) (MetricSample, error)
+}
+
+// gateOnCompare holds the rollout at its current stage until the candidate
+// scores well enough against the stable Revision; like the analysis gate the
+// comparison only runs once the stage timer wants to advance, a marginal score
+// pauses the stage timer and retries, and a score below the marginal mark
+// fails the rollout outright
+// Without a metrics provider the gate passes: there is nothing to compare
+func (c *Reconciler) gateOnCompare(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	metrics := c.metricProviderFor(cfg, policy)
+	if metrics == nil {
+		return false, nil
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		return false, nil
+	}
+	stable := oldestRevision(revisionMap)
+	if stable == nil || stable.Name == revName {
+		return false, nil
+	}
+	current := currentPercent(route, revName)
+	gate := stageCompare(policy, current)
+	if gate == nil {
+		return false, nil
+	}
+	// only run the comparison once the stage timer wants to advance past this stage
+	pause := pauseOffset(ps)
+	if ps.Status.FreezeStartTime != nil {
+		pause += c.clock.Since(ps.Status.FreezeStartTime.Time)
+	}
+	if computeNewPercentExplicit(policy, jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pause))) <= current {
+		return false, nil
+	}
+	// a spawned baseline replaces the stable Revision in the comparison: its
+	// instances are as cold as the candidate's, so neither side benefits from
+	// warmed caches; until the baseline is ready the stable Revision stands in
+	reference := stable.Name
+	if policy.SpawnBaseline {
+		if name := c.baselineRevision(cfg); name != "" {
+			reference = name
+		}
+	}
+	window := time.Duration(gate.WindowSeconds) * time.Second
+	candidateSample, err := metrics.Sample(cfg.Namespace, revName, window)
+	if err != nil {
+		return false, err
+	}
+	stableSample, err := metrics.Sample(cfg.Namespace, reference, window)
+	if err != nil {
+		return false, err
+	}
+	score := canaryScore(candidateSample, stableSample)
+	// the pass and failure counters belong to one stage; entering a new stage
+	// starts them over
+	if ps.Status.CompareStagePercent != current {
+		ps.Status.CompareStagePercent = current
+		ps.Status.ComparePassStreak = 0
+		ps.Status.CompareFailures = 0
+	}
+	if score >= gate.PassScore {
+		ps.Status.ComparePassStreak++
+		if ps.Status.ComparePassStreak >= requiredPasses(gate) {
+			logging.FromContext(ctx).Infof("Canary score %d passes the compare gate for stage %d", score, current)
+			return false, nil
+		}
+		logging.FromContext(ctx).Infof("Canary score %d passes (%d of %d consecutive passes), holding for another evaluation",
+			score, ps.Status.ComparePassStreak, requiredPasses(gate))
+		return true, c.holdForCompare(ctx, cfg, ps, compareInterval(gate))
+	}
+	// any non-passing score breaks the streak
+	ps.Status.ComparePassStreak = 0
+	if score < gate.MarginalScore {
+		ps.Status.CompareFailures++
+		if ps.Status.CompareFailures > gate.AllowedFailures {
+			logging.FromContext(ctx).Infof("Canary score %d is below the marginal mark %d, failing the rollout", score, gate.MarginalScore)
+			return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+		}
+		logging.FromContext(ctx).Infof("Canary score %d is below the marginal mark (failure %d of %d allowed), holding",
+			score, ps.Status.CompareFailures, gate.AllowedFailures)
+		return true, c.holdForCompare(ctx, cfg, ps, compareInterval(gate))
+	}
+	if deadlineExceeded(policy, ps, c.clock) {
+		return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+	}
+	logging.FromContext(ctx).Infof("Canary score %d has not reached the pass mark %d, holding the current traffic split", score, gate.PassScore)
+	return true, c.holdForCompare(ctx, cfg, ps, compareInterval(gate))
+}
+
+// holdForCompare pauses the stage timer until the next evaluation; unlike
+// holdForAnalysis the PolicyState is written on every evaluation, because the
+// pass and failure counters just moved
+func (c *Reconciler) holdForCompare(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, interval time.Duration) error {
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+	}
+	if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+		return err
+	}
+	c.followup(cfg, interval)
+	return nil
+}
+
+// requiredPasses is how many consecutive passing scores the gate demands; the
+// zero value means a single pass promotes
+func requiredPasses(gate *v1alpha1.CompareGate) int {
+	if gate.ConsecutivePasses > 0 {
+		return gate.ConsecutivePasses
+	}
+	return 1
+}
+
+// compareInterval is how long a holding gate waits before re-evaluating
+func compareInterval(gate *v1alpha1.CompareGate) time.Duration {
+	if gate.IntervalSeconds > 0 {
+		return time.Duration(gate.IntervalSeconds) * time.Second
+	}
+	return AnalysisRecheck
+}
+
+// canaryScore folds the candidate-versus-stable comparison into a score from 0
+// (much worse than stable) to 100 (no worse than stable): each metric scores
+// 100 when the candidate matches or beats the stable Revision, decays linearly,
+// and bottoms out at 0 once the candidate is twice as bad; the final score is
+// the mean of the metrics that have data
+// Comparing ratios instead of absolute values is what keeps the gate meaningful
+// under bursty traffic: a burst inflates both Revisions alike
+func canaryScore(candidate, stable MetricSample) int {
+	var scores []int
+	if candidate.Requests > 0 && stable.Requests > 0 {
+		// one phantom error per sample keeps a zero-error stable Revision from
+		// zeroing the ratio on the candidate's first failure
+		candRate := float64(candidate.Errors+1) / float64(candidate.Requests+1)
+		stableRate := float64(stable.Errors+1) / float64(stable.Requests+1)
+		scores = append(scores, ratioScore(candRate/stableRate))
+	}
+	if candidate.LatencyP50Millis > 0 && stable.LatencyP50Millis > 0 {
+		scores = append(scores, ratioScore(float64(candidate.LatencyP50Millis)/float64(stable.LatencyP50Millis)))
+	}
+	if candidate.LatencyP99Millis > 0 && stable.LatencyP99Millis > 0 {
+		scores = append(scores, ratioScore(float64(candidate.LatencyP99Millis)/float64(stable.LatencyP99Millis)))
+	}
+	// no overlapping data means no verdict
+	if len(scores) == 0 {
+		return 100
+	}
+	total := 0
+	for _, s := range scores {
+		total += s
+	}
+	return total / len(scores)
+}
+
+// ratioScore maps a candidate/stable ratio to a 0-100 score: parity or better
+// is 100, twice as bad or worse is 0, linear in between
+func ratioScore(ratio float64) int {
+	switch {
+	case ratio <= 1:
+		return 100
+	case ratio >= 2:
+		return 0
+	default:
+		return int(100 * (2 - ratio))
+	}
+}
+
+// stageCompare returns the compare gate declared by the stage at the given traffic percent
+func stageCompare(policy *Policy, percent int) *v1alpha1.CompareGate {
+	for _, s := range policy.Stages {
+		if s.Percent == percent {
+			return s.Compare
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+func TestCanaryScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate MetricSample
+		stable    MetricSample
+		want      int
+	}{{
+		name: "no overlapping data means no verdict",
+		want: 100,
+	}, {
+		name:      "candidate matching stable scores full marks",
+		candidate: MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		stable:    MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		want:      100,
+	}, {
+		name:      "candidate beating stable scores full marks",
+		candidate: MetricSample{Requests: 1000, Errors: 1, LatencyP50Millis: 10, LatencyP99Millis: 100},
+		stable:    MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		want:      100,
+	}, {
+		name:      "candidate twice as bad on every metric scores zero",
+		candidate: MetricSample{Requests: 1000, Errors: 21, LatencyP50Millis: 40, LatencyP99Millis: 400},
+		stable:    MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		want:      0,
+	}, {
+		name:      "a single degraded metric only drags down its share",
+		candidate: MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 400},
+		stable:    MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		want:      66,
+	}, {
+		name:      "metrics without data on both sides are left out",
+		candidate: MetricSample{Requests: 1000, Errors: 10},
+		stable:    MetricSample{Requests: 1000, Errors: 10, LatencyP50Millis: 20, LatencyP99Millis: 200},
+		want:      100,
+	}, {
+		name:      "a traffic burst hitting both Revisions alike keeps near-parity",
+		candidate: MetricSample{Requests: 50000, Errors: 500},
+		stable:    MetricSample{Requests: 450000, Errors: 4500},
+		want:      99, // the phantom error weighs slightly more on the smaller sample
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canaryScore(tt.candidate, tt.stable); got != tt.want {
+				t.Errorf("canaryScore() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareCadence(t *testing.T) {
+	if got := requiredPasses(&v1alpha1.CompareGate{}); got != 1 {
+		t.Errorf("requiredPasses() defaults to %d, want 1", got)
+	}
+	if got := requiredPasses(&v1alpha1.CompareGate{ConsecutivePasses: 3}); got != 3 {
+		t.Errorf("requiredPasses() = %d, want 3", got)
+	}
+	if got := compareInterval(&v1alpha1.CompareGate{}); got != AnalysisRecheck {
+		t.Errorf("compareInterval() defaults to %v, want %v", got, AnalysisRecheck)
+	}
+	if got := compareInterval(&v1alpha1.CompareGate{IntervalSeconds: 45}); got != 45*time.Second {
+		t.Errorf("compareInterval() = %v, want 45s", got)
+	}
+}
+
+func TestRatioScore(t *testing.T) {
+	tests := []struct {
+		ratio float64
+		want  int
+	}{
+		{0.5, 100},
+		{1.0, 100},
+		{1.5, 50},
+		{2.0, 0},
+		{3.0, 0},
+	}
+	for _, tt := range tests {
+		if got := ratioScore(tt.ratio); got != tt.want {
+			t.Errorf("ratioScore(%v) = %d, want %d", tt.ratio, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// the possible values of PolicyState.Status.PendingReason
+const (
+	// PendingReasonAwaitingSlot means the rollout is queued because the
+	// concurrency budget of active rollouts is already spent
+	PendingReasonAwaitingSlot = "AwaitingRolloutSlot"
+	// PendingReasonOutranked means a slot is free but a queued rollout with a
+	// higher priority (or an earlier arrival at equal priority) gets it first
+	PendingReasonOutranked = "OutrankedByPriority"
+)
+
+// gateOnConcurrencyBudget holds a rollout that has not yet claimed any traffic
+// while the cluster-wide or per-namespace budget of active rollouts is already
+// spent; the candidate waits at 0% in the Pending phase until a slot frees up,
+// so big multi-team clusters can cap how much simultaneous risk is in flight
+// a rollout that already serves traffic keeps its slot until it completes
+// when a slot is contended, priority decides who gets it: the waiting rollout
+// with the highest Policy priority is admitted first, and equal priorities go
+// in arrival order
+func (c *Reconciler) gateOnConcurrencyBudget(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	// an admitted rollout sheds any queueing reason; the holds below re-set it
+	ps.Status.PendingReason = ""
+	delivery := config.FromContextOrDefaults(ctx).Delivery
+	if delivery.MaxActiveRollouts <= 0 && delivery.NamespaceMaxActiveRollouts <= 0 {
+		return false, nil
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	if _, ok := revisionMap[revName]; !ok {
+		return false, nil
+	}
+	// only a rollout waiting for its first traffic can be made to queue; the
+	// first Revision of a Configuration never rolls out gradually either way
+	if currentPercent(route, revName) > 0 {
+		return false, nil
+	}
+	if stable := oldestRevision(revisionMap); stable == nil || stable.Name == revName {
+		return false, nil
+	}
+	// record the rollout's priority so contending rollouts can rank each
+	// other from the PolicyStates alone
+	ps.Status.RolloutPriority = policy.Priority
+	states, err := c.policystateLister.List(labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	active, nsActive := countActiveRollouts(states, cfg)
+	if delivery.MaxActiveRollouts > 0 && active >= int(delivery.MaxActiveRollouts) {
+		return true, c.holdForRolloutSlot(ctx, cfg, ps, PendingReasonAwaitingSlot,
+			fmt.Sprintf("%d of %d allowed rollouts are already active cluster-wide", active, delivery.MaxActiveRollouts))
+	}
+	if delivery.NamespaceMaxActiveRollouts > 0 && nsActive >= int(delivery.NamespaceMaxActiveRollouts) {
+		return true, c.holdForRolloutSlot(ctx, cfg, ps, PendingReasonAwaitingSlot,
+			fmt.Sprintf("%d of %d allowed rollouts are already active in namespace %s", nsActive, delivery.NamespaceMaxActiveRollouts, cfg.Namespace))
+	}
+	// a free slot goes to the best-ranked waiter, not to whoever happens to
+	// reconcile first; yield while a queued rollout outranks this one
+	if rival := outrankingWaiter(states, cfg, policy.Priority, ps.Status.FreezeStartTime, delivery.MaxActiveRollouts <= 0); rival != nil {
+		return true, c.holdForRolloutSlot(ctx, cfg, ps, PendingReasonOutranked,
+			fmt.Sprintf("queued rollout %s/%s (priority %d) is ahead of this one (priority %d)",
+				rival.Namespace, rival.Name, rival.Status.RolloutPriority, policy.Priority))
+	}
+	return false, nil
+}
+
+// holdForRolloutSlot parks the rollout in the Pending phase with the given
+// reason until a slot frees; like the other holds it reuses the freeze
+// bookkeeping, so the queueing time is folded into PauseOffsetSeconds and the
+// ladder starts at its first stage once the rollout is admitted
+func (c *Reconciler) holdForRolloutSlot(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, reason, message string) error {
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(cfg, corev1.EventTypeNormal, reason, "%s; waiting for a rollout slot", message)
+	}
+	changed := ps.Status.TransitionPhase(v1alpha1.PhasePending)
+	if ps.Status.PendingReason != reason {
+		ps.Status.PendingReason = reason
+		changed = true
+	}
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		changed = true
+	}
+	if changed {
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("%s, queueing this rollout at 0%%", message)
+	c.followup(cfg, SlotRecheck)
+	return nil
+}
+
+// outrankingWaiter returns a queued rollout that must be admitted before this
+// one: one with a strictly higher priority, or an equal-priority one that has
+// been queueing for longer; exact ties fall back to namespace/name order so
+// two waiters cannot both yield to each other
+// when only the per-namespace budget is configured, rollouts in other
+// namespaces do not contend for the same slots and are ignored
+func outrankingWaiter(states []*v1alpha1.PolicyState, cfg *v1.Configuration, priority int, queuedSince *metav1.Time, sameNamespaceOnly bool) *v1alpha1.PolicyState {
+	var rival *v1alpha1.PolicyState
+	for _, other := range states {
+		if other.Namespace == cfg.Namespace && other.Name == cfg.Name {
+			continue
+		}
+		if sameNamespaceOnly && other.Namespace != cfg.Namespace {
+			continue
+		}
+		if other.Status.Phase != v1alpha1.PhasePending || other.Status.PendingReason == "" {
+			continue
+		}
+		if other.Status.RolloutPriority < priority {
+			continue
+		}
+		if other.Status.RolloutPriority == priority {
+			if other.Status.FreezeStartTime == nil {
+				continue
+			}
+			if queuedSince != nil {
+				if queuedSince.Time.Before(other.Status.FreezeStartTime.Time) {
+					continue
+				}
+				if queuedSince.Time.Equal(other.Status.FreezeStartTime.Time) &&
+					cfg.Namespace+"/"+cfg.Name < other.Namespace+"/"+other.Name {
+					continue
+				}
+			}
+		}
+		if rival == nil || other.Status.RolloutPriority > rival.Status.RolloutPriority {
+			rival = other
+		}
+	}
+	return rival
+}
+
+// countActiveRollouts counts the PolicyStates other than the Configuration's
+// own whose rollout is actively shifting traffic, cluster-wide and within the
+// Configuration's namespace; queued (Pending), finished, and stalled rollouts
+// do not occupy a slot
+func countActiveRollouts(states []*v1alpha1.PolicyState, cfg *v1.Configuration) (active, nsActive int) {
+	for _, ps := range states {
+		if ps.Namespace == cfg.Namespace && ps.Name == cfg.Name {
+			continue
+		}
+		if !rolloutActive(ps) {
+			continue
+		}
+		active++
+		if ps.Namespace == cfg.Namespace {
+			nsActive++
+		}
+	}
+	return
+}
+
+// rolloutActive reports whether a PolicyState's rollout occupies a concurrency
+// budget slot: one that is advancing, temporarily paused, or walking a gradual
+// rollback is still exposure in flight
+func rolloutActive(ps *v1alpha1.PolicyState) bool {
+	switch ps.Status.Phase {
+	case v1alpha1.PhaseProgressing, v1alpha1.PhasePaused, v1alpha1.PhaseRollingBack:
+		return true
+	}
+	return false
+}
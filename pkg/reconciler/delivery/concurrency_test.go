@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func makePhaseState(namespace, name, phase string) *v1alpha1.PolicyState {
+	ps := &v1alpha1.PolicyState{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	ps.Status.Phase = phase
+	return ps
+}
+
+func TestRolloutActive(t *testing.T) {
+	occupied := []string{v1alpha1.PhaseProgressing, v1alpha1.PhasePaused, v1alpha1.PhaseRollingBack}
+	free := []string{"", v1alpha1.PhasePending, v1alpha1.PhaseStalled, v1alpha1.PhaseCompleted, v1alpha1.PhaseAborted}
+	for _, phase := range occupied {
+		if !rolloutActive(makePhaseState("default", "cfg", phase)) {
+			t.Errorf("rolloutActive(%q) = false, want true", phase)
+		}
+	}
+	for _, phase := range free {
+		if rolloutActive(makePhaseState("default", "cfg", phase)) {
+			t.Errorf("rolloutActive(%q) = true, want false", phase)
+		}
+	}
+}
+
+func TestCountActiveRollouts(t *testing.T) {
+	cfg := &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "waiting"}}
+	states := []*v1alpha1.PolicyState{
+		// the Configuration's own PolicyState never counts against itself
+		makePhaseState("default", "waiting", v1alpha1.PhaseProgressing),
+		makePhaseState("default", "neighbor", v1alpha1.PhaseProgressing),
+		makePhaseState("default", "parked", v1alpha1.PhasePending),
+		makePhaseState("other", "rollback", v1alpha1.PhaseRollingBack),
+		makePhaseState("other", "done", v1alpha1.PhaseCompleted),
+	}
+	active, nsActive := countActiveRollouts(states, cfg)
+	if active != 2 {
+		t.Errorf("countActiveRollouts() active = %d, want 2", active)
+	}
+	if nsActive != 1 {
+		t.Errorf("countActiveRollouts() nsActive = %d, want 1", nsActive)
+	}
+}
+
+func makeWaiter(namespace, name string, priority int, queuedAt time.Time) *v1alpha1.PolicyState {
+	ps := makePhaseState(namespace, name, v1alpha1.PhasePending)
+	ps.Status.PendingReason = PendingReasonAwaitingSlot
+	ps.Status.RolloutPriority = priority
+	ps.Status.FreezeStartTime = &metav1.Time{queuedAt}
+	return ps
+}
+
+func TestOutrankingWaiter(t *testing.T) {
+	cfg := &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "mine"}}
+	earlier := time.Unix(1000, 0)
+	later := time.Unix(2000, 0)
+
+	// a higher-priority waiter always wins the slot
+	critical := makeWaiter("other", "critical", 10, later)
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{critical}, cfg, 0, &metav1.Time{earlier}, false); got != critical {
+		t.Errorf("outrankingWaiter() = %v, want the higher-priority waiter", got)
+	}
+
+	// equal priority goes in arrival order
+	peer := makeWaiter("other", "peer", 0, later)
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{peer}, cfg, 0, &metav1.Time{earlier}, false); got != nil {
+		t.Errorf("outrankingWaiter() = %v, want nil for an equal-priority waiter that queued later", got)
+	}
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{peer}, cfg, 0, &metav1.Time{later.Add(time.Hour)}, false); got != peer {
+		t.Errorf("outrankingWaiter() = %v, want the equal-priority waiter that queued first", got)
+	}
+
+	// a lower-priority waiter never blocks admission
+	minor := makeWaiter("other", "minor", -5, earlier)
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{minor}, cfg, 0, nil, false); got != nil {
+		t.Errorf("outrankingWaiter() = %v, want nil for a lower-priority waiter", got)
+	}
+
+	// with only the per-namespace budget configured, other namespaces do not contend
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{critical}, cfg, 0, &metav1.Time{earlier}, true); got != nil {
+		t.Errorf("outrankingWaiter() = %v, want nil for a waiter in another namespace", got)
+	}
+
+	// active or admitted PolicyStates are not waiters
+	admitted := makePhaseState("other", "admitted", v1alpha1.PhaseProgressing)
+	admitted.Status.RolloutPriority = 10
+	if got := outrankingWaiter([]*v1alpha1.PolicyState{admitted}, cfg, 0, nil, false); got != nil {
+		t.Errorf("outrankingWaiter() = %v, want nil for a non-queued PolicyState", got)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/configmap"
+)
+
+const (
+	// ConfigName is the name of the ConfigMap that holds cluster-wide KCD settings
+	ConfigName = "config-delivery"
+
+	// FreezeRolloutsKey is the data key that controls the global rollout freeze
+	FreezeRolloutsKey = "freeze-rollouts"
+
+	// HTTPCABundleKey is the data key holding an optional PEM bundle of extra CA
+	// certificates trusted by the outbound HTTP clients (metric providers,
+	// notification endpoints), e.g. the CA of a corporate egress proxy
+	HTTPCABundleKey = "http-ca-bundle"
+
+	// EnabledNamespacesKey is the data key listing, comma-separated, the only
+	// namespaces KCD may manage; empty means every namespace
+	EnabledNamespacesKey = "enabled-namespaces"
+
+	// DisabledNamespacesKey is the data key listing, comma-separated, namespaces
+	// KCD must never manage; the deny list wins over the allow list
+	DisabledNamespacesKey = "disabled-namespaces"
+
+	// RouteWritesPerSecondKey is the data key bounding how many Route updates
+	// the reconciler may issue per second across the whole cluster; zero or
+	// unset means unlimited
+	RouteWritesPerSecondKey = "route-writes-per-second"
+
+	// NamespaceRouteWritesPerSecondKey is the data key bounding how many Route
+	// updates the reconciler may issue per second within any one namespace;
+	// zero or unset means unlimited
+	NamespaceRouteWritesPerSecondKey = "namespace-route-writes-per-second"
+
+	// StalledThresholdMultiplierKey is the data key controlling when a rollout
+	// counts as stalled: no stage transition for this multiple of the stage's
+	// threshold marks the PolicyState Stalled; zero disables the check
+	StalledThresholdMultiplierKey = "stalled-threshold-multiplier"
+
+	// MaxRolloutDurationKey is the data key bounding the counted time a
+	// Policy's stages may add up to; Policies planning longer rollouts are
+	// rejected at admission, and zero or unset means no bound
+	MaxRolloutDurationKey = "max-rollout-duration"
+
+	// MaxActiveRolloutsKey is the data key bounding how many rollouts may be
+	// actively shifting traffic at once across the whole cluster; additional
+	// new Revisions wait at 0% until a slot frees, and zero or unset means
+	// unlimited
+	MaxActiveRolloutsKey = "max-active-rollouts"
+
+	// NamespaceMaxActiveRolloutsKey is the data key bounding how many rollouts
+	// may be actively shifting traffic at once within any one namespace; zero
+	// or unset means unlimited
+	NamespaceMaxActiveRolloutsKey = "namespace-max-active-rollouts"
+
+	// RouteOwnershipModeKey is the data key selecting who re-enforces the
+	// computed traffic split on Routes: "webhook" (the default) lets the
+	// defaulting webhook rewrite every Route update, while "reconciler" makes
+	// the reconciler the sole writer of Route specs, for clusters where
+	// mutating webhooks on Routes are not allowed
+	RouteOwnershipModeKey = "route-ownership-mode"
+
+	// RouteOwnershipWebhook is the default route ownership mode: the
+	// defaulting webhook re-injects the computed split on every Route update
+	RouteOwnershipWebhook = "webhook"
+
+	// RouteOwnershipReconciler makes the reconciler the sole writer of Route
+	// specs; the webhook stands down and does not need to be installed
+	RouteOwnershipReconciler = "reconciler"
+)
+
+// Delivery holds the cluster-wide settings read from the config-delivery ConfigMap
+type Delivery struct {
+	// FreezeRollouts, when true, holds every in-flight rollout at its current
+	// traffic split until the freeze is lifted
+	FreezeRollouts bool
+
+	// HTTPCABundle is the PEM bundle of extra CA certificates trusted by the
+	// outbound HTTP clients, appended to the system roots; empty means system
+	// roots only
+	HTTPCABundle string
+
+	// EnabledNamespaces is the allow list of namespaces KCD may manage; empty
+	// means every namespace
+	EnabledNamespaces []string
+
+	// DisabledNamespaces is the deny list of namespaces KCD must never manage
+	DisabledNamespaces []string
+
+	// RouteWritesPerSecond caps Route updates per second cluster-wide; zero
+	// means unlimited
+	RouteWritesPerSecond float64
+
+	// NamespaceRouteWritesPerSecond caps Route updates per second within any
+	// one namespace; zero means unlimited
+	NamespaceRouteWritesPerSecond float64
+
+	// StalledThresholdMultiplier is how many multiples of a stage's threshold
+	// a rollout may go without a stage transition before it is marked Stalled;
+	// zero disables the check
+	StalledThresholdMultiplier float64
+
+	// MaxRolloutDuration bounds the counted time a Policy's stages may add up
+	// to; zero means no bound
+	MaxRolloutDuration time.Duration
+
+	// MaxActiveRollouts caps how many rollouts may be actively shifting
+	// traffic at once cluster-wide; zero means unlimited
+	MaxActiveRollouts int32
+
+	// NamespaceMaxActiveRollouts caps how many rollouts may be actively
+	// shifting traffic at once within any one namespace; zero means unlimited
+	NamespaceMaxActiveRollouts int32
+
+	// RouteOwnershipMode selects who re-enforces the computed traffic split
+	// on Routes, "webhook" or "reconciler"; empty means "webhook"
+	RouteOwnershipMode string
+}
+
+// ReconcilerOwnsRoutes reports whether the reconciler is the sole writer of
+// Route specs, i.e. the route ownership mode is "reconciler"
+func (d *Delivery) ReconcilerOwnsRoutes() bool {
+	return d.RouteOwnershipMode == RouteOwnershipReconciler
+}
+
+// NamespaceAllowed reports whether KCD may manage workloads in the namespace:
+// a namespace on the deny list is always out, and a non-empty allow list
+// admits only its members
+func (d *Delivery) NamespaceAllowed(namespace string) bool {
+	for _, ns := range d.DisabledNamespaces {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(d.EnabledNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range d.EnabledNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultConfig returns the settings used when config-delivery is absent or a key is unset
+func defaultConfig() *Delivery {
+	return &Delivery{
+		FreezeRollouts:             false,
+		StalledThresholdMultiplier: 3,
+	}
+}
+
+// NewConfigFromConfigMap creates a Delivery config from the supplied ConfigMap
+func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Delivery, error) {
+	return NewConfigFromMap(configMap.Data)
+}
+
+// NewConfigFromMap creates a Delivery config from the supplied data map
+func NewConfigFromMap(data map[string]string) (*Delivery, error) {
+	c := defaultConfig()
+	var enabled, disabled string
+	if err := configmap.Parse(data,
+		configmap.AsBool(FreezeRolloutsKey, &c.FreezeRollouts),
+		configmap.AsString(HTTPCABundleKey, &c.HTTPCABundle),
+		configmap.AsString(EnabledNamespacesKey, &enabled),
+		configmap.AsString(DisabledNamespacesKey, &disabled),
+		configmap.AsFloat64(RouteWritesPerSecondKey, &c.RouteWritesPerSecond),
+		configmap.AsFloat64(NamespaceRouteWritesPerSecondKey, &c.NamespaceRouteWritesPerSecond),
+		configmap.AsFloat64(StalledThresholdMultiplierKey, &c.StalledThresholdMultiplier),
+		configmap.AsDuration(MaxRolloutDurationKey, &c.MaxRolloutDuration),
+		configmap.AsInt32(MaxActiveRolloutsKey, &c.MaxActiveRollouts),
+		configmap.AsInt32(NamespaceMaxActiveRolloutsKey, &c.NamespaceMaxActiveRollouts),
+		configmap.AsString(RouteOwnershipModeKey, &c.RouteOwnershipMode),
+	); err != nil {
+		return nil, err
+	}
+	switch c.RouteOwnershipMode {
+	case "", RouteOwnershipWebhook, RouteOwnershipReconciler:
+	default:
+		return nil, fmt.Errorf("unrecognized %s: %q", RouteOwnershipModeKey, c.RouteOwnershipMode)
+	}
+	c.EnabledNamespaces = splitNamespaces(enabled)
+	c.DisabledNamespaces = splitNamespaces(disabled)
+	return c, nil
+}
+
+// splitNamespaces parses a comma-separated namespace list, dropping empty entries
+func splitNamespaces(list string) []string {
+	var out []string
+	for _, ns := range strings.Split(list, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
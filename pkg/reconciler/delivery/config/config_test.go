@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewConfigFromMap(t *testing.T) {
+	var tests = []struct {
+		name        string
+		data        map[string]string
+		want        *Delivery
+		errExpected bool
+	}{
+		{name: "empty map uses defaults", data: map[string]string{}, want: &Delivery{FreezeRollouts: false, StalledThresholdMultiplier: 3}},
+		{name: "freeze enabled", data: map[string]string{FreezeRolloutsKey: "true"}, want: &Delivery{FreezeRollouts: true, StalledThresholdMultiplier: 3}},
+		{name: "anything but true means false", data: map[string]string{FreezeRolloutsKey: "maybe"}, want: &Delivery{FreezeRollouts: false, StalledThresholdMultiplier: 3}},
+		{name: "CA bundle is carried verbatim", data: map[string]string{HTTPCABundleKey: "-----BEGIN CERTIFICATE-----"}, want: &Delivery{HTTPCABundle: "-----BEGIN CERTIFICATE-----", StalledThresholdMultiplier: 3}},
+		{name: "namespace lists are split and trimmed",
+			data: map[string]string{EnabledNamespacesKey: "team-a, team-b,", DisabledNamespacesKey: "kube-system"},
+			want: &Delivery{EnabledNamespaces: []string{"team-a", "team-b"}, DisabledNamespaces: []string{"kube-system"}, StalledThresholdMultiplier: 3}},
+		{name: "write rate limits are parsed",
+			data: map[string]string{RouteWritesPerSecondKey: "5", NamespaceRouteWritesPerSecondKey: "0.5"},
+			want: &Delivery{RouteWritesPerSecond: 5, NamespaceRouteWritesPerSecond: 0.5, StalledThresholdMultiplier: 3}},
+		{name: "stalled multiplier can be tuned or disabled",
+			data: map[string]string{StalledThresholdMultiplierKey: "0"},
+			want: &Delivery{StalledThresholdMultiplier: 0}},
+		{name: "max rollout duration is parsed",
+			data: map[string]string{MaxRolloutDurationKey: "720h"},
+			want: &Delivery{StalledThresholdMultiplier: 3, MaxRolloutDuration: 720 * time.Hour}},
+		{name: "malformed max rollout duration is rejected",
+			data:        map[string]string{MaxRolloutDurationKey: "a month"},
+			errExpected: true},
+		{name: "rollout concurrency budgets are parsed",
+			data: map[string]string{MaxActiveRolloutsKey: "10", NamespaceMaxActiveRolloutsKey: "2"},
+			want: &Delivery{StalledThresholdMultiplier: 3, MaxActiveRollouts: 10, NamespaceMaxActiveRollouts: 2}},
+		{name: "non-numeric rollout concurrency budget is rejected",
+			data:        map[string]string{MaxActiveRolloutsKey: "a few"},
+			errExpected: true},
+		{name: "route ownership mode is parsed",
+			data: map[string]string{RouteOwnershipModeKey: "reconciler"},
+			want: &Delivery{StalledThresholdMultiplier: 3, RouteOwnershipMode: RouteOwnershipReconciler}},
+		{name: "unknown route ownership mode is rejected",
+			data:        map[string]string{RouteOwnershipModeKey: "operator"},
+			errExpected: true},
+		{name: "non-numeric write rate is rejected",
+			data:        map[string]string{RouteWritesPerSecondKey: "lots"},
+			errExpected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewConfigFromMap(tt.data)
+			if (tt.errExpected && err == nil) || (!tt.errExpected && err != nil) {
+				t.Errorf("error output doesn't match (got %v)", err)
+			}
+			if tt.errExpected {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Delivery config is incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestNamespaceAllowed(t *testing.T) {
+	var tests = []struct {
+		name     string
+		delivery *Delivery
+		ns       string
+		want     bool
+	}{
+		{name: "no lists allow everything", delivery: &Delivery{}, ns: "default", want: true},
+		{name: "allow list admits its members", delivery: &Delivery{EnabledNamespaces: []string{"team-a"}}, ns: "team-a", want: true},
+		{name: "allow list excludes everyone else", delivery: &Delivery{EnabledNamespaces: []string{"team-a"}}, ns: "team-b", want: false},
+		{name: "deny list wins over the allow list", delivery: &Delivery{EnabledNamespaces: []string{"team-a"}, DisabledNamespaces: []string{"team-a"}}, ns: "team-a", want: false},
+		{name: "deny list alone excludes only its members", delivery: &Delivery{DisabledNamespaces: []string{"kube-system"}}, ns: "default", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.delivery.NamespaceAllowed(tt.ns); got != tt.want {
+				t.Errorf("NamespaceAllowed(%q) = %v, want %v", tt.ns, got, tt.want)
+			}
+		})
+	}
+}
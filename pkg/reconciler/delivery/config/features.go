@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/configmap"
+)
+
+const (
+	// FeaturesConfigName is the name of the ConfigMap that gates experimental KCD capabilities
+	FeaturesConfigName = "config-features"
+
+	// MetricGatesKey enables gating stage promotion on observed request metrics
+	MetricGatesKey = "metric-gates"
+
+	// MirroringKey enables the shadow-traffic (request mirroring) stage types
+	MirroringKey = "mirroring"
+
+	// MultiClusterKey enables coordinating rollouts across multiple clusters
+	MultiClusterKey = "multi-cluster"
+)
+
+// Features holds the feature gates read from the config-features ConfigMap
+// every gate defaults to off, so experimental subsystems ship dark and are
+// enabled per cluster without separate builds
+type Features struct {
+	// MetricGates allows Policies to gate promotion on observed request metrics
+	MetricGates bool
+
+	// Mirroring allows Policies to declare shadow-traffic stages
+	Mirroring bool
+
+	// MultiCluster allows rollouts to be coordinated across clusters
+	MultiCluster bool
+}
+
+// defaultFeatures returns the gates used when config-features is absent or a key is unset
+func defaultFeatures() *Features {
+	return &Features{
+		MetricGates:  false,
+		Mirroring:    false,
+		MultiCluster: false,
+	}
+}
+
+// NewFeaturesFromConfigMap creates a Features config from the supplied ConfigMap
+func NewFeaturesFromConfigMap(configMap *corev1.ConfigMap) (*Features, error) {
+	return NewFeaturesFromMap(configMap.Data)
+}
+
+// NewFeaturesFromMap creates a Features config from the supplied data map
+func NewFeaturesFromMap(data map[string]string) (*Features, error) {
+	f := defaultFeatures()
+	if err := configmap.Parse(data,
+		configmap.AsBool(MetricGatesKey, &f.MetricGates),
+		configmap.AsBool(MirroringKey, &f.Mirroring),
+		configmap.AsBool(MultiClusterKey, &f.MultiCluster),
+	); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
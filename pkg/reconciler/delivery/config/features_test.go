@@ -0,0 +1,51 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewFeaturesFromMap(t *testing.T) {
+	var tests = []struct {
+		name        string
+		data        map[string]string
+		want        *Features
+		errExpected bool
+	}{
+		{name: "empty map keeps every gate off", data: map[string]string{}, want: &Features{}},
+		{name: "single gate enabled", data: map[string]string{MirroringKey: "true"}, want: &Features{Mirroring: true}},
+		{name: "multiple gates enabled", data: map[string]string{MetricGatesKey: "true", MultiClusterKey: "true"},
+			want: &Features{MetricGates: true, MultiCluster: true}},
+		{name: "anything but true means off", data: map[string]string{MetricGatesKey: "enabled"}, want: &Features{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewFeaturesFromMap(tt.data)
+			if (tt.errExpected && err == nil) || (!tt.errExpected && err != nil) {
+				t.Errorf("error output doesn't match (got %v)", err)
+			}
+			if tt.errExpected {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Features config is incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// PolicyConfigMapKey is the ConfigMap data key the policy document is read from
+const PolicyConfigMapKey = "policy"
+
+// policyFromConfigMap materializes a Policy from the ConfigMap named by a
+// "configmap:" annotation reference; the document under the "policy" key is the
+// YAML (or JSON) form of a Policy spec and goes through the same defaulting and
+// validation as a Policy resource, so a broken document fails the reconcile
+// loudly instead of silently driving a rollout
+func (c *Reconciler) policyFromConfigMap(ctx context.Context, cfg *v1.Configuration, ref string) (*v1alpha1.Policy, error) {
+	namespace, name := cfg.Namespace, ref
+	if s := strings.SplitN(ref, "/", 2); len(s) > 1 {
+		namespace, name = s[0], s[1]
+	}
+	cm, err := c.kubeclient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	doc, ok := cm.Data[PolicyConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no %q key to read a policy document from", namespace, name, PolicyConfigMapKey)
+	}
+	var spec v1alpha1.PolicySpec
+	if err := yaml.Unmarshal([]byte(doc), &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse the policy document in ConfigMap %s/%s: %v", namespace, name, err)
+	}
+	p := &v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       spec,
+	}
+	p.SetDefaults(ctx)
+	if ferr := p.Validate(ctx); ferr != nil {
+		return nil, fmt.Errorf("invalid policy document in ConfigMap %s/%s: %v", namespace, name, ferr)
+	}
+	return p, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func policyConfigMap(namespace, name, doc string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Data:       map[string]string{PolicyConfigMapKey: doc},
+	}
+}
+
+func TestPolicyFromConfigMap(t *testing.T) {
+	goodDoc := `
+mode: time
+defaultThreshold: 60
+stages:
+- percent: 10
+- percent: 50
+`
+	tests := []struct {
+		name    string
+		objects []*corev1.ConfigMap
+		ref     string
+		wantErr bool
+	}{{
+		name:    "well-formed document in the same namespace",
+		objects: []*corev1.ConfigMap{policyConfigMap("default", "rollout", goodDoc)},
+		ref:     "rollout",
+	}, {
+		name:    "explicit namespace reference",
+		objects: []*corev1.ConfigMap{policyConfigMap("platform", "shared", goodDoc)},
+		ref:     "platform/shared",
+	}, {
+		name:    "missing ConfigMap is an error",
+		ref:     "no-such-configmap",
+		wantErr: true,
+	}, {
+		name:    "missing policy key is an error",
+		objects: []*corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "empty"}}},
+		ref:     "empty",
+		wantErr: true,
+	}, {
+		name:    "unparseable document is an error",
+		objects: []*corev1.ConfigMap{policyConfigMap("default", "garbage", "{not yaml")},
+		ref:     "garbage",
+		wantErr: true,
+	}, {
+		name:    "invalid spec is rejected by validation",
+		objects: []*corev1.ConfigMap{policyConfigMap("default", "bad", "mode: time\nstages:\n- percent: 200\n")},
+		ref:     "bad",
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var objects []runtime.Object
+			for _, cm := range test.objects {
+				objects = append(objects, cm)
+			}
+			c := &Reconciler{kubeclient: kubefake.NewSimpleClientset(objects...)}
+			cfg := &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+			p, err := c.policyFromConfigMap(context.Background(), cfg, test.ref)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("wantErr = %v, but got %v", test.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			// the document goes through defaulting, so the implicit 0-stage is present
+			if len(p.Spec.Stages) != 3 || p.Spec.Stages[0].Percent != 0 {
+				t.Errorf("policy document was not defaulted: %v", p.Spec.Stages)
+			}
+		})
+	}
+}
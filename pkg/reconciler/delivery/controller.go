@@ -16,8 +16,13 @@ package delivery
 
 import (
 	"context"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/googleinterns/knative-continuous-delivery/pkg/catalog"
+
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
@@ -25,11 +30,18 @@ import (
 	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
 	policyinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policy"
 	policystateinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	servingclient "knative.dev/serving/pkg/client/injection/client"
+	configurationinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/configuration"
 	revisioninformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/revision"
 	routeinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/route"
 	configurationreconciler "knative.dev/serving/pkg/client/injection/reconciler/serving/v1/configuration"
 
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/cache"
@@ -47,22 +59,80 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 	logger := logging.FromContext(ctx)
 	routeInformer := routeinformer.Get(ctx)
 	revisionInformer := revisioninformer.Get(ctx)
+	configurationInformer := configurationinformer.Get(ctx)
 	policyInformer := policyinformer.Get(ctx)
 	policystateInformer := policystateinformer.Get(ctx)
 
+	// watch the config-delivery ConfigMap so that cluster-wide settings (e.g. the
+	// rollout freeze, the outbound CA bundle) are attached to the context of
+	// every reconciliation
+	configStore := config.NewStore(logger.Named("config-store"))
+	configStore.WatchConfigs(cmw)
+
 	c := &Reconciler{
-		client:            servingclient.Get(ctx),
-		psclient:          deliveryclient.Get(ctx),
-		routeLister:       routeInformer.Lister(),
-		revisionLister:    revisionInformer.Lister(),
-		policyLister:      policyInformer.Lister(),
-		policystateLister: policystateInformer.Lister(),
-		clock:             clock.RealClock{},
+		client:              servingclient.Get(ctx),
+		psclient:            deliveryclient.Get(ctx),
+		kubeclient:          kubeclient.Get(ctx),
+		httpclient:          newHTTPClient(configStore.Load),
+		routeLister:         routeInformer.Lister(),
+		revisionLister:      revisionInformer.Lister(),
+		configurationLister: configurationInformer.Lister(),
+		policyLister:        policyInformer.Lister(),
+		policystateLister:   policystateInformer.Lister(),
+		preflight:           newPreflightGate(),
+		writeLimiter:        newWriteLimiter(),
+		clock:               clock.RealClock{},
 	}
-	impl := configurationreconciler.NewImpl(ctx, c)
-	// a little hack that allows the reconciler to queue an event for future processing by itself
+
+	// verify the install before letting the reconciler near any Route: CRDs
+	// served, list/watch permissions in place, webhook endpoints ready; until
+	// the checks pass every key is held in the workqueue, and a consolidated
+	// report of what is broken is logged on every retry
+	go func() {
+		for {
+			// the ownership mode is re-read on every retry, so flipping the
+			// ConfigMap to reconciler mode unblocks a webhook-less install
+			// without a restart
+			requireWebhook := !configStore.Load().Delivery.ReconcilerOwnsRoutes()
+			failures := runPreflight(c.kubeclient, c.psclient.Discovery(), requireWebhook)
+			if len(failures) == 0 {
+				c.preflight.pass()
+				logger.Info("Startup preflight passed, reconciliation enabled")
+				return
+			}
+			logger.Warnf("Startup preflight failed, holding reconciliation: %s", strings.Join(failures, "; "))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(PreflightRecheck):
+			}
+		}
+	}()
+
+	impl := configurationreconciler.NewImpl(ctx, c, func(*controller.Impl) controller.Options {
+		return controller.Options{ConfigStore: configStore}
+	})
+	// let the reconciler queue an event for future processing by itself; the
+	// tracker keeps one pending wake-up per Configuration instead of stacking a
+	// timer on every reconcile; with leader election enabled only the replica
+	// leading the key's bucket arms timers, so running several replicas never
+	// double-fires a stage advancement (the generated reconciler re-enqueues
+	// every key of a bucket on promotion, which re-arms the timers there)
+	leaderAware, _ := impl.Reconciler.(interface {
+		IsLeaderFor(types.NamespacedName) bool
+	})
+	tracker := newFollowupTracker(c.clock)
 	c.followup = func(cfg *v1.Configuration, delay time.Duration) {
-		impl.WorkQueue.AddAfter(types.NamespacedName{Namespace: cfg.GetNamespace(), Name: cfg.GetName()}, delay)
+		key := types.NamespacedName{Namespace: cfg.GetNamespace(), Name: cfg.GetName()}
+		if leaderAware != nil && !leaderAware.IsLeaderFor(key) {
+			return
+		}
+		if tracker.track(key, delay) {
+			impl.EnqueueKeyAfter(key, delay)
+		}
+	}
+	c.cancelFollowup = func(cfg *v1.Configuration) {
+		tracker.forget(types.NamespacedName{Namespace: cfg.GetNamespace(), Name: cfg.GetName()})
 	}
 
 	// set up event handlers to put things in the work queue of impl
@@ -75,5 +145,107 @@ func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl
 
 	revisionInformer.Informer().AddEventHandler(handleControllerOf)
 
+	// re-reconcile every Configuration that may be bound to a Policy whenever it
+	// changes, so tightening or loosening stages takes effect promptly instead of
+	// waiting for the next Configuration event; a namespace Policy can bind any
+	// Configuration in its namespace (annotation, selector or default), and the
+	// cluster default in the KCD namespace can bind Configurations anywhere
+	handlePolicyChange := func(obj interface{}) {
+		p, ok := obj.(*v1alpha1.Policy)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if p, ok = tombstone.Obj.(*v1alpha1.Policy); !ok {
+				return
+			}
+		}
+		var cfgs []*v1.Configuration
+		var err error
+		if p.Namespace == KCDNamespace {
+			cfgs, err = configurationInformer.Lister().List(labels.Everything())
+		} else {
+			cfgs, err = configurationInformer.Lister().Configurations(p.Namespace).List(labels.Everything())
+		}
+		if err != nil {
+			logger.Errorw("Failed to list Configurations for Policy change", zap.Error(err))
+			return
+		}
+		for _, cfg := range cfgs {
+			if shouldSkipConfig(cfg) {
+				continue
+			}
+			impl.EnqueueKey(types.NamespacedName{Namespace: cfg.Namespace, Name: cfg.Name})
+		}
+	}
+
+	policyInformer.Informer().AddEventHandler(controller.HandleAll(handlePolicyChange))
+
+	// re-enforce the computed traffic split when something else rewrites the
+	// Route (an operator edit, the Serving reconciler); without this the drift
+	// would only be fixed on the Route's next trip through the webhook
+	handleRouteDrift := func(obj interface{}) {
+		route, ok := obj.(*v1.Route)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if route, ok = tombstone.Obj.(*v1.Route); !ok {
+				return
+			}
+		}
+		ps, err := policystateInformer.Lister().PolicyStates(route.Namespace).Get(route.Name)
+		if err != nil || len(ps.Spec.Traffic) == 0 {
+			return
+		}
+		if equality.Semantic.DeepEqual(route.Spec.Traffic, ps.Spec.Traffic) {
+			return
+		}
+		logger.Infof("Route %s/%s drifted from the desired traffic split, re-enqueueing", route.Namespace, route.Name)
+		impl.EnqueueKey(types.NamespacedName{Namespace: route.Namespace, Name: route.Name})
+	}
+
+	routeInformer.Informer().AddEventHandler(controller.HandleAll(handleRouteDrift))
+
+	// re-arm time-based promotions after a restart: the wake-up timers live only
+	// in memory, so sweep every PolicyState with a pending update once the
+	// caches sync and enqueue its Configuration; reconciling it recomputes the
+	// rollout position and schedules the next followup
+	go func() {
+		if !cache.WaitForCacheSync(ctx.Done(), policystateInformer.Informer().HasSynced) {
+			return
+		}
+		pss, err := policystateInformer.Lister().List(labels.Everything())
+		if err != nil {
+			logger.Errorw("Failed to list PolicyStates for the restart sweep", zap.Error(err))
+			return
+		}
+		for _, ps := range pss {
+			if ps.Status.NextUpdateTimestamp == nil {
+				continue
+			}
+			impl.EnqueueKey(types.NamespacedName{Namespace: ps.Namespace, Name: ps.Name})
+		}
+		logger.Info("Re-enqueued in-flight rollouts after restart")
+	}()
+
+	// optionally expose the read-only rollout feed for service catalogs; it
+	// stays disabled unless a bearer token is configured
+	if token := os.Getenv(catalog.TokenEnvVar); token != "" {
+		srv := catalog.NewServer(catalog.DefaultAddr, token, policystateInformer.Lister(), configurationInformer.Lister())
+		logger.Infof("Serving the rollout catalog feed on %s%s", catalog.DefaultAddr, catalog.FeedPath)
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Errorw("Rollout catalog feed server failed", zap.Error(err))
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+	}
+
 	return impl
 }
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
 	"strings"
 	"time"
 
@@ -29,10 +30,18 @@ import (
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
 	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
 	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
 	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/resources"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/tracing"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
 	pkgreconciler "knative.dev/pkg/reconciler"
 	"knative.dev/serving/pkg/apis/serving"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
@@ -40,6 +49,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
 )
 
 const (
@@ -51,60 +61,237 @@ const (
 	KCDName = "knative-continuous-delivery"
 	// WaitForReady makes sure that when a newly created Revision becomes ready, it triggers the reconciler
 	WaitForReady = 5 * time.Second
+	// FreezeRecheck is how often a frozen rollout re-checks whether the freeze has been lifted
+	FreezeRecheck = 30 * time.Second
+	// HookRecheck is how often a rollout waiting on stage verification Jobs re-checks them
+	HookRecheck = 15 * time.Second
+	// AnalysisRecheck is how often a rollout waiting on an analysis gate re-consults it
+	AnalysisRecheck = 30 * time.Second
+	// StuckDeployGrace is how long a Revision may sit in Ready=Unknown before the
+	// rollout calls it out as stuck (image pull backoff, unschedulable pods, ...)
+	StuckDeployGrace = 2 * time.Minute
+	// StuckRecheck is the slower backoff used once a deploying Revision is stuck;
+	// there is no point in polling a dead image pull every few seconds
+	StuckRecheck = 30 * time.Second
+	// DependencyRecheck is how often a rollout held by a missing ConfigMap or Secret
+	// re-checks whether the dependency has reappeared
+	DependencyRecheck = 30 * time.Second
+	// SlotRecheck is how often a rollout queued behind the concurrency budget
+	// re-checks whether a slot has freed up
+	SlotRecheck = 30 * time.Second
+	// ReplicaParityRecheck is how often a rollout waiting for the candidate Revision
+	// to scale up to replica parity re-counts its ready pods
+	ReplicaParityRecheck = 15 * time.Second
 	// TimeFormat specifies the format used by time.Parse and time.Format
 	TimeFormat = time.RFC3339
+	// CandidateTag is the traffic target tag placed on the candidate Revision so that
+	// Knative mints a distinct preview URL for it during the rollout
+	CandidateTag = "candidate"
 )
 
 // Reconciler implements controller.Reconciler
 type Reconciler struct {
-	client            clientset.Interface
-	psclient          deliveryclientset.Interface
-	routeLister       listers.RouteLister
-	revisionLister    listers.RevisionLister
-	policyLister      pslisters.PolicyLister
-	policystateLister pslisters.PolicyStateLister
-	followup          enqueueFunc
-	clock             clock.Clock
+	client              clientset.Interface
+	psclient            deliveryclientset.Interface
+	kubeclient          kubernetes.Interface
+	httpclient          *http.Client
+	routeLister         listers.RouteLister
+	revisionLister      listers.RevisionLister
+	configurationLister listers.ConfigurationLister
+	policyLister        pslisters.PolicyLister
+	policystateLister   pslisters.PolicyStateLister
+	followup            enqueueFunc
+	cancelFollowup      cancelFunc
+	preflight           *preflightGate
+	writeLimiter        *writeLimiter
+	clock               clock.Clock
+	// metrics supplies per-Revision samples to compare gates whose Policy does
+	// not configure a metricProvider of its own; nil makes those gates no-ops
+	metrics MetricsProvider
 }
 
 // private aliases for the types in Reconciler
 type enqueueFunc func(*v1.Configuration, time.Duration)
+type cancelFunc func(*v1.Configuration)
 
 // Check that our Reconciler implements ksvcreconciler.Interface
 var _ configurationreconciler.Interface = (*Reconciler)(nil)
 
 // ReconcileKind is triggered to enforce the rollout policy
 func (c *Reconciler) ReconcileKind(ctx context.Context, cfg *v1.Configuration) pkgreconciler.Event {
+	// a half-working install must not touch Routes; hold every key until the
+	// startup preflight has passed and let the workqueue retry with backoff
+	if c.preflight != nil && !c.preflight.passed() {
+		return fmt.Errorf("the startup preflight has not passed yet")
+	}
+
 	// ignore changes triggered by continuous-delivery itself
 	if shouldSkipConfig(cfg) {
 		return nil
 	}
 
+	// an explicit opt-out releases the Route back to the Serving reconciler
+	if delivery.Disabled(cfg.Annotations) {
+		return c.releaseConfig(ctx, cfg)
+	}
+
+	// Serving's native rollout-duration feature rewrites the same traffic
+	// targets this reconciler does, and both running at once oscillate; the
+	// webhook refuses the explicit combination, and any workload that still
+	// carries the native annotation is treated like an opt-out here
+	if _, ok := cfg.Annotations[delivery.ServingRolloutDurationKey]; ok {
+		logging.FromContext(ctx).Infof("%s/%s uses Serving's native rollout-duration, leaving it to the Serving reconciler", cfg.Namespace, cfg.Name)
+		return c.releaseConfig(ctx, cfg)
+	}
+
+	// namespaces outside the configured allow/deny lists behave exactly like an
+	// explicit opt-out, so piloting KCD in a few namespaces risks nothing else
+	if !config.FromContextOrDefaults(ctx).Delivery.NamespaceAllowed(cfg.Namespace) {
+		return c.releaseConfig(ctx, cfg)
+	}
+
 	// wait for latest created Revision to be ready
 	if !configReady(cfg) {
-		c.followup(cfg, WaitForReady)
-		return nil
+		return c.waitForLatestCreated(ctx, cfg)
 	}
 
-	// ignore if no policy is specified
-	if _, ok := cfg.Annotations[delivery.PolicyNameKey]; !ok {
-		logging.FromContext(ctx).Infof("No policy specified for %v, skipping", cfg.Namespace+"/"+cfg.Name)
+	// ignore if no policy source applies
+	raw, source, err := c.resolvePolicy(ctx, cfg)
+	if err == errNoPolicy {
+		logging.FromContext(ctx).Infof("No policy applies to %v, skipping", cfg.Namespace+"/"+cfg.Name)
 		return nil
+	} else if err != nil {
+		c.recordAPIError(ctx, cfg, err)
+		return err
 	}
 
-	// check for existing NextUpdateTimestamp to prevent event leaks in case of KCD controller restart, etc.
-	if ps, err := c.fetchPolicyState(cfg); err != nil {
+	// record this Policy's blast radius in its status; failures here must not
+	// stall the rollout itself
+	if err := c.updatePolicyUsage(ctx, raw); err != nil {
+		logging.FromContext(ctx).Warnf("Failed to update usage status of Policy %s/%s: %v", raw.Namespace, raw.Name, err)
+	}
+
+	// when rollouts are frozen cluster-wide, hold the current traffic split and check back later
+	if config.FromContextOrDefaults(ctx).Delivery.FreezeRollouts {
+		return c.holdFrozenRollout(ctx, cfg)
+	}
+
+	ps, err := c.fetchPolicyState(cfg)
+	if err != nil {
 		return err
-	} else if ps.Status.NextUpdateTimestamp != nil && ps.Status.NextUpdateTimestamp.Time.After(c.clock.Now()) {
+	}
+
+	// check for existing NextUpdateTimestamp to prevent event leaks in case of KCD controller restart, etc.
+	if ps.Status.NextUpdateTimestamp != nil && ps.Status.NextUpdateTimestamp.Time.After(c.clock.Now()) {
 		c.followup(cfg, ps.Status.NextUpdateTimestamp.Time.Sub(c.clock.Now()))
 	}
 
-	return c.updateRoute(ctx, cfg)
+	// a self-canarying Policy staggers its own edits across bound Configurations
+	raw = c.applySelfCanary(ctx, cfg, raw, ps)
+
+	// a Pinned policy keeps in-flight rollouts on the spec snapshot they started with
+	effective := c.applyUpdateStrategy(ctx, raw, ps)
+
+	// a reconcile that gets this far has recovered from any translated API
+	// error; the PolicyState update below persists the cleared condition
+	ps.Status.MarkOperational()
+
+	// per-Configuration override annotations layer on top of whatever policy
+	// spec won above, so they apply to pinned snapshots as well
+	policy := applyOverrides(ctx, cfg, translatePolicy(effective.DeepCopy()))
+
+	if err := c.updateRoute(ctx, cfg, ps, policy, source, raw.Namespace+"/"+raw.Name); err != nil {
+		c.recordAPIError(ctx, cfg, err)
+		return err
+	}
+	return nil
+}
+
+// applyUpdateStrategy returns the Policy the rollout should follow, honoring the
+// Policy's update strategy: a Pinned policy snapshots its spec into the
+// PolicyState when a rollout starts, and in-flight rollouts keep following that
+// snapshot even if the Policy is edited; Live (the default) always follows the
+// current spec
+// the snapshot mutation is persisted by whichever PolicyState update the
+// reconciliation performs later
+func (c *Reconciler) applyUpdateStrategy(ctx context.Context, policy *v1alpha1.Policy, ps *v1alpha1.PolicyState) *v1alpha1.Policy {
+	if policy.Spec.UpdateStrategy != v1alpha1.UpdateStrategyPinned {
+		ps.Spec.PinnedPolicy = nil
+		return policy
+	}
+	if ps.Status.NextUpdateTimestamp != nil && ps.Spec.PinnedPolicy != nil {
+		logging.FromContext(ctx).Infof("Policy %s/%s is pinned, following the snapshot taken when the rollout started", policy.Namespace, policy.Name)
+		pinned := policy.DeepCopy()
+		pinned.Spec = *ps.Spec.PinnedPolicy.DeepCopy()
+		return pinned
+	}
+	// a new rollout (re)takes the snapshot and follows the live spec
+	ps.Spec.PinnedPolicy = policy.Spec.DeepCopy()
+	return policy
+}
+
+// waitForLatestCreated schedules a re-check while the latest created Revision is
+// still deploying; a Revision that has sat in Ready=Unknown for longer than
+// StuckDeployGrace is called out with a dedicated condition and re-checked on a
+// slower cadence, so that stuck deploys are easy to tell apart from healthy ones
+func (c *Reconciler) waitForLatestCreated(ctx context.Context, cfg *v1.Configuration) error {
+	rev, err := c.revisionLister.Revisions(cfg.Namespace).Get(cfg.Status.LatestCreatedRevisionName)
+	if err != nil {
+		// the Revision may not have reached the indexer yet; use the generic backoff
+		c.followup(cfg, WaitForReady)
+		return nil
+	}
+	cond := rev.Status.GetCondition(v1.RevisionConditionReady)
+	if cond != nil && cond.IsUnknown() && c.clock.Since(cond.LastTransitionTime.Inner.Time) > StuckDeployGrace {
+		ps, err := c.fetchPolicyState(cfg)
+		if err != nil {
+			return err
+		}
+		ps.Status.MarkRevisionStuckDeploying(rev.Name)
+		ps.Status.TransitionPhase(v1alpha1.PhaseStalled)
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Infof("Revision %s has been deploying for over %v, backing off", rev.Name, StuckDeployGrace)
+		c.followup(cfg, StuckRecheck)
+		return nil
+	}
+	c.recordPhase(ctx, cfg, v1alpha1.PhasePending)
+	c.followup(cfg, WaitForReady)
+	return nil
+}
+
+// releaseConfig cleans up after a workload that opted out of continuous
+// delivery: deleting the PolicyState stops the defaulting webhook from copying
+// a traffic split into the Route, so the Serving reconciler takes back over
+func (c *Reconciler) releaseConfig(ctx context.Context, cfg *v1.Configuration) error {
+	// a Route the reconciler owned keeps no ownership marker once released
+	if err := c.releaseRouteOwnership(ctx, cfg); err != nil {
+		return err
+	}
+	if _, err := c.policystateLister.PolicyStates(cfg.Namespace).Get(cfg.Name); apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Infof("Continuous delivery is disabled for %s/%s, deleting its PolicyState", cfg.Namespace, cfg.Name)
+	if err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Delete(cfg.Name, &metav1.DeleteOptions{}); err != nil && !apierrs.IsNotFound(err) {
+		return err
+	}
+	if c.cancelFollowup != nil {
+		c.cancelFollowup(cfg)
+	}
+	return nil
 }
 
 // shouldSkipConfig determines if we should do a no-op because the reconciler is triggered
 // by changes in KCD itself
 func shouldSkipConfig(cfg *v1.Configuration) bool {
+	// baseline Configurations exist only for compare gates to sample; running a
+	// rollout on one would recurse
+	if cfg.Labels[delivery.BaselineForKey] != "" {
+		return true
+	}
 	return cfg.Namespace == KCDNamespace && cfg.Name == KCDName
 }
 
@@ -139,17 +326,6 @@ func (c *Reconciler) fetchRevisions(cfg *v1.Configuration) (map[string]*v1.Revis
 	return revisionMap, nil
 }
 
-// fetchPolicy queries the indexer to retrieve a Policy object and return its translated version
-// if annotations don't specify a Policy or if the specified Policy cannot be found, an error is returned
-func (c *Reconciler) fetchPolicy(cfg *v1.Configuration) (*Policy, error) {
-	policyNamespace, policyName := identifyPolicy(cfg)
-	p, err := c.policyLister.Policies(policyNamespace).Get(policyName)
-	if err != nil {
-		return nil, err
-	}
-	return translatePolicy(p.DeepCopy()), nil
-}
-
 // fetchPolicyState queries the indexer to retrieve a PolicyState object whose namespace/name match with cfg
 // it creates one if a PolicyState object doesn't already exist for the given namespace/name
 func (c *Reconciler) fetchPolicyState(cfg *v1.Configuration) (*v1alpha1.PolicyState, error) {
@@ -166,16 +342,300 @@ func (c *Reconciler) fetchPolicyState(cfg *v1.Configuration) (*v1alpha1.PolicySt
 	return ps.DeepCopy(), nil
 }
 
+// holdFrozenRollout records the Frozen condition (and the freeze start time, used later to
+// restore the remaining thresholds) without touching the Route, then schedules a re-check
+func (c *Reconciler) holdFrozenRollout(ctx context.Context, cfg *v1.Configuration) error {
+	ps, err := c.fetchPolicyState(cfg)
+	if err != nil {
+		return err
+	}
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+	}
+	ps.Status.MarkFrozen()
+	ps.Status.TransitionPhase(v1alpha1.PhasePaused)
+	if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Rollouts are frozen cluster-wide, holding the current traffic split")
+	c.followup(cfg, FreezeRecheck)
+	return nil
+}
+
+// holdUnhealthyRollout pauses the stage timer while the candidate Revision is unready,
+// emitting a Warning event so that the regression is visible to the rollout's owner
+// the hold reuses the freeze bookkeeping: its duration is folded into PauseOffsetSeconds
+// once the Revision recovers, so no stages are skipped while it was unhealthy
+func (c *Reconciler) holdUnhealthyRollout(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, rev *v1.Revision) error {
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(cfg, corev1.EventTypeWarning, "RevisionUnhealthy",
+			"Revision %q is not ready; pausing rollout until it recovers", rev.Name)
+	}
+	changed := ps.Status.TransitionPhase(v1alpha1.PhasePaused)
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		changed = true
+	}
+	if changed {
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("Revision %s is unhealthy, holding the current traffic split", rev.Name)
+	c.followup(cfg, WaitForReady)
+	return nil
+}
+
+// holdUnprogrammedRoute pauses the stage timer while the ingress has not yet
+// programmed the last requested split; scheduling purely on the wall clock
+// would count programming latency as soak time the split never actually served
+// like the other holds it reuses the freeze bookkeeping, so the wait is folded
+// into PauseOffsetSeconds once the Route catches up
+func (c *Reconciler) holdUnprogrammedRoute(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, route *v1.Route) error {
+	changed := ps.Status.TransitionPhase(v1alpha1.PhasePaused)
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		changed = true
+	}
+	if changed {
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("Route %s has not programmed the requested split yet, holding the stage timer", route.Name)
+	c.followup(cfg, WaitForReady)
+	return nil
+}
+
+// deadlineExceeded determines whether a held rollout has been stuck for longer than
+// the Policy's progress deadline allows
+func deadlineExceeded(policy *Policy, ps *v1alpha1.PolicyState, clock clock.Clock) bool {
+	if policy.ProgressDeadlineSeconds == nil || ps.Status.FreezeStartTime == nil {
+		return false
+	}
+	return clock.Since(ps.Status.FreezeStartTime.Time) > time.Duration(*policy.ProgressDeadlineSeconds)*time.Second
+}
+
+// failRollout declares the rollout failed after exceeding its progress deadline
+// what happens to traffic is decided by failureResponse: a held failure keeps
+// the current split and re-checks, the other responses are terminal and may
+// send all traffic back to the stable (oldest) Revision first
+func (c *Reconciler) failRollout(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) error {
+	return c.finishFailedRollout(ctx, cfg, route, ps, revisionMap, policy, func() {
+		logging.FromContext(ctx).Infof("Rollout for %s/%s exceeded its progress deadline", cfg.Namespace, cfg.Name)
+		if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+			msg := fmt.Sprintf("Rollout was unable to advance for more than %d seconds", *policy.ProgressDeadlineSeconds)
+			if actor := ps.Status.TriggeredBy; actor != "" {
+				msg += fmt.Sprintf(" (deployed by %s)", actor)
+			}
+			recorder.Event(cfg, corev1.EventTypeWarning, "ProgressDeadlineExceeded", msg)
+		}
+		ps.Status.MarkProgressDeadlineExceeded(*policy.ProgressDeadlineSeconds)
+		c.notifyRolloutFailed(ctx, cfg, policy, route, ps.Status.TriggeredBy)
+	})
+}
+
+// finishFailedRollout drives any terminal rollout failure through the Policy's
+// failure response: a held failure keeps the current split and re-checks, a
+// rollback walks traffic back to the stable Revision (gradually when rollback
+// stages are configured) and everything else aborts in place; report runs only
+// on first entry, so re-entries of a held failure or a gradual rollback stay
+// quiet instead of re-reporting the failure
+func (c *Reconciler) finishFailedRollout(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy, report func()) error {
+	response := failureResponse(cfg, policy)
+	if response == v1alpha1.FailureHold && ps.Status.Phase == v1alpha1.PhaseStalled {
+		c.followup(cfg, FreezeRecheck)
+		return nil
+	}
+	if response == v1alpha1.FailureRollback && ps.Status.Phase == v1alpha1.PhaseRollingBack {
+		done, err := c.stepGradualRollback(ctx, cfg, route, ps, revisionMap, policy)
+		if err != nil {
+			return err
+		}
+		if done {
+			ps.Status.TransitionPhase(v1alpha1.PhaseAborted)
+			if err := c.releaseScalePins(ctx, cfg, revisionMap); err != nil {
+				return err
+			}
+			if policy.SpawnBaseline {
+				if err := c.cleanupBaseline(ctx, cfg); err != nil {
+					return err
+				}
+			}
+		}
+		_, err = c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps)
+		return err
+	}
+	report()
+	ps.Status.NextUpdateTimestamp = nil
+	switch response {
+	case v1alpha1.FailureHold:
+		// keep the current split and keep watching; if the candidate recovers
+		// before an operator intervenes, the freeze lifts and the rollout resumes
+		ps.Status.TransitionPhase(v1alpha1.PhaseStalled)
+		c.followup(cfg, FreezeRecheck)
+	case v1alpha1.FailureRollback:
+		ps.Status.TransitionPhase(v1alpha1.PhaseRollingBack)
+		if rollbackStages(policy) != nil {
+			// step down through the rollback stages instead of snapping back:
+			// the stable Revision may have scaled down during the rollout, and
+			// an instant 100% would cold-start it under the full load
+			done, err := c.stepGradualRollback(ctx, cfg, route, ps, revisionMap, policy)
+			if err != nil {
+				return err
+			}
+			if done {
+				ps.Status.TransitionPhase(v1alpha1.PhaseAborted)
+			}
+		} else {
+			if err := c.rollbackToStable(cfg, route, ps, revisionMap); err != nil {
+				return err
+			}
+			ps.Status.TransitionPhase(v1alpha1.PhaseAborted)
+		}
+	default:
+		ps.Status.TransitionPhase(v1alpha1.PhaseAborted)
+	}
+	// a failed candidate no longer needs to be kept warm (a held rollout does:
+	// it may still recover and resume, and a gradual rollback is still serving
+	// traffic from it); the baseline goes with it
+	if response != v1alpha1.FailureHold && ps.Status.Phase != v1alpha1.PhaseRollingBack {
+		if err := c.releaseScalePins(ctx, cfg, revisionMap); err != nil {
+			return err
+		}
+		if policy.SpawnBaseline {
+			if err := c.cleanupBaseline(ctx, cfg); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps)
+	return err
+}
+
+// failureResponse resolves what a failed rollout does with traffic: the
+// per-Configuration annotation wins over the Policy's onFailure field, which
+// wins over the older rollbackOnFailure flag
+func failureResponse(cfg *v1.Configuration, policy *Policy) string {
+	if v, ok := cfg.Annotations[delivery.OnFailureKey]; ok && v1alpha1.ValidFailureResponse(v) {
+		return v
+	}
+	if policy.OnFailure != "" {
+		return policy.OnFailure
+	}
+	if policy.RollbackOnFailure {
+		return v1alpha1.FailureRollback
+	}
+	return v1alpha1.FailureAbort
+}
+
+// rollbackToStable routes all traffic back to the stable (oldest) Revision
+func (c *Reconciler) rollbackToStable(cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision) error {
+	oldest := oldestRevision(revisionMap)
+	if oldest == nil {
+		return nil
+	}
+	route.Spec.Traffic = []v1.TrafficTarget{traffictarget.Pinned(oldest.Name, 100)}
+	ps.Spec.Traffic = route.Spec.Traffic
+	_, err := c.client.ServingV1().Routes(cfg.Namespace).Update(route)
+	return err
+}
+
+// stepGradualRollback moves traffic one rollback stage closer to the stable
+// Revision; the candidate's target share descends with the time elapsed since
+// RollbackStartTime, exactly like the forward arithmetic but in reverse
+// It returns true once all traffic is back on the stable Revision; until then
+// a followup re-enters this function at the next stage boundary
+func (c *Reconciler) stepGradualRollback(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	stable := oldestRevision(revisionMap)
+	candidate := cfg.Status.LatestReadyRevisionName
+	if stable == nil || stable.Name == candidate {
+		return true, nil
+	}
+	if ps.Status.RollbackStartTime == nil {
+		ps.Status.RollbackStartTime = &metav1.Time{Time: c.clock.Now()}
+	}
+	target, wait := rollbackTarget(rollbackStages(policy), policy.DefaultThreshold, c.clock.Since(ps.Status.RollbackStartTime.Time))
+	// never move traffic back toward the failed candidate; a rollback
+	// triggered mid-ramp skips the stages above the current share
+	if current := candidatePercent(route, candidate); target > current {
+		target = current
+	}
+	if target <= 0 {
+		return true, c.rollbackToStable(cfg, route, ps, revisionMap)
+	}
+	route.Spec.Traffic = []v1.TrafficTarget{
+		traffictarget.Pinned(stable.Name, int64(100-target)),
+		traffictarget.Tagged(traffictarget.Pinned(candidate, int64(target)), CandidateTag),
+	}
+	ps.Spec.Traffic = route.Spec.Traffic
+	if _, err := c.client.ServingV1().Routes(cfg.Namespace).Update(route); err != nil {
+		return false, err
+	}
+	logging.FromContext(ctx).Infof("Rolling back gradually: candidate %s holds %d%%, next step down in %v", candidate, target, wait)
+	c.followup(cfg, wait)
+	return false, nil
+}
+
 // applyChanges applies the newly create Route and PolicyState objects and wraps up the reconciliation
 func (c *Reconciler) applyChanges(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, p *Policy) error {
 	logger := logging.FromContext(ctx)
 
+	// a configurable token bucket keeps a burst of rollouts from flooding the
+	// API server with Route updates; a postponed write loses nothing, the
+	// followup re-runs the reconcile once a token is available
+	if c.writeLimiter != nil {
+		delivery := config.FromContextOrDefaults(ctx).Delivery
+		if delay, throttled := c.writeLimiter.throttle(c.clock.Now(), cfg.Namespace, delivery); throttled {
+			logger.Infof("Route write throttled by the rate limiter, retrying in %v", delay)
+			if tctx, err := tag.New(ctx, tag.Insert(throttledNamespaceKey, cfg.Namespace)); err == nil {
+				metrics.Record(tctx, throttledWriteCount.M(1))
+			}
+			c.followup(cfg, delay)
+			return nil
+		}
+	}
+
+	// start a stage transition span and hand its context to the webhook through
+	// the PolicyState annotations, so a distributed trace covers the full
+	// reconcile -> webhook -> Route update path of this traffic change
+	ctx, span := trace.StartSpan(ctx, "kcd.stage-transition")
+	defer span.End()
+	span.AddAttributes(
+		trace.StringAttribute("namespace", cfg.Namespace),
+		trace.StringAttribute("configuration", cfg.Name),
+		trace.StringAttribute("revision", cfg.Status.LatestReadyRevisionName),
+	)
+	// persisting the context is only worthwhile for sampled traces; unsampled
+	// transitions clear it instead, so the webhook never parents a mutation to
+	// a span from some earlier rollout
+	if sc := span.SpanContext(); sc.IsSampled() {
+		tracing.Inject(sc, ps)
+	} else {
+		tracing.Clear(ps)
+	}
+
 	// first compute whether or not we need to enqueue events for future rollout stages
 	if *route.Spec.Traffic[0].LatestRevision {
 		logger.Info("Routing state has stabilized!")
 		ps.Status.NextUpdateTimestamp = nil
+		ps.Status.TransitionPhase(v1alpha1.PhaseCompleted)
+		if rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]; ok {
+			if err := c.releaseCandidateMinScale(ctx, rev); err != nil {
+				return err
+			}
+		}
+		if err := c.restoreScaleTransfer(ctx, revisionMap, cfg.Status.LatestReadyRevisionName); err != nil {
+			return err
+		}
+		if p.SpawnBaseline {
+			if err := c.cleanupBaseline(ctx, cfg); err != nil {
+				return err
+			}
+		}
 	} else {
-		delay, err := timeTillNextEvent(route, revisionMap, p, c.clock)
+		ps.Status.TransitionPhase(v1alpha1.PhaseProgressing)
+		delay, err := timeTillNextEvent(route, revisionMap, p, c.clock, pauseOffset(ps))
 		if err != nil {
 			return err
 		}
@@ -186,31 +646,77 @@ func (c *Reconciler) applyChanges(ctx context.Context, cfg *v1.Configuration, ro
 		ps.Status.NextUpdateTimestamp = &metav1.Time{
 			c.clock.Now().Add(delay),
 		}
+		if p.TransferMinScale {
+			if err := c.applyScaleTransfer(ctx, route, revisionMap, cfg.Status.LatestReadyRevisionName); err != nil {
+				return err
+			}
+		}
+		if p.CandidateMinScale != nil {
+			if rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]; ok {
+				if err := c.holdCandidateMinScale(ctx, rev, *p.CandidateMinScale); err != nil {
+					return err
+				}
+			}
+		}
+		if p.SpawnBaseline {
+			if err := c.ensureBaseline(ctx, cfg, revisionMap); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Revisions that dropped out of the desired split keep none of their
+	// rollout leftovers, whether the rollout completed or squeezed them out
+	if err := c.cleanupRetiredRevisions(ctx, cfg, route, revisionMap); err != nil {
+		return err
 	}
 
+	ps.Status.CandidateURL = candidateURL(route, cfg.Status.LatestReadyRevisionName)
+	ps.Status.AssignmentSeed = assignmentSeed(p.AssignmentSalt, cfg.Namespace, cfg.Status.LatestReadyRevisionName)
+
+	recordRolloutSLIs(ctx, cfg, ps, route, revisionMap, c.clock)
+	c.checkStalledRollout(ctx, cfg, ps, route, revisionMap, p)
+
+	// mirror the enforced split into the status; the webhook used to write
+	// this during admission, but status bookkeeping belongs to the reconciler
+	ps.Status.Traffic = ps.Spec.Traffic
+
 	logger.Info("Applying PolicyState object")
-	_, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps)
-	if err != nil {
+	if err := c.patchPolicyState(ps); err != nil {
 		return err
 	}
 	logger.Info("Applying Route object")
-	_, err = c.client.ServingV1().Routes(cfg.Namespace).Update(route)
-	if err != nil {
+	if err := c.patchRoute(ctx, cfg, route); err != nil {
 		return err
 	}
 	return nil
 }
 
+// candidateURL extracts the tagged preview URL of the candidate Revision from the
+// Route status, if Knative has minted one already
+func candidateURL(route *v1.Route, revName string) string {
+	for _, t := range route.Status.Traffic {
+		if t.Tag == CandidateTag && t.RevisionName == revName && t.URL != nil {
+			return t.URL.String()
+		}
+	}
+	return ""
+}
+
 // updateRoute assigns traffic to active Revisions, applies new Route, and enqueues future events
-func (c *Reconciler) updateRoute(ctx context.Context, cfg *v1.Configuration) error {
+// policySource and policyRef identify where the resolved Policy came from, for the PolicyState status
+func (c *Reconciler) updateRoute(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, policy *Policy, policySource, policyRef string) error {
 	route, err := c.fetchRoute(ctx, cfg)
 	if err != nil {
 		return err
 	}
 
-	policy, err := c.fetchPolicy(cfg)
-	if err != nil {
-		return err
+	// hold the current traffic split when we are outside all promotion windows
+	if !withinWindow(policy, c.clock.Now()) {
+		delay := timeTillWindowOpen(policy, c.clock.Now())
+		logging.FromContext(ctx).Infof("Outside of all promotion windows, holding rollout for %v", delay)
+		c.followup(cfg, delay)
+		return nil
 	}
 
 	revisionMap, err := c.fetchRevisions(cfg)
@@ -218,20 +724,134 @@ func (c *Reconciler) updateRoute(ctx context.Context, cfg *v1.Configuration) err
 		return err
 	}
 
-	ps, err := c.fetchPolicyState(cfg)
-	if err != nil {
+	// the latest Revision made it through deployment, so clear any stuck-deploy marker
+	ps.Status.MarkRevisionDeployed()
+
+	// record which policy source won the resolution, so conflicts are debuggable
+	ps.Status.PolicySource = policySource
+	ps.Status.PolicyName = policyRef
+
+	// record who deployed the candidate, so notifications and audits can
+	// attribute the rollout to an actor
+	ps.Status.TriggeredBy = rolloutActor(cfg, revisionMap[cfg.Status.LatestReadyRevisionName])
+
+	// an unhealthy candidate must not be promoted any further; hold the rollout
+	// (pausing the stage timer) until the Revision becomes ready again
+	// a missing or Unknown Ready condition is NOT treated as unhealthy, only an
+	// explicit failure is, so freshly created Revisions proceed normally
+	if rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]; ok && rev.Status.GetCondition(v1.RevisionConditionReady).IsFalse() {
+		if deadlineExceeded(policy, ps, c.clock) {
+			return c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+		}
+		return c.holdUnhealthyRollout(ctx, cfg, ps, rev)
+	}
+
+	// a split the reconciler wrote earlier is not real exposure until the ingress
+	// programs it; hold the stage timer until the Route's observed traffic catches
+	// up, so stage durations measure time the split actually served
+	if !routeProgrammed(route, ps) {
+		return c.holdUnprogrammedRoute(ctx, cfg, ps, route)
+	}
+
+	// a rollout that has not claimed any traffic yet queues behind the
+	// cluster's concurrency budget until a slot frees up
+	if held, err := c.gateOnConcurrencyBudget(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
 		return err
 	}
 
-	route, err = modifyRouteSpec(route, revisionMap, cfg.Status.LatestReadyRevisionName, policy, c.clock)
-	if err != nil {
+	// a stage that declares verification hooks may not be left behind until all of
+	// its hook Jobs have completed; waiting for them pauses the stage timer
+	if held, err := c.gateOnStageHooks(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
+		return err
+	}
+
+	// likewise, a stage that declares an analysis gate may not be left behind until
+	// the external endpoint approves the promotion
+	if held, err := c.gateOnAnalysis(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
+		return err
+	}
+
+	// a stage that declares a compare gate may not be left behind until the
+	// candidate scores well enough against the stable Revision
+	if held, err := c.gateOnCompare(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
+		return err
+	}
+
+	// a candidate that is burning through the Policy's error budget faster than
+	// allowed must not be promoted any further; the failure response decides
+	// whether traffic holds or rolls back
+	if held, err := c.gateOnSLO(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
+		return err
+	}
+
+	// a candidate whose referenced ConfigMaps or Secrets have been deleted must not
+	// receive any more traffic until the dependencies are restored
+	if held, err := c.gateOnDependencies(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
+		return err
+	}
+
+	// a candidate with too few ready pods must not be handed its first traffic
+	// share; wait for it to scale up to the required fraction of the stable Revision
+	if held, err := c.gateOnReplicaParity(ctx, cfg, route, ps, revisionMap, policy); held || err != nil {
 		return err
 	}
+
+	// if a freeze just ended, fold its duration into the pause offset so that the
+	// rollout resumes with the remaining thresholds intact instead of skipping ahead
+	if ps.Status.FreezeStartTime != nil {
+		ps.Status.PauseOffsetSeconds += int(c.clock.Since(ps.Status.FreezeStartTime.Time) / time.Second)
+		ps.Status.FreezeStartTime = nil
+		ps.Status.MarkUnfrozen()
+	}
+
+	// remember where the candidate stood before this update, so notifications
+	// only fire when the traffic split actually changed
+	prevPercent := currentPercent(route, cfg.Status.LatestReadyRevisionName)
+	wasInFlight := ps.Status.NextUpdateTimestamp != nil
+
+	// an experiment Policy splits traffic across its tracks instead of the
+	// shared ladder, once both arms exist; until then the ladder applies
+	experimentHandled := false
+	if policy.Experiment != nil {
+		experimentHandled = c.applyExperiment(ctx, cfg, route, revisionMap, policy, pauseOffset(ps))
+	}
+	if !experimentHandled {
+		route, err = modifyRouteSpec(route, revisionMap, cfg.Status.LatestReadyRevisionName, policy, c.clock, pauseOffset(ps))
+		if err != nil {
+			return err
+		}
+	}
+	// an experimental time-sliced stage alternates the candidate between its
+	// stage percent and a 0% control slice; apply the current phase and wake up
+	// again at the flip
+	if untilFlip := c.applyTimeSlice(ctx, cfg, route, ps, revisionMap, policy); untilFlip > 0 {
+		c.followup(cfg, untilFlip)
+	}
+	applyMaxUnavailable(ctx, route, revisionMap, policy)
+	applyMirror(ctx, route, policy, cfg.Status.LatestReadyRevisionName)
+	applyHeaderRouting(ctx, route, policy, cfg.Status.LatestReadyRevisionName)
 	ps.Spec = v1alpha1.PolicyStateSpec{
-		Traffic: route.Spec.Traffic,
+		FormatVersion: v1alpha1.PolicyStateFormatVersion,
+		Traffic:       route.Spec.Traffic,
+		// carry the pinned Policy snapshot forward; applyUpdateStrategy owns it
+		PinnedPolicy: ps.Spec.PinnedPolicy,
+		// likewise the self-canary adoption snapshot, owned by applySelfCanary
+		AdoptedPolicy:     ps.Spec.AdoptedPolicy,
+		AdoptedGeneration: ps.Spec.AdoptedGeneration,
+	}
+
+	// without a webhook re-writing Routes, the ownership annotation is the
+	// only marker telling other tooling that this spec is managed
+	if config.FromContextOrDefaults(ctx).Delivery.ReconcilerOwnsRoutes() {
+		markRouteOwned(route)
 	}
 
-	return c.applyChanges(ctx, cfg, route, ps, revisionMap, policy)
+	if err := c.applyChanges(ctx, cfg, route, ps, revisionMap, policy); err != nil {
+		return err
+	}
+	c.notifyTrafficChange(ctx, cfg, policy, prevPercent, wasInFlight, route, ps.Status.TriggeredBy)
+	c.annotateRolloutStatus(ctx, cfg, ps, policy, route)
+	return nil
 }
 
 // min is a helper that returns the minimum of an arbitrary number of integers
@@ -249,9 +869,18 @@ func min(items ...int) int {
 }
 
 // timeTillNextEvent calculates the time to wait before enqueueing the next event
-func timeTillNextEvent(route *v1.Route, r map[string]*v1.Revision, policy *Policy, clock clock.Clock) (time.Duration, error) {
+func timeTillNextEvent(route *v1.Route, r map[string]*v1.Revision, policy *Policy, clock clock.Clock, pause time.Duration) (time.Duration, error) {
 	result := math.MaxInt32
 	oldest := oldestRevision(r)
+	// a running experiment is paced by its tracks, not by the shared ladder
+	if policy.Experiment != nil {
+		if arms := experimentArms(r, len(policy.Experiment.Tracks)); len(arms) == len(policy.Experiment.Tracks) {
+			for i, arm := range arms {
+				result = min(trackWait(policy.Experiment.Tracks[i], policy.DefaultThreshold, rolloutElapsed(clock, arm, pause)), result)
+			}
+			return time.Duration(result) * time.Second, nil
+		}
+	}
 	// compute how long each Revision would like to wait, and then take the minimum
 	for _, t := range route.Spec.Traffic {
 		revision, ok := r[t.RevisionName]
@@ -261,12 +890,40 @@ func timeTillNextEvent(route *v1.Route, r map[string]*v1.Revision, policy *Polic
 		if revision == oldest {
 			continue
 		}
-		timeElapsed := clock.Since(revision.CreationTimestamp.Time)
+		// a Revision waiting for its scheduled start needs a wakeup at that
+		// moment rather than at the first stage boundary
+		if wait := startAtWait(clock, revision); wait > 0 {
+			result = min(int(wait/time.Second)+1, result)
+			continue
+		}
+		timeElapsed := jitteredElapsed(policy, revision, rolloutElapsed(clock, revision, pause))
 		result = min(metricTillNextStage(policy, timeElapsed), result)
 	}
 	return time.Duration(result) * time.Second, nil
 }
 
+// rolloutElapsed computes how long a Revision has effectively been rolling out,
+// discounting any time the rollout spent frozen
+func rolloutElapsed(clock clock.Clock, revision *v1.Revision, pause time.Duration) time.Duration {
+	from := revision.CreationTimestamp.Time
+	// a scheduled start counts rollout time from that moment, so the ladder
+	// begins at its first stage when the schedule opens instead of jumping to
+	// wherever the Revision's age lands
+	if at, ok := startAt(revision.Annotations); ok && at.After(from) {
+		from = at
+	}
+	elapsed := clock.Since(from) - pause
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return elapsed
+}
+
+// pauseOffset extracts the accumulated frozen duration recorded on a PolicyState
+func pauseOffset(ps *v1alpha1.PolicyState) time.Duration {
+	return time.Duration(ps.Status.PauseOffsetSeconds) * time.Second
+}
+
 // oldestRevision returns the oldest revision (as determined by creation timestamp)
 func oldestRevision(r map[string]*v1.Revision) *v1.Revision {
 	var result *v1.Revision
@@ -15,12 +15,14 @@
 package delivery
 
 import (
+	"context"
 	"math"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
 	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"knative.dev/pkg/ptr"
@@ -41,6 +43,9 @@ func TestShouldSkipConfig(t *testing.T) {
 		{name: "name matches, but namespace doesn't", cfg: Configuration("random", KCDName), want: false},
 		{name: "namespace and name both match", cfg: Configuration(KCDNamespace, KCDName), want: true},
 		{name: "neither namespace nor name matches", cfg: Configuration("random_namespace", "random_name"), want: false},
+		{name: "baseline Configurations are skipped", cfg: Configuration("random", "cfg-baseline", func(c *v1.Configuration) {
+			c.Labels = map[string]string{delivery.BaselineForKey: "cfg"}
+		}), want: true},
 	}
 
 	for _, tt := range tests {
@@ -168,7 +173,7 @@ func TestTimeTillNextEvent(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ans, e := timeTillNextEvent(tt.route, tt.revMap, tt.policy, tt.clock)
+			ans, e := timeTillNextEvent(tt.route, tt.revMap, tt.policy, tt.clock, 0)
 			if ans != tt.want {
 				t.Errorf("wrong answer (got %v, want %v)", ans, tt.want)
 			}
@@ -230,11 +235,20 @@ func TestModifyRouteSpec(t *testing.T) {
 		revName := "R" + strconv.Itoa(i)
 		largeTestRouteTraffic[i-101] = pair{revName, 1}
 	}
-	for i := 0; i < 99; i++ {
+	// the stable Revision at the front of the pool survives the cap; the oldest
+	// mid-rollout entry (R102) is the one evicted to make room for R201
+	largeTestRouteTrafficNew[0] = largeTestRouteTraffic[0]
+	for i := 1; i < 99; i++ {
 		largeTestRouteTrafficNew[i] = largeTestRouteTraffic[i+1]
 	}
 	largeTestRouteTrafficNew[99] = pair{"R201", 1}
 
+	var cappedPool = pa
+	cappedPool.MaxActiveRevisions = intptr(3)
+
+	var flooredPool = pa
+	flooredPool.MinStablePercent = intptr(95)
+
 	var tests = []struct {
 		name        string
 		route       *v1.Route
@@ -274,7 +288,7 @@ func TestModifyRouteSpec(t *testing.T) {
 		policy:     &pa,
 		clock:      timer,
 		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 95}, pair{"R2", 5}),
-			withTraffic(WithSpecTraffic, pair{"R1", 94}, pair{"R2", 5}, pair{"R3", 1})),
+			withTraffic(WithSpecTraffic, pair{"R1", 94}, pair{"R2", 5}, pair{"R3", 1}), withSpecCandidateTag("R3")),
 		errExpected: false,
 	}, {
 		name:  "promotion, but pool size doesn't change",
@@ -288,7 +302,7 @@ func TestModifyRouteSpec(t *testing.T) {
 		policy:     &pa,
 		clock:      timer,
 		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 94}, pair{"R2", 5}, pair{"R3", 1}),
-			withTraffic(WithSpecTraffic, pair{"R1", 93}, pair{"R2", 6}, pair{"R3", 1})),
+			withTraffic(WithSpecTraffic, pair{"R1", 93}, pair{"R2", 6}, pair{"R3", 1}), withSpecCandidateTag("R3")),
 		errExpected: false,
 	}, {
 		name:  "promotion, and pool size shrinks",
@@ -302,7 +316,7 @@ func TestModifyRouteSpec(t *testing.T) {
 		policy:     &pa,
 		clock:      timer,
 		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 85}, pair{"R2", 8}, pair{"R3", 7}),
-			withTraffic(WithSpecTraffic, pair{"R2", 93}, pair{"R3", 7})),
+			withTraffic(WithSpecTraffic, pair{"R2", 93}, pair{"R3", 7}), withSpecCandidateTag("R3")),
 		errExpected: false,
 	}, {
 		name:  "oldest revision always ignores progression/timer",
@@ -315,7 +329,7 @@ func TestModifyRouteSpec(t *testing.T) {
 		policy:     &pa,
 		clock:      timer,
 		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
-			withTraffic(WithSpecTraffic, pair{"R1", 98}, pair{"R2", 2})),
+			withTraffic(WithSpecTraffic, pair{"R1", 98}, pair{"R2", 2}), withSpecCandidateTag("R2")),
 		errExpected: false,
 	}, {
 		name:       "> 100 revisions split traffic at 1% granularity",
@@ -325,13 +339,61 @@ func TestModifyRouteSpec(t *testing.T) {
 		policy:     &pa,
 		clock:      timer,
 		want: Route("default", "test", withTraffic(WithStatusTraffic, largeTestRouteTraffic...),
-			withTraffic(WithSpecTraffic, largeTestRouteTrafficNew...)),
+			withTraffic(WithSpecTraffic, largeTestRouteTrafficNew...), withSpecCandidateTag("R201")),
+		errExpected: false,
+	}, {
+		name:  "maxActiveRevisions evicts the oldest mid-rollout Revision",
+		route: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 90}, pair{"R2", 5}, pair{"R3", 5})),
+		revMap: map[string]*v1.Revision{
+			"R1": Revision("default", "R1", WithCreationTimestamp(now.Add(-10000*time.Second))),
+			"R2": Revision("default", "R2", WithCreationTimestamp(now.Add(-30*time.Second))),
+			"R3": Revision("default", "R3", WithCreationTimestamp(now.Add(-12*time.Second))),
+			"R4": Revision("default", "R4", WithCreationTimestamp(now.Add(-2*time.Second))),
+		},
+		newRevName: "R4",
+		policy:     &cappedPool,
+		clock:      timer,
+		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 90}, pair{"R2", 5}, pair{"R3", 5}),
+			withTraffic(WithSpecTraffic, pair{"R1", 96}, pair{"R3", 3}, pair{"R4", 1}), withSpecCandidateTag("R4")),
+		errExpected: false,
+	}, {
+		name:  "minStablePercent clamps the candidates, not the stable Revision",
+		route: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 95}, pair{"R2", 5})),
+		revMap: map[string]*v1.Revision{
+			"R1": Revision("default", "R1", WithCreationTimestamp(now.Add(-10000*time.Second))),
+			"R2": Revision("default", "R2", WithCreationTimestamp(now.Add(-26*time.Second))),
+			"R3": Revision("default", "R3", WithCreationTimestamp(now)),
+		},
+		newRevName: "R3",
+		policy:     &flooredPool,
+		clock:      timer,
+		// without the floor R2 would take 6%; the floor leaves the candidates
+		// only 5% between them
+		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 95}, pair{"R2", 5}),
+			withTraffic(WithSpecTraffic, pair{"R1", 95}, pair{"R2", 4}, pair{"R3", 1}), withSpecCandidateTag("R3")),
+		errExpected: false,
+	}, {
+		name:  "final stage is exempt from minStablePercent",
+		route: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+		revMap: map[string]*v1.Revision{
+			"R1": Revision("default", "R1", WithCreationTimestamp(now.Add(-20000*time.Second))),
+			"R2": Revision("default", "R2", WithCreationTimestamp(now.Add(-10000*time.Second))),
+		},
+		newRevName: "R2",
+		policy:     &flooredPool,
+		clock:      timer,
+		want: Route("default", "test", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
+			WithSpecTraffic(v1.TrafficTarget{
+				ConfigurationName: "test",
+				LatestRevision:    ptr.Bool(true),
+				Percent:           ptr.Int64(100),
+			})),
 		errExpected: false,
 	}}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ans, e := modifyRouteSpec(tt.route, tt.revMap, tt.newRevName, tt.policy, tt.clock)
+			ans, e := modifyRouteSpec(tt.route, tt.revMap, tt.newRevName, tt.policy, tt.clock, 0)
 			if diff := cmp.Diff(tt.want, ans); diff != "" {
 				t.Errorf("Route object is incorrect (-want, +got): %s", diff)
 			}
@@ -469,3 +531,100 @@ func withOwnerReferences(references []metav1.OwnerReference) RevisionOption {
 		rev.ObjectMeta.SetOwnerReferences(references)
 	}
 }
+
+func TestApplyUpdateStrategy(t *testing.T) {
+	liveSpec := v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 30, UpdateStrategy: v1alpha1.UpdateStrategyPinned}
+	oldSpec := v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 60, UpdateStrategy: v1alpha1.UpdateStrategyPinned}
+	mkPolicy := func(spec v1alpha1.PolicySpec) *v1alpha1.Policy {
+		p := MakePolicy("default", "test")
+		p.Spec = spec
+		return p
+	}
+	now := metav1.Now()
+	var tests = []struct {
+		name       string
+		policy     *v1alpha1.Policy
+		ps         *v1alpha1.PolicyState
+		wantSpec   v1alpha1.PolicySpec
+		wantPinned *v1alpha1.PolicySpec
+	}{{
+		name:       "live policy clears any stale snapshot",
+		policy:     mkPolicy(v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 30}),
+		ps:         PolicyState("default", "test", func(ps *v1alpha1.PolicyState) { ps.Spec.PinnedPolicy = &oldSpec }),
+		wantSpec:   v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 30},
+		wantPinned: nil,
+	}, {
+		name:       "pinned policy snapshots the spec for a new rollout",
+		policy:     mkPolicy(liveSpec),
+		ps:         PolicyState("default", "test"),
+		wantSpec:   liveSpec,
+		wantPinned: &liveSpec,
+	}, {
+		name:   "pinned policy follows the snapshot while the rollout is in flight",
+		policy: mkPolicy(liveSpec),
+		ps: PolicyState("default", "test", WithNextUpdateTimestamp(now.Time), func(ps *v1alpha1.PolicyState) {
+			ps.Spec.PinnedPolicy = &oldSpec
+		}),
+		wantSpec:   oldSpec,
+		wantPinned: &oldSpec,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &Reconciler{}
+			got := c.applyUpdateStrategy(context.Background(), test.policy, test.ps)
+			if diff := cmp.Diff(test.wantSpec, got.Spec); diff != "" {
+				t.Errorf("effective Policy spec is incorrect (-want, +got): %s", diff)
+			}
+			if diff := cmp.Diff(test.wantPinned, test.ps.Spec.PinnedPolicy); diff != "" {
+				t.Errorf("pinned snapshot is incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestFailureResponse(t *testing.T) {
+	cfg := func(annotations map[string]string) *v1.Configuration {
+		return &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", Annotations: annotations}}
+	}
+
+	tests := []struct {
+		name   string
+		cfg    *v1.Configuration
+		policy *Policy
+		want   string
+	}{{
+		name:   "default is abort",
+		cfg:    cfg(nil),
+		policy: &Policy{},
+		want:   v1alpha1.FailureAbort,
+	}, {
+		name:   "legacy rollback flag maps to rollback",
+		cfg:    cfg(nil),
+		policy: &Policy{RollbackOnFailure: true},
+		want:   v1alpha1.FailureRollback,
+	}, {
+		name:   "explicit onFailure wins over the legacy flag",
+		cfg:    cfg(nil),
+		policy: &Policy{RollbackOnFailure: true, OnFailure: v1alpha1.FailureHold},
+		want:   v1alpha1.FailureHold,
+	}, {
+		name:   "Configuration annotation wins over the Policy",
+		cfg:    cfg(map[string]string{delivery.OnFailureKey: v1alpha1.FailureAbort}),
+		policy: &Policy{OnFailure: v1alpha1.FailureRollback},
+		want:   v1alpha1.FailureAbort,
+	}, {
+		name:   "unrecognized annotation value falls back to the Policy",
+		cfg:    cfg(map[string]string{delivery.OnFailureKey: "retry"}),
+		policy: &Policy{OnFailure: v1alpha1.FailureHold},
+		want:   v1alpha1.FailureHold,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := failureResponse(test.cfg, test.policy); got != test.want {
+				t.Errorf("failureResponse() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
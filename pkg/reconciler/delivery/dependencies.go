@@ -0,0 +1,137 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// gateOnDependencies holds the rollout at its current stage when the candidate
+// Revision references ConfigMaps or Secrets that no longer exist; a Revision keeps
+// running on its cached config after its dependencies are deleted, but promoting
+// it further would only widen the blast radius once its pods get rescheduled
+func (c *Reconciler) gateOnDependencies(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	if !policy.VerifyDependencies {
+		return false, nil
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		return false, nil
+	}
+	current := currentPercent(route, revName)
+	// only check the dependencies once the stage timer wants a traffic increase
+	pause := pauseOffset(ps)
+	if ps.Status.FreezeStartTime != nil {
+		pause += c.clock.Since(ps.Status.FreezeStartTime.Time)
+	}
+	if computeNewPercentExplicit(policy, jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pause))) <= current {
+		return false, nil
+	}
+	missing, err := c.missingDependency(cfg.Namespace, rev)
+	if err != nil {
+		return false, err
+	}
+	if missing == "" {
+		return false, nil
+	}
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(cfg, corev1.EventTypeWarning, "DependencyMissing",
+			"Revision %q references %s, which no longer exists; pausing rollout", rev.Name, missing)
+	}
+	if deadlineExceeded(policy, ps, c.clock) {
+		return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+	}
+	return true, c.holdForDependencies(ctx, cfg, ps, missing)
+}
+
+// holdForDependencies pauses the stage timer until the missing dependency reappears
+func (c *Reconciler) holdForDependencies(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, missing string) error {
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("Candidate Revision references missing %s, holding the current traffic split", missing)
+	c.followup(cfg, DependencyRecheck)
+	return nil
+}
+
+// missingDependency returns a description of the first ConfigMap or Secret that the
+// Revision references but that no longer exists, or the empty string if all resolve
+func (c *Reconciler) missingDependency(namespace string, rev *v1.Revision) (string, error) {
+	configmaps, secrets := revisionDependencies(rev)
+	for _, name := range configmaps {
+		if _, err := c.kubeclient.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{}); apierrs.IsNotFound(err) {
+			return fmt.Sprintf("ConfigMap %q", name), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	for _, name := range secrets {
+		if _, err := c.kubeclient.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{}); apierrs.IsNotFound(err) {
+			return fmt.Sprintf("Secret %q", name), nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// revisionDependencies collects the names of the ConfigMaps and Secrets that the
+// Revision's pod spec references through env vars, envFrom sources, and volumes
+// references explicitly marked optional are skipped
+func revisionDependencies(rev *v1.Revision) (configmaps, secrets []string) {
+	for _, container := range rev.Spec.Containers {
+		for _, envFrom := range container.EnvFrom {
+			if ref := envFrom.ConfigMapRef; ref != nil && !(ref.Optional != nil && *ref.Optional) {
+				configmaps = append(configmaps, ref.Name)
+			}
+			if ref := envFrom.SecretRef; ref != nil && !(ref.Optional != nil && *ref.Optional) {
+				secrets = append(secrets, ref.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && !(ref.Optional != nil && *ref.Optional) {
+				configmaps = append(configmaps, ref.Name)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil && !(ref.Optional != nil && *ref.Optional) {
+				secrets = append(secrets, ref.Name)
+			}
+		}
+	}
+	for _, vol := range rev.Spec.Volumes {
+		if vol.ConfigMap != nil {
+			configmaps = append(configmaps, vol.ConfigMap.Name)
+		}
+		if vol.Secret != nil {
+			secrets = append(secrets, vol.Secret.SecretName)
+		}
+	}
+	return
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestRevisionDependencies(t *testing.T) {
+	rev := &v1.Revision{
+		Spec: v1.RevisionSpec{
+			PodSpec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					EnvFrom: []corev1.EnvFromSource{{
+						ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"}},
+					}, {
+						SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "app-creds"}},
+					}, {
+						// optional references don't block promotion
+						ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "extras"}, Optional: ptr.Bool(true)},
+					}},
+					Env: []corev1.EnvVar{{
+						Name: "PLAIN", Value: "value",
+					}, {
+						Name: "FROM_CM",
+						ValueFrom: &corev1.EnvVarSource{
+							ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "keyed-config"}, Key: "k"},
+						},
+					}, {
+						Name: "FROM_SECRET",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "keyed-secret"}, Key: "k"},
+						},
+					}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name:         "cm-vol",
+					VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "mounted-config"}}},
+				}, {
+					Name:         "secret-vol",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "mounted-secret"}},
+				}},
+			},
+		},
+	}
+	wantConfigMaps := []string{"app-config", "keyed-config", "mounted-config"}
+	wantSecrets := []string{"app-creds", "keyed-secret", "mounted-secret"}
+	configmaps, secrets := revisionDependencies(rev)
+	if diff := cmp.Diff(wantConfigMaps, configmaps); diff != "" {
+		t.Errorf("wrong ConfigMap references (-want, +got) %v", diff)
+	}
+	if diff := cmp.Diff(wantSecrets, secrets); diff != "" {
+		t.Errorf("wrong Secret references (-want, +got) %v", diff)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// classifyAPIError translates recognizable API errors into a condition reason
+// and a remediation hint; unrecognized errors return ok=false and stay generic
+func classifyAPIError(err error) (reason, hint string, ok bool) {
+	switch {
+	case apierrs.IsForbidden(err):
+		return "RBACMisconfigured",
+			"the controller was denied access; check that the ClusterRole bindings installed with KCD are intact", true
+	case apierrs.IsNotFound(err):
+		return "PolicyMissing",
+			"a referenced object does not exist; check the \"delivery.knative.dev/policy\" annotation for a dangling reference", true
+	case apierrs.IsInternalError(err), apierrs.IsTimeout(err), apierrs.IsServerTimeout(err):
+		return "WebhookUnavailable",
+			"the API server could not complete the call, often because an admission webhook is down; check the webhook Deployment", true
+	}
+	return "", "", false
+}
+
+// recordAPIError surfaces a failed reconcile on the PolicyState's Operational
+// condition when the error is recognizable; this is best-effort bookkeeping on
+// the way out of an already-failed reconcile, so its own failures only log
+func (c *Reconciler) recordAPIError(ctx context.Context, cfg *v1.Configuration, reconcileErr error) {
+	reason, hint, ok := classifyAPIError(reconcileErr)
+	if !ok {
+		return
+	}
+	ps, err := c.policystateLister.PolicyStates(cfg.Namespace).Get(cfg.Name)
+	if err != nil {
+		return
+	}
+	ps = ps.DeepCopy()
+	ps.Status.MarkNotOperational(reason, fmt.Sprintf("%v (%s)", reconcileErr, hint))
+	if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+		logging.FromContext(ctx).Warnf("Failed to record %s condition on PolicyState %s/%s: %v", reason, cfg.Namespace, cfg.Name, err)
+	}
+}
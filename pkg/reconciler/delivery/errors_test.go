@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"errors"
+	"testing"
+
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClassifyAPIError(t *testing.T) {
+	gr := schema.GroupResource{Group: "serving.knative.dev", Resource: "routes"}
+	tests := []struct {
+		name       string
+		err        error
+		wantReason string
+		wantOk     bool
+	}{{
+		name:       "forbidden maps to RBACMisconfigured",
+		err:        apierrs.NewForbidden(gr, "app", errors.New("denied")),
+		wantReason: "RBACMisconfigured",
+		wantOk:     true,
+	}, {
+		name:       "not found maps to PolicyMissing",
+		err:        apierrs.NewNotFound(gr, "app"),
+		wantReason: "PolicyMissing",
+		wantOk:     true,
+	}, {
+		name:       "internal error maps to WebhookUnavailable",
+		err:        apierrs.NewInternalError(errors.New("failed calling webhook")),
+		wantReason: "WebhookUnavailable",
+		wantOk:     true,
+	}, {
+		name:       "timeout maps to WebhookUnavailable",
+		err:        apierrs.NewTimeoutError("timed out", 5),
+		wantReason: "WebhookUnavailable",
+		wantOk:     true,
+	}, {
+		name:   "plain errors stay generic",
+		err:    errors.New("something else"),
+		wantOk: false,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, hint, ok := classifyAPIError(test.err)
+			if ok != test.wantOk {
+				t.Fatalf("ok = %v, want %v", ok, test.wantOk)
+			}
+			if reason != test.wantReason {
+				t.Errorf("reason = %q, want %q", reason, test.wantReason)
+			}
+			if ok && hint == "" {
+				t.Error("expected a remediation hint, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+// applyExperiment assigns traffic for an experiment Policy: the two newest
+// candidate Revisions progress on independent tracks, each with its own stage
+// table and tag, while the stable (oldest) Revision holds the remainder
+// It returns false when the experiment cannot run yet (fewer candidates than
+// tracks), in which case the shared Stages ladder applies as usual
+func (c *Reconciler) applyExperiment(ctx context.Context, cfg *v1.Configuration, route *v1.Route, r map[string]*v1.Revision, policy *Policy, pause time.Duration) bool {
+	logger := logging.FromContext(ctx)
+
+	// an explicit winner ends the experiment: all traffic goes to it
+	if winner, ok := cfg.Annotations[delivery.WinnerKey]; ok {
+		if _, exists := r[winner]; exists {
+			logger.Infof("Experiment winner %s takes all traffic", winner)
+			route.Spec.Traffic = []v1.TrafficTarget{traffictarget.Pinned(winner, 100)}
+			return true
+		}
+		logger.Infof("Ignoring %s annotation naming unknown Revision %q", delivery.WinnerKey, winner)
+	}
+
+	stable := oldestRevision(r)
+	arms := experimentArms(r, len(policy.Experiment.Tracks))
+	if stable == nil || len(arms) < len(policy.Experiment.Tracks) {
+		return false
+	}
+
+	total := 0
+	traffic := make([]v1.TrafficTarget, 0, len(arms)+1)
+	for i, arm := range arms {
+		percent := trackPercent(policy.Experiment.Tracks[i], policy.DefaultThreshold, rolloutElapsed(c.clock, arm, pause))
+		// an arm that turned unhealthy is eliminated: it stops receiving
+		// traffic, and naming the surviving arm as winner is left to the operator
+		if arm.Status.GetCondition(v1.RevisionConditionReady).IsFalse() {
+			logger.Infof("Experiment arm %s is unhealthy, eliminating it from the traffic split", arm.Name)
+			percent = 0
+		}
+		// validation caps the final stages at a combined 99%, but clamp anyway
+		// so an unvalidated policy document cannot starve the stable Revision
+		if total+percent > 99 {
+			percent = 99 - total
+		}
+		total += percent
+		if percent == 0 {
+			continue
+		}
+		traffic = append(traffic, traffictarget.Tagged(traffictarget.Pinned(arm.Name, int64(percent)), policy.Experiment.Tracks[i].Tag))
+	}
+	route.Spec.Traffic = append([]v1.TrafficTarget{traffictarget.Pinned(stable.Name, int64(100-total))}, traffic...)
+	return true
+}
+
+// experimentArms returns the newest count Revisions other than the stable one,
+// ordered oldest first so that track assignment is deterministic across
+// reconciles: the older arm keeps the first track for the experiment's lifetime
+func experimentArms(r map[string]*v1.Revision, count int) []*v1.Revision {
+	stable := oldestRevision(r)
+	arms := make([]*v1.Revision, 0, len(r))
+	for _, rev := range r {
+		if rev != stable {
+			arms = append(arms, rev)
+		}
+	}
+	sort.Slice(arms, func(i, j int) bool {
+		if !arms[i].CreationTimestamp.Equal(&arms[j].CreationTimestamp) {
+			return arms[i].CreationTimestamp.Before(&arms[j].CreationTimestamp)
+		}
+		return arms[i].Name < arms[j].Name
+	})
+	if len(arms) > count {
+		arms = arms[len(arms)-count:]
+	}
+	return arms
+}
+
+// trackPercent walks a track's stage table the way computeNewPercentExplicit
+// walks the shared ladder, except that there is no implicit promotion to 100:
+// past the last stage the track holds its final percentage until a winner is
+// chosen
+func trackPercent(track v1alpha1.ExperimentTrack, defaultThreshold int, elapsed time.Duration) int {
+	metric := float64(elapsed) / float64(time.Second)
+	metricCumulative := 0
+	for _, s := range track.Stages {
+		extra := defaultThreshold
+		if s.Threshold != nil {
+			extra = *s.Threshold
+		}
+		metricCumulative += extra
+		if float64(metricCumulative) > metric {
+			return s.Percent
+		}
+	}
+	if len(track.Stages) == 0 {
+		return 0
+	}
+	return track.Stages[len(track.Stages)-1].Percent
+}
+
+// trackWait computes how many full seconds to wait before the track's next
+// stage boundary; a track holding its final percentage has nothing to wait for
+func trackWait(track v1alpha1.ExperimentTrack, defaultThreshold int, elapsed time.Duration) int {
+	metric := float64(elapsed) / float64(time.Second)
+	metricCumulative := 0
+	for _, s := range track.Stages {
+		extra := defaultThreshold
+		if s.Threshold != nil {
+			extra = *s.Threshold
+		}
+		metricCumulative += extra
+		if float64(metricCumulative) > metric {
+			return nextBiggerInt(float64(metricCumulative) - metric)
+		}
+	}
+	return math.MaxInt32
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/clock"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	. "knative.dev/serving/pkg/testing/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+var abExperiment = &v1alpha1.ExperimentConfig{Tracks: []v1alpha1.ExperimentTrack{
+	{Tag: "track-a", Stages: []v1alpha1.Stage{{Percent: 10}, {Percent: 45}}},
+	{Tag: "track-b", Stages: []v1alpha1.Stage{{Percent: 10}, {Percent: 45}}},
+}}
+
+func TestTrackPercent(t *testing.T) {
+	track := abExperiment.Tracks[0]
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "first stage applies immediately", elapsed: 0, want: 10},
+		{name: "second stage after the default threshold", elapsed: 7 * time.Second, want: 45},
+		{name: "the final stage holds, there is no implicit 100", elapsed: 10000 * time.Second, want: 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trackPercent(track, 5, tt.elapsed); got != tt.want {
+				t.Errorf("trackPercent(%v) = %v, want %v", tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyExperiment(t *testing.T) {
+	now := time.Now()
+	timer := clock.NewFakeClock(now)
+	policy := &Policy{Mode: "time", Stages: []Stage{{Percent: 0}}, DefaultThreshold: 5, Experiment: abExperiment}
+	revisionMap := map[string]*v1.Revision{
+		"R1": Revision("default", "R1", WithCreationTimestamp(now.Add(-10000*time.Second))),
+		"R2": Revision("default", "R2", WithCreationTimestamp(now.Add(-100*time.Second))),
+		"R3": Revision("default", "R3", WithCreationTimestamp(now.Add(-50*time.Second))),
+	}
+	c := &Reconciler{clock: timer}
+
+	// both tracks are past their ladders, so the arms hold 45/45 and the
+	// stable Revision keeps the remaining 10; the older arm owns track-a
+	cfg := Configuration("default", "test")
+	route := Route("default", "test")
+	if handled := c.applyExperiment(context.Background(), cfg, route, revisionMap, policy, 0); !handled {
+		t.Fatal("applyExperiment() = false, want the experiment to run")
+	}
+	want := []v1.TrafficTarget{
+		traffictarget.Pinned("R1", 10),
+		traffictarget.Tagged(traffictarget.Pinned("R2", 45), "track-a"),
+		traffictarget.Tagged(traffictarget.Pinned("R3", 45), "track-b"),
+	}
+	if diff := cmp.Diff(want, route.Spec.Traffic); diff != "" {
+		t.Errorf("experiment traffic diff (-want +got): %v", diff)
+	}
+
+	// a winner annotation ends the experiment
+	cfg.Annotations = map[string]string{delivery.WinnerKey: "R3"}
+	if handled := c.applyExperiment(context.Background(), cfg, route, revisionMap, policy, 0); !handled {
+		t.Fatal("applyExperiment() = false, want the winner to be honored")
+	}
+	if diff := cmp.Diff([]v1.TrafficTarget{traffictarget.Pinned("R3", 100)}, route.Spec.Traffic); diff != "" {
+		t.Errorf("winner traffic diff (-want +got): %v", diff)
+	}
+
+	// with only one candidate the experiment cannot run yet
+	delete(revisionMap, "R3")
+	cfg.Annotations = nil
+	if handled := c.applyExperiment(context.Background(), cfg, route, revisionMap, policy, 0); handled {
+		t.Error("applyExperiment() = true with a single candidate, want fallthrough to the shared ladder")
+	}
+}
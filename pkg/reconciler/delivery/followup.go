@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// followupTracker dedups the reconciler's self-enqueued wake-ups: a single
+// reconciliation can ask for several followups (stage transitions, slice flips,
+// policy adoption turns) and successive reconciliations re-ask for the same
+// ones, so without tracking every reconcile stacks another timer on the queue
+// the tracker remembers the earliest pending wake-up per Configuration and only
+// lets an enqueue through when it is due sooner than what is already scheduled;
+// a wake-up that has come due expires from the map by time comparison, and the
+// reconcile it triggers re-schedules whatever is still needed
+type followupTracker struct {
+	clock clock.Clock
+
+	mu      sync.Mutex
+	pending map[types.NamespacedName]time.Time
+}
+
+func newFollowupTracker(clock clock.Clock) *followupTracker {
+	return &followupTracker{
+		clock:   clock,
+		pending: make(map[types.NamespacedName]time.Time),
+	}
+}
+
+// track records a wake-up for key after delay and reports whether it should be
+// enqueued: false means an equal-or-earlier wake-up is already pending, true
+// replaces the pending record (the superseded later timer, which the work queue
+// cannot cancel, fires a cheap recompute when it comes due)
+func (f *followupTracker) track(key types.NamespacedName, delay time.Duration) bool {
+	when := f.clock.Now().Add(delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p, ok := f.pending[key]; ok && p.After(f.clock.Now()) && !p.After(when) {
+		return false
+	}
+	f.pending[key] = when
+	return true
+}
+
+// forget drops the pending record for key, so that the next followup request
+// schedules afresh; it is used when a Configuration leaves continuous delivery
+// and its NextUpdateTimestamp no longer exists
+func (f *followupTracker) forget(key types.NamespacedName) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.pending, key)
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+func TestFollowupTracker(t *testing.T) {
+	timer := clock.NewFakeClock(time.Now())
+	f := newFollowupTracker(timer)
+	key := types.NamespacedName{Namespace: "default", Name: "test"}
+	other := types.NamespacedName{Namespace: "default", Name: "other"}
+
+	if !f.track(key, time.Minute) {
+		t.Error("first followup was not scheduled")
+	}
+	if f.track(key, time.Minute) {
+		t.Error("duplicate followup was scheduled")
+	}
+	if f.track(key, 2*time.Minute) {
+		t.Error("later followup superseded an earlier pending one")
+	}
+	if !f.track(key, time.Second) {
+		t.Error("earlier followup did not replace the pending one")
+	}
+	if !f.track(other, time.Minute) {
+		t.Error("tracking is not keyed by Configuration")
+	}
+
+	// once the pending wake-up comes due, the next request schedules afresh
+	timer.Step(2 * time.Second)
+	if !f.track(key, time.Minute) {
+		t.Error("followup after the pending one came due was not scheduled")
+	}
+
+	f.forget(key)
+	if !f.track(key, time.Minute) {
+		t.Error("followup after forget was not scheduled")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"knative.dev/pkg/logging"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// cleanupRetiredRevisions sweeps Revisions that are done serving: anything in
+// the pool that is neither the candidate nor referenced by the desired Route
+// spec has either handed over all of its traffic or been squeezed out of the
+// pool, and the routing leftovers a rollout placed on it — minScale pins and
+// transfer markers — are removed here so they cannot accumulate across
+// rollouts. Each swept Revision is also annotated as safe to delete; the
+// annotation is a suggestion for external garbage collection, KCD itself
+// never deletes user workloads
+func (c *Reconciler) cleanupRetiredRevisions(ctx context.Context, cfg *v1.Configuration, route *v1.Route, revisionMap map[string]*v1.Revision) error {
+	active := map[string]bool{}
+	for _, t := range route.Spec.Traffic {
+		if t.LatestRevision != nil && *t.LatestRevision {
+			active[cfg.Status.LatestReadyRevisionName] = true
+		}
+		if t.RevisionName != "" {
+			active[t.RevisionName] = true
+		}
+	}
+	for name, rev := range revisionMap {
+		if active[name] || name == cfg.Status.LatestReadyRevisionName {
+			continue
+		}
+		if err := c.sweepRevision(ctx, rev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweepRevision removes the rollout leftovers from one retired Revision in a
+// single update: the warmth pin is dropped, a transferred minScale is restored
+// to its declared value, and the deletion suggestion is added; a Revision that
+// was already swept produces no write at all
+func (c *Reconciler) sweepRevision(ctx context.Context, rev *v1.Revision) error {
+	update := rev.DeepCopy()
+	if update.Annotations == nil {
+		update.Annotations = map[string]string{}
+	}
+	changed := false
+	if update.Annotations[delivery.HoldsMinScaleKey] != "" {
+		delete(update.Annotations, autoscaling.MinScaleAnnotationKey)
+		delete(update.Annotations, delivery.HoldsMinScaleKey)
+		changed = true
+	}
+	if original := update.Annotations[delivery.OriginalMinScaleKey]; original != "" {
+		update.Annotations[autoscaling.MinScaleAnnotationKey] = original
+		delete(update.Annotations, delivery.OriginalMinScaleKey)
+		changed = true
+	}
+	if update.Annotations[delivery.SafeToDeleteKey] == "" {
+		update.Annotations[delivery.SafeToDeleteKey] = "true"
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	logging.FromContext(ctx).Infof("Revision %s no longer serves traffic; cleaning its rollout leftovers and marking it safe to delete", rev.Name)
+	_, err := c.client.ServingV1().Revisions(rev.Namespace).Update(update)
+	return err
+}
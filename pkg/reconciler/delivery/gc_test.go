@@ -0,0 +1,92 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/ptr"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+	. "knative.dev/serving/pkg/testing/v1"
+)
+
+func TestCleanupRetiredRevisions(t *testing.T) {
+	retired := Revision("default", "cfg-00001")
+	retired.Annotations = map[string]string{
+		autoscaling.MinScaleAnnotationKey: "1",
+		delivery.HoldsMinScaleKey:         "true",
+	}
+	serving := Revision("default", "cfg-00002")
+	candidate := Revision("default", "cfg-00003")
+	client := servingfake.NewSimpleClientset(retired, serving, candidate)
+	c := &Reconciler{client: client}
+
+	cfg := Configuration("default", "cfg", WithLatestReady("cfg-00003"))
+	route := Route("default", "cfg")
+	route.Spec.Traffic = []v1.TrafficTarget{
+		{RevisionName: "cfg-00002", Percent: ptr.Int64(90)},
+		{RevisionName: "cfg-00003", Percent: ptr.Int64(10), Tag: CandidateTag},
+	}
+	revisionMap := map[string]*v1.Revision{
+		"cfg-00001": retired, "cfg-00002": serving, "cfg-00003": candidate,
+	}
+
+	if err := c.cleanupRetiredRevisions(context.Background(), cfg, route, revisionMap); err != nil {
+		t.Fatalf("cleanupRetiredRevisions() = %v", err)
+	}
+
+	// the retired Revision loses its minScale pin and gains the suggestion
+	got, err := client.ServingV1().Revisions("default").Get("cfg-00001", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[autoscaling.MinScaleAnnotationKey]; ok {
+		t.Errorf("minScale pin survived the sweep: %v", got.Annotations)
+	}
+	if got.Annotations[delivery.SafeToDeleteKey] != "true" {
+		t.Errorf("retired Revision is missing the safe-to-delete suggestion: %v", got.Annotations)
+	}
+
+	// Revisions still referenced by the Route spec are left alone
+	for _, name := range []string{"cfg-00002", "cfg-00003"} {
+		got, err := client.ServingV1().Revisions("default").Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := got.Annotations[delivery.SafeToDeleteKey]; ok {
+			t.Errorf("Revision %s serves traffic but was marked safe to delete", name)
+		}
+	}
+
+	// a second sweep has nothing left to write
+	before := len(client.Actions())
+	if err := c.cleanupRetiredRevisions(context.Background(), cfg, route, map[string]*v1.Revision{
+		"cfg-00001": got, "cfg-00002": serving, "cfg-00003": candidate,
+	}); err != nil {
+		t.Fatalf("cleanupRetiredRevisions() = %v", err)
+	}
+	for _, a := range client.Actions()[before:] {
+		if a.GetVerb() == "update" {
+			t.Error("unexpected update action on an already swept Revision")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// applyHeaderRouting records the current stage's attribute-based routing rule on
+// the Route, so that requests carrying the configured header or cookie value are
+// sent to the candidate Revision regardless of the percentage split; this is what
+// makes employee-only and opt-in canaries possible before the percentage ramp
+// stages without a rule clear any leftover header-route annotations instead
+func applyHeaderRouting(ctx context.Context, route *v1.Route, policy *Policy, revName string) {
+	hr := stageHeaderRouting(policy, candidatePercent(route, revName))
+	if hr == nil {
+		networking.ClearHeaderRoute(route)
+		return
+	}
+	logging.FromContext(ctx).Infof("Routing requests matching the canary attribute to Revision %s", revName)
+	networking.SetHeaderRoute(route, networking.HeaderRoute{
+		Header:       hr.Header,
+		Cookie:       hr.Cookie,
+		Value:        hr.Value,
+		RevisionName: revName,
+	})
+}
+
+// stageHeaderRouting returns the header-routing rule declared by the stage that
+// the rollout currently sits at, or nil when the stage doesn't declare one
+func stageHeaderRouting(policy *Policy, percent int) *v1alpha1.HeaderRouting {
+	for _, s := range policy.Stages {
+		if s.Percent == percent {
+			return s.HeaderRouting
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestApplyHeaderRouting(t *testing.T) {
+	optIn := &v1alpha1.HeaderRouting{Header: "X-Canary", Value: "true"}
+	policy := &Policy{Stages: []Stage{{Percent: 0, HeaderRouting: optIn}, {Percent: 10}}}
+	routeAt := func(percent int64) *v1.Route {
+		return &v1.Route{Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+			{RevisionName: "R1", Percent: ptr.Int64(100 - percent)},
+			{RevisionName: "R2", Percent: ptr.Int64(percent)},
+		}}}
+	}
+
+	var tests = []struct {
+		name  string
+		route *v1.Route
+		want  *networking.HeaderRoute
+	}{
+		{name: "stage with a rule", route: routeAt(0),
+			want: &networking.HeaderRoute{Header: "X-Canary", Value: "true", RevisionName: "R2"}},
+		{name: "stage without a rule clears leftovers", route: routeAt(10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networking.SetHeaderRoute(tt.route, networking.HeaderRoute{Cookie: "stale", Value: "1", RevisionName: "stale"})
+			applyHeaderRouting(context.Background(), tt.route, policy, "R2")
+			got := networking.GetHeaderRoute(tt.route)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("expected no header route, got %+v", *got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("header route is incorrect: got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
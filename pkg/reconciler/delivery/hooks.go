@@ -0,0 +1,259 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/resources"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+var (
+	// hookFailureCount counts verification hook failures, including ones that
+	// were retried under a stage's failure limit; a climbing rate points at a
+	// flaky verification suite before any rollout is actually failed by it
+	hookFailureCount = stats.Int64(
+		"stage_hook_failures",
+		"Number of stage verification hook failures, including retried ones",
+		stats.UnitDimensionless)
+
+	// hookFailureNamespaceKey tags hook failures with the workload's namespace
+	hookFailureNamespaceKey = tag.MustNewKey("namespace_name")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: hookFailureCount.Description(),
+		Measure:     hookFailureCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{hookFailureNamespaceKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// hookStatus summarizes the aggregate state of a stage's verification Jobs
+type hookStatus int
+
+const (
+	hooksCompleted hookStatus = iota
+	hooksRunning
+	hooksFailed
+)
+
+// gateOnStageHooks holds the rollout at its current stage until all of the stage's
+// verification Jobs have completed; the hold reuses the freeze bookkeeping so that
+// the stage timer is paused while the Jobs run, and a failed Job fails the rollout
+// the first return value reports whether the rollout is being held (or has failed),
+// in which case the caller must not advance the traffic split any further
+func (c *Reconciler) gateOnStageHooks(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		return false, nil
+	}
+	current := currentPercent(route, revName)
+	hooks := stageHooks(policy, current)
+	if len(hooks) == 0 {
+		return false, nil
+	}
+	// only consult the hooks once the stage timer wants to advance past this stage
+	pause := pauseOffset(ps)
+	if ps.Status.FreezeStartTime != nil {
+		pause += c.clock.Since(ps.Status.FreezeStartTime.Time)
+	}
+	if computeNewPercentExplicit(policy, jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pause))) <= current {
+		return false, nil
+	}
+	status, hookName, err := c.checkStageHooks(ctx, cfg, revName, current, hooks)
+	if err != nil {
+		return false, err
+	}
+	switch status {
+	case hooksFailed:
+		// every failure is counted, surfaced as an event and recorded on the
+		// package metric; the stage's failure limit decides whether the Job is
+		// retried or the failure is terminal
+		if ps.Status.HookFailurePercent != current {
+			ps.Status.HookFailurePercent = current
+			ps.Status.HookFailures = 0
+		}
+		ps.Status.HookFailures++
+		if tctx, terr := tag.New(ctx, tag.Insert(hookFailureNamespaceKey, cfg.Namespace)); terr == nil {
+			metrics.Record(tctx, hookFailureCount.M(1))
+		}
+		if limit := stageFailureLimit(policy, current); ps.Status.HookFailures <= limit {
+			return true, c.retryStageHook(ctx, cfg, ps, revName, current, hooks, hookName, limit)
+		}
+		return true, c.failStageHook(ctx, cfg, route, ps, revisionMap, policy, hookName)
+	case hooksRunning:
+		if deadlineExceeded(policy, ps, c.clock) {
+			return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+		}
+		return true, c.holdForHooks(ctx, cfg, ps)
+	}
+	return false, nil
+}
+
+// checkStageHooks makes sure that every hook Job for the given stage exists and
+// reports their aggregate outcome, along with the name of the first failed hook
+// Jobs are created lazily the first time the stage tries to advance
+func (c *Reconciler) checkStageHooks(ctx context.Context, cfg *v1.Configuration, revName string, percent int, hooks []v1alpha1.StageHook) (hookStatus, string, error) {
+	result := hooksCompleted
+	for _, hook := range hooks {
+		name := resources.HookJobName(cfg, revName, percent, hook)
+		job, err := c.kubeclient.BatchV1().Jobs(cfg.Namespace).Get(name, metav1.GetOptions{})
+		if apierrs.IsNotFound(err) {
+			logging.FromContext(ctx).Infof("Creating hook Job %s for stage %d", name, percent)
+			if _, err := c.kubeclient.BatchV1().Jobs(cfg.Namespace).Create(resources.MakeHookJob(cfg, revName, percent, hook)); err != nil {
+				return result, "", err
+			}
+			result = hooksRunning
+			continue
+		} else if err != nil {
+			return result, "", err
+		}
+		switch {
+		case jobFailed(job):
+			return hooksFailed, hook.Name, nil
+		case !jobCompleted(job):
+			result = hooksRunning
+		}
+	}
+	return result, "", nil
+}
+
+// holdForHooks pauses the stage timer while the stage's verification Jobs are running
+func (c *Reconciler) holdForHooks(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState) error {
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Info("Stage verification hooks are still running, holding the current traffic split")
+	c.followup(cfg, HookRecheck)
+	return nil
+}
+
+// retryStageHook deletes the failed hook Job so that the next evaluation runs it
+// again, holding the rollout in the meantime; the failure is still surfaced as a
+// warning event so retries stay visible to the rollout's owner
+func (c *Reconciler) retryStageHook(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, revName string, percent int, hooks []v1alpha1.StageHook, hookName string, limit int) error {
+	logging.FromContext(ctx).Infof("Verification hook %q failed (failure %d of %d allowed), retrying", hookName, ps.Status.HookFailures, limit)
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(cfg, corev1.EventTypeWarning, "StageHookRetried",
+			"Verification hook %q failed (failure %d of %d allowed); retrying", hookName, ps.Status.HookFailures, limit)
+	}
+	for _, hook := range hooks {
+		if hook.Name != hookName {
+			continue
+		}
+		name := resources.HookJobName(cfg, revName, percent, hook)
+		if err := c.kubeclient.BatchV1().Jobs(cfg.Namespace).Delete(name, nil); err != nil && !apierrs.IsNotFound(err) {
+			return err
+		}
+	}
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+	}
+	if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+		return err
+	}
+	c.followup(cfg, HookRecheck)
+	return nil
+}
+
+// failStageHook declares the rollout failed because one of its verification Jobs
+// failed; like a progress deadline failure it is terminal and runs through the
+// shared failure handling, so the Policy's failure response decides what happens
+// to traffic and the phase, scale pins and baseline are wound down the same way
+func (c *Reconciler) failStageHook(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy, hookName string) error {
+	return c.finishFailedRollout(ctx, cfg, route, ps, revisionMap, policy, func() {
+		logging.FromContext(ctx).Infof("Verification hook %q for %s/%s failed", hookName, cfg.Namespace, cfg.Name)
+		if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+			recorder.Eventf(cfg, corev1.EventTypeWarning, "StageHookFailed",
+				"Verification hook %q failed; rollout will not advance", hookName)
+		}
+		ps.Status.MarkStageHookFailed(hookName)
+	})
+}
+
+// stageFailureLimit returns the hook failure budget of the stage at the given
+// traffic percent; the zero value means the first failure is terminal
+func stageFailureLimit(policy *Policy, percent int) int {
+	for _, s := range policy.Stages {
+		if s.Percent == percent && s.FailureLimit != nil {
+			return *s.FailureLimit
+		}
+	}
+	return 0
+}
+
+// stageHooks returns the hooks declared by the stage at the given traffic percent
+func stageHooks(policy *Policy, percent int) []v1alpha1.StageHook {
+	for _, s := range policy.Stages {
+		if s.Percent == percent {
+			return s.Hooks
+		}
+	}
+	return nil
+}
+
+// currentPercent extracts the candidate Revision's current share from the Route status
+// a single latestRevision target means the candidate already owns all of the traffic
+func currentPercent(route *v1.Route, revName string) int {
+	for _, t := range route.Status.Traffic {
+		if t.LatestRevision != nil && *t.LatestRevision {
+			return 100
+		}
+		if t.RevisionName == revName && t.Percent != nil {
+			return int(*t.Percent)
+		}
+	}
+	return 0
+}
+
+// jobCompleted reports whether a Job has reached its Complete condition
+func jobCompleted(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobComplete && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// jobFailed reports whether a Job has reached its Failed condition
+func jobFailed(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
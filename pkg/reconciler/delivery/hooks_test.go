@@ -0,0 +1,200 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	deliveryfake "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned/fake"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+)
+
+func TestStageHooks(t *testing.T) {
+	smoke := v1alpha1.StageHook{Name: "smoke"}
+	p := &Policy{Mode: "time", Stages: []Stage{
+		{Percent: 0},
+		{Percent: 10, Hooks: []v1alpha1.StageHook{smoke}},
+		{Percent: 50},
+	}, DefaultThreshold: 60}
+	tests := []struct {
+		name    string
+		percent int
+		want    int
+	}{
+		{name: "stage without hooks", percent: 0, want: 0},
+		{name: "stage with hooks", percent: 10, want: 1},
+		{name: "percent not a stage", percent: 42, want: 0},
+		{name: "final stage is never gated", percent: 100, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := len(stageHooks(p, test.percent)); got != test.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStageFailureLimit(t *testing.T) {
+	p := &Policy{Mode: "time", Stages: []Stage{
+		{Percent: 0},
+		{Percent: 10, FailureLimit: intptr(2)},
+		{Percent: 50},
+	}, DefaultThreshold: 60}
+	tests := []struct {
+		name    string
+		percent int
+		want    int
+	}{
+		{name: "stage with a failure budget", percent: 10, want: 2},
+		{name: "stage without one fails on the first failure", percent: 50, want: 0},
+		{name: "percent not a stage", percent: 42, want: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := stageFailureLimit(p, test.percent); got != test.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCurrentPercent(t *testing.T) {
+	tests := []struct {
+		name    string
+		traffic []v1.TrafficTarget
+		revName string
+		want    int
+	}{{
+		name:    "candidate is listed",
+		traffic: []v1.TrafficTarget{{RevisionName: "R1", Percent: ptr.Int64(90)}, {RevisionName: "R2", Percent: ptr.Int64(10)}},
+		revName: "R2",
+		want:    10,
+	}, {
+		name:    "candidate is not listed yet",
+		traffic: []v1.TrafficTarget{{RevisionName: "R1", Percent: ptr.Int64(100)}},
+		revName: "R2",
+		want:    0,
+	}, {
+		name:    "stabilized route pins the latest Revision",
+		traffic: []v1.TrafficTarget{{ConfigurationName: "test", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)}},
+		revName: "R2",
+		want:    100,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route := &v1.Route{Status: v1.RouteStatus{RouteStatusFields: v1.RouteStatusFields{Traffic: test.traffic}}}
+			if got := currentPercent(route, test.revName); got != test.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFailStageHookGradualRollback(t *testing.T) {
+	now := time.Now()
+	timer := clock.NewFakeClock(now)
+	policy := &Policy{
+		OnFailure:        v1alpha1.FailureRollback,
+		RollbackStages:   []Stage{{Percent: 50, Threshold: intptr(60)}, {Percent: 10, Threshold: intptr(60)}},
+		DefaultThreshold: 60,
+	}
+	cfg := &v1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Status:     v1.ConfigurationStatus{ConfigurationStatusFields: v1.ConfigurationStatusFields{LatestReadyRevisionName: "cand"}},
+	}
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+			traffictarget.Pinned("stable", 50),
+			traffictarget.Tagged(traffictarget.Pinned("cand", 50), CandidateTag),
+		}},
+	}
+	ps := &v1alpha1.PolicyState{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+	ps.Status.Phase = v1alpha1.PhaseProgressing
+	revisionMap := map[string]*v1.Revision{
+		"stable": {ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stable", CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)}}},
+		"cand":   {ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cand", CreationTimestamp: metav1.Time{Time: now}}},
+	}
+	var enqueued time.Duration
+	c := &Reconciler{
+		client:   servingfake.NewSimpleClientset(route),
+		psclient: deliveryfake.NewSimpleClientset(ps),
+		clock:    timer,
+		followup: func(_ *v1.Configuration, d time.Duration) { enqueued = d },
+	}
+
+	// the first failure enters the rollback but keeps the candidate's current
+	// share instead of snapping all traffic back to stable in one step
+	if err := c.failStageHook(context.Background(), cfg, route, ps, revisionMap, policy, "smoke"); err != nil {
+		t.Fatalf("failStageHook() = %v", err)
+	}
+	if ps.Status.Phase != v1alpha1.PhaseRollingBack {
+		t.Errorf("Phase = %q, want %q", ps.Status.Phase, v1alpha1.PhaseRollingBack)
+	}
+	if got := candidatePercent(route, "cand"); got != 50 {
+		t.Errorf("candidate share after the failure = %d%%, want 50%%", got)
+	}
+	if enqueued == 0 {
+		t.Error("no followup armed for the next rollback step")
+	}
+
+	// re-entry past the first stage boundary steps down, quietly
+	timer.Step(61 * time.Second)
+	if err := c.failStageHook(context.Background(), cfg, route, ps, revisionMap, policy, "smoke"); err != nil {
+		t.Fatalf("failStageHook() re-entry = %v", err)
+	}
+	if got := candidatePercent(route, "cand"); got != 10 {
+		t.Errorf("candidate share after one step down = %d%%, want 10%%", got)
+	}
+
+	// past the last stage all traffic is back on stable and the rollout aborts
+	timer.Step(60 * time.Second)
+	if err := c.failStageHook(context.Background(), cfg, route, ps, revisionMap, policy, "smoke"); err != nil {
+		t.Fatalf("failStageHook() final step = %v", err)
+	}
+	if got := candidatePercent(route, "cand"); got != 0 {
+		t.Errorf("candidate share after the rollback = %d%%, want 0%%", got)
+	}
+	if ps.Status.Phase != v1alpha1.PhaseAborted {
+		t.Errorf("Phase = %q, want %q", ps.Status.Phase, v1alpha1.PhaseAborted)
+	}
+}
+
+func TestJobConditions(t *testing.T) {
+	completed := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+		{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+	}}}
+	failed := &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+		{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+	}}}
+	running := &batchv1.Job{}
+	if !jobCompleted(completed) || jobCompleted(failed) || jobCompleted(running) {
+		t.Errorf("jobCompleted misclassified a Job")
+	}
+	if jobFailed(completed) || !jobFailed(failed) || jobFailed(running) {
+		t.Errorf("jobFailed misclassified a Job")
+	}
+}
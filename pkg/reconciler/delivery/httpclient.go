@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+)
+
+// newHTTPClient builds the client used for all outbound HTTP (metric
+// providers, verification hooks, notification endpoints): it honors the
+// cluster's proxy environment variables, dials dual-stack so IPv6-only
+// endpoints work, and trusts any extra CA bundle from config-delivery on top
+// of the system roots; the bundle is re-read through load on every request, so
+// rotating the ConfigMap takes effect without a controller restart
+func newHTTPClient(load func() *config.Config) *http.Client {
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &dynamicTransport{load: load},
+	}
+}
+
+// dynamicTransport lazily builds the underlying transport from the current CA
+// bundle and rebuilds it whenever the bundle changes
+type dynamicTransport struct {
+	load func() *config.Config
+
+	mu        sync.Mutex
+	bundle    string
+	transport *http.Transport
+}
+
+func (t *dynamicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	bundle := t.load().Delivery.HTTPCABundle
+	t.mu.Lock()
+	if t.transport == nil || t.bundle != bundle {
+		transport, err := newTransport(bundle)
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		t.transport, t.bundle = transport, bundle
+	}
+	transport := t.transport
+	t.mu.Unlock()
+	return transport.RoundTrip(req)
+}
+
+// newTransport builds a proxy-aware, dual-stack transport trusting the system
+// roots plus the given PEM bundle
+func newTransport(caBundle string) (*http.Transport, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if caBundle == "" {
+		return transport, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, fmt.Errorf("failed to parse any certificates from the %s bundle", config.HTTPCABundleKey)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+)
+
+// selfSignedPEM mints a throwaway CA certificate for transport tests
+func selfSignedPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kcd-test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestNewTransport(t *testing.T) {
+	// without a bundle the transport keeps the default (system) trust store
+	transport, err := newTransport("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transport.TLSClientConfig != nil {
+		t.Error("empty bundle should not install a custom TLS config")
+	}
+	if transport.Proxy == nil {
+		t.Error("transport must honor the proxy environment variables")
+	}
+
+	// a valid bundle is appended to the trust store
+	transport, err = newTransport(selfSignedPEM(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.RootCAs == nil {
+		t.Error("valid bundle should install a custom trust store")
+	}
+
+	// garbage is rejected instead of silently distrusting the endpoint
+	if _, err := newTransport("not a certificate"); err == nil {
+		t.Error("expected an error for an unparseable bundle")
+	}
+}
+
+func TestDynamicTransportRebuild(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	bundle := ""
+	dt := &dynamicTransport{load: func() *config.Config {
+		return &config.Config{Delivery: &config.Delivery{HTTPCABundle: bundle}}
+	}}
+	get := func() {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to build request: %v", err)
+		}
+		resp, err := dt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// the first request builds the transport, identical config reuses it
+	get()
+	first := dt.transport
+	get()
+	if dt.transport != first {
+		t.Error("unchanged CA bundle must not rebuild the transport")
+	}
+
+	// a rotated bundle swaps the transport without a restart
+	bundle = selfSignedPEM(t)
+	get()
+	if dt.transport == first {
+		t.Error("changed CA bundle must rebuild the transport")
+	}
+}
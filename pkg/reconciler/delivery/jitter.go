@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"hash/fnv"
+	"math"
+	"time"
+
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// jitteredElapsed stretches or shrinks a Revision's counted rollout time by a
+// deterministic per-Revision factor within plus or minus the Policy's
+// JitterPercent, so that hundreds of Configurations sharing one Policy do not
+// hit their stage boundaries in the same instant and hammer the API server;
+// hashing the Revision's key keeps the factor stable across reconciles, so
+// every gate and timer in one rollout agrees on its (shifted) schedule
+func jitteredElapsed(policy *Policy, revision *v1.Revision, elapsed time.Duration) time.Duration {
+	if policy.JitterPercent == nil || *policy.JitterPercent <= 0 {
+		return elapsed
+	}
+	h := fnv.New32a()
+	h.Write([]byte(revision.Namespace + "/" + revision.Name))
+	// map the hash onto [-1, 1] and scale the elapsed time by 1 plus that
+	// fraction of the configured jitter
+	unit := float64(h.Sum32())/float64(math.MaxUint32)*2 - 1
+	scale := 1 + unit*float64(*policy.JitterPercent)/100
+	return time.Duration(float64(elapsed) * scale)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestJitteredElapsed(t *testing.T) {
+	elapsed := 100 * time.Second
+	plain := &Policy{Mode: "time", DefaultThreshold: 60}
+	jittered := &Policy{Mode: "time", DefaultThreshold: 60, JitterPercent: intptr(20)}
+
+	// no jitter configured leaves the elapsed time untouched
+	rev := Revision("default", "R1")
+	if got := jitteredElapsed(plain, rev, elapsed); got != elapsed {
+		t.Errorf("jitteredElapsed() without jitter = %v, want %v", got, elapsed)
+	}
+
+	// the skew is deterministic per Revision and stays within the bound
+	lo, hi := 80*time.Second, 120*time.Second
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		rev := Revision("default", fmt.Sprintf("cfg-%05d", i))
+		got := jitteredElapsed(jittered, rev, elapsed)
+		if got != jitteredElapsed(jittered, rev, elapsed) {
+			t.Errorf("jitteredElapsed() is not deterministic for %s", rev.Name)
+		}
+		if got < lo || got > hi {
+			t.Errorf("jitteredElapsed() for %s = %v, want within [%v, %v]", rev.Name, got, lo, hi)
+		}
+		seen[got] = true
+	}
+	// different Revisions must actually spread out, or the jitter is useless
+	if len(seen) < 2 {
+		t.Error("jitteredElapsed() produced the same skew for every Revision")
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// metricProviderFor builds the metrics provider a Policy's compare gates
+// sample from; a Policy without a metricProvider falls back to the provider
+// injected into the Reconciler (nil in production, a fake in tests)
+func (c *Reconciler) metricProviderFor(cfg *v1.Configuration, policy *Policy) MetricsProvider {
+	mp := policy.MetricProvider
+	if mp == nil {
+		return c.metrics
+	}
+	switch mp.Type {
+	case "prometheus":
+		return &prometheusProvider{url: mp.URL, client: c.httpclient}
+	case "datadog":
+		return &datadogProvider{url: mp.URL, client: c.httpclient,
+			kubeclient: c.kubeclient, namespace: cfg.Namespace, secretName: mp.APIKeySecret}
+	case "otlp":
+		return &otlpProvider{url: mp.URL, client: c.httpclient}
+	}
+	return c.metrics
+}
+
+// prometheusProvider samples a PromQL endpoint using the metric names that
+// Knative Serving exports for Revisions
+type prometheusProvider struct {
+	url    string
+	client *http.Client
+}
+
+// Sample implements MetricsProvider
+func (p *prometheusProvider) Sample(namespace, revision string, window time.Duration) (MetricSample, error) {
+	secs := int(window / time.Second)
+	selector := fmt.Sprintf(`namespace_name=%q,revision_name=%q`, namespace, revision)
+	requests, err := p.query(fmt.Sprintf(`sum(increase(revision_app_request_count{%s}[%ds]))`, selector, secs))
+	if err != nil {
+		return MetricSample{}, err
+	}
+	errors, err := p.query(fmt.Sprintf(`sum(increase(revision_app_request_count{%s,response_code_class="5xx"}[%ds]))`, selector, secs))
+	if err != nil {
+		return MetricSample{}, err
+	}
+	p50, err := p.query(fmt.Sprintf(`histogram_quantile(0.5, sum by (le) (increase(revision_app_request_latencies_bucket{%s}[%ds])))`, selector, secs))
+	if err != nil {
+		return MetricSample{}, err
+	}
+	p99, err := p.query(fmt.Sprintf(`histogram_quantile(0.99, sum by (le) (increase(revision_app_request_latencies_bucket{%s}[%ds])))`, selector, secs))
+	if err != nil {
+		return MetricSample{}, err
+	}
+	return MetricSample{Requests: int64(requests), Errors: int64(errors),
+		LatencyP50Millis: int64(p50), LatencyP99Millis: int64(p99)}, nil
+}
+
+// query runs one instant query and returns the value of its first sample;
+// an empty result means the metric does not exist yet and reads as zero
+func (p *prometheusProvider) query(q string) (float64, error) {
+	resp, err := p.client.Get(p.url + "/api/v1/query?query=" + url.QueryEscape(q))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("prometheus query returned status %d", resp.StatusCode)
+	}
+	return parsePromValue(body)
+}
+
+// parsePromValue extracts the scalar value from a Prometheus instant-query
+// response; Prometheus encodes sample values as strings inside [time, value] pairs
+func parsePromValue(body []byte) (float64, error) {
+	var parsed struct {
+		Data struct {
+			Result []struct {
+				Value []interface{} `json:"value"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return 0, err
+	}
+	if len(parsed.Data.Result) == 0 || len(parsed.Data.Result[0].Value) < 2 {
+		return 0, nil
+	}
+	s, ok := parsed.Data.Result[0].Value[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected prometheus value %v", parsed.Data.Result[0].Value[1])
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// datadogProvider samples the Datadog query API; the credentials live in a
+// Secret next to the workload so that a shared Policy never embeds them
+type datadogProvider struct {
+	url        string
+	client     *http.Client
+	kubeclient kubernetes.Interface
+	namespace  string
+	secretName string
+}
+
+// Sample implements MetricsProvider
+func (d *datadogProvider) Sample(namespace, revision string, window time.Duration) (MetricSample, error) {
+	apiKey, appKey, err := d.keys()
+	if err != nil {
+		return MetricSample{}, err
+	}
+	scope := fmt.Sprintf("namespace_name:%s,revision_name:%s", namespace, revision)
+	requests, err := d.query(fmt.Sprintf("sum:knative.revision_app_request_count{%s}.as_count()", scope), window, apiKey, appKey, sumPoints)
+	if err != nil {
+		return MetricSample{}, err
+	}
+	errors, err := d.query(fmt.Sprintf("sum:knative.revision_app_request_count{%s,response_code_class:5xx}.as_count()", scope), window, apiKey, appKey, sumPoints)
+	if err != nil {
+		return MetricSample{}, err
+	}
+	p50, err := d.query(fmt.Sprintf("p50:knative.revision_app_request_latencies{%s}", scope), window, apiKey, appKey, lastPoint)
+	if err != nil {
+		return MetricSample{}, err
+	}
+	p99, err := d.query(fmt.Sprintf("p99:knative.revision_app_request_latencies{%s}", scope), window, apiKey, appKey, lastPoint)
+	if err != nil {
+		return MetricSample{}, err
+	}
+	return MetricSample{Requests: int64(requests), Errors: int64(errors),
+		LatencyP50Millis: int64(p50), LatencyP99Millis: int64(p99)}, nil
+}
+
+// keys reads the Datadog credentials from the workload's Secret
+func (d *datadogProvider) keys() (string, string, error) {
+	secret, err := d.kubeclient.CoreV1().Secrets(d.namespace).Get(d.secretName, metav1.GetOptions{})
+	if err != nil {
+		return "", "", err
+	}
+	return string(secret.Data["api-key"]), string(secret.Data["app-key"]), nil
+}
+
+// query runs one timeseries query over the window and folds the returned
+// points with the given reducer
+func (d *datadogProvider) query(q string, window time.Duration, apiKey, appKey string, reduce func([]float64) float64) (float64, error) {
+	now := time.Now()
+	params := url.Values{}
+	params.Set("query", q)
+	params.Set("from", strconv.FormatInt(now.Add(-window).Unix(), 10))
+	params.Set("to", strconv.FormatInt(now.Unix(), 10))
+	req, err := http.NewRequest(http.MethodGet, d.url+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("DD-API-KEY", apiKey)
+	if appKey != "" {
+		req.Header.Set("DD-APPLICATION-KEY", appKey)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("datadog query returned status %d", resp.StatusCode)
+	}
+	points, err := parseDatadogPoints(body)
+	if err != nil {
+		return 0, err
+	}
+	return reduce(points), nil
+}
+
+// parseDatadogPoints flattens a Datadog timeseries response into its values,
+// skipping the null points Datadog emits for empty intervals
+func parseDatadogPoints(body []byte) ([]float64, error) {
+	var parsed struct {
+		Series []struct {
+			Pointlist [][]*float64 `json:"pointlist"`
+		} `json:"series"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, err
+	}
+	var points []float64
+	for _, series := range parsed.Series {
+		for _, point := range series.Pointlist {
+			if len(point) < 2 || point[1] == nil {
+				continue
+			}
+			points = append(points, *point[1])
+		}
+	}
+	return points, nil
+}
+
+// sumPoints reduces count points to their total over the window
+func sumPoints(points []float64) float64 {
+	total := 0.0
+	for _, p := range points {
+		total += p
+	}
+	return total
+}
+
+// lastPoint reduces gauge points to the most recent observation
+func lastPoint(points []float64) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	return points[len(points)-1]
+}
+
+// otlpProvider samples a store fed by OTLP through the generic sample
+// endpoint: a GET returning the sample fields as JSON; this covers the many
+// backends that can front their data with a small adapter but speak neither
+// PromQL nor the Datadog API
+type otlpProvider struct {
+	url    string
+	client *http.Client
+}
+
+// Sample implements MetricsProvider
+func (o *otlpProvider) Sample(namespace, revision string, window time.Duration) (MetricSample, error) {
+	params := url.Values{}
+	params.Set("namespace", namespace)
+	params.Set("revision", revision)
+	params.Set("windowSeconds", strconv.Itoa(int(window/time.Second)))
+	resp, err := o.client.Get(o.url + "?" + params.Encode())
+	if err != nil {
+		return MetricSample{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return MetricSample{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return MetricSample{}, fmt.Errorf("sample endpoint returned status %d", resp.StatusCode)
+	}
+	var sample struct {
+		Requests         int64 `json:"requests"`
+		Errors           int64 `json:"errors"`
+		LatencyP50Millis int64 `json:"latencyP50Millis"`
+		LatencyP99Millis int64 `json:"latencyP99Millis"`
+	}
+	if err := json.Unmarshal(body, &sample); err != nil {
+		return MetricSample{}, err
+	}
+	return MetricSample{Requests: sample.Requests, Errors: sample.Errors,
+		LatencyP50Millis: sample.LatencyP50Millis, LatencyP99Millis: sample.LatencyP99Millis}, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+)
+
+func TestParsePromValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    float64
+		wantErr bool
+	}{{
+		name: "a vector result yields its first sample",
+		body: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1598889600,"42.5"]}]}}`,
+		want: 42.5,
+	}, {
+		name: "an empty result reads as zero",
+		body: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+		want: 0,
+	}, {
+		name:    "a non-string sample value is rejected",
+		body:    `{"data":{"result":[{"value":[1598889600,42.5]}]}}`,
+		wantErr: true,
+	}, {
+		name:    "garbage is rejected",
+		body:    `not json`,
+		wantErr: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePromValue([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePromValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePromValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDatadogPoints(t *testing.T) {
+	body := `{"series":[{"pointlist":[[1598889600000,10],[1598889660000,null],[1598889720000,20]]}]}`
+	points, err := parseDatadogPoints([]byte(body))
+	if err != nil {
+		t.Fatalf("parseDatadogPoints() returned %v", err)
+	}
+	if got := sumPoints(points); got != 30 {
+		t.Errorf("sumPoints() = %v, want 30", got)
+	}
+	if got := lastPoint(points); got != 20 {
+		t.Errorf("lastPoint() = %v, want 20", got)
+	}
+	if got := lastPoint(nil); got != 0 {
+		t.Errorf("lastPoint(nil) = %v, want 0", got)
+	}
+}
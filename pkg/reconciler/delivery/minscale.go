@@ -0,0 +1,159 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"strconv"
+
+	"knative.dev/pkg/logging"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// holdCandidateMinScale pins the candidate Revision's autoscaling minScale so
+// it cannot scale to zero mid-rollout: at 1% traffic a candidate may see
+// minutes between requests, and judging it through cold starts would skew
+// exactly the signals the rollout is watching
+// A minScale annotation the user set themselves is left alone; only pins
+// carrying the reconciler's marker are ever written or removed
+func (c *Reconciler) holdCandidateMinScale(ctx context.Context, rev *v1.Revision, minScale int) error {
+	desired := strconv.Itoa(minScale)
+	if existing := rev.Annotations[autoscaling.MinScaleAnnotationKey]; existing != "" {
+		if rev.Annotations[delivery.HoldsMinScaleKey] == "" {
+			return nil // the user pinned their own minScale
+		}
+		if existing == desired {
+			return nil
+		}
+	}
+	update := rev.DeepCopy()
+	if update.Annotations == nil {
+		update.Annotations = map[string]string{}
+	}
+	update.Annotations[autoscaling.MinScaleAnnotationKey] = desired
+	update.Annotations[delivery.HoldsMinScaleKey] = "true"
+	logging.FromContext(ctx).Infof("Keeping candidate %s warm with minScale=%s for the duration of the rollout", rev.Name, desired)
+	_, err := c.client.ServingV1().Revisions(rev.Namespace).Update(update)
+	return err
+}
+
+// releaseCandidateMinScale removes the scale floor once the rollout ends, but
+// only when the reconciler's marker shows the pin was ours to remove
+func (c *Reconciler) releaseCandidateMinScale(ctx context.Context, rev *v1.Revision) error {
+	if rev.Annotations[delivery.HoldsMinScaleKey] == "" {
+		return nil
+	}
+	update := rev.DeepCopy()
+	delete(update.Annotations, autoscaling.MinScaleAnnotationKey)
+	delete(update.Annotations, delivery.HoldsMinScaleKey)
+	logging.FromContext(ctx).Infof("Releasing the minScale pin on Revision %s", rev.Name)
+	_, err := c.client.ServingV1().Revisions(rev.Namespace).Update(update)
+	return err
+}
+
+// scaleMinScaleToShare adjusts a Revision's user-declared minScale to the
+// given traffic share, so reserved capacity follows the split instead of both
+// Revisions holding their full pod count through a long rollout; the declared
+// value is remembered in an annotation and put back by restoreMinScale
+func (c *Reconciler) scaleMinScaleToShare(ctx context.Context, rev *v1.Revision, percent int) error {
+	original := rev.Annotations[delivery.OriginalMinScaleKey]
+	if original == "" {
+		// a pin placed by holdCandidateMinScale is a warmth floor, not declared
+		// capacity, and is not scaled
+		if rev.Annotations[delivery.HoldsMinScaleKey] != "" {
+			return nil
+		}
+		original = rev.Annotations[autoscaling.MinScaleAnnotationKey]
+	}
+	declared, err := strconv.Atoi(original)
+	if err != nil || declared < 1 {
+		return nil // no declared capacity to transfer
+	}
+	scaled := (declared*percent + 99) / 100 // round up so capacity leads traffic
+	if scaled < 1 {
+		scaled = 1
+	}
+	desired := strconv.Itoa(scaled)
+	if rev.Annotations[autoscaling.MinScaleAnnotationKey] == desired && rev.Annotations[delivery.OriginalMinScaleKey] == original {
+		return nil
+	}
+	update := rev.DeepCopy()
+	update.Annotations[delivery.OriginalMinScaleKey] = original
+	update.Annotations[autoscaling.MinScaleAnnotationKey] = desired
+	logging.FromContext(ctx).Infof("Scaling minScale of Revision %s to %s for its %d%% traffic share", rev.Name, desired, percent)
+	_, err = c.client.ServingV1().Revisions(rev.Namespace).Update(update)
+	return err
+}
+
+// restoreMinScale puts a Revision's declared minScale back once the rollout
+// ends; Revisions without the transfer marker are left alone
+func (c *Reconciler) restoreMinScale(ctx context.Context, rev *v1.Revision) error {
+	original := rev.Annotations[delivery.OriginalMinScaleKey]
+	if original == "" {
+		return nil
+	}
+	update := rev.DeepCopy()
+	update.Annotations[autoscaling.MinScaleAnnotationKey] = original
+	delete(update.Annotations, delivery.OriginalMinScaleKey)
+	logging.FromContext(ctx).Infof("Restoring the declared minScale=%s of Revision %s", original, rev.Name)
+	_, err := c.client.ServingV1().Revisions(rev.Namespace).Update(update)
+	return err
+}
+
+// releaseScalePins undoes both kinds of minScale intervention at once; it runs
+// when a rollout reaches a terminal phase
+func (c *Reconciler) releaseScalePins(ctx context.Context, cfg *v1.Configuration, revisionMap map[string]*v1.Revision) error {
+	if rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]; ok {
+		if err := c.releaseCandidateMinScale(ctx, rev); err != nil {
+			return err
+		}
+	}
+	return c.restoreScaleTransfer(ctx, revisionMap, cfg.Status.LatestReadyRevisionName)
+}
+
+// applyScaleTransfer runs the proportional transfer for one Route update: the
+// candidate scales to its share and the stable Revision to the remainder
+func (c *Reconciler) applyScaleTransfer(ctx context.Context, route *v1.Route, revisionMap map[string]*v1.Revision, newRevName string) error {
+	share := candidatePercent(route, newRevName)
+	if rev, ok := revisionMap[newRevName]; ok {
+		if err := c.scaleMinScaleToShare(ctx, rev, share); err != nil {
+			return err
+		}
+	}
+	if stable := oldestRevision(revisionMap); stable != nil && stable.Name != newRevName {
+		if err := c.scaleMinScaleToShare(ctx, stable, 100-share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreScaleTransfer undoes applyScaleTransfer on both ends of the rollout
+func (c *Reconciler) restoreScaleTransfer(ctx context.Context, revisionMap map[string]*v1.Revision, newRevName string) error {
+	if rev, ok := revisionMap[newRevName]; ok {
+		if err := c.restoreMinScale(ctx, rev); err != nil {
+			return err
+		}
+	}
+	if stable := oldestRevision(revisionMap); stable != nil && stable.Name != newRevName {
+		if err := c.restoreMinScale(ctx, stable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/serving/pkg/apis/autoscaling"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestCandidateMinScale(t *testing.T) {
+	rev := Revision("default", "cfg-00002")
+	client := servingfake.NewSimpleClientset(rev)
+	c := &Reconciler{client: client}
+	ctx := context.Background()
+
+	// holding pins minScale and leaves the reconciler's marker
+	if err := c.holdCandidateMinScale(ctx, rev, 2); err != nil {
+		t.Fatalf("holdCandidateMinScale() = %v", err)
+	}
+	got, err := client.ServingV1().Revisions("default").Get("cfg-00002", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[autoscaling.MinScaleAnnotationKey] != "2" || got.Annotations[delivery.HoldsMinScaleKey] != "true" {
+		t.Errorf("annotations after hold = %v, want minScale=2 with the marker", got.Annotations)
+	}
+
+	// releasing removes both annotations again
+	if err := c.releaseCandidateMinScale(ctx, got); err != nil {
+		t.Fatalf("releaseCandidateMinScale() = %v", err)
+	}
+	got, err = client.ServingV1().Revisions("default").Get("cfg-00002", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[autoscaling.MinScaleAnnotationKey]; ok {
+		t.Errorf("minScale annotation survived the release: %v", got.Annotations)
+	}
+	if _, ok := got.Annotations[delivery.HoldsMinScaleKey]; ok {
+		t.Errorf("marker annotation survived the release: %v", got.Annotations)
+	}
+}
+
+func TestCandidateMinScaleRespectsUserPin(t *testing.T) {
+	rev := Revision("default", "cfg-00002")
+	rev.Annotations = map[string]string{autoscaling.MinScaleAnnotationKey: "5"}
+	client := servingfake.NewSimpleClientset(rev)
+	c := &Reconciler{client: client}
+	ctx := context.Background()
+
+	// a user-set minScale is left exactly as it is, both on hold and release
+	if err := c.holdCandidateMinScale(ctx, rev, 1); err != nil {
+		t.Fatalf("holdCandidateMinScale() = %v", err)
+	}
+	if err := c.releaseCandidateMinScale(ctx, rev); err != nil {
+		t.Fatalf("releaseCandidateMinScale() = %v", err)
+	}
+	got, err := client.ServingV1().Revisions("default").Get("cfg-00002", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[autoscaling.MinScaleAnnotationKey] != "5" {
+		t.Errorf("the user's minScale was modified: %v", got.Annotations)
+	}
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "update" {
+			t.Error("unexpected update action on a user-pinned Revision")
+		}
+	}
+}
+
+func TestScaleTransfer(t *testing.T) {
+	rev := Revision("default", "cfg-00001")
+	rev.Annotations = map[string]string{autoscaling.MinScaleAnnotationKey: "10"}
+	client := servingfake.NewSimpleClientset(rev)
+	c := &Reconciler{client: client}
+	ctx := context.Background()
+
+	// 25% of a declared 10 rounds up to 3, and the declared value is remembered
+	if err := c.scaleMinScaleToShare(ctx, rev, 25); err != nil {
+		t.Fatalf("scaleMinScaleToShare() = %v", err)
+	}
+	got, err := client.ServingV1().Revisions("default").Get("cfg-00001", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[autoscaling.MinScaleAnnotationKey] != "3" || got.Annotations[delivery.OriginalMinScaleKey] != "10" {
+		t.Errorf("annotations after transfer = %v, want minScale=3 remembering 10", got.Annotations)
+	}
+
+	// rescaling later in the rollout works off the remembered declared value
+	if err := c.scaleMinScaleToShare(ctx, got, 80); err != nil {
+		t.Fatalf("scaleMinScaleToShare() = %v", err)
+	}
+	got, _ = client.ServingV1().Revisions("default").Get("cfg-00001", metav1.GetOptions{})
+	if got.Annotations[autoscaling.MinScaleAnnotationKey] != "8" {
+		t.Errorf("minScale after rescaling = %q, want 8", got.Annotations[autoscaling.MinScaleAnnotationKey])
+	}
+
+	// restoring puts the declared value back and drops the marker
+	if err := c.restoreMinScale(ctx, got); err != nil {
+		t.Fatalf("restoreMinScale() = %v", err)
+	}
+	got, _ = client.ServingV1().Revisions("default").Get("cfg-00001", metav1.GetOptions{})
+	if got.Annotations[autoscaling.MinScaleAnnotationKey] != "10" {
+		t.Errorf("minScale after restore = %q, want 10", got.Annotations[autoscaling.MinScaleAnnotationKey])
+	}
+	if _, ok := got.Annotations[delivery.OriginalMinScaleKey]; ok {
+		t.Errorf("transfer marker survived the restore: %v", got.Annotations)
+	}
+}
+
+func TestScaleTransferSkipsUndeclared(t *testing.T) {
+	rev := Revision("default", "cfg-00002")
+	client := servingfake.NewSimpleClientset(rev)
+	c := &Reconciler{client: client}
+
+	// no declared minScale (and no transfer marker) means nothing to move
+	if err := c.scaleMinScaleToShare(context.Background(), rev, 50); err != nil {
+		t.Fatalf("scaleMinScaleToShare() = %v", err)
+	}
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "update" {
+			t.Error("unexpected update action on a Revision without declared capacity")
+		}
+	}
+}
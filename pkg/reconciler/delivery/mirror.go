@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// applyMirror records the current stage's shadow-traffic request on the Route, so
+// that the ingress layer duplicates live requests to the candidate Revision while
+// the stage holds; stages without a mirror (and rollouts on clusters where the
+// mirroring feature gate is off) clear any leftover mirror annotations instead
+// the Route spec passed in has already been written for the new stage
+func applyMirror(ctx context.Context, route *v1.Route, policy *Policy, revName string) {
+	mp := stageMirrorPercent(policy, candidatePercent(route, revName))
+	if mp == nil {
+		networking.ClearMirror(route)
+		return
+	}
+	if !config.FromContextOrDefaults(ctx).Features.Mirroring {
+		logging.FromContext(ctx).Info("Stage requests traffic mirroring, but the mirroring feature gate is off; skipping")
+		networking.ClearMirror(route)
+		return
+	}
+	logging.FromContext(ctx).Infof("Mirroring %d%% of live requests to Revision %s", *mp, revName)
+	networking.SetMirror(route, networking.MirrorTarget{RevisionName: revName, Percent: *mp})
+}
+
+// stageMirrorPercent returns the mirror percentage declared by the stage that the
+// rollout currently sits at, or nil when the stage doesn't ask for mirroring
+func stageMirrorPercent(policy *Policy, percent int) *int {
+	for _, s := range policy.Stages {
+		if s.Percent == percent {
+			return s.MirrorPercent
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/networking"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestApplyMirror(t *testing.T) {
+	mirrorStage := &Policy{Stages: []Stage{{Percent: 0, MirrorPercent: intptr(50)}, {Percent: 10}}}
+	plainStage := &Policy{Stages: []Stage{{Percent: 0}, {Percent: 10}}}
+	routeAt := func(percent int64) *v1.Route {
+		return &v1.Route{Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+			{RevisionName: "R1", Percent: ptr.Int64(100 - percent)},
+			{RevisionName: "R2", Percent: ptr.Int64(percent)},
+		}}}
+	}
+	withMirroring := config.ToContext(context.Background(), &config.Config{
+		Delivery: &config.Delivery{},
+		Features: &config.Features{Mirroring: true},
+	})
+
+	var tests = []struct {
+		name   string
+		ctx    context.Context
+		policy *Policy
+		route  *v1.Route
+		want   *networking.MirrorTarget
+	}{
+		{name: "mirror stage with gate enabled", ctx: withMirroring, policy: mirrorStage, route: routeAt(0),
+			want: &networking.MirrorTarget{RevisionName: "R2", Percent: 50}},
+		{name: "mirror stage with gate disabled", ctx: context.Background(), policy: mirrorStage, route: routeAt(0)},
+		{name: "stage without a mirror", ctx: withMirroring, policy: mirrorStage, route: routeAt(10)},
+		{name: "policy without mirrors clears leftovers", ctx: withMirroring, policy: plainStage, route: routeAt(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networking.SetMirror(tt.route, networking.MirrorTarget{RevisionName: "stale", Percent: 1})
+			applyMirror(tt.ctx, tt.route, tt.policy, "R2")
+			got := networking.GetMirror(tt.route)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("expected no mirror target, got %+v", *got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("mirror target is incorrect: got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,9 +16,10 @@ package delivery
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
 	"k8s.io/apimachinery/pkg/util/clock"
-	"knative.dev/pkg/ptr"
 	v1 "knative.dev/serving/pkg/apis/serving/v1"
 )
 
@@ -34,18 +35,44 @@ func isNameListed(route *v1.Route, newRevName string) bool {
 	return nameListed
 }
 
-/****************************************************************************************************************
-   modifyRouteSpec assigns traffic to an arbitrary number of active Revisions using a policy
-   arguments:
-   - route: the current Route object
-   - r: a lister to query the Revisions by name
-   - newRevName: name string of the latest ready Revision
-   - policy: pointer to the Policy struct that commands the rollout process
-   return values:
-   - 1st value: a new route object whose spec field has been written with the desired state
-   - 2nd value: error if anything goes wrong
-****************************************************************************************************************/
-func modifyRouteSpec(route *v1.Route, r map[string]*v1.Revision, newRevName string, policy *Policy, clock clock.Clock) (*v1.Route, error) {
+// maxTrafficTargets is the hard ceiling on the pool: Route percentages are
+// whole numbers, so past 100 entries the stage arithmetic would squeeze older
+// Revisions to zero regardless of any Policy setting
+const maxTrafficTargets = 100
+
+// capRoster enforces the Policy's pool cap (and the hard percent-granularity
+// ceiling) deterministically: the stable Revision at the front and the newest
+// entries survive, the mid-rollout Revisions between them are evicted and
+// their share returns to the stable Revision on this Route update
+func capRoster(roster []string, policy *Policy) []string {
+	limit := maxTrafficTargets
+	if policy.MaxActiveRevisions != nil && *policy.MaxActiveRevisions < limit {
+		limit = *policy.MaxActiveRevisions
+	}
+	if len(roster) <= limit {
+		return roster
+	}
+	capped := make([]string, 0, limit)
+	capped = append(capped, roster[0])
+	return append(capped, roster[len(roster)-(limit-1):]...)
+}
+
+/*
+***************************************************************************************************************
+
+	modifyRouteSpec assigns traffic to an arbitrary number of active Revisions using a policy
+	arguments:
+	- route: the current Route object
+	- r: a lister to query the Revisions by name
+	- newRevName: name string of the latest ready Revision
+	- policy: pointer to the Policy struct that commands the rollout process
+	return values:
+	- 1st value: a new route object whose spec field has been written with the desired state
+	- 2nd value: error if anything goes wrong
+
+***************************************************************************************************************
+*/
+func modifyRouteSpec(route *v1.Route, r map[string]*v1.Revision, newRevName string, policy *Policy, clock clock.Clock, pause time.Duration) (*v1.Route, error) {
 	// assumption 1: the current Route Status traffic % are all non-zero (any zero entries would not have been written)
 	// assumption 2: the current Route Status traffic entries are ordered from oldest to newest Revision
 
@@ -63,21 +90,27 @@ func modifyRouteSpec(route *v1.Route, r map[string]*v1.Revision, newRevName stri
 		if !ok {
 			return route, fmt.Errorf("cannot find Revision %s in indexer", newRevName)
 		}
-		route.Spec.Traffic = []v1.TrafficTarget{{
-			ConfigurationName: newRevision.OwnerReferences[0].Name,
-			LatestRevision:    ptr.Bool(true),
-			Percent:           ptr.Int64(100),
-		}}
+		route.Spec.Traffic = []v1.TrafficTarget{traffictarget.Latest(newRevision.OwnerReferences[0].Name)}
 		return route, nil
 	}
-	traffic := make([]v1.TrafficTarget, ln) // container for holding traffic assignments
-	roster := make([]string, ln)            // ordered list of all Revision names in the pool
+	roster := make([]string, ln) // ordered list of all Revision names in the pool
 	for i, t := range route.Status.Traffic {
 		roster[i] = t.RevisionName
 	}
 	if len(route.Status.Traffic) < len(roster) {
 		roster[len(roster)-1] = newRevName
 	}
+	roster = capRoster(roster, policy)
+	traffic := make([]v1.TrafficTarget, len(roster)) // container for holding traffic assignments
+
+	// the optional floor reserves part of the budget for the stable Revision so
+	// that many concurrent candidates cannot collectively starve it; a candidate
+	// that reached the final stage (100%) is exempt, otherwise no rollout could
+	// ever complete
+	budget := 100
+	if policy.MinStablePercent != nil {
+		budget = 100 - *policy.MinStablePercent
+	}
 
 	// go through the roster in reverse order (newest to oldest) and assign traffic to each Revision
 	alreadyAssigned := 0
@@ -89,21 +122,25 @@ func modifyRouteSpec(route *v1.Route, r map[string]*v1.Revision, newRevName stri
 		}
 		// exception for the oldest Revision
 		if revision == oldest {
-			traffic[i] = v1.TrafficTarget{
-				RevisionName:   roster[i],
-				LatestRevision: ptr.Bool(false),
-				Percent:        ptr.Int64(int64(100 - alreadyAssigned)),
-			}
+			traffic[i] = traffictarget.Pinned(roster[i], int64(100-alreadyAssigned))
 			break
 		}
-		timeElapsed := clock.Since(revision.CreationTimestamp.Time)
+		timeElapsed := jitteredElapsed(policy, revision, rolloutElapsed(clock, revision, pause))
 		want := computeNewPercentExplicit(policy, timeElapsed)
-		actual := min(want, 100-alreadyAssigned)
+		// a scheduled start holds the Revision at 0% (reachable only through
+		// its tag URL) until the requested moment
+		if startAtWait(clock, revision) > 0 {
+			want = 0
+		}
+		actual := min(want, budget-alreadyAssigned)
+		if want == 100 {
+			actual = min(want, 100-alreadyAssigned)
+		}
 		alreadyAssigned += actual
-		traffic[i] = v1.TrafficTarget{
-			RevisionName:   roster[i],
-			LatestRevision: ptr.Bool(false),
-			Percent:        ptr.Int64(int64(actual)),
+		traffic[i] = traffictarget.Pinned(roster[i], int64(actual))
+		if roster[i] == newRevName {
+			// tag the candidate so that Knative mints a distinct preview URL for it
+			traffic[i] = traffictarget.Tagged(traffic[i], CandidateTag)
 		}
 		if alreadyAssigned >= 100 {
 			traffic = traffic[i:] // eliminate all redundant 0 entries
@@ -114,11 +151,7 @@ func modifyRouteSpec(route *v1.Route, r map[string]*v1.Revision, newRevName stri
 	// this deals with the case e.g. a 10/90 split progressing to 0/100 leaving only one traffic target behind
 	// if we don't take care of this, then we might violate assumption 1 for future calls
 	if len(traffic) == 1 {
-		traffic[0] = v1.TrafficTarget{
-			ConfigurationName: route.Name,
-			LatestRevision:    ptr.Bool(true),
-			Percent:           ptr.Int64(100),
-		}
+		traffic[0] = traffictarget.Latest(route.Name)
 	}
 
 	route.Spec.Traffic = traffic
@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+const (
+	// NotificationSeverityInfo marks notifications nobody needs to act on
+	NotificationSeverityInfo = "info"
+	// NotificationSeverityPaging marks notifications that warrant waking a human
+	NotificationSeverityPaging = "paging"
+
+	// NotifyEventStageTransition announces a traffic percentage change
+	NotifyEventStageTransition = "StageTransition"
+	// NotifyEventRolloutCompleted announces that the candidate owns all traffic
+	NotifyEventRolloutCompleted = "RolloutCompleted"
+	// NotifyEventRolloutFailed announces a rollout that exceeded its progress deadline
+	NotifyEventRolloutFailed = "RolloutFailed"
+)
+
+// RolloutNotification is the JSON payload POSTed to a notification endpoint
+type RolloutNotification struct {
+	Severity      string `json:"severity"`
+	Event         string `json:"event"`
+	Namespace     string `json:"namespace"`
+	Configuration string `json:"configuration"`
+	Revision      string `json:"revision"`
+	Percent       int    `json:"percent"`
+	TriggeredBy   string `json:"triggeredBy,omitempty"`
+	Message       string `json:"message"`
+}
+
+// notifyTrafficChange announces the outcome of a successful traffic update:
+// a completion when the routing state just stabilized, or a stage transition
+// when the candidate's percentage actually moved; unchanged splits stay silent
+// so that routine reconciles don't flood the channel
+func (c *Reconciler) notifyTrafficChange(ctx context.Context, cfg *v1.Configuration, policy *Policy, prevPercent int, wasInFlight bool, route *v1.Route, actor string) {
+	if policy.Notifications == nil {
+		return
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	if *route.Spec.Traffic[0].LatestRevision {
+		if wasInFlight {
+			c.sendNotification(ctx, policy, &RolloutNotification{
+				Severity:      NotificationSeverityInfo,
+				Event:         NotifyEventRolloutCompleted,
+				Namespace:     cfg.Namespace,
+				Configuration: cfg.Name,
+				Revision:      revName,
+				Percent:       100,
+				TriggeredBy:   actor,
+				Message:       fmt.Sprintf("Rollout of Revision %q completed", revName),
+			})
+		}
+		return
+	}
+	percent := candidatePercent(route, revName)
+	if percent == prevPercent {
+		return
+	}
+	// stage transitions are the chatty part; "EndsOnly" policies suppress them
+	if policy.Notifications.Scope == v1alpha1.NotifyScopeEndsOnly {
+		return
+	}
+	c.sendNotification(ctx, policy, &RolloutNotification{
+		Severity:      NotificationSeverityInfo,
+		Event:         NotifyEventStageTransition,
+		Namespace:     cfg.Namespace,
+		Configuration: cfg.Name,
+		Revision:      revName,
+		Percent:       percent,
+		TriggeredBy:   actor,
+		Message:       fmt.Sprintf("Revision %q moved from %d%% to %d%% of traffic", revName, prevPercent, percent),
+	})
+}
+
+// notifyRolloutFailed announces a rollout that exceeded its progress deadline;
+// failures page regardless of the configured scope
+// route is the Route as it looked when the deadline fired, before any rollback
+func (c *Reconciler) notifyRolloutFailed(ctx context.Context, cfg *v1.Configuration, policy *Policy, route *v1.Route, actor string) {
+	if policy.Notifications == nil {
+		return
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	c.sendNotification(ctx, policy, &RolloutNotification{
+		Severity:      NotificationSeverityPaging,
+		Event:         NotifyEventRolloutFailed,
+		Namespace:     cfg.Namespace,
+		Configuration: cfg.Name,
+		Revision:      revName,
+		Percent:       currentPercent(route, revName),
+		TriggeredBy:   actor,
+		Message:       fmt.Sprintf("Rollout of Revision %q exceeded its progress deadline", revName),
+	})
+}
+
+// sendNotification routes the notification to the endpoint configured for its
+// severity and delivers it best-effort: delivery problems are logged, never
+// allowed to fail the reconciliation that produced the notification
+func (c *Reconciler) sendNotification(ctx context.Context, policy *Policy, n *RolloutNotification) {
+	url := policy.Notifications.InfoURL
+	if n.Severity == NotificationSeverityPaging && policy.Notifications.PagingURL != "" {
+		url = policy.Notifications.PagingURL
+	}
+	if url == "" {
+		return
+	}
+	payload, err := json.Marshal(n)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Failed to encode %s notification: %v", n.Event, err)
+		return
+	}
+	resp, err := c.httpclient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Failed to deliver %s notification: %v", n.Event, err)
+		return
+	}
+	resp.Body.Close()
+}
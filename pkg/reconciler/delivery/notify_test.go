@@ -0,0 +1,149 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestNotifyTrafficChange(t *testing.T) {
+	cfg := &v1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+		Status: v1.ConfigurationStatus{
+			ConfigurationStatusFields: v1.ConfigurationStatusFields{LatestReadyRevisionName: "R2"},
+		},
+	}
+	pinnedRoute := func(percent int) *v1.Route {
+		return &v1.Route{
+			Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{{
+				RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(int64(100 - percent)),
+			}, {
+				RevisionName: "R2", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(int64(percent)),
+			}}},
+		}
+	}
+	stableRoute := &v1.Route{
+		Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{{
+			ConfigurationName: "test", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100),
+		}}},
+	}
+	tests := []struct {
+		name        string
+		scope       string
+		prevPercent int
+		wasInFlight bool
+		route       *v1.Route
+		wantEvent   string // empty means no notification expected
+		wantPercent int
+	}{{
+		name:        "stage transition notifies",
+		prevPercent: 10,
+		wasInFlight: true,
+		route:       pinnedRoute(25),
+		wantEvent:   NotifyEventStageTransition,
+		wantPercent: 25,
+	}, {
+		name:        "unchanged split stays silent",
+		prevPercent: 25,
+		wasInFlight: true,
+		route:       pinnedRoute(25),
+	}, {
+		name:        "EndsOnly suppresses stage transitions",
+		scope:       v1alpha1.NotifyScopeEndsOnly,
+		prevPercent: 10,
+		wasInFlight: true,
+		route:       pinnedRoute(25),
+	}, {
+		name:        "completion notifies even for EndsOnly",
+		scope:       v1alpha1.NotifyScopeEndsOnly,
+		prevPercent: 50,
+		wasInFlight: true,
+		route:       stableRoute,
+		wantEvent:   NotifyEventRolloutCompleted,
+		wantPercent: 100,
+	}, {
+		name:        "already stable rollout stays silent",
+		prevPercent: 0,
+		wasInFlight: false,
+		route:       stableRoute,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got *RolloutNotification
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = &RolloutNotification{}
+				if err := json.NewDecoder(r.Body).Decode(got); err != nil {
+					t.Errorf("notification body is not valid JSON: %v", err)
+				}
+			}))
+			defer server.Close()
+
+			c := &Reconciler{httpclient: server.Client()}
+			policy := &Policy{Notifications: &v1alpha1.NotificationConfig{Scope: test.scope, InfoURL: server.URL}}
+			c.notifyTrafficChange(context.Background(), cfg, policy, test.prevPercent, test.wasInFlight, test.route, "user@example.com")
+
+			if test.wantEvent == "" {
+				if got != nil {
+					t.Fatalf("expected no notification, got %v", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatalf("expected a %s notification, got none", test.wantEvent)
+			}
+			if got.Event != test.wantEvent || got.Severity != NotificationSeverityInfo {
+				t.Errorf("wrong notification classification (got %s/%s, want %s/%s)", got.Severity, got.Event, NotificationSeverityInfo, test.wantEvent)
+			}
+			if got.Percent != test.wantPercent {
+				t.Errorf("wrong percent (got %d, want %d)", got.Percent, test.wantPercent)
+			}
+			if got.Revision != "R2" || got.Namespace != "default" || got.Configuration != "test" || got.TriggeredBy != "user@example.com" {
+				t.Errorf("wrong rollout identity in notification: %v", *got)
+			}
+		})
+	}
+}
+
+func TestSendNotificationSeverityRouting(t *testing.T) {
+	var infoHits, pagingHits int
+	info := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { infoHits++ }))
+	defer info.Close()
+	paging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { pagingHits++ }))
+	defer paging.Close()
+
+	c := &Reconciler{httpclient: info.Client()}
+	policy := &Policy{Notifications: &v1alpha1.NotificationConfig{InfoURL: info.URL, PagingURL: paging.URL}}
+	c.sendNotification(context.Background(), policy, &RolloutNotification{Severity: NotificationSeverityInfo, Event: NotifyEventStageTransition})
+	c.sendNotification(context.Background(), policy, &RolloutNotification{Severity: NotificationSeverityPaging, Event: NotifyEventRolloutFailed})
+	if infoHits != 1 || pagingHits != 1 {
+		t.Errorf("wrong severity routing (info %d, paging %d, want 1 each)", infoHits, pagingHits)
+	}
+
+	// without a dedicated paging endpoint, failures fall back to the info one
+	fallback := &Policy{Notifications: &v1alpha1.NotificationConfig{InfoURL: info.URL}}
+	c.sendNotification(context.Background(), fallback, &RolloutNotification{Severity: NotificationSeverityPaging, Event: NotifyEventRolloutFailed})
+	if infoHits != 2 {
+		t.Errorf("paging notification did not fall back to InfoURL (info hits %d, want 2)", infoHits)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// applyOverrides layers the per-Configuration override annotations on top of
+// the resolved Policy, so one workload can speed up or slow down its rollout
+// without forking the shared Policy; the webhook validates the annotations,
+// but a malformed value that slipped past it is logged and ignored rather
+// than stalling the rollout
+func applyOverrides(ctx context.Context, cfg *v1.Configuration, policy *Policy) *Policy {
+	tv, hasThreshold := cfg.Annotations[delivery.OverrideThresholdKey]
+	sv, hasStages := cfg.Annotations[delivery.OverrideStagesKey]
+	if !hasThreshold && !hasStages {
+		return policy
+	}
+	out := *policy
+	if hasStages {
+		if percents, err := delivery.ParseOverrideStages(sv); err != nil {
+			logging.FromContext(ctx).Infof("Ignoring malformed %s annotation: %v", delivery.OverrideStagesKey, err)
+		} else {
+			stages := make([]Stage, 0, len(percents)+1)
+			for _, p := range percents {
+				stages = append(stages, Stage{Percent: p})
+			}
+			// prepend the implicit 0-stage, exactly like translatePolicy
+			if len(stages) == 0 || stages[0].Percent != 0 {
+				stages = append([]Stage{{Percent: 0}}, stages...)
+			}
+			out.Stages = stages
+			logging.FromContext(ctx).Infof("Overriding rollout stages for %s/%s with %v", cfg.Namespace, cfg.Name, percents)
+		}
+	}
+	if hasThreshold {
+		if threshold, err := delivery.ParseOverrideThreshold(tv); err != nil {
+			logging.FromContext(ctx).Infof("Ignoring malformed %s annotation: %v", delivery.OverrideThresholdKey, err)
+		} else {
+			out.DefaultThreshold = threshold
+			// strip per-stage thresholds so the override applies uniformly; the
+			// 0-stage keeps its threshold because that is a dark-launch soak,
+			// not a pacing knob
+			stages := make([]Stage, len(out.Stages))
+			copy(stages, out.Stages)
+			for i := range stages {
+				if stages[i].Percent != 0 {
+					stages[i].Threshold = nil
+				}
+			}
+			out.Stages = stages
+			logging.FromContext(ctx).Infof("Overriding stage threshold for %s/%s with %d", cfg.Namespace, cfg.Name, threshold)
+		}
+	}
+	return &out
+}
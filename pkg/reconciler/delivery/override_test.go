@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	base := Policy{Mode: "time", Stages: []Stage{
+		{Percent: 0, Threshold: intptr(5)},
+		{Percent: 10, Threshold: intptr(60)},
+		{Percent: 50},
+	}, DefaultThreshold: 100}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *Policy
+	}{{
+		name: "no override annotations leave the policy untouched",
+		want: &base,
+	}, {
+		name:        "threshold override applies uniformly, sparing the dark-launch soak",
+		annotations: map[string]string{delivery.OverrideThresholdKey: "10"},
+		want: &Policy{Mode: "time", Stages: []Stage{
+			{Percent: 0, Threshold: intptr(5)},
+			{Percent: 10},
+			{Percent: 50},
+		}, DefaultThreshold: 10},
+	}, {
+		name:        "stage override replaces the ramp and gets the implicit 0-stage",
+		annotations: map[string]string{delivery.OverrideStagesKey: "25,75"},
+		want: &Policy{Mode: "time", Stages: []Stage{
+			{Percent: 0}, {Percent: 25}, {Percent: 75},
+		}, DefaultThreshold: 100},
+	}, {
+		name: "both overrides combine",
+		annotations: map[string]string{
+			delivery.OverrideThresholdKey: "20",
+			delivery.OverrideStagesKey:    "30",
+		},
+		want: &Policy{Mode: "time", Stages: []Stage{
+			{Percent: 0}, {Percent: 30},
+		}, DefaultThreshold: 20},
+	}, {
+		name:        "malformed override is ignored",
+		annotations: map[string]string{delivery.OverrideStagesKey: "fast"},
+		want:        &base,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Configuration("default", "test")
+			cfg.Annotations = tt.annotations
+			got := applyOverrides(context.Background(), cfg, &base)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("applyOverrides() diff (-want +got): %v", diff)
+			}
+		})
+	}
+
+	// the input policy must never be mutated; other Configurations share it
+	if diff := cmp.Diff(Policy{Mode: "time", Stages: []Stage{
+		{Percent: 0, Threshold: intptr(5)},
+		{Percent: 10, Threshold: intptr(60)},
+		{Percent: 50},
+	}, DefaultThreshold: 100}, base); diff != "" {
+		t.Errorf("applyOverrides() mutated its input (-want +got): %v", diff)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// In the "reconciler" route ownership mode there is no defaulting webhook
+// re-injecting the computed traffic split on every Route update; the
+// reconciler is the sole writer of Route specs, stamps managed Routes with an
+// ownership annotation so other tooling leaves them alone, and relies on the
+// Route drift handler to re-enforce the split when something rewrites it.
+
+// markRouteOwned stamps the ownership annotation on a Route the reconciler
+// manages; it reports whether the annotation was newly added
+func markRouteOwned(route *v1.Route) bool {
+	if route.Annotations[delivery.RouteOwnerKey] == delivery.RouteOwnerValue {
+		return false
+	}
+	if route.Annotations == nil {
+		route.Annotations = map[string]string{}
+	}
+	route.Annotations[delivery.RouteOwnerKey] = delivery.RouteOwnerValue
+	return true
+}
+
+// releaseRouteOwnership removes the ownership annotation from a released
+// workload's Route, so other writers know the spec is theirs again; a missing
+// Route or annotation is not an error
+func (c *Reconciler) releaseRouteOwnership(ctx context.Context, cfg *v1.Configuration) error {
+	route, err := c.routeLister.Routes(cfg.Namespace).Get(cfg.Name)
+	if apierrs.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if route.Annotations[delivery.RouteOwnerKey] != delivery.RouteOwnerValue {
+		return nil
+	}
+	logging.FromContext(ctx).Infof("Releasing Route %s/%s from reconciler ownership", route.Namespace, route.Name)
+	route = route.DeepCopy()
+	delete(route.Annotations, delivery.RouteOwnerKey)
+	_, err = c.client.ServingV1().Routes(route.Namespace).Update(route)
+	return err
+}
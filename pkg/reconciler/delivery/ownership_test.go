@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+	listers "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestMarkRouteOwned(t *testing.T) {
+	route := &v1.Route{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cfg"}}
+	if !markRouteOwned(route) {
+		t.Error("markRouteOwned() = false for an unowned Route, want true")
+	}
+	if route.Annotations[delivery.RouteOwnerKey] != delivery.RouteOwnerValue {
+		t.Errorf("ownership annotation = %q, want %q", route.Annotations[delivery.RouteOwnerKey], delivery.RouteOwnerValue)
+	}
+	// stamping again is a no-op
+	if markRouteOwned(route) {
+		t.Error("markRouteOwned() = true for an already-owned Route, want false")
+	}
+}
+
+func TestReleaseRouteOwnership(t *testing.T) {
+	owned := &v1.Route{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "cfg",
+		Annotations: map[string]string{delivery.RouteOwnerKey: delivery.RouteOwnerValue},
+	}}
+	client := servingfake.NewSimpleClientset(owned)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	indexer.Add(owned)
+	c := &Reconciler{client: client, routeLister: listers.NewRouteLister(indexer)}
+	cfg := Configuration("default", "cfg")
+	ctx := context.Background()
+
+	if err := c.releaseRouteOwnership(ctx, cfg); err != nil {
+		t.Fatalf("releaseRouteOwnership() = %v", err)
+	}
+	got, err := client.ServingV1().Routes("default").Get("cfg", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Annotations[delivery.RouteOwnerKey]; ok {
+		t.Errorf("ownership annotation survived the release: %v", got.Annotations)
+	}
+
+	// a missing Route is not an error
+	if err := c.releaseRouteOwnership(ctx, Configuration("default", "gone")); err != nil {
+		t.Errorf("releaseRouteOwnership() for a missing Route = %v, want nil", err)
+	}
+}
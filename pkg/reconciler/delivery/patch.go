@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"knative.dev/pkg/apis/duck"
+	"knative.dev/pkg/controller"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// The reconciler persists its Route and PolicyState changes as JSON merge
+// patches diffed against the informer's copy, instead of full updates: a patch
+// only carries the fields this reconcile actually changed, so concurrent
+// writers (the Serving reconciler, the webhook) no longer trip optimistic
+// concurrency on fields KCD does not care about.
+
+// patchPolicyState writes the reconciler's changes to a PolicyState; a
+// PolicyState created earlier in this reconcile has no informer copy to diff
+// against yet and falls back to a full update
+func (c *Reconciler) patchPolicyState(ps *v1alpha1.PolicyState) error {
+	before, err := c.policystateLister.PolicyStates(ps.Namespace).Get(ps.Name)
+	if err != nil {
+		_, err := c.psclient.DeliveryV1alpha1().PolicyStates(ps.Namespace).Update(ps)
+		return err
+	}
+	patch, err := duck.CreateMergePatch(before, ps)
+	if err != nil {
+		return err
+	}
+	if emptyPatch(patch) {
+		return nil
+	}
+	_, err = c.psclient.DeliveryV1alpha1().PolicyStates(ps.Namespace).Patch(ps.Name, types.MergePatchType, patch)
+	return err
+}
+
+// patchRoute writes the reconciler's changes to a Route, retrying conflicts
+// and transient API errors in place with exponential backoff: each retry
+// re-reads the Route and re-applies the computed traffic, so the patch is
+// never diffed against a stale base; once the backoff is exhausted it gives up
+// with a Warning event instead of leaning on workqueue retries with stale math
+func (c *Reconciler) patchRoute(ctx context.Context, cfg *v1.Configuration, route *v1.Route) error {
+	before, err := c.routeLister.Routes(route.Namespace).Get(route.Name)
+	if err != nil {
+		return err
+	}
+	desired := route
+	attempts := 0
+	err = retry.OnError(retry.DefaultBackoff, retriableWriteError, func() error {
+		if attempts > 0 {
+			fresh, err := c.client.ServingV1().Routes(route.Namespace).Get(route.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			before = fresh
+			desired = fresh.DeepCopy()
+			desired.Spec.Traffic = route.Spec.Traffic
+		}
+		attempts++
+		patch, err := duck.CreateMergePatch(before, desired)
+		if err != nil {
+			return err
+		}
+		if emptyPatch(patch) {
+			return nil
+		}
+		_, err = c.client.ServingV1().Routes(route.Namespace).Patch(route.Name, types.MergePatchType, patch)
+		return err
+	})
+	if err != nil {
+		if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+			recorder.Eventf(cfg, corev1.EventTypeWarning, "RouteUpdateFailed",
+				"Failed to write the computed traffic split to Route %q after %d attempts: %v", route.Name, attempts, err)
+		}
+	}
+	return err
+}
+
+// retriableWriteError reports whether a write failure is worth retrying in
+// place: optimistic-concurrency conflicts and transient API server hiccups
+// resolve on their own, anything else needs the caller's attention
+func retriableWriteError(err error) bool {
+	return apierrs.IsConflict(err) || apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) ||
+		apierrs.IsTooManyRequests(err) || apierrs.IsServiceUnavailable(err)
+}
+
+// emptyPatch reports whether a merge patch is a no-op ("{}")
+func emptyPatch(patch []byte) bool {
+	return len(patch) <= 2
+}
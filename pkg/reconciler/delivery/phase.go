@@ -0,0 +1,40 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// recordPhase moves the Configuration's PolicyState to phase when the state
+// machine allows it, on reconcile paths that perform no PolicyState update of
+// their own; this is status bookkeeping only, so failures are logged and
+// swallowed, and nothing is written when the phase is already current
+func (c *Reconciler) recordPhase(ctx context.Context, cfg *v1.Configuration, phase string) {
+	ps, err := c.policystateLister.PolicyStates(cfg.Namespace).Get(cfg.Name)
+	if err != nil {
+		return
+	}
+	ps = ps.DeepCopy()
+	if !ps.Status.TransitionPhase(phase) {
+		return
+	}
+	if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+		logging.FromContext(ctx).Warnf("Failed to record rollout phase %q for %s/%s: %v", phase, cfg.Namespace, cfg.Name, err)
+	}
+}
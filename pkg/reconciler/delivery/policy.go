@@ -44,35 +44,235 @@ type Policy struct {
 	// all rollout stages, in which case there is no need to copy paste the same value in all entries
 	// The interpretation of DefaultThreshold depends on the value of Mode
 	DefaultThreshold int
+
+	// JitterPercent spreads stage transitions of rollouts sharing this Policy
+	// by deterministically skewing each rollout's counted time within plus or
+	// minus this percentage; nil means no jitter
+	JitterPercent *int
+
+	// Priority ranks this rollout against others contending for the cluster's
+	// concurrency budget; higher wins, and the default is 0
+	Priority int
+
+	// Windows restricts when stage promotions may happen; empty means no restriction
+	Windows []v1alpha1.TimeWindow
+
+	// ProgressDeadlineSeconds bounds how long the rollout may sit unable to advance; nil means forever
+	ProgressDeadlineSeconds *int
+
+	// RollbackOnFailure sends all traffic back to the stable Revision when the rollout fails
+	RollbackOnFailure bool
+
+	// OnFailure names the failure response explicitly ("hold", "rollback" or
+	// "abort"); empty falls back to RollbackOnFailure
+	OnFailure string
+
+	// GradualRollback walks the forward Stages in reverse on rollback instead
+	// of snapping all traffic back to the stable Revision at once
+	GradualRollback bool
+
+	// RollbackStages are explicit descending stages for a gradual rollback;
+	// non-empty implies GradualRollback
+	RollbackStages []Stage
+
+	// VerifyDependencies blocks promotion while the candidate Revision's referenced
+	// ConfigMaps and Secrets are missing
+	VerifyDependencies bool
+
+	// MaxUnavailablePercent caps the combined traffic share of Revisions that are
+	// not fully Ready during any single Route update; nil means no cap
+	MaxUnavailablePercent *int
+
+	// ReplicaParityPercent requires the candidate to have at least this percent of
+	// the stable Revision's ready pods before its first non-zero traffic; nil means no gate
+	ReplicaParityPercent *int
+
+	// MaxActiveRevisions caps how many Revisions may hold traffic at once; nil means unbounded
+	MaxActiveRevisions *int
+
+	// MinStablePercent guarantees the stable Revision at least this percent of
+	// traffic until a candidate reaches the final stage; nil means no floor
+	MinStablePercent *int
+
+	// CandidateMinScale keeps the candidate warm during the rollout by pinning
+	// its autoscaling minScale to this value; nil means no pin
+	CandidateMinScale *int
+
+	// TransferMinScale scales the stable and candidate minScale annotations in
+	// proportion to their traffic shares for the duration of the rollout
+	TransferMinScale bool
+
+	// Experiment runs the two newest candidates as an A/B experiment on
+	// independent tracks; nil means all candidates share the Stages ladder
+	Experiment *v1alpha1.ExperimentConfig
+
+	// SLO fails the rollout when the candidate burns its error budget faster
+	// than the allowed multiple; nil means no error-budget gate
+	SLO *v1alpha1.SLOConfig
+
+	// MetricProvider configures the metrics backend the compare gates sample
+	// from; nil means no data source
+	MetricProvider *v1alpha1.MetricProviderConfig
+
+	// SpawnBaseline deploys a fresh copy of the stable Revision for compare
+	// gates to judge the candidate against, removing warm-cache bias
+	SpawnBaseline bool
+
+	// AssignmentSalt is mixed into the hashing seed recorded for user-bucketing modes
+	AssignmentSalt string
+
+	// Notifications configures which rollout events notify and where each
+	// severity is delivered; nil means no notifications
+	Notifications *v1alpha1.NotificationConfig
 }
 
 // Stage contains information about a progressive rollout stage
 type Stage struct {
-	Percent   int
-	Threshold *int
+	Percent            int
+	Threshold          *int
+	SlicePeriodSeconds *int
+	MirrorPercent      *int
+	HeaderRouting      *v1alpha1.HeaderRouting
+	Hooks              []v1alpha1.StageHook
+	Analysis           *v1alpha1.AnalysisGate
+	Compare            *v1alpha1.CompareGate
+	FailureLimit       *int
 }
 
 // translatePolicy takes in a v1alpha1.Policy and returns a Policy
 // it essentially copies v1alpha1.PolicySpec into a delivery.Policy object
 // the purpose is to strip away extraneous info and make things easier for other functions
 func translatePolicy(p *v1alpha1.Policy) *Policy {
+	// threshold values translate into seconds here, so that everything
+	// downstream keeps its bare-seconds arithmetic regardless of the unit the
+	// Policy was written in
+	factor := p.Spec.ThresholdUnitFactor()
 	stages := make([]Stage, len(p.Spec.Stages))
 	for i := range stages {
 		var thresholdptr *int = nil
-		if p.Spec.Stages[i].Threshold != nil {
-			thresholdptr = &(*p.Spec.Stages[i].Threshold)
+		if p.Spec.Stages[i].After != nil {
+			t := int(p.Spec.Stages[i].After.Duration / time.Second)
+			thresholdptr = &t
+		} else if p.Spec.Stages[i].Threshold != nil {
+			t := *p.Spec.Stages[i].Threshold * factor
+			thresholdptr = &t
 		}
-		stages[i] = Stage{p.Spec.Stages[i].Percent, thresholdptr}
+		stages[i] = Stage{Percent: p.Spec.Stages[i].Percent, Threshold: thresholdptr, MirrorPercent: p.Spec.Stages[i].MirrorPercent,
+			SlicePeriodSeconds: p.Spec.Stages[i].SlicePeriodSeconds,
+			HeaderRouting:      p.Spec.Stages[i].HeaderRouting, Hooks: p.Spec.Stages[i].Hooks, Analysis: p.Spec.Stages[i].Analysis,
+			Compare: p.Spec.Stages[i].Compare, FailureLimit: p.Spec.Stages[i].FailureLimit}
 	}
 	// prepend a 0-stage if first stage has non-zero or doesn't exist
 	if len(stages) == 0 || stages[0].Percent != 0 {
-		stages = append([]Stage{{0, nil}}, stages...)
+		stages = append([]Stage{{Percent: 0}}, stages...)
+	}
+	// experiment track stages live inside the raw config, so their explicit
+	// durations and non-second units are folded into bare-second thresholds here
+	experiment := p.Spec.Experiment
+	if experiment != nil {
+		experiment = experiment.DeepCopy()
+		for i := range experiment.Tracks {
+			for j := range experiment.Tracks[i].Stages {
+				s := &experiment.Tracks[i].Stages[j]
+				if s.After != nil {
+					t := int(s.After.Duration / time.Second)
+					s.Threshold = &t
+					s.After = nil
+				} else if s.Threshold != nil {
+					scaled := *s.Threshold * factor
+					s.Threshold = &scaled
+				}
+			}
+		}
 	}
 	return &Policy{
-		Mode:             p.Spec.Mode,
-		Stages:           stages,
-		DefaultThreshold: p.Spec.DefaultThreshold,
+		Mode:                    p.Spec.Mode,
+		Stages:                  stages,
+		DefaultThreshold:        p.Spec.DefaultThreshold * factor,
+		JitterPercent:           p.Spec.JitterPercent,
+		Priority:                p.Spec.Priority,
+		Windows:                 p.Spec.Windows,
+		ProgressDeadlineSeconds: p.Spec.ProgressDeadlineSeconds,
+		RollbackOnFailure:       p.Spec.RollbackOnFailure,
+		OnFailure:               p.Spec.OnFailure,
+		GradualRollback:         p.Spec.GradualRollback,
+		RollbackStages:          rollbackStagesOf(p),
+		VerifyDependencies:      p.Spec.VerifyDependencies,
+		MaxUnavailablePercent:   p.Spec.MaxUnavailablePercent,
+		ReplicaParityPercent:    p.Spec.ReplicaParityPercent,
+		MaxActiveRevisions:      p.Spec.MaxActiveRevisions,
+		MinStablePercent:        p.Spec.MinStablePercent,
+		CandidateMinScale:       p.Spec.CandidateMinScale,
+		TransferMinScale:        p.Spec.TransferMinScale,
+		Experiment:              experiment,
+		SLO:                     p.Spec.SLO,
+		MetricProvider:          p.Spec.MetricProvider,
+		SpawnBaseline:           p.Spec.SpawnBaseline,
+		AssignmentSalt:          p.Spec.AssignmentSalt,
+		Notifications:           p.Spec.Notifications,
+	}
+}
+
+// rollbackStagesOf copies the v1alpha1 rollback stages the way the forward
+// stages are copied, keeping only the fields a rollback can act on
+func rollbackStagesOf(p *v1alpha1.Policy) []Stage {
+	if len(p.Spec.RollbackStages) == 0 {
+		return nil
+	}
+	factor := p.Spec.ThresholdUnitFactor()
+	stages := make([]Stage, len(p.Spec.RollbackStages))
+	for i := range stages {
+		var thresholdptr *int = nil
+		if p.Spec.RollbackStages[i].After != nil {
+			t := int(p.Spec.RollbackStages[i].After.Duration / time.Second)
+			thresholdptr = &t
+		} else if p.Spec.RollbackStages[i].Threshold != nil {
+			t := *p.Spec.RollbackStages[i].Threshold * factor
+			thresholdptr = &t
+		}
+		stages[i] = Stage{Percent: p.Spec.RollbackStages[i].Percent, Threshold: thresholdptr}
+	}
+	return stages
+}
+
+// rollbackStages returns the descending stages a gradual rollback walks
+// through: the explicit RollbackStages when given, otherwise the forward
+// Stages mirrored; nil means rollbacks snap straight back to stable
+func rollbackStages(p *Policy) []Stage {
+	if len(p.RollbackStages) > 0 {
+		return p.RollbackStages
+	}
+	if !p.GradualRollback {
+		return nil
 	}
+	mirrored := make([]Stage, 0, len(p.Stages))
+	for i := len(p.Stages) - 1; i >= 0; i-- {
+		// ending at 0% is implicit on the way down, like 100% on the way up
+		if p.Stages[i].Percent == 0 {
+			continue
+		}
+		mirrored = append(mirrored, Stage{Percent: p.Stages[i].Percent, Threshold: p.Stages[i].Threshold})
+	}
+	return mirrored
+}
+
+// rollbackTarget computes the candidate's traffic share the given duration
+// into a gradual rollback, plus how long to wait before the next step down;
+// past the last stage the target is 0 and there is nothing left to wait for
+func rollbackTarget(stages []Stage, defaultThreshold int, elapsed time.Duration) (int, time.Duration) {
+	metric := float64(elapsed) / float64(time.Second)
+	metricCumulative := 0
+	for _, s := range stages {
+		extra := defaultThreshold
+		if s.Threshold != nil {
+			extra = *s.Threshold
+		}
+		metricCumulative += extra
+		if float64(metricCumulative) > metric {
+			return s.Percent, time.Duration(nextBiggerInt(float64(metricCumulative)-metric)) * time.Second
+		}
+	}
+	return 0, 0
 }
 
 // computeNewPercent calculates, given a Policy and the current rollout stage,
@@ -115,6 +315,14 @@ func computeNewPercentExplicit(p *Policy, elapsed time.Duration) int {
 	}
 	metric := float64(elapsed) / float64(time.Second)
 	metricCumulative := 0
+	// a 0-stage with an explicit threshold is a dark launch: the candidate soaks at
+	// 0% (reachable only through its tag URL) before any mainstream traffic shifts
+	if p.Stages[0].Percent == 0 && p.Stages[0].Threshold != nil {
+		metricCumulative += *p.Stages[0].Threshold
+		if float64(metricCumulative) > metric {
+			return 0
+		}
+	}
 	for _, s := range p.Stages[1:] {
 		extra := p.DefaultThreshold
 		if s.Threshold != nil {
@@ -137,6 +345,13 @@ func metricTillNextStage(p *Policy, elapsed time.Duration) int {
 	}
 	metric := float64(elapsed) / float64(time.Second)
 	metricCumulative := 0
+	// mirror the dark-launch soak period handled in computeNewPercentExplicit
+	if p.Stages[0].Percent == 0 && p.Stages[0].Threshold != nil {
+		metricCumulative += *p.Stages[0].Threshold
+		if float64(metricCumulative) > metric {
+			return nextBiggerInt(float64(metricCumulative) - metric)
+		}
+	}
 	for _, s := range p.Stages[1:] {
 		extra := p.DefaultThreshold
 		if s.Threshold != nil {
@@ -21,21 +21,23 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
 )
 
 var (
-	pa = Policy{"time", []Stage{{0, nil}, {1, nil}, {2, nil}, {3, nil}, {4, nil}, {5, nil}, {6, nil}, {7, nil}, {8, nil}, {99, nil}}, 5}
-	pb = Policy{"request", []Stage{{0, nil}, {90, nil}, {91, nil}, {92, nil}, {93, nil}, {94, nil}, {95, nil}, {96, nil}, {97, nil}, {98, nil}, {99, nil}}, 500}
-	pc = Policy{"error", []Stage{{0, nil}, {5, nil}, {20, nil}, {50, nil}, {80, nil}, {95, nil}}, 3}
-	pd = Policy{"time", []Stage{
-		{0, intptr(5)},
-		{4, intptr(10)},
-		{7, intptr(50)},
-		{10, nil},
-	}, 100}
-	p0 = Policy{"time", []Stage{}, 10}
-	pX = Policy{"request", []Stage{{90, nil}, {80, nil}, {70, nil}}, 5}
+	pa = Policy{Mode: "time", Stages: []Stage{{Percent: 0}, {Percent: 1}, {Percent: 2}, {Percent: 3}, {Percent: 4}, {Percent: 5}, {Percent: 6}, {Percent: 7}, {Percent: 8}, {Percent: 99}}, DefaultThreshold: 5}
+	pb = Policy{Mode: "request", Stages: []Stage{{Percent: 0}, {Percent: 90}, {Percent: 91}, {Percent: 92}, {Percent: 93}, {Percent: 94}, {Percent: 95}, {Percent: 96}, {Percent: 97}, {Percent: 98}, {Percent: 99}}, DefaultThreshold: 500}
+	pc = Policy{Mode: "error", Stages: []Stage{{Percent: 0}, {Percent: 5}, {Percent: 20}, {Percent: 50}, {Percent: 80}, {Percent: 95}}, DefaultThreshold: 3}
+	pd = Policy{Mode: "time", Stages: []Stage{
+		{Percent: 0, Threshold: intptr(5)},
+		{Percent: 4, Threshold: intptr(10)},
+		{Percent: 7, Threshold: intptr(50)},
+		{Percent: 10},
+	}, DefaultThreshold: 100}
+	p0 = Policy{Mode: "time", Stages: []Stage{}, DefaultThreshold: 10}
+	pX = Policy{Mode: "request", Stages: []Stage{{Percent: 90}, {Percent: 80}, {Percent: 70}}, DefaultThreshold: 5}
 )
 
 // knative.dev/pkg/ptr library doesn't have Int, so we need to implement it here
@@ -123,7 +125,8 @@ func TestComputeNewPercentExplicit(t *testing.T) {
 		{name: "policy D, elapsed halfway across non-uniform stages", policy: &pd, elapsed: 45 * time.Second, want: 7},
 		{name: "policy B, very long elapsed time", policy: &pb, elapsed: 10000000 * time.Second, want: 100},
 		{name: "policy A, elapsed time lies spot-on stage boundary", policy: &pa, elapsed: 25 * time.Second, want: 6},
-		{name: "policy D, elapsed time lies spot-on final boundary", policy: &pd, elapsed: 160 * time.Second, want: 100},
+		{name: "policy D, elapsed time lies spot-on final boundary", policy: &pd, elapsed: 165 * time.Second, want: 100},
+		{name: "policy D, elapsed time within the dark-launch soak", policy: &pd, elapsed: 3 * time.Second, want: 0},
 		{name: "Empty policy always return 100", policy: &p0, elapsed: 0, want: 100},
 		{name: "Unsorted policy doesn't affect result", policy: &pX, elapsed: 7 * time.Second, want: 70},
 	}
@@ -146,10 +149,11 @@ func TestMetricTillNextStage(t *testing.T) {
 		want    int
 	}{
 		{name: "policy A, elapsed time is halfway across a stage", policy: &pa, elapsed: 17 * time.Second, want: 4},
-		{name: "policy D, elapsed halfway across non-uniform stages", policy: &pd, elapsed: 45 * time.Second, want: 16},
+		{name: "policy D, elapsed halfway across non-uniform stages", policy: &pd, elapsed: 45 * time.Second, want: 21},
 		{name: "policy B, very long elapsed time", policy: &pb, elapsed: 10000000 * time.Second, want: math.MaxInt32},
 		{name: "policy A, elapsed time lies spot-on stage boundary", policy: &pa, elapsed: 25 * time.Second, want: 6},
-		{name: "policy D, elapsed time lies spot-on final boundary", policy: &pd, elapsed: 160 * time.Second, want: math.MaxInt32},
+		{name: "policy D, elapsed time lies spot-on final boundary", policy: &pd, elapsed: 165 * time.Second, want: math.MaxInt32},
+		{name: "policy D, elapsed time within the dark-launch soak", policy: &pd, elapsed: 3 * time.Second, want: 3},
 		{name: "Empty policy always return MAX INT", policy: &p0, elapsed: 0, want: math.MaxInt32},
 		{name: "Unsorted policy doesn't affect result", policy: &pX, elapsed: 7 * time.Second, want: 4},
 	}
@@ -164,6 +168,56 @@ func TestMetricTillNextStage(t *testing.T) {
 	}
 }
 
+func TestRollbackStages(t *testing.T) {
+	mirrored := pa
+	mirrored.GradualRollback = true
+	explicit := pa
+	explicit.RollbackStages = []Stage{{Percent: 50, Threshold: intptr(10)}, {Percent: 10}}
+
+	var tests = []struct {
+		name   string
+		policy *Policy
+		want   []Stage
+	}{
+		{name: "no gradual rollback configured", policy: &pa, want: nil},
+		{name: "forward stages mirrored, 0-stage dropped", policy: &mirrored,
+			want: []Stage{{Percent: 99}, {Percent: 8}, {Percent: 7}, {Percent: 6}, {Percent: 5}, {Percent: 4}, {Percent: 3}, {Percent: 2}, {Percent: 1}}},
+		{name: "explicit rollback stages win over mirroring", policy: &explicit,
+			want: []Stage{{Percent: 50, Threshold: intptr(10)}, {Percent: 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, rollbackStages(tt.policy)); diff != "" {
+				t.Errorf("rollbackStages() diff (-want +got): %v", diff)
+			}
+		})
+	}
+}
+
+func TestRollbackTarget(t *testing.T) {
+	stages := []Stage{{Percent: 50, Threshold: intptr(10)}, {Percent: 10}}
+	var tests = []struct {
+		name     string
+		elapsed  time.Duration
+		want     int
+		wantWait time.Duration
+	}{
+		{name: "first step down happens immediately", elapsed: 0, want: 50, wantWait: 11 * time.Second},
+		{name: "second stage uses the default threshold", elapsed: 12 * time.Second, want: 10, wantWait: 4 * time.Second},
+		{name: "past the last stage all traffic is back on stable", elapsed: 15 * time.Second, want: 0, wantWait: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, wait := rollbackTarget(stages, 5, tt.elapsed)
+			if got != tt.want || wait != tt.wantWait {
+				t.Errorf("rollbackTarget() = (%v, %v), want (%v, %v)", got, wait, tt.want, tt.wantWait)
+			}
+		})
+	}
+}
+
 func TestNextBiggerInt(t *testing.T) {
 	var tests = []struct {
 		name string
@@ -196,34 +250,52 @@ func TestTranslatePolicy(t *testing.T) {
 		want: &Policy{
 			Mode:             "time",
 			DefaultThreshold: 50,
-			Stages:           []Stage{{0, nil}},
+			Stages:           []Stage{{Percent: 0}},
 		},
 	}, {
 		name: "normal policy with optional thresholds",
 		in: MakePolicy("default", "test", WithMode("time"), WithDefaultThreshold(50),
-			WithStages(v1alpha1.Stage{10, intptr(20)}, v1alpha1.Stage{20, intptr(30)}, v1alpha1.Stage{50, nil})),
+			WithStages(v1alpha1.Stage{Percent: 10, Threshold: intptr(20)}, v1alpha1.Stage{Percent: 20, Threshold: intptr(30)}, v1alpha1.Stage{Percent: 50})),
 		want: &Policy{
 			Mode:             "time",
 			DefaultThreshold: 50,
-			Stages:           []Stage{{0, nil}, {10, intptr(20)}, {20, intptr(30)}, {50, nil}},
+			Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(20)}, {Percent: 20, Threshold: intptr(30)}, {Percent: 50}},
 		},
 	}, {
 		name: "normal policy without optional thresholds",
 		in: MakePolicy("default", "test", WithMode("time"), WithDefaultThreshold(50),
-			WithStages(v1alpha1.Stage{10, nil}, v1alpha1.Stage{20, nil}, v1alpha1.Stage{50, nil})),
+			WithStages(v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 50})),
+		want: &Policy{
+			Mode:             "time",
+			DefaultThreshold: 50,
+			Stages:           []Stage{{Percent: 0}, {Percent: 10}, {Percent: 20}, {Percent: 50}},
+		},
+	}, {
+		name: "minute thresholds translate into seconds",
+		in: MakePolicy("default", "test", WithMode("time"), WithDefaultThreshold(5), WithThresholdUnit(v1alpha1.ThresholdUnitMinutes),
+			WithStages(v1alpha1.Stage{Percent: 10, Threshold: intptr(2)}, v1alpha1.Stage{Percent: 50})),
+		want: &Policy{
+			Mode:             "time",
+			DefaultThreshold: 300,
+			Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(120)}, {Percent: 50}},
+		},
+	}, {
+		name: "explicit stage durations translate into seconds",
+		in: MakePolicy("default", "test", WithMode("time"), WithDefaultThreshold(50),
+			WithStages(v1alpha1.Stage{Percent: 10, After: &metav1.Duration{Duration: 5 * time.Minute}}, v1alpha1.Stage{Percent: 50})),
 		want: &Policy{
 			Mode:             "time",
 			DefaultThreshold: 50,
-			Stages:           []Stage{{0, nil}, {10, nil}, {20, nil}, {50, nil}},
+			Stages:           []Stage{{Percent: 0}, {Percent: 10, Threshold: intptr(300)}, {Percent: 50}},
 		},
 	}, {
 		name: "do not prepend 0 if it already exists",
 		in: MakePolicy("default", "test", WithMode("time"), WithDefaultThreshold(50),
-			WithStages(v1alpha1.Stage{0, nil}, v1alpha1.Stage{10, nil}, v1alpha1.Stage{20, nil}, v1alpha1.Stage{50, nil})),
+			WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 50})),
 		want: &Policy{
 			Mode:             "time",
 			DefaultThreshold: 50,
-			Stages:           []Stage{{0, nil}, {10, nil}, {20, nil}, {50, nil}},
+			Stages:           []Stage{{Percent: 0}, {Percent: 10}, {Percent: 20}, {Percent: 50}},
 		},
 	}}
 
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// applySelfCanary returns the Policy spec this Configuration should follow when
+// the Policy staggers its own edits: each Configuration is assigned a
+// deterministic bucket in [0, 100), and an edited spec is adopted once the
+// Configuration's fraction of the self-canary window has elapsed, so a bad
+// policy edit changes rollout behavior for a growing subset of services instead
+// of all of them at once
+// the adopted spec snapshot on the PolicyState is persisted by whichever
+// PolicyState update the reconciliation performs later
+func (c *Reconciler) applySelfCanary(ctx context.Context, cfg *v1.Configuration, policy *v1alpha1.Policy, ps *v1alpha1.PolicyState) *v1alpha1.Policy {
+	if policy.Spec.SelfCanarySeconds == nil {
+		ps.Spec.AdoptedPolicy = nil
+		ps.Spec.AdoptedGeneration = 0
+		return policy
+	}
+	// the first sight of a self-canarying Policy and a re-observation of the
+	// already-adopted generation both follow the current spec
+	if ps.Spec.AdoptedPolicy == nil || ps.Spec.AdoptedGeneration == policy.Generation {
+		ps.Spec.AdoptedPolicy = policy.Spec.DeepCopy()
+		ps.Spec.AdoptedGeneration = policy.Generation
+		return policy
+	}
+	changeTime := c.observeSpecChange(ctx, policy)
+	window := time.Duration(*policy.Spec.SelfCanarySeconds) * time.Second
+	bucket := adoptionBucket(policy.Namespace+"/"+policy.Name, cfg.Namespace+"/"+cfg.Name)
+	adoptAt := changeTime.Add(window * time.Duration(bucket+1) / 100)
+	if !adoptAt.After(c.clock.Now()) {
+		logging.FromContext(ctx).Infof("Adopting generation %d of self-canarying Policy %s/%s for %s/%s",
+			policy.Generation, policy.Namespace, policy.Name, cfg.Namespace, cfg.Name)
+		ps.Spec.AdoptedPolicy = policy.Spec.DeepCopy()
+		ps.Spec.AdoptedGeneration = policy.Generation
+		return policy
+	}
+	// hold the previously adopted spec and check back when our turn comes
+	c.followup(cfg, adoptAt.Sub(c.clock.Now()))
+	held := policy.DeepCopy()
+	held.Spec = *ps.Spec.AdoptedPolicy.DeepCopy()
+	return held
+}
+
+// observeSpecChange returns when the Policy's current spec generation was first
+// observed, stamping the Policy status on first sight; the stamp anchors the
+// self-canary window identically for every bound Configuration
+// stamping is status bookkeeping only, so failures fall back to the local clock
+func (c *Reconciler) observeSpecChange(ctx context.Context, policy *v1alpha1.Policy) time.Time {
+	if policy.Status.ObservedSpecGeneration == policy.Generation && policy.Status.SpecChangeTime != nil {
+		return policy.Status.SpecChangeTime.Time
+	}
+	now := c.clock.Now()
+	updated := policy.DeepCopy()
+	updated.Status.ObservedSpecGeneration = policy.Generation
+	updated.Status.SpecChangeTime = &metav1.Time{Time: now}
+	if _, err := c.psclient.DeliveryV1alpha1().Policies(updated.Namespace).UpdateStatus(updated); err != nil {
+		logging.FromContext(ctx).Warnf("Failed to record spec change time of Policy %s/%s: %v", policy.Namespace, policy.Name, err)
+	}
+	return now
+}
+
+// adoptionBucket deterministically places a Configuration in [0, 100) for a
+// given Policy, so that adoption order is stable across reconciles and
+// controller restarts, and differs between Policies
+func adoptionBucket(policyKey, cfgKey string) int {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s", policyKey, cfgKey)
+	return int(h.Sum64() % 100)
+}
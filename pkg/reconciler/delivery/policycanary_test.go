@@ -0,0 +1,161 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	deliveryfake "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned/fake"
+)
+
+func TestAdoptionBucket(t *testing.T) {
+	// buckets are deterministic, in range, and vary with both keys
+	b := adoptionBucket("default/policy", "default/cfg")
+	if b != adoptionBucket("default/policy", "default/cfg") {
+		t.Error("adoptionBucket is not deterministic")
+	}
+	if b < 0 || b >= 100 {
+		t.Errorf("adoptionBucket = %d, want [0, 100)", b)
+	}
+	if b == adoptionBucket("default/policy", "default/other") && b == adoptionBucket("default/other", "default/cfg") {
+		t.Error("adoptionBucket ignores its inputs")
+	}
+}
+
+func TestApplySelfCanary(t *testing.T) {
+	now := time.Now()
+	window := 1000
+	oldSpec := v1alpha1.PolicySpec{DefaultThreshold: 60, SelfCanarySeconds: &window}
+	newSpec := v1alpha1.PolicySpec{DefaultThreshold: 120, SelfCanarySeconds: &window}
+	cfg := &v1.Configuration{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cfg"}}
+	bucket := adoptionBucket("default/policy", "default/cfg")
+	rampTime := time.Duration(*newSpec.SelfCanarySeconds) * time.Second * time.Duration(bucket+1) / 100
+
+	canaryPolicy := func(changeTime time.Time) *v1alpha1.Policy {
+		return &v1alpha1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "policy", Generation: 2},
+			Spec:       newSpec,
+			Status: v1alpha1.PolicyStatus{PolicyStatusFields: v1alpha1.PolicyStatusFields{
+				ObservedSpecGeneration: 2,
+				SpecChangeTime:         &metav1.Time{Time: changeTime},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		policy         *v1alpha1.Policy
+		ps             *v1alpha1.PolicyState
+		wantThreshold  int
+		wantAdoptedGen int64
+		wantFollowup   time.Duration
+	}{{
+		name: "no self-canary window clears the snapshot",
+		policy: &v1alpha1.Policy{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "policy", Generation: 2},
+			Spec:       v1alpha1.PolicySpec{DefaultThreshold: 120},
+		},
+		ps: &v1alpha1.PolicyState{Spec: v1alpha1.PolicyStateSpec{
+			AdoptedPolicy: oldSpec.DeepCopy(), AdoptedGeneration: 1,
+		}},
+		wantThreshold: 120,
+	}, {
+		name:           "first sight adopts immediately",
+		policy:         canaryPolicy(now),
+		ps:             &v1alpha1.PolicyState{},
+		wantThreshold:  120,
+		wantAdoptedGen: 2,
+	}, {
+		name:   "already-adopted generation follows the current spec",
+		policy: canaryPolicy(now),
+		ps: &v1alpha1.PolicyState{Spec: v1alpha1.PolicyStateSpec{
+			AdoptedPolicy: newSpec.DeepCopy(), AdoptedGeneration: 2,
+		}},
+		wantThreshold:  120,
+		wantAdoptedGen: 2,
+	}, {
+		name:   "fresh edit holds the old spec until our turn",
+		policy: canaryPolicy(now),
+		ps: &v1alpha1.PolicyState{Spec: v1alpha1.PolicyStateSpec{
+			AdoptedPolicy: oldSpec.DeepCopy(), AdoptedGeneration: 1,
+		}},
+		wantThreshold:  60,
+		wantAdoptedGen: 1,
+		wantFollowup:   rampTime,
+	}, {
+		name:   "edit is adopted once our turn in the window comes",
+		policy: canaryPolicy(now.Add(-rampTime)),
+		ps: &v1alpha1.PolicyState{Spec: v1alpha1.PolicyStateSpec{
+			AdoptedPolicy: oldSpec.DeepCopy(), AdoptedGeneration: 1,
+		}},
+		wantThreshold:  120,
+		wantAdoptedGen: 2,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var enqueued time.Duration
+			c := &Reconciler{
+				clock:    clock.NewFakeClock(now),
+				psclient: deliveryfake.NewSimpleClientset(test.policy),
+				followup: func(_ *v1.Configuration, d time.Duration) { enqueued = d },
+			}
+			got := c.applySelfCanary(context.Background(), cfg, test.policy, test.ps)
+			if got.Spec.DefaultThreshold != test.wantThreshold {
+				t.Errorf("effective DefaultThreshold = %d, want %d", got.Spec.DefaultThreshold, test.wantThreshold)
+			}
+			if test.ps.Spec.AdoptedGeneration != test.wantAdoptedGen {
+				t.Errorf("AdoptedGeneration = %d, want %d", test.ps.Spec.AdoptedGeneration, test.wantAdoptedGen)
+			}
+			if enqueued != test.wantFollowup {
+				t.Errorf("followup = %v, want %v", enqueued, test.wantFollowup)
+			}
+		})
+	}
+}
+
+func TestObserveSpecChange(t *testing.T) {
+	now := time.Now()
+	policy := &v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "policy", Generation: 2},
+	}
+	client := deliveryfake.NewSimpleClientset(policy)
+	c := &Reconciler{clock: clock.NewFakeClock(now), psclient: client}
+
+	// an unobserved generation is stamped with the current time
+	if got := c.observeSpecChange(context.Background(), policy); !got.Equal(now) {
+		t.Errorf("observeSpecChange = %v, want %v", got, now)
+	}
+	stamped, err := client.DeliveryV1alpha1().Policies("default").Get("policy", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to fetch Policy: %v", err)
+	}
+	if stamped.Status.ObservedSpecGeneration != 2 || stamped.Status.SpecChangeTime == nil {
+		t.Errorf("spec change was not stamped: %+v", stamped.Status)
+	}
+
+	// a stamped generation reuses the recorded time instead of re-stamping
+	past := now.Add(-time.Hour)
+	stamped.Status.SpecChangeTime = &metav1.Time{Time: past}
+	if got := c.observeSpecChange(context.Background(), stamped); !got.Equal(past) {
+		t.Errorf("observeSpecChange = %v, want recorded %v", got, past)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"sort"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// updatePolicyUsage refreshes the Policy's view of its blast radius: which
+// Configurations currently resolve to it and how many of them are mid-rollout
+// this is status bookkeeping only, so the caller treats failures as non-fatal
+func (c *Reconciler) updatePolicyUsage(ctx context.Context, policy *v1alpha1.Policy) error {
+	cfgs, err := c.configurationLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	var bound []string
+	active := 0
+	for _, cfg := range cfgs {
+		if shouldSkipConfig(cfg) {
+			continue
+		}
+		winner, _, err := c.resolvePolicy(ctx, cfg)
+		if err != nil || winner.Namespace != policy.Namespace || winner.Name != policy.Name {
+			continue
+		}
+		bound = append(bound, cfg.Namespace+"/"+cfg.Name)
+		if ps, err := c.policystateLister.PolicyStates(cfg.Namespace).Get(cfg.Name); err == nil && ps.Status.NextUpdateTimestamp != nil {
+			active++
+		}
+	}
+	sort.Strings(bound)
+
+	if equality.Semantic.DeepEqual(bound, policy.Status.BoundConfigurations) && active == policy.Status.ActiveRollouts {
+		return nil
+	}
+	updated := policy.DeepCopy()
+	updated.Status.BoundConfigurations = bound
+	updated.Status.ActiveRollouts = active
+	_, err = c.psclient.DeliveryV1alpha1().Policies(updated.Namespace).UpdateStatus(updated)
+	return err
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// the possible values of PolicyState.Status.PolicySource, named after the policy
+// source that won the resolution for the rollout
+const (
+	PolicySourceAnnotation       = "annotation"
+	PolicySourceConfigMap        = "configmap"
+	PolicySourceSelector         = "label-selector"
+	PolicySourceNamespaceDefault = "namespace-default"
+	PolicySourceClusterDefault   = "cluster-default"
+)
+
+// DefaultPolicyName is the well-known name of namespace and cluster default Policies
+const DefaultPolicyName = "default"
+
+// errNoPolicy signals that no policy source applies to a Configuration; the
+// Configuration is then simply left alone rather than treated as an error
+var errNoPolicy = errors.New("no Policy applies to this Configuration")
+
+// resolvePolicy is the precedence engine for the possible policy sources
+// the sources are consulted in a fixed order, so conflicts resolve deterministically:
+//  1. the Policy named by the Configuration's "delivery.knative.dev/policy" annotation
+//  2. Policies in the Configuration's namespace whose label selector matches the
+//     Configuration (the highest spec.priority wins; ties break to the
+//     alphabetically first name)
+//  3. the Policy named "default" in the Configuration's namespace
+//  4. the Policy named "default" in KCD's own namespace (the cluster default)
+//
+// the winning source and Policy are recorded in the PolicyState status, so a
+// surprising rollout can always be traced back to the Policy that drove it
+func (c *Reconciler) resolvePolicy(ctx context.Context, cfg *v1.Configuration) (*v1alpha1.Policy, string, error) {
+	if name, ok := cfg.Annotations[delivery.PolicyNameKey]; ok {
+		// "configmap:" references hold the policy document in a ConfigMap rather
+		// than a Policy resource, for environments where creating CRs is gated
+		if strings.HasPrefix(name, delivery.PolicyConfigMapPrefix) {
+			p, err := c.policyFromConfigMap(ctx, cfg, strings.TrimPrefix(name, delivery.PolicyConfigMapPrefix))
+			if err != nil {
+				return nil, "", err
+			}
+			return p, PolicySourceConfigMap, nil
+		}
+		policyNamespace, policyName := identifyPolicy(cfg)
+		p, err := c.policyLister.Policies(policyNamespace).Get(policyName)
+		if err != nil {
+			// an explicitly named Policy that cannot be found is an error, never
+			// a fallthrough: silently using a lower-ranked source would be worse
+			return nil, "", err
+		}
+		return p, PolicySourceAnnotation, nil
+	}
+	candidates, err := c.policyLister.Policies(cfg.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, "", err
+	}
+	if p := bestSelectorPolicy(cfg, candidates); p != nil {
+		return p, PolicySourceSelector, nil
+	}
+	if p, err := c.policyLister.Policies(cfg.Namespace).Get(DefaultPolicyName); err == nil {
+		return p, PolicySourceNamespaceDefault, nil
+	} else if !apierrs.IsNotFound(err) {
+		return nil, "", err
+	}
+	if p, err := c.policyLister.Policies(KCDNamespace).Get(DefaultPolicyName); err == nil {
+		return p, PolicySourceClusterDefault, nil
+	} else if !apierrs.IsNotFound(err) {
+		return nil, "", err
+	}
+	return nil, "", errNoPolicy
+}
+
+// bestSelectorPolicy picks the winning selector Policy for a Configuration
+// among the Policies whose selector matches, the highest Priority wins and ties
+// break to the alphabetically first name; Policies without a selector never match
+func bestSelectorPolicy(cfg *v1.Configuration, policies []*v1alpha1.Policy) *v1alpha1.Policy {
+	var best *v1alpha1.Policy
+	for _, p := range policies {
+		if p.Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(p.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(cfg.Labels)) {
+			continue
+		}
+		if best == nil || p.Spec.Priority > best.Spec.Priority ||
+			(p.Spec.Priority == best.Spec.Priority && p.Name < best.Name) {
+			best = p
+		}
+	}
+	return best
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func selectorPolicy(name string, priority int, matchLabels map[string]string) *v1alpha1.Policy {
+	return &v1alpha1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: v1alpha1.PolicySpec{
+			Priority: priority,
+			Selector: &metav1.LabelSelector{MatchLabels: matchLabels},
+		},
+	}
+}
+
+func TestBestSelectorPolicy(t *testing.T) {
+	cfg := &v1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "app",
+			Labels:    map[string]string{"team": "search", "tier": "frontend"},
+		},
+	}
+	noSelector := &v1alpha1.Policy{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "plain"}}
+
+	var tests = []struct {
+		name     string
+		policies []*v1alpha1.Policy
+		want     string
+	}{
+		{name: "no candidates", policies: []*v1alpha1.Policy{noSelector}, want: ""},
+		{name: "non-matching selector loses", policies: []*v1alpha1.Policy{
+			selectorPolicy("other-team", 10, map[string]string{"team": "ads"}),
+			selectorPolicy("search", 0, map[string]string{"team": "search"}),
+		}, want: "search"},
+		{name: "highest priority wins", policies: []*v1alpha1.Policy{
+			selectorPolicy("careful", 5, map[string]string{"team": "search"}),
+			selectorPolicy("fast", 1, map[string]string{"tier": "frontend"}),
+		}, want: "careful"},
+		{name: "priority ties break to the first name", policies: []*v1alpha1.Policy{
+			selectorPolicy("zebra", 3, map[string]string{"team": "search"}),
+			selectorPolicy("aardvark", 3, map[string]string{"tier": "frontend"}),
+		}, want: "aardvark"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bestSelectorPolicy(cfg, tt.policies)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no policy to win, got %s", got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Errorf("winning policy is incorrect: got %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+const (
+	// PreflightRecheck is how often a failed startup preflight is retried
+	PreflightRecheck = 10 * time.Second
+
+	// webhookServiceName is the Service fronting the KCD mutating webhook; a
+	// rollout computed while the webhook is down would be silently dropped from
+	// the Route, so preflight refuses to start without it
+	webhookServiceName = "continuous-delivery-webhook"
+)
+
+// preflightGate holds reconciliation until the startup checks have passed;
+// a nil gate (as in tests) means no gating
+type preflightGate struct {
+	once sync.Once
+	done chan struct{}
+}
+
+func newPreflightGate() *preflightGate {
+	return &preflightGate{done: make(chan struct{})}
+}
+
+// pass marks the preflight as passed; it is safe to call more than once
+func (g *preflightGate) pass() {
+	g.once.Do(func() { close(g.done) })
+}
+
+// passed reports whether the preflight has passed without blocking
+func (g *preflightGate) passed() bool {
+	select {
+	case <-g.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// runPreflight verifies that this install is complete enough to safely manage
+// Routes: the CRDs are served, the controller may list/watch everything it
+// reconciles, and (in the webhook route ownership mode) the mutating webhook
+// has ready endpoints; it returns one message per failed check so the caller
+// can log a consolidated report
+func runPreflight(kc kubernetes.Interface, dc discovery.DiscoveryInterface, requireWebhook bool) []string {
+	var failures []string
+	if err := crdsServed(dc, v1alpha1.SchemeGroupVersion.String(), "policies", "policystates"); err != nil {
+		failures = append(failures, err.Error())
+	}
+	if err := crdsServed(dc, servingv1.SchemeGroupVersion.String(), "routes", "revisions", "configurations"); err != nil {
+		failures = append(failures, err.Error())
+	}
+	for _, r := range []struct{ group, resource string }{
+		{servingv1.SchemeGroupVersion.Group, "routes"},
+		{servingv1.SchemeGroupVersion.Group, "revisions"},
+		{servingv1.SchemeGroupVersion.Group, "configurations"},
+		{v1alpha1.SchemeGroupVersion.Group, "policies"},
+		{v1alpha1.SchemeGroupVersion.Group, "policystates"},
+	} {
+		if err := canListWatch(kc, r.group, r.resource); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	// in the reconciler-owned route mode the webhook stands down and need not
+	// be installed at all, so its endpoints are not a startup requirement
+	if requireWebhook {
+		if err := webhookReady(kc); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return failures
+}
+
+// crdsServed checks that the given group/version is served and offers all of
+// the named resources
+func crdsServed(dc discovery.DiscoveryInterface, groupVersion string, resources ...string) error {
+	list, err := dc.ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		return fmt.Errorf("the %s API is not served: %v", groupVersion, err)
+	}
+	served := make(map[string]bool, len(list.APIResources))
+	for _, r := range list.APIResources {
+		served[r.Name] = true
+	}
+	for _, r := range resources {
+		if !served[r] {
+			return fmt.Errorf("the %s API does not serve %s", groupVersion, r)
+		}
+	}
+	return nil
+}
+
+// canListWatch checks that the controller's service account may list and watch
+// the given resource cluster-wide
+func canListWatch(kc kubernetes.Interface, group, resource string) error {
+	for _, verb := range []string{"list", "watch"} {
+		ssar := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Group:    group,
+					Resource: resource,
+					Verb:     verb,
+				},
+			},
+		}
+		resp, err := kc.AuthorizationV1().SelfSubjectAccessReviews().Create(ssar)
+		if err != nil {
+			return fmt.Errorf("failed to review access to %s %s.%s: %v", verb, resource, group, err)
+		}
+		if !resp.Status.Allowed {
+			return fmt.Errorf("missing permission to %s %s.%s", verb, resource, group)
+		}
+	}
+	return nil
+}
+
+// webhookReady checks that the mutating webhook Service has at least one ready
+// endpoint
+func webhookReady(kc kubernetes.Interface) error {
+	ep, err := kc.CoreV1().Endpoints(KCDNamespace).Get(webhookServiceName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to look up the webhook service %s/%s: %v", KCDNamespace, webhookServiceName, err)
+	}
+	for _, subset := range ep.Subsets {
+		if len(subset.Addresses) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("the webhook service %s/%s has no ready endpoints", KCDNamespace, webhookServiceName)
+}
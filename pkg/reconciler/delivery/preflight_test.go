@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"strings"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	deliveryfake "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned/fake"
+)
+
+// healthyInstall builds fake clients describing a fully working install: every
+// access review is allowed, both API groups are served, and the webhook
+// Service has a ready endpoint; tests then break individual pieces
+func healthyInstall(denyVerb string) (*k8sfake.Clientset, *deliveryfake.Clientset) {
+	kc := k8sfake.NewSimpleClientset(&corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: KCDNamespace, Name: webhookServiceName},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	})
+	kc.PrependReactor("create", "selfsubjectaccessreviews", func(a ktesting.Action) (bool, runtime.Object, error) {
+		ssar := a.(ktesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		ssar.Status.Allowed = ssar.Spec.ResourceAttributes.Verb != denyVerb
+		return true, ssar, nil
+	})
+	dc := deliveryfake.NewSimpleClientset()
+	dc.Fake.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "delivery.knative.dev/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "policies"}, {Name: "policystates"}},
+	}, {
+		GroupVersion: "serving.knative.dev/v1",
+		APIResources: []metav1.APIResource{{Name: "routes"}, {Name: "revisions"}, {Name: "configurations"}},
+	}}
+	return kc, dc
+}
+
+func TestRunPreflight(t *testing.T) {
+	kc, dc := healthyInstall("")
+	if failures := runPreflight(kc, dc.Discovery(), true); len(failures) != 0 {
+		t.Errorf("healthy install should pass preflight, got %v", failures)
+	}
+
+	// a denied verb fails every resource the controller needs to watch
+	kc, dc = healthyInstall("watch")
+	failures := runPreflight(kc, dc.Discovery(), true)
+	if len(failures) != 5 {
+		t.Errorf("expected 5 permission failures, got %d: %v", len(failures), failures)
+	}
+	for _, f := range failures {
+		if !strings.Contains(f, "missing permission to watch") {
+			t.Errorf("unexpected failure message %q", f)
+		}
+	}
+
+	// missing CRDs are reported per group
+	kc, dc = healthyInstall("")
+	dc.Fake.Resources = dc.Fake.Resources[1:]
+	failures = runPreflight(kc, dc.Discovery(), true)
+	if len(failures) != 1 || !strings.Contains(failures[0], "delivery.knative.dev/v1alpha1") {
+		t.Errorf("expected one failure for the delivery API group, got %v", failures)
+	}
+
+	// a webhook without ready endpoints blocks startup
+	kc, dc = healthyInstall("")
+	kc.CoreV1().Endpoints(KCDNamespace).Delete(webhookServiceName, &metav1.DeleteOptions{})
+	failures = runPreflight(kc, dc.Discovery(), true)
+	if len(failures) != 1 || !strings.Contains(failures[0], webhookServiceName) {
+		t.Errorf("expected one failure for the webhook service, got %v", failures)
+	}
+
+	// in the reconciler-owned route mode the webhook need not be installed,
+	// so its absence must not hold up startup
+	failures = runPreflight(kc, dc.Discovery(), false)
+	if len(failures) != 0 {
+		t.Errorf("reconciler mode should pass preflight without a webhook service, got %v", failures)
+	}
+}
+
+func TestPreflightGate(t *testing.T) {
+	g := newPreflightGate()
+	if g.passed() {
+		t.Error("a fresh gate must not report passed")
+	}
+	g.pass()
+	g.pass() // idempotent
+	if !g.passed() {
+		t.Error("gate should report passed after pass()")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	"knative.dev/serving/pkg/apis/serving"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// gateOnReplicaParity holds the rollout before the candidate Revision's first
+// non-zero traffic percent until it runs at least ReplicaParityPercent of the
+// stable Revision's ready pods; without it, stage 1 would dump its whole traffic
+// share onto however few pods the candidate happens to have (often a single one)
+func (c *Reconciler) gateOnReplicaParity(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	if policy.ReplicaParityPercent == nil {
+		return false, nil
+	}
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		return false, nil
+	}
+	// the gate only protects the step from zero to the first non-zero percent;
+	// once the candidate serves traffic, autoscaling takes over
+	current := currentPercent(route, revName)
+	if current > 0 {
+		return false, nil
+	}
+	stable := oldestRevision(revisionMap)
+	if stable == nil || stable.Name == revName {
+		return false, nil
+	}
+	// only count pods once the stage timer wants the first traffic increase
+	pause := pauseOffset(ps)
+	if ps.Status.FreezeStartTime != nil {
+		pause += c.clock.Since(ps.Status.FreezeStartTime.Time)
+	}
+	if computeNewPercentExplicit(policy, jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pause))) <= current {
+		return false, nil
+	}
+	candidateReady, err := c.readyPodCount(cfg.Namespace, revName)
+	if err != nil {
+		return false, err
+	}
+	stableReady, err := c.readyPodCount(cfg.Namespace, stable.Name)
+	if err != nil {
+		return false, err
+	}
+	required := requiredReadyReplicas(stableReady, *policy.ReplicaParityPercent)
+	if candidateReady >= required {
+		return false, nil
+	}
+	if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+		recorder.Eventf(cfg, corev1.EventTypeWarning, "AwaitingReplicaParity",
+			"Revision %q has %d ready pods but needs %d (%d%% of stable Revision %q) before first traffic; pausing rollout",
+			revName, candidateReady, required, *policy.ReplicaParityPercent, stable.Name)
+	}
+	if deadlineExceeded(policy, ps, c.clock) {
+		return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+	}
+	return true, c.holdForReplicaParity(ctx, cfg, ps, candidateReady, required)
+}
+
+// holdForReplicaParity pauses the stage timer until the candidate scales up
+func (c *Reconciler) holdForReplicaParity(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, ready, required int) error {
+	if ps.Status.FreezeStartTime == nil {
+		ps.Status.FreezeStartTime = &metav1.Time{c.clock.Now()}
+		if _, err := c.psclient.DeliveryV1alpha1().PolicyStates(cfg.Namespace).Update(ps); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Infof("Candidate Revision has %d of %d required ready pods, holding the current traffic split", ready, required)
+	c.followup(cfg, ReplicaParityRecheck)
+	return nil
+}
+
+// readyPodCount counts the pods of the given Revision that report Ready
+func (c *Reconciler) readyPodCount(namespace, revName string) (int, error) {
+	selector := labels.SelectorFromSet(labels.Set{serving.RevisionLabelKey: revName})
+	pods, err := c.kubeclient.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, pod := range pods.Items {
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// requiredReadyReplicas computes how many ready pods the candidate must have to
+// reach the given percent of the stable Revision's ready pod count, rounding up
+// so that a non-zero requirement never truncates to zero
+func requiredReadyReplicas(stableReady, percent int) int {
+	return (stableReady*percent + 99) / 100
+}
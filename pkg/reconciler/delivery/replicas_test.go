@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+)
+
+func TestRequiredReadyReplicas(t *testing.T) {
+	tests := []struct {
+		name        string
+		stableReady int
+		percent     int
+		want        int
+	}{{
+		name:        "full parity",
+		stableReady: 10,
+		percent:     100,
+		want:        10,
+	}, {
+		name:        "half parity rounds up",
+		stableReady: 5,
+		percent:     50,
+		want:        3,
+	}, {
+		name:        "small fraction never truncates to zero",
+		stableReady: 3,
+		percent:     10,
+		want:        1,
+	}, {
+		name:        "scaled-to-zero stable requires nothing",
+		stableReady: 0,
+		percent:     100,
+		want:        0,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := requiredReadyReplicas(test.stableReady, test.percent); got != test.want {
+				t.Errorf("requiredReadyReplicas(%d, %d) = %d, want %d", test.stableReady, test.percent, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// BaselineName deterministically names the baseline Configuration spawned for a
+// rollout, so the reconciler can find it again on subsequent passes
+func BaselineName(cfg *v1.Configuration) string {
+	return kmeta.ChildName(cfg.Name, "-baseline")
+}
+
+// MakeBaseline creates a Configuration running a fresh copy of the stable
+// Revision's spec; its instances come up as cold as the candidate's, which is
+// what makes a candidate-versus-baseline comparison fair
+// The Configuration is owned by the rollout's Configuration so that it is
+// garbage collected with it, and the baseline label keeps the delivery
+// reconciler from treating it as a rollout of its own
+func MakeBaseline(cfg *v1.Configuration, stable *v1.Revision) *v1.Configuration {
+	return &v1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            BaselineName(cfg),
+			Namespace:       cfg.Namespace,
+			Labels:          map[string]string{delivery.BaselineForKey: cfg.Name},
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(cfg)},
+		},
+		Spec: v1.ConfigurationSpec{
+			Template: v1.RevisionTemplateSpec{
+				Spec: *stable.Spec.DeepCopy(),
+			},
+		},
+	}
+}
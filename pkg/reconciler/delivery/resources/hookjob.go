@@ -0,0 +1,44 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"fmt"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/kmeta"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// HookJobName deterministically names the Job for a stage hook so that the reconciler
+// can find the Job again on subsequent passes over the same stage
+func HookJobName(cfg *v1.Configuration, revName string, percent int, hook v1alpha1.StageHook) string {
+	return kmeta.ChildName(revName, fmt.Sprintf("-stage-%d-%s", percent, hook.Name))
+}
+
+// MakeHookJob creates the verification Job for a stage hook
+// the Job is owned by the Configuration so that it is garbage collected with it
+func MakeHookJob(cfg *v1.Configuration, revName string, percent int, hook v1alpha1.StageHook) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            HookJobName(cfg, revName, percent, hook),
+			Namespace:       cfg.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*kmeta.NewControllerRef(cfg)},
+		},
+		Spec: *hook.Template.DeepCopy(),
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// RolloutStatus is the JSON document written to the rollout-status annotation,
+// so that someone looking at the Configuration can see the rollout without
+// chasing the PolicyState
+type RolloutStatus struct {
+	// Stage is the current position in the Policy, e.g. "3/6"
+	Stage string `json:"stage,omitempty"`
+	// Percent is the candidate Revision's current traffic share
+	Percent int `json:"percent"`
+	// ETA is the RFC 3339 time of the next stage transition, when one is scheduled
+	ETA string `json:"eta,omitempty"`
+}
+
+// rolloutStatusValue renders the annotation value for the current rollout, or
+// "" when the Route is stable and the annotation should come off
+func rolloutStatusValue(cfg *v1.Configuration, ps *v1alpha1.PolicyState, policy *Policy, route *v1.Route) string {
+	if len(route.Spec.Traffic) == 0 || *route.Spec.Traffic[0].LatestRevision {
+		return ""
+	}
+	status := RolloutStatus{Percent: candidatePercent(route, cfg.Status.LatestReadyRevisionName)}
+	// the implicit final stage is 100%, hence the +1 on the stage count
+	i := sort.Search(len(policy.Stages), func(i int) bool {
+		return policy.Stages[i].Percent >= status.Percent
+	})
+	if i < len(policy.Stages) && policy.Stages[i].Percent == status.Percent {
+		status.Stage = fmt.Sprintf("%d/%d", i+1, len(policy.Stages)+1)
+	}
+	if ps.Status.NextUpdateTimestamp != nil {
+		status.ETA = ps.Status.NextUpdateTimestamp.Time.UTC().Format(time.RFC3339)
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// annotateRolloutStatus patches the rollout-status annotation onto the
+// Configuration whenever the rollout moves, and removes it once the Route is
+// stable; the write is skipped when the value is already current, so the
+// reconcile this update triggers converges instead of looping
+func (c *Reconciler) annotateRolloutStatus(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, policy *Policy, route *v1.Route) {
+	want := rolloutStatusValue(cfg, ps, policy, route)
+	if cfg.Annotations[delivery.RolloutStatusKey] == want {
+		return
+	}
+	updated := cfg.DeepCopy()
+	if want == "" {
+		delete(updated.Annotations, delivery.RolloutStatusKey)
+	} else {
+		if updated.Annotations == nil {
+			updated.Annotations = make(map[string]string)
+		}
+		updated.Annotations[delivery.RolloutStatusKey] = want
+	}
+	// surfacing the status is best-effort bookkeeping; it must not fail the rollout
+	if _, err := c.client.ServingV1().Configurations(cfg.Namespace).Update(updated); err != nil {
+		logging.FromContext(ctx).Warnf("Failed to update the rollout-status annotation on %s/%s: %v", cfg.Namespace, cfg.Name, err)
+	}
+}
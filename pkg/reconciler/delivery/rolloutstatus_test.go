@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+func TestRolloutStatusValue(t *testing.T) {
+	cfg := &v1.Configuration{
+		Status: v1.ConfigurationStatus{ConfigurationStatusFields: v1.ConfigurationStatusFields{
+			LatestReadyRevisionName: "R2",
+		}},
+	}
+	policy := &Policy{Stages: []Stage{{Percent: 0}, {Percent: 10}, {Percent: 50}}}
+	next := metav1.Time{Time: time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)}
+	inFlight := &v1alpha1.PolicyState{
+		Status: v1alpha1.PolicyStateStatus{PolicyStateStatusFields: v1alpha1.PolicyStateStatusFields{
+			NextUpdateTimestamp: &next,
+		}},
+	}
+	splitRoute := &v1.Route{Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+		traffictarget.Pinned("R1", 90),
+		traffictarget.Tagged(traffictarget.Pinned("R2", 10), CandidateTag),
+	}}}
+	stableRoute := &v1.Route{Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+		traffictarget.Latest("app"),
+	}}}
+
+	if got := rolloutStatusValue(cfg, inFlight, policy, stableRoute); got != "" {
+		t.Errorf("stable Route should clear the annotation, got %q", got)
+	}
+	want := `{"stage":"2/4","percent":10,"eta":"2020-07-01T12:00:00Z"}`
+	if got := rolloutStatusValue(cfg, inFlight, policy, splitRoute); got != want {
+		t.Errorf("annotation value = %q, want %q", got, want)
+	}
+	idle := &v1alpha1.PolicyState{}
+	wantNoETA := `{"stage":"2/4","percent":10}`
+	if got := rolloutStatusValue(cfg, idle, policy, splitRoute); got != wantNoETA {
+		t.Errorf("annotation value = %q, want %q", got, wantNoETA)
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"reflect"
+
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// routeProgrammed reports whether the ingress actually serves the split the
+// reconciler last asked for: the Route must not be explicitly unready, and its
+// observed traffic must match the PolicyState's recorded spec
+// A PolicyState that has not asked for anything yet trivially passes, and a
+// missing or Unknown Ready condition does not hold the rollout on its own;
+// the observed traffic is the concrete signal
+func routeProgrammed(route *v1.Route, ps *v1alpha1.PolicyState) bool {
+	if len(ps.Spec.Traffic) == 0 {
+		return true
+	}
+	if route.Status.GetCondition(v1.RouteConditionReady).IsFalse() {
+		return false
+	}
+	return reflect.DeepEqual(trafficShares(ps.Spec.Traffic), trafficShares(route.Status.Traffic))
+}
+
+// trafficShares reduces a traffic list to the share each Revision holds,
+// ignoring the tags and URLs that the Route status adds; latestRevision
+// targets collapse to one key, matching whatever Revision the status resolved
+// them to
+func trafficShares(targets []v1.TrafficTarget) map[string]int64 {
+	shares := map[string]int64{}
+	for _, t := range targets {
+		if t.Percent == nil || *t.Percent == 0 {
+			continue
+		}
+		key := t.RevisionName
+		if t.LatestRevision != nil && *t.LatestRevision {
+			key = "@latest"
+		}
+		shares[key] += *t.Percent
+	}
+	return shares
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	. "knative.dev/serving/pkg/testing/v1"
+
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+func TestRouteProgrammed(t *testing.T) {
+	desired := []v1.TrafficTarget{
+		traffictarget.Pinned("R1", 90),
+		traffictarget.Tagged(traffictarget.Pinned("R2", 10), CandidateTag),
+	}
+
+	tests := []struct {
+		name string
+		spec []v1.TrafficTarget
+		ro   []RouteOption
+		want bool
+	}{{
+		name: "a PolicyState that asked for nothing yet trivially passes",
+		ro:   []RouteOption{withTraffic(WithStatusTraffic, pair{"R1", 100})},
+		want: true,
+	}, {
+		name: "observed traffic matching the requested split passes",
+		spec: desired,
+		ro:   []RouteOption{withTraffic(WithStatusTraffic, pair{"R1", 90}, pair{"R2", 10})},
+		want: true,
+	}, {
+		name: "lagging observed traffic holds the rollout",
+		spec: desired,
+		ro:   []RouteOption{withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})},
+		want: false,
+	}, {
+		name: "an explicitly unready Route holds even when percentages agree",
+		spec: desired,
+		ro: []RouteOption{
+			withTraffic(WithStatusTraffic, pair{"R1", 90}, pair{"R2", 10}),
+			func(r *v1.Route) { r.Status.MarkMissingTrafficTarget("Revision", "R2") },
+		},
+		want: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := Route("default", "test", tt.ro...)
+			ps := PolicyState("default", "test")
+			ps.Spec.Traffic = tt.spec
+			if got := routeProgrammed(route, ps); got != tt.want {
+				t.Errorf("routeProgrammed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
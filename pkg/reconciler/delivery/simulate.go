@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"math"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// TimelineStep is one row of a simulated rollout: the candidate's traffic
+// share and the offset of counted rollout time at which it takes effect
+type TimelineStep struct {
+	OffsetSeconds int
+	Percent       int
+}
+
+// Simulate returns the sequence of traffic splits a rollout under the Policy
+// walks through, ending with the implicit 100% stage. It drives the same
+// computeNewPercentExplicit the reconciler uses, so the prediction cannot
+// drift from the real stage arithmetic. Offsets count rollout time; pauses,
+// windows and gates stretch the wall-clock moments but never the order
+func Simulate(p *v1alpha1.Policy) []TimelineStep {
+	policy := translatePolicy(p)
+	var steps []TimelineStep
+	elapsed := time.Duration(0)
+	for {
+		percent := computeNewPercentExplicit(policy, elapsed)
+		steps = append(steps, TimelineStep{OffsetSeconds: int(elapsed / time.Second), Percent: percent})
+		if percent == 100 {
+			return steps
+		}
+		wait := metricTillNextStage(policy, elapsed)
+		if wait == math.MaxInt32 {
+			return steps
+		}
+		// metricTillNextStage returns strictly more than the remaining time, and
+		// with whole-second thresholds that is the boundary plus one; the new
+		// stage applies exactly at the boundary
+		elapsed += time.Duration(wait-1) * time.Second
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+func TestSimulate(t *testing.T) {
+	thirty := 30
+	tests := []struct {
+		name string
+		spec v1alpha1.PolicySpec
+		want []TimelineStep
+	}{{
+		name: "no stages promote immediately",
+		spec: v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 60},
+		want: []TimelineStep{{OffsetSeconds: 0, Percent: 100}},
+	}, {
+		name: "each stage holds for its threshold",
+		spec: v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 60, Stages: []v1alpha1.Stage{
+			{Percent: 10, Threshold: &thirty},
+			{Percent: 50},
+		}},
+		want: []TimelineStep{
+			{OffsetSeconds: 0, Percent: 10},
+			{OffsetSeconds: 30, Percent: 50},
+			{OffsetSeconds: 90, Percent: 100},
+		},
+	}, {
+		name: "dark launch soaks at zero before any traffic",
+		spec: v1alpha1.PolicySpec{Mode: "time", DefaultThreshold: 60, Stages: []v1alpha1.Stage{
+			{Percent: 0, Threshold: &thirty},
+			{Percent: 50},
+		}},
+		want: []TimelineStep{
+			{OffsetSeconds: 0, Percent: 0},
+			{OffsetSeconds: 30, Percent: 50},
+			{OffsetSeconds: 90, Percent: 100},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &v1alpha1.Policy{Spec: test.spec}
+			got := Simulate(p)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("Simulate() is incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// recordRolloutSLIs keeps track of how quickly KCD itself delivers a Revision:
+// the time from the Revision becoming Ready to its first mainstream traffic, and
+// to owning 100% of traffic; both are persisted in the PolicyState status so the
+// rollout's owner can measure the latency introduced by the delivery pipeline
+func recordRolloutSLIs(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, route *v1.Route, revisionMap map[string]*v1.Revision, clock clock.Clock) {
+	rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]
+	if !ok {
+		return
+	}
+	// a newer Revision resets the measurements
+	if ps.Status.MeasuredRevisionName != rev.Name {
+		ps.Status.MeasuredRevisionName = rev.Name
+		ps.Status.FirstTrafficTime = nil
+		ps.Status.FullTrafficTime = nil
+		ps.Status.TimeToFirstTrafficSeconds = 0
+		ps.Status.TimeToFullTrafficSeconds = 0
+		ps.Status.StageSnapshots = nil
+		ps.Status.CompareStagePercent = 0
+		ps.Status.ComparePassStreak = 0
+		ps.Status.CompareFailures = 0
+		ps.Status.HookFailurePercent = 0
+		ps.Status.HookFailures = 0
+		ps.Status.CompletionReport = nil
+	}
+	percent := candidatePercent(route, rev.Name)
+	now := clock.Now()
+	recordStageSnapshot(ps, percent, now)
+	readyAt := revisionReadyTime(rev)
+	if percent > 0 && ps.Status.FirstTrafficTime == nil {
+		ps.Status.FirstTrafficTime = &metav1.Time{now}
+		ps.Status.TimeToFirstTrafficSeconds = int(now.Sub(readyAt).Seconds())
+		logging.FromContext(ctx).Infof("Revision %s received first traffic %ds after becoming ready", rev.Name, ps.Status.TimeToFirstTrafficSeconds)
+	}
+	if percent == 100 && ps.Status.FullTrafficTime == nil {
+		ps.Status.FullTrafficTime = &metav1.Time{now}
+		ps.Status.TimeToFullTrafficSeconds = int(now.Sub(readyAt).Seconds())
+		logging.FromContext(ctx).Infof("Revision %s reached 100%% of traffic %ds after becoming ready", rev.Name, ps.Status.TimeToFullTrafficSeconds)
+		writeCompletionReport(ps, rev, revisionMap, now)
+	}
+}
+
+// writeCompletionReport preserves a summary of the rollout that just finished;
+// the working state (timers, counters, snapshots) is cleared when the next
+// Revision arrives, so the report is what remains of the delivery afterwards
+func writeCompletionReport(ps *v1alpha1.PolicyState, rev *v1.Revision, revisionMap map[string]*v1.Revision, now time.Time) {
+	report := &v1alpha1.RolloutReport{
+		ToRevisionName:  rev.Name,
+		DurationSeconds: ps.Status.TimeToFullTrafficSeconds,
+		Stages:          len(ps.Status.StageSnapshots),
+		PausedSeconds:   ps.Status.PauseOffsetSeconds,
+		CompletedAt:     &metav1.Time{now},
+	}
+	if stable := oldestRevision(revisionMap); stable != nil && stable.Name != rev.Name {
+		report.FromRevisionName = stable.Name
+	}
+	for i := 1; i < len(ps.Status.StageSnapshots); i++ {
+		if ps.Status.StageSnapshots[i].Percent < ps.Status.StageSnapshots[i-1].Percent {
+			report.Rollbacks++
+		}
+	}
+	ps.Status.CompletionReport = report
+}
+
+// maxStageSnapshots bounds how many stage snapshots a PolicyState retains so
+// a long ramp cannot grow the object without limit
+const maxStageSnapshots = 20
+
+// recordStageSnapshot appends an audit entry whenever the candidate's traffic
+// share moves to a new stage; the metric fields stay zero until metric-based
+// modes start filling them in, but the percents and timestamps already let a
+// failed promotion or rollback be reconstructed from the PolicyState alone
+func recordStageSnapshot(ps *v1alpha1.PolicyState, percent int, now time.Time) {
+	snaps := ps.Status.StageSnapshots
+	if len(snaps) > 0 && snaps[len(snaps)-1].Percent == percent {
+		return
+	}
+	snaps = append(snaps, v1alpha1.StageSnapshot{
+		Percent:     percent,
+		EvaluatedAt: &metav1.Time{now},
+	})
+	if len(snaps) > maxStageSnapshots {
+		snaps = snaps[len(snaps)-maxStageSnapshots:]
+	}
+	ps.Status.StageSnapshots = snaps
+}
+
+// candidatePercent extracts the candidate Revision's share from the Route spec
+// a single latestRevision target means the candidate owns all of the traffic
+func candidatePercent(route *v1.Route, revName string) int {
+	for _, t := range route.Spec.Traffic {
+		if t.LatestRevision != nil && *t.LatestRevision {
+			return 100
+		}
+		if t.RevisionName == revName && t.Percent != nil {
+			return int(*t.Percent)
+		}
+	}
+	return 0
+}
+
+// revisionReadyTime determines when a Revision became Ready, falling back to its
+// creation timestamp when the Ready condition carries no transition time
+func revisionReadyTime(rev *v1.Revision) time.Time {
+	if cond := rev.Status.GetCondition(v1.RevisionConditionReady); cond != nil && !cond.LastTransitionTime.Inner.IsZero() {
+		return cond.LastTransitionTime.Inner.Time
+	}
+	return rev.CreationTimestamp.Time
+}
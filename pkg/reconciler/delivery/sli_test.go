@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestRecordStageSnapshot(t *testing.T) {
+	now := time.Now()
+	ps := PolicyState("default", "test")
+
+	recordStageSnapshot(ps, 0, now)
+	recordStageSnapshot(ps, 0, now.Add(time.Second))
+	recordStageSnapshot(ps, 10, now.Add(2*time.Second))
+	recordStageSnapshot(ps, 10, now.Add(3*time.Second))
+	recordStageSnapshot(ps, 50, now.Add(4*time.Second))
+
+	snaps := ps.Status.StageSnapshots
+	if len(snaps) != 3 {
+		t.Fatalf("expected 3 snapshots, got %d", len(snaps))
+	}
+	for i, wantPercent := range []int{0, 10, 50} {
+		if snaps[i].Percent != wantPercent {
+			t.Errorf("snapshot %d has Percent %d, want %d", i, snaps[i].Percent, wantPercent)
+		}
+		if snaps[i].EvaluatedAt == nil {
+			t.Errorf("snapshot %d is missing its EvaluatedAt timestamp", i)
+		}
+	}
+}
+
+func TestWriteCompletionReport(t *testing.T) {
+	now := time.Now()
+	ps := PolicyState("default", "test")
+	ps.Status.TimeToFullTrafficSeconds = 300
+	ps.Status.PauseOffsetSeconds = 45
+	for i, percent := range []int{0, 10, 50, 10, 50, 100} {
+		ps.Status.StageSnapshots = append(ps.Status.StageSnapshots,
+			v1alpha1.StageSnapshot{Percent: percent, EvaluatedAt: &metav1.Time{now.Add(time.Duration(i) * time.Minute)}})
+	}
+	candidate := &v1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "R2", CreationTimestamp: metav1.Time{now}}}
+	stable := &v1.Revision{ObjectMeta: metav1.ObjectMeta{Name: "R1", CreationTimestamp: metav1.Time{now.Add(-time.Hour)}}}
+
+	writeCompletionReport(ps, candidate, map[string]*v1.Revision{"R1": stable, "R2": candidate}, now)
+
+	report := ps.Status.CompletionReport
+	if report == nil {
+		t.Fatal("expected a completion report to be written")
+	}
+	if report.FromRevisionName != "R1" || report.ToRevisionName != "R2" {
+		t.Errorf("report names the wrong Revisions (from %q to %q)", report.FromRevisionName, report.ToRevisionName)
+	}
+	if report.DurationSeconds != 300 || report.PausedSeconds != 45 {
+		t.Errorf("report timings are wrong (duration %d, paused %d)", report.DurationSeconds, report.PausedSeconds)
+	}
+	if report.Stages != 6 {
+		t.Errorf("report counts %d stages, want 6", report.Stages)
+	}
+	// the 50 -> 10 step is the one rollback in the snapshot trail
+	if report.Rollbacks != 1 {
+		t.Errorf("report counts %d rollbacks, want 1", report.Rollbacks)
+	}
+	if report.CompletedAt == nil {
+		t.Errorf("report is missing its completion timestamp")
+	}
+}
+
+func TestRecordStageSnapshotCap(t *testing.T) {
+	now := time.Now()
+	ps := PolicyState("default", "test")
+
+	for i := 0; i <= maxStageSnapshots; i++ {
+		recordStageSnapshot(ps, i, now.Add(time.Duration(i)*time.Second))
+	}
+
+	snaps := ps.Status.StageSnapshots
+	if len(snaps) != maxStageSnapshots {
+		t.Fatalf("expected the snapshot list to be capped at %d entries, got %d", maxStageSnapshots, len(snaps))
+	}
+	// the oldest entry is dropped first
+	if snaps[0].Percent != 1 {
+		t.Errorf("oldest retained snapshot has Percent %d, want 1", snaps[0].Percent)
+	}
+	if snaps[len(snaps)-1].Percent != maxStageSnapshots {
+		t.Errorf("newest snapshot has Percent %d, want %d", snaps[len(snaps)-1].Percent, maxStageSnapshots)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"knative.dev/pkg/logging"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// gateOnSLO fails the rollout when the candidate is spending the Policy's error
+// budget faster than the allowed multiple of the steady rate; the failure goes
+// through failRollout so the Policy's OnFailure response (hold, rollback, abort)
+// decides what happens to the traffic
+// The burn rate is computed from the request and error counts recorded in the
+// stage snapshots; snapshots without data make the gate pass, so until
+// metric-based modes start filling the counts in, the gate is inert
+func (c *Reconciler) gateOnSLO(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) (bool, error) {
+	if policy.SLO == nil {
+		return false, nil
+	}
+	requests, errors := windowCounts(ps, policy.SLO.WindowSeconds, c.clock.Now())
+	if !burnExceeded(policy.SLO, requests, errors) {
+		return false, nil
+	}
+	logging.FromContext(ctx).Infof("Candidate burned the error budget too fast (%d errors in %d requests over %ds), failing the rollout",
+		errors, requests, policy.SLO.WindowSeconds)
+	return true, c.failRollout(ctx, cfg, route, ps, revisionMap, policy)
+}
+
+// windowCounts sums the request and error counts of the stage snapshots that
+// fall inside the SLO window
+func windowCounts(ps *v1alpha1.PolicyState, windowSeconds int, now time.Time) (requests, errors int64) {
+	cutoff := now.Add(-time.Duration(windowSeconds) * time.Second)
+	for _, s := range ps.Status.StageSnapshots {
+		if s.EvaluatedAt == nil || s.EvaluatedAt.Time.Before(cutoff) {
+			continue
+		}
+		requests += s.RequestCount
+		errors += s.ErrorCount
+	}
+	return
+}
+
+// burnExceeded reports whether the observed error fraction exceeds the allowed
+// fraction times the burn multiple; the comparison is cross-multiplied so the
+// whole computation stays in integers
+// No requests means no verdict: an idle candidate cannot be failing its SLO
+func burnExceeded(slo *v1alpha1.SLOConfig, requests, errors int64) bool {
+	if requests == 0 {
+		return false
+	}
+	maxBurn := int64(slo.MaxBurnRate)
+	if maxBurn <= 0 {
+		maxBurn = 1
+	}
+	allowedPermyriad := int64(10000 - slo.TargetAvailabilityPermyriad)
+	return errors*10000 > requests*allowedPermyriad*maxBurn
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestBurnExceeded(t *testing.T) {
+	// 99.90% availability leaves a 0.10% error budget
+	slo := &v1alpha1.SLOConfig{TargetAvailabilityPermyriad: 9990, WindowSeconds: 3600}
+
+	tests := []struct {
+		name     string
+		slo      *v1alpha1.SLOConfig
+		requests int64
+		errors   int64
+		want     bool
+	}{{
+		name: "no requests means no verdict",
+		slo:  slo,
+		want: false,
+	}, {
+		name:     "error rate inside the budget passes",
+		slo:      slo,
+		requests: 100000,
+		errors:   100, // exactly 0.10%
+		want:     false,
+	}, {
+		name:     "error rate beyond the budget breaches",
+		slo:      slo,
+		requests: 100000,
+		errors:   101,
+		want:     true,
+	}, {
+		name:     "a burn multiple widens the allowance",
+		slo:      &v1alpha1.SLOConfig{TargetAvailabilityPermyriad: 9990, WindowSeconds: 3600, MaxBurnRate: 2},
+		requests: 100000,
+		errors:   200, // twice the steady rate is still allowed
+		want:     false,
+	}, {
+		name:     "the burn multiple is still a ceiling",
+		slo:      &v1alpha1.SLOConfig{TargetAvailabilityPermyriad: 9990, WindowSeconds: 3600, MaxBurnRate: 2},
+		requests: 100000,
+		errors:   201,
+		want:     true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := burnExceeded(tt.slo, tt.requests, tt.errors); got != tt.want {
+				t.Errorf("burnExceeded(%d, %d) = %v, want %v", tt.requests, tt.errors, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowCounts(t *testing.T) {
+	now := time.Now()
+	ps := PolicyState("default", "test")
+	ps.Status.StageSnapshots = []v1alpha1.StageSnapshot{{
+		Percent:      10,
+		EvaluatedAt:  &metav1.Time{now.Add(-2 * time.Hour)},
+		RequestCount: 500,
+		ErrorCount:   50,
+	}, {
+		Percent:      20,
+		EvaluatedAt:  &metav1.Time{now.Add(-30 * time.Minute)},
+		RequestCount: 1000,
+		ErrorCount:   3,
+	}, {
+		Percent:      50,
+		EvaluatedAt:  &metav1.Time{now.Add(-5 * time.Minute)},
+		RequestCount: 2000,
+		ErrorCount:   1,
+	}}
+
+	// only the snapshots inside the last hour count
+	requests, errors := windowCounts(ps, 3600, now)
+	if requests != 3000 || errors != 4 {
+		t.Errorf("windowCounts() = (%d, %d), want (3000, 4)", requests, errors)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+var (
+	// stalledRolloutCount counts rollouts newly marked Stalled; on-call alerts
+	// on this instead of polling every PolicyState for the condition
+	stalledRolloutCount = stats.Int64(
+		"stalled_rollouts",
+		"Number of rollouts marked Stalled for going too long without a stage transition",
+		stats.UnitDimensionless)
+
+	// stalledNamespaceKey tags stalled rollouts with the workload's namespace
+	stalledNamespaceKey = tag.MustNewKey("namespace_name")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: stalledRolloutCount.Description(),
+		Measure:     stalledRolloutCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{stalledNamespaceKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// checkStalledRollout flags rollouts that have silently wedged: when the
+// candidate has gone without a stage transition for a configurable multiple of
+// its stage's threshold, the PolicyState gains a Progressing=False condition
+// with reason Stalled and the stalled_rollouts metric ticks, so on-call can
+// alert without knowing which gate or hold is responsible; the condition
+// clears by itself once the rollout moves again
+func (c *Reconciler) checkStalledRollout(ctx context.Context, cfg *v1.Configuration, ps *v1alpha1.PolicyState, route *v1.Route, revisionMap map[string]*v1.Revision, policy *Policy) {
+	multiplier := config.FromContextOrDefaults(ctx).Delivery.StalledThresholdMultiplier
+	if multiplier <= 0 {
+		return
+	}
+	rev, ok := revisionMap[cfg.Status.LatestReadyRevisionName]
+	if !ok {
+		return
+	}
+	current := currentPercent(route, rev.Name)
+	if current == 100 {
+		ps.Status.ClearStalled()
+		return
+	}
+	threshold, err := getThreshold(policy, current)
+	if err != nil || threshold <= 0 {
+		return
+	}
+	idle := c.clock.Since(lastStageTransition(ps, rev))
+	allowed := time.Duration(float64(threshold)*multiplier) * time.Second
+	if idle <= allowed {
+		ps.Status.ClearStalled()
+		return
+	}
+	// the metric counts the transition into the stalled state, not every
+	// reconcile that observes it, so its rate matches the number of incidents
+	if !ps.Status.IsStalled() {
+		logging.FromContext(ctx).Infof("Rollout for %s/%s has not advanced a stage for %v (threshold %ds), marking it Stalled",
+			cfg.Namespace, cfg.Name, idle, threshold)
+		if tctx, terr := tag.New(ctx, tag.Insert(stalledNamespaceKey, cfg.Namespace)); terr == nil {
+			metrics.Record(tctx, stalledRolloutCount.M(1))
+		}
+	}
+	ps.Status.MarkStalled(int(idle.Seconds()))
+}
+
+// lastStageTransition is the instant the candidate last entered a new stage:
+// the newest stage snapshot when there is one, otherwise the moment the
+// Revision became ready and the rollout began
+func lastStageTransition(ps *v1alpha1.PolicyState, rev *v1.Revision) time.Time {
+	if snaps := ps.Status.StageSnapshots; len(snaps) > 0 && snaps[len(snaps)-1].EvaluatedAt != nil {
+		return snaps[len(snaps)-1].EvaluatedAt.Time
+	}
+	return revisionReadyTime(rev)
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	v1alpha1 "github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestLastStageTransition(t *testing.T) {
+	created := time.Unix(1000, 0)
+	entered := time.Unix(5000, 0)
+	rev := &v1.Revision{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.Time{created}}}
+
+	ps := &v1alpha1.PolicyState{}
+	if got := lastStageTransition(ps, rev); !got.Equal(created) {
+		t.Errorf("without snapshots the rollout start counts (got %v, want %v)", got, created)
+	}
+
+	ps.Status.StageSnapshots = []v1alpha1.StageSnapshot{
+		{Percent: 0, EvaluatedAt: &metav1.Time{created}},
+		{Percent: 10, EvaluatedAt: &metav1.Time{entered}},
+	}
+	if got := lastStageTransition(ps, rev); !got.Equal(entered) {
+		t.Errorf("the newest snapshot wins (got %v, want %v)", got, entered)
+	}
+}
+
+func TestStalledMarker(t *testing.T) {
+	ps := &v1alpha1.PolicyState{}
+	if ps.Status.IsStalled() {
+		t.Errorf("a fresh PolicyState must not be stalled")
+	}
+	ps.Status.MarkStalled(7200)
+	if !ps.Status.IsStalled() {
+		t.Errorf("MarkStalled did not take")
+	}
+	ps.Status.ClearStalled()
+	if ps.Status.IsStalled() {
+		t.Errorf("ClearStalled did not take")
+	}
+	// other Progressing failures must survive ClearStalled
+	ps.Status.MarkProgressDeadlineExceeded(600)
+	ps.Status.ClearStalled()
+	if ps.Status.GetCondition(v1alpha1.PolicyStateConditionProgressing) == nil {
+		t.Errorf("ClearStalled removed a terminal Progressing failure")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+)
+
+// startAt parses the scheduled rollout start from the annotations, which the
+// Configuration propagates onto its Revisions; ok is false when no schedule is
+// set or the value does not parse (admission rejects malformed values, so a
+// broken one simply means no schedule)
+func startAt(annotations map[string]string) (time.Time, bool) {
+	raw, ok := annotations[delivery.StartAtKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// startAtWait returns how long the Revision still has to wait for its
+// scheduled start; zero when no schedule is set or the moment has passed
+func startAtWait(clock clock.Clock, revision *v1.Revision) time.Duration {
+	at, ok := startAt(revision.Annotations)
+	if !ok {
+		return 0
+	}
+	if wait := at.Sub(clock.Now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+)
+
+func TestStartAtWait(t *testing.T) {
+	now := time.Date(2020, 7, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFakeClock(now)
+
+	scheduled := func(at string) map[string]string {
+		return map[string]string{delivery.StartAtKey: at}
+	}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        time.Duration
+	}{{
+		name: "no schedule means no wait",
+	}, {
+		name:        "future start waits until the scheduled moment",
+		annotations: scheduled("2020-07-01T10:00:00Z"),
+		want:        time.Hour,
+	}, {
+		name:        "timezone offsets are honored",
+		annotations: scheduled("2020-07-01T03:00:00-07:00"),
+		want:        time.Hour,
+	}, {
+		name:        "a passed start means no wait",
+		annotations: scheduled("2020-07-01T08:00:00Z"),
+	}, {
+		name:        "an unparseable schedule means no schedule",
+		annotations: scheduled("tomorrow morning"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rev := Revision("default", "R1")
+			rev.Annotations = test.annotations
+			if got := startAtWait(fake, rev); got != test.want {
+				t.Errorf("startAtWait() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRolloutElapsedWithScheduledStart(t *testing.T) {
+	now := time.Date(2020, 7, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFakeClock(now)
+
+	// the Revision is two hours old, but its rollout was scheduled to start
+	// one hour ago, so only that hour counts
+	rev := Revision("default", "R1")
+	rev.CreationTimestamp = metav1.Time{Time: now.Add(-2 * time.Hour)}
+	rev.Annotations = map[string]string{delivery.StartAtKey: "2020-07-01T08:00:00Z"}
+	if got := rolloutElapsed(fake, rev, 0); got != time.Hour {
+		t.Errorf("rolloutElapsed() = %v, want %v", got, time.Hour)
+	}
+
+	// before the scheduled start no time has elapsed at all
+	rev.Annotations[delivery.StartAtKey] = "2020-07-01T10:00:00Z"
+	if got := rolloutElapsed(fake, rev, 0); got != 0 {
+		t.Errorf("rolloutElapsed() before the scheduled start = %v, want 0", got)
+	}
+}
@@ -16,6 +16,7 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -23,9 +24,12 @@ import (
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
 	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
 	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/clock"
 	clientgotesting "k8s.io/client-go/testing"
+	"knative.dev/pkg/apis/duck"
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
@@ -41,8 +45,15 @@ import (
 	. "knative.dev/serving/pkg/testing/v1"
 
 	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/trace"
 )
 
+// the default trace sampler is probabilistic; pin it off so the expected
+// PolicyState updates below never pick up a trace context annotation
+func init() {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
+}
+
 func TestReconcile(t *testing.T) {
 	now := time.Now()
 	table := TableTest{{
@@ -69,6 +80,33 @@ func TestReconcile(t *testing.T) {
 		PostConditions: []func(*testing.T, *TableRow){
 			assertEventQueued("default/test2", 5*time.Second),
 		},
+	}, {
+		Name: "opt-out annotation deletes the PolicyState",
+		Key:  "default/optout",
+		Objects: []runtime.Object{
+			Route("default", "optout", WithConfigTarget("optout"), WithRouteGeneration(1)),
+			Configuration("default", "optout", WithLatestCreated("R1"), WithLatestReady("R1"),
+				withPolicy("optout"), withDisabled()),
+			PolicyState("default", "optout"),
+		},
+		WantDeletes: []clientgotesting.DeleteActionImpl{{
+			ActionImpl: clientgotesting.ActionImpl{Namespace: "default"},
+			Name:       "optout",
+		}},
+		PostConditions: []func(*testing.T, *TableRow){
+			assertNoEventQueued("default/optout"),
+		},
+	}, {
+		Name: "opt-out without a PolicyState is a no-op",
+		Key:  "default/optout2",
+		Objects: []runtime.Object{
+			Route("default", "optout2", WithConfigTarget("optout2"), WithRouteGeneration(1)),
+			Configuration("default", "optout2", WithLatestCreated("R1"), WithLatestReady("R1"),
+				withPolicy("optout2"), withDisabled()),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			assertNoEventQueued("default/optout2"),
+		},
 	}, {
 		Name: "degenerate to simple logic with only 2 Revisions",
 		Key:  "default/test3",
@@ -81,14 +119,35 @@ func TestReconcile(t *testing.T) {
 				WithRevisionLabel(serving.ConfigurationLabelKey, "test3")),
 			PolicyState("default", "test3"),
 			MakePolicy("default", "test3", WithMode("time"), WithDefaultThreshold(60),
-				WithStages(v1alpha1.Stage{0, nil}, v1alpha1.Stage{1, nil}, v1alpha1.Stage{10, nil}, v1alpha1.Stage{20, nil}, v1alpha1.Stage{90, nil})),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
 		},
 		WantUpdates: []clientgotesting.UpdateActionImpl{{
-			Object: Route("default", "test3", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
-				withTraffic(WithSpecTraffic, pair{"R1", 90}, pair{"R2", 10})),
+			Object: Configuration("default", "test3", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test3"),
+				withRolloutStatus("3/6", 10, now.Add(59*time.Second))),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			Name: "test3",
+			Patch: mergePatch(
+				Route("default", "test3", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+				Route("default", "test3", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
+					withTraffic(WithSpecTraffic, pair{"R1", 90}, pair{"R2", 10}), withSpecCandidateTag("R2"))),
 		}, {
-			Object: PolicyState("default", "test3", withPSTraffic(WithPSSpecTraffic, pair{"R1", 90}, pair{"R2", 10}),
-				WithNextUpdateTimestamp(now.Add(59*time.Second))),
+			Name: "test3",
+			Patch: mergePatch(
+				PolicyState("default", "test3"),
+				PolicyState("default", "test3", withPSTraffic(WithPSSpecTraffic, pair{"R1", 90}, pair{"R2", 10}),
+					withPSCandidateTag("R2"), withPSStatusMirror(),
+					WithNextUpdateTimestamp(now.Add(59*time.Second)),
+					WithPhase(v1alpha1.PhaseProgressing),
+					WithPolicySource(PolicySourceAnnotation, "default/test3"),
+					WithAssignmentSeed(assignmentSeed("", "default", "R2")),
+					WithMeasuredRevision("R2"), WithFirstTraffic(now, 61),
+					WithStageSnapshot(10, now))),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test3", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test3")),
 		}},
 		PostConditions: []func(*testing.T, *TableRow){
 			assertEventQueued("default/test3", 59*time.Second),
@@ -99,8 +158,10 @@ func TestReconcile(t *testing.T) {
 		Objects: []runtime.Object{
 			Route("default", "test4", withTraffic(WithStatusTraffic, pair{"R1", 58}, pair{"R2", 10}, pair{"R3", 10}, pair{"R4", 10}, pair{"R5", 10}, pair{"R6", 1}, pair{"R7", 1})),
 			Configuration("default", "test4", WithLatestCreated("R7"), WithLatestReady("R7"), withPolicy("test4")),
+			// R1 was already swept on an earlier pass, so this reconcile's sweep
+			// has nothing left to write for it
 			Revision("default", "R1", WithCreationTimestamp(now.Add(-125*time.Second)),
-				WithRevisionLabel(serving.ConfigurationLabelKey, "test4")),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test4"), withSafeToDelete()),
 			Revision("default", "R2", WithCreationTimestamp(now.Add(-121500*time.Millisecond)),
 				WithRevisionLabel(serving.ConfigurationLabelKey, "test4")),
 			Revision("default", "R3", WithCreationTimestamp(now.Add(-121500*time.Millisecond)),
@@ -115,35 +176,244 @@ func TestReconcile(t *testing.T) {
 				WithRevisionLabel(serving.ConfigurationLabelKey, "test4")),
 			PolicyState("default", "test4"),
 			MakePolicy("default", "test4", WithMode("time"), WithDefaultThreshold(60),
-				WithStages(v1alpha1.Stage{0, nil}, v1alpha1.Stage{1, nil}, v1alpha1.Stage{10, nil}, v1alpha1.Stage{20, nil}, v1alpha1.Stage{90, nil})),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
 		},
 		WantUpdates: []clientgotesting.UpdateActionImpl{{
-			Object: Route("default", "test4", withTraffic(WithStatusTraffic, pair{"R1", 58}, pair{"R2", 10}, pair{"R3", 10}, pair{"R4", 10}, pair{"R5", 10}, pair{"R6", 1}, pair{"R7", 1}),
-				withTraffic(WithSpecTraffic, pair{"R2", 20}, pair{"R3", 20}, pair{"R4", 20}, pair{"R5", 20}, pair{"R6", 10}, pair{"R7", 10})),
+			Object: Configuration("default", "test4", WithLatestCreated("R7"), WithLatestReady("R7"), withPolicy("test4"),
+				withRolloutStatus("3/6", 10, now.Add(58*time.Second))),
+		}},
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			Name: "test4",
+			Patch: mergePatch(
+				Route("default", "test4", withTraffic(WithStatusTraffic, pair{"R1", 58}, pair{"R2", 10}, pair{"R3", 10}, pair{"R4", 10}, pair{"R5", 10}, pair{"R6", 1}, pair{"R7", 1})),
+				Route("default", "test4", withTraffic(WithStatusTraffic, pair{"R1", 58}, pair{"R2", 10}, pair{"R3", 10}, pair{"R4", 10}, pair{"R5", 10}, pair{"R6", 1}, pair{"R7", 1}),
+					withTraffic(WithSpecTraffic, pair{"R2", 20}, pair{"R3", 20}, pair{"R4", 20}, pair{"R5", 20}, pair{"R6", 10}, pair{"R7", 10}), withSpecCandidateTag("R7"))),
 		}, {
-			Object: PolicyState("default", "test4",
-				withPSTraffic(WithPSSpecTraffic, pair{"R2", 20}, pair{"R3", 20}, pair{"R4", 20}, pair{"R5", 20}, pair{"R6", 10}, pair{"R7", 10}),
-				WithNextUpdateTimestamp(now.Add(58*time.Second))),
+			Name: "test4",
+			Patch: mergePatch(
+				PolicyState("default", "test4"),
+				PolicyState("default", "test4",
+					withPSTraffic(WithPSSpecTraffic, pair{"R2", 20}, pair{"R3", 20}, pair{"R4", 20}, pair{"R5", 20}, pair{"R6", 10}, pair{"R7", 10}),
+					withPSCandidateTag("R7"), withPSStatusMirror(),
+					WithNextUpdateTimestamp(now.Add(58*time.Second)),
+					WithPhase(v1alpha1.PhaseProgressing),
+					WithPolicySource(PolicySourceAnnotation, "default/test4"),
+					WithAssignmentSeed(assignmentSeed("", "default", "R7")),
+					WithMeasuredRevision("R7"), WithFirstTraffic(now, 61),
+					WithStageSnapshot(10, now))),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test4", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test4")),
 		}},
 		PostConditions: []func(*testing.T, *TableRow){
 			assertEventQueued("default/test4", 58*time.Second),
 		},
+	}, {
+		Name: "surfaces a PolicyState update failure without touching the Route",
+		Key:  "default/test5",
+		Objects: []runtime.Object{
+			Route("default", "test5", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+			Configuration("default", "test5", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test5")),
+			Revision("default", "R1", WithCreationTimestamp(now.Add(-125*time.Second)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test5")),
+			Revision("default", "R2", WithCreationTimestamp(now.Add(-61100*time.Millisecond)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test5")),
+			PolicyState("default", "test5"),
+			MakePolicy("default", "test5", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("patch", "policystates"),
+		},
+		WantErr: true,
+		// the PolicyState patch is attempted first and fails, so the Route must not be touched
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			Name: "test5",
+			Patch: mergePatch(
+				PolicyState("default", "test5"),
+				PolicyState("default", "test5", withPSTraffic(WithPSSpecTraffic, pair{"R1", 90}, pair{"R2", 10}),
+					withPSCandidateTag("R2"), withPSStatusMirror(),
+					WithNextUpdateTimestamp(now.Add(59*time.Second)),
+					WithPhase(v1alpha1.PhaseProgressing),
+					WithPolicySource(PolicySourceAnnotation, "default/test5"),
+					WithAssignmentSeed(assignmentSeed("", "default", "R2")),
+					WithMeasuredRevision("R2"), WithFirstTraffic(now, 61),
+					WithStageSnapshot(10, now))),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test5", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test5")),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for %s %s", "patch", "policystates"),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			// the retry rides on the workqueue's error backoff, so the stage
+			// timer event must only have been enqueued once
+			assertEventQueued("default/test5", 59*time.Second),
+			assertEnqueueCount("default/test5", 1),
+		},
+	}, {
+		Name: "surfaces a Route update failure after the PolicyState is written",
+		Key:  "default/test6",
+		Objects: []runtime.Object{
+			Route("default", "test6", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+			Configuration("default", "test6", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test6")),
+			Revision("default", "R1", WithCreationTimestamp(now.Add(-125*time.Second)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test6")),
+			Revision("default", "R2", WithCreationTimestamp(now.Add(-61100*time.Millisecond)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test6")),
+			PolicyState("default", "test6"),
+			MakePolicy("default", "test6", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("patch", "routes"),
+		},
+		WantErr: true,
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			Name: "test6",
+			Patch: mergePatch(
+				Route("default", "test6", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+				Route("default", "test6", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
+					withTraffic(WithSpecTraffic, pair{"R1", 90}, pair{"R2", 10}), withSpecCandidateTag("R2"))),
+		}, {
+			Name: "test6",
+			Patch: mergePatch(
+				PolicyState("default", "test6"),
+				PolicyState("default", "test6", withPSTraffic(WithPSSpecTraffic, pair{"R1", 90}, pair{"R2", 10}),
+					withPSCandidateTag("R2"), withPSStatusMirror(),
+					WithNextUpdateTimestamp(now.Add(59*time.Second)),
+					WithPhase(v1alpha1.PhaseProgressing),
+					WithPolicySource(PolicySourceAnnotation, "default/test6"),
+					WithAssignmentSeed(assignmentSeed("", "default", "R2")),
+					WithMeasuredRevision("R2"), WithFirstTraffic(now, 61),
+					WithStageSnapshot(10, now))),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test6", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test6")),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "RouteUpdateFailed",
+				"Failed to write the computed traffic split to Route %q after %d attempts: %v",
+				"test6", 1, "inducing failure for patch routes"),
+			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for %s %s", "patch", "routes"),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			assertEventQueued("default/test6", 59*time.Second),
+			assertEnqueueCount("default/test6", 1),
+		},
+	}, {
+		Name: "retries the Route patch in place when it hits a conflict",
+		Key:  "default/test8",
+		Objects: []runtime.Object{
+			Route("default", "test8", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+			Configuration("default", "test8", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test8")),
+			Revision("default", "R1", WithCreationTimestamp(now.Add(-125*time.Second)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test8")),
+			Revision("default", "R2", WithCreationTimestamp(now.Add(-61100*time.Millisecond)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test8")),
+			PolicyState("default", "test8"),
+			MakePolicy("default", "test8", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			failOnce("patch", "routes", apierrs.NewConflict(v1.Resource("routes"), "test8", errors.New("concurrent write"))),
+		},
+		WantUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: Configuration("default", "test8", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test8"),
+				withRolloutStatus("3/6", 10, now.Add(59*time.Second))),
+		}},
+		// the first patch hits the conflict; the retry re-reads the Route and
+		// re-applies the same computed traffic, so the patches are identical
+		WantPatches: []clientgotesting.PatchActionImpl{{
+			Name: "test8",
+			Patch: mergePatch(
+				Route("default", "test8", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+				Route("default", "test8", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
+					withTraffic(WithSpecTraffic, pair{"R1", 90}, pair{"R2", 10}), withSpecCandidateTag("R2"))),
+		}, {
+			Name: "test8",
+			Patch: mergePatch(
+				Route("default", "test8", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+				Route("default", "test8", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1}),
+					withTraffic(WithSpecTraffic, pair{"R1", 90}, pair{"R2", 10}), withSpecCandidateTag("R2"))),
+		}, {
+			Name: "test8",
+			Patch: mergePatch(
+				PolicyState("default", "test8"),
+				PolicyState("default", "test8", withPSTraffic(WithPSSpecTraffic, pair{"R1", 90}, pair{"R2", 10}),
+					withPSCandidateTag("R2"), withPSStatusMirror(),
+					WithNextUpdateTimestamp(now.Add(59*time.Second)),
+					WithPhase(v1alpha1.PhaseProgressing),
+					WithPolicySource(PolicySourceAnnotation, "default/test8"),
+					WithAssignmentSeed(assignmentSeed("", "default", "R2")),
+					WithMeasuredRevision("R2"), WithFirstTraffic(now, 61),
+					WithStageSnapshot(10, now))),
+		}},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test8", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test8")),
+		}},
+		PostConditions: []func(*testing.T, *TableRow){
+			assertEventQueued("default/test8", 59*time.Second),
+		},
+	}, {
+		Name: "surfaces a PolicyState create failure before any traffic math",
+		Key:  "default/test7",
+		Objects: []runtime.Object{
+			Route("default", "test7", withTraffic(WithStatusTraffic, pair{"R1", 99}, pair{"R2", 1})),
+			Configuration("default", "test7", WithLatestCreated("R2"), WithLatestReady("R2"), withPolicy("test7")),
+			Revision("default", "R1", WithCreationTimestamp(now.Add(-125*time.Second)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test7")),
+			Revision("default", "R2", WithCreationTimestamp(now.Add(-61100*time.Millisecond)),
+				WithRevisionLabel(serving.ConfigurationLabelKey, "test7")),
+			MakePolicy("default", "test7", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90})),
+		},
+		WithReactors: []clientgotesting.ReactionFunc{
+			InduceFailure("create", "policystates"),
+		},
+		WantErr: true,
+		WantCreates: []runtime.Object{
+			PolicyState("default", "test7"),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: MakePolicy("default", "test7", WithMode("time"), WithDefaultThreshold(60),
+				WithStages(v1alpha1.Stage{Percent: 0}, v1alpha1.Stage{Percent: 1}, v1alpha1.Stage{Percent: 10}, v1alpha1.Stage{Percent: 20}, v1alpha1.Stage{Percent: 90}),
+				WithBoundConfigurations("default/test7")),
+		}},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "InternalError", "inducing failure for %s %s", "create", "policystates"),
+		},
+		PostConditions: []func(*testing.T, *TableRow){
+			assertNoEventQueued("default/test7"),
+		},
 	}}
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, cmw configmap.Watcher, tr *TableRow) controller.Reconciler {
 		tr.OtherTestData = make(map[string]interface{})
 		r := &Reconciler{
-			client:            servingclient.Get(ctx),
-			psclient:          deliveryclient.Get(ctx),
-			routeLister:       listers.GetRouteLister(),
-			revisionLister:    listers.GetRevisionLister(),
-			policyLister:      listers.GetPolicyLister(),
-			policystateLister: listers.GetPolicyStateLister(),
-			clock:             clock.NewFakeClock(now),
+			client:              servingclient.Get(ctx),
+			psclient:            deliveryclient.Get(ctx),
+			routeLister:         listers.GetRouteLister(),
+			revisionLister:      listers.GetRevisionLister(),
+			configurationLister: listers.GetConfigurationLister(),
+			policyLister:        listers.GetPolicyLister(),
+			policystateLister:   listers.GetPolicyStateLister(),
+			clock:               clock.NewFakeClock(now),
 			// note that we manually, systematically assigned unique namespace/name strings to each test Configuration
 			// we use those strings for each test
 			followup: func(cfg *v1.Configuration, t time.Duration) {
 				key := cfg.GetNamespace() + "/" + cfg.GetName()
 				tr.OtherTestData[key] = fmt.Sprintf("%v", t)
+				count, _ := tr.OtherTestData[key+"/enqueues"].(int)
+				tr.OtherTestData[key+"/enqueues"] = count + 1
 			},
 		}
 		return configurationreconciler.NewReconciler(ctx, logging.FromContext(ctx), servingclient.Get(ctx),
@@ -172,6 +442,47 @@ func withPSTraffic(pf psoTrafficFunc, nameValuePairs ...pair) PolicyStateOption
 	return pf(tt...)
 }
 
+// withPSStatusMirror copies the spec traffic into the status, the way the
+// reconciler mirrors the enforced split; it must come after the spec options
+func withPSStatusMirror() PolicyStateOption {
+	return func(ps *v1alpha1.PolicyState) {
+		ps.Status.Traffic = ps.Spec.Traffic
+	}
+}
+
+// withSpecCandidateTag marks the named Revision's spec traffic target as the candidate
+func withSpecCandidateTag(revName string) RouteOption {
+	return func(r *v1.Route) {
+		for i := range r.Spec.Traffic {
+			if r.Spec.Traffic[i].RevisionName == revName {
+				r.Spec.Traffic[i].Tag = CandidateTag
+			}
+		}
+	}
+}
+
+// withPSCandidateTag is the PolicyState counterpart of withSpecCandidateTag
+func withPSCandidateTag(revName string) PolicyStateOption {
+	return func(ps *v1alpha1.PolicyState) {
+		for i := range ps.Spec.Traffic {
+			if ps.Spec.Traffic[i].RevisionName == revName {
+				ps.Spec.Traffic[i].Tag = CandidateTag
+			}
+		}
+	}
+}
+
+// withSafeToDelete marks a Revision with the advisory deletion suggestion the
+// reconciler writes once the Revision drops out of the traffic split
+func withSafeToDelete() RevisionOption {
+	return func(rev *v1.Revision) {
+		if rev.Annotations == nil {
+			rev.Annotations = make(map[string]string)
+		}
+		rev.Annotations[delivery.SafeToDeleteKey] = "true"
+	}
+}
+
 // withPolicy annotates the Configuration with policyName
 // the library doesn't have a WithAnnotation helper for v1.Configuration (!!!)
 func withPolicy(policyName string) ConfigOption {
@@ -183,6 +494,52 @@ func withPolicy(policyName string) ConfigOption {
 	}
 }
 
+// withRolloutStatus sets the rollout-status annotation the reconciler writes
+// while a rollout is in flight
+func withRolloutStatus(stage string, percent int, eta time.Time) ConfigOption {
+	return func(cfg *v1.Configuration) {
+		if cfg.Annotations == nil {
+			cfg.Annotations = make(map[string]string)
+		}
+		cfg.Annotations[delivery.RolloutStatusKey] = fmt.Sprintf(`{"stage":%q,"percent":%d,"eta":%q}`,
+			stage, percent, eta.UTC().Format(time.RFC3339))
+	}
+}
+
+// withDisabled opts the Configuration out of continuous delivery
+func withDisabled() ConfigOption {
+	return func(cfg *v1.Configuration) {
+		if cfg.Annotations == nil {
+			cfg.Annotations = make(map[string]string)
+		}
+		cfg.Annotations[delivery.EnabledKey] = "false"
+	}
+}
+
+// mergePatch computes the JSON merge patch the reconciler would send for the
+// given before/after pair; building expectations the same way the code under
+// test does keeps them independent of field ordering
+func mergePatch(before, after interface{}) []byte {
+	patch, err := duck.CreateMergePatch(before, after)
+	if err != nil {
+		panic(err)
+	}
+	return patch
+}
+
+// failOnce returns a reactor that fails the first matching action with err and
+// lets every subsequent one through to the default reactors
+func failOnce(verb, resource string, err error) clientgotesting.ReactionFunc {
+	failed := false
+	return func(action clientgotesting.Action) (bool, runtime.Object, error) {
+		if failed || !action.Matches(verb, resource) {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, err
+	}
+}
+
 func makeTrafficTargetList(nameValuePairs ...pair) []v1.TrafficTarget {
 	tt := make([]v1.TrafficTarget, len(nameValuePairs))
 	for i, pair := range nameValuePairs {
@@ -213,6 +570,17 @@ func assertEventQueued(key string, want time.Duration) func(*testing.T, *TableRo
 	}
 }
 
+// assertEnqueueCount checks how many times the reconciler enqueued a follow-up
+// event for key; failure paths use it to guard against double-enqueueing
+func assertEnqueueCount(key string, want int) func(*testing.T, *TableRow) {
+	return func(t *testing.T, r *TableRow) {
+		got, _ := r.OtherTestData[key+"/enqueues"].(int)
+		if got != want {
+			t.Errorf("wrong number of enqueued events (got %d, want %d)", got, want)
+		}
+	}
+}
+
 // assertNoEventQueued is used for tests where events should NOT be enqueued
 func assertNoEventQueued(key string) func(*testing.T, *TableRow) {
 	return func(t *testing.T, r *TableRow) {
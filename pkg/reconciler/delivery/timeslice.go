@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"time"
+
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// the possible values of PolicyState.Status.SlicePhase
+const (
+	// SlicePhaseOn means the candidate is serving its stage percent
+	SlicePhaseOn = "on"
+	// SlicePhaseOff means the candidate is parked at 0% for a control slice
+	SlicePhaseOff = "off"
+)
+
+// slicePhase locates the stage the rollout is in (the same cumulative walk as
+// computeNewPercentExplicit) and, when that stage is time-sliced, reports
+// whether the rollout is in an off slice and how long until the next flip
+// slices alternate on a fixed cadence starting with an on slice, so the phase
+// is derivable from elapsed time alone and survives controller restarts
+func slicePhase(p *Policy, elapsed time.Duration) (off bool, untilFlip time.Duration, sliced bool) {
+	if len(p.Stages) == 0 {
+		return false, 0, false
+	}
+	metric := int(elapsed / time.Second)
+	metricCumulative := 0
+	// the dark-launch soak period holds at 0% and is never sliced
+	if p.Stages[0].Percent == 0 && p.Stages[0].Threshold != nil {
+		metricCumulative += *p.Stages[0].Threshold
+		if metricCumulative > metric {
+			return false, 0, false
+		}
+	}
+	for _, s := range p.Stages[1:] {
+		extra := p.DefaultThreshold
+		if s.Threshold != nil {
+			extra = *s.Threshold
+		}
+		start := metricCumulative
+		metricCumulative += extra
+		if metricCumulative > metric {
+			if s.SlicePeriodSeconds == nil || *s.SlicePeriodSeconds <= 0 {
+				return false, 0, false
+			}
+			period := *s.SlicePeriodSeconds
+			inStage := metric - start
+			return (inStage/period)%2 == 1, time.Duration(period-inStage%period) * time.Second, true
+		}
+	}
+	// the implicit final stage has the full traffic and nothing to slice
+	return false, 0, false
+}
+
+// applyTimeSlice applies the current slice phase to the just-computed Route
+// spec: during an off slice the candidate's share is handed back to the first
+// other target, and the returned duration schedules a wake-up at the flip
+// the phase is recorded on the PolicyState, so observers can tell a control
+// slice apart from a rollout that has not started
+func (c *Reconciler) applyTimeSlice(ctx context.Context, cfg *v1.Configuration, route *v1.Route, ps *v1alpha1.PolicyState, revisionMap map[string]*v1.Revision, policy *Policy) time.Duration {
+	revName := cfg.Status.LatestReadyRevisionName
+	rev, ok := revisionMap[revName]
+	if !ok {
+		ps.Status.SlicePhase = ""
+		return 0
+	}
+	off, untilFlip, sliced := slicePhase(policy, jitteredElapsed(policy, rev, rolloutElapsed(c.clock, rev, pauseOffset(ps))))
+	if !sliced {
+		ps.Status.SlicePhase = ""
+		return 0
+	}
+	if !off {
+		ps.Status.SlicePhase = SlicePhaseOn
+		return untilFlip
+	}
+	ps.Status.SlicePhase = SlicePhaseOff
+	logging.FromContext(ctx).Infof("Time-sliced stage is in a control slice, parking the candidate at 0%% for %v", untilFlip)
+	parkCandidate(route, revName)
+	return untilFlip
+}
+
+// parkCandidate zeroes the candidate's traffic share and hands it to the first
+// other target, preserving a split that still sums to 100
+func parkCandidate(route *v1.Route, revName string) {
+	candidateIdx, absorberIdx := -1, -1
+	for i, t := range route.Spec.Traffic {
+		if t.Percent == nil {
+			continue
+		}
+		if t.RevisionName == revName {
+			candidateIdx = i
+		} else if absorberIdx < 0 {
+			absorberIdx = i
+		}
+	}
+	if candidateIdx < 0 || absorberIdx < 0 || *route.Spec.Traffic[candidateIdx].Percent == 0 {
+		return
+	}
+	share := *route.Spec.Traffic[candidateIdx].Percent
+	route.Spec.Traffic[candidateIdx].Percent = ptr.Int64(0)
+	absorber := &route.Spec.Traffic[absorberIdx]
+	absorber.Percent = ptr.Int64(*absorber.Percent + share)
+}
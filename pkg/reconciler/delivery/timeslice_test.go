@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func TestSlicePhase(t *testing.T) {
+	sliced := &Policy{
+		DefaultThreshold: 60,
+		Stages: []Stage{
+			{Percent: 0},
+			{Percent: 20, Threshold: intptr(600), SlicePeriodSeconds: intptr(60)},
+			{Percent: 50},
+		},
+	}
+	plain := &Policy{DefaultThreshold: 60, Stages: []Stage{{Percent: 0}, {Percent: 20}}}
+
+	tests := []struct {
+		name      string
+		policy    *Policy
+		elapsed   time.Duration
+		wantOff   bool
+		wantFlip  time.Duration
+		wantSlice bool
+	}{{
+		name:      "first slice is on",
+		policy:    sliced,
+		elapsed:   30 * time.Second,
+		wantOff:   false,
+		wantFlip:  30 * time.Second,
+		wantSlice: true,
+	}, {
+		name:      "second slice is off",
+		policy:    sliced,
+		elapsed:   90 * time.Second,
+		wantOff:   true,
+		wantFlip:  30 * time.Second,
+		wantSlice: true,
+	}, {
+		name:      "third slice is on again",
+		policy:    sliced,
+		elapsed:   130 * time.Second,
+		wantOff:   false,
+		wantFlip:  50 * time.Second,
+		wantSlice: true,
+	}, {
+		name:    "later stage without slicing",
+		policy:  sliced,
+		elapsed: 620 * time.Second,
+	}, {
+		name:    "policy without slicing",
+		policy:  plain,
+		elapsed: 30 * time.Second,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			off, untilFlip, sliced := slicePhase(test.policy, test.elapsed)
+			if off != test.wantOff || untilFlip != test.wantFlip || sliced != test.wantSlice {
+				t.Errorf("slicePhase = (%v, %v, %v), want (%v, %v, %v)",
+					off, untilFlip, sliced, test.wantOff, test.wantFlip, test.wantSlice)
+			}
+		})
+	}
+}
+
+func TestParkCandidate(t *testing.T) {
+	route := &v1.Route{Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{
+		traffictarget.Pinned("R1", 80),
+		traffictarget.Tagged(traffictarget.Pinned("R2", 20), CandidateTag),
+	}}}
+	parkCandidate(route, "R2")
+	want := []v1.TrafficTarget{
+		traffictarget.Pinned("R1", 100),
+		traffictarget.Tagged(traffictarget.Pinned("R2", 0), CandidateTag),
+	}
+	if diff := cmp.Diff(want, route.Spec.Traffic); diff != "" {
+		t.Errorf("wrong traffic after parking (-want, +got): %s", diff)
+	}
+	// parking an already-parked candidate changes nothing
+	parkCandidate(route, "R2")
+	if diff := cmp.Diff(want, route.Spec.Traffic); diff != "" {
+		t.Errorf("parking is not idempotent (-want, +got): %s", diff)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// applyMaxUnavailable caps the combined traffic share of Revisions that are not
+// fully Ready at the Policy's MaxUnavailablePercent before the Route is written
+// if the candidate degrades mid-transition, the excess is clamped off the
+// unready targets (newest first) and handed back to the first fully Ready one,
+// so a single Route update can never put more than the cap at risk
+func applyMaxUnavailable(ctx context.Context, route *v1.Route, revisionMap map[string]*v1.Revision, policy *Policy) {
+	if policy.MaxUnavailablePercent == nil {
+		return
+	}
+	limit := *policy.MaxUnavailablePercent
+	readyIdx := -1 // first fully Ready target, which absorbs the clamped traffic
+	var unreadyIdx []int
+	unreadyTotal := 0
+	for i, t := range route.Spec.Traffic {
+		rev, ok := revisionMap[t.RevisionName]
+		if !ok || t.Percent == nil {
+			continue
+		}
+		if revisionFullyReady(rev) {
+			if readyIdx < 0 {
+				readyIdx = i
+			}
+			continue
+		}
+		unreadyTotal += int(*t.Percent)
+		unreadyIdx = append(unreadyIdx, i)
+	}
+	excess := unreadyTotal - limit
+	if excess <= 0 || readyIdx < 0 {
+		return
+	}
+	logging.FromContext(ctx).Infof("Unready Revisions hold %d%% of traffic, clamping to the %d%% cap", unreadyTotal, limit)
+	// reclaim the excess from the unready targets, newest (appended last) first
+	reclaimed := 0
+	for i := len(unreadyIdx) - 1; i >= 0 && reclaimed < excess; i-- {
+		t := &route.Spec.Traffic[unreadyIdx[i]]
+		take := min(int(*t.Percent), excess-reclaimed)
+		t.Percent = ptr.Int64(*t.Percent - int64(take))
+		reclaimed += take
+	}
+	absorber := &route.Spec.Traffic[readyIdx]
+	absorber.Percent = ptr.Int64(*absorber.Percent + int64(reclaimed))
+}
+
+// revisionFullyReady reports whether the Revision's Ready condition is True;
+// unlike the unhealthy-rollout hold, which only reacts to an explicit failure,
+// the unavailability cap also counts Revisions still in Ready=Unknown
+func revisionFullyReady(rev *v1.Revision) bool {
+	cond := rev.Status.GetCondition(v1.RevisionConditionReady)
+	return cond != nil && cond.IsTrue()
+}
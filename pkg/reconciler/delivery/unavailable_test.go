@@ -0,0 +1,101 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+func revisionWithReadiness(name string, ready corev1.ConditionStatus) *v1.Revision {
+	return &v1.Revision{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: v1.RevisionStatus{
+			Status: duckv1.Status{
+				Conditions: duckv1.Conditions{{Type: apis.ConditionType(v1.RevisionConditionReady), Status: ready}},
+			},
+		},
+	}
+}
+
+func TestApplyMaxUnavailable(t *testing.T) {
+	revisions := func(candidate corev1.ConditionStatus) map[string]*v1.Revision {
+		return map[string]*v1.Revision{
+			"R1": revisionWithReadiness("R1", corev1.ConditionTrue),
+			"R2": revisionWithReadiness("R2", candidate),
+		}
+	}
+	split := func(stable, candidate int64) []v1.TrafficTarget {
+		return []v1.TrafficTarget{
+			traffictarget.Pinned("R1", stable),
+			traffictarget.Tagged(traffictarget.Pinned("R2", candidate), CandidateTag),
+		}
+	}
+	tests := []struct {
+		name      string
+		limit     *int
+		candidate corev1.ConditionStatus
+		traffic   []v1.TrafficTarget
+		want      []v1.TrafficTarget
+	}{{
+		name:      "no cap leaves the split alone",
+		limit:     nil,
+		candidate: corev1.ConditionUnknown,
+		traffic:   split(50, 50),
+		want:      split(50, 50),
+	}, {
+		name:      "ready candidate is not clamped",
+		limit:     intptr(10),
+		candidate: corev1.ConditionTrue,
+		traffic:   split(50, 50),
+		want:      split(50, 50),
+	}, {
+		name:      "degraded candidate is clamped to the cap",
+		limit:     intptr(10),
+		candidate: corev1.ConditionUnknown,
+		traffic:   split(50, 50),
+		want:      split(90, 10),
+	}, {
+		name:      "unready share within the cap is untouched",
+		limit:     intptr(25),
+		candidate: corev1.ConditionFalse,
+		traffic:   split(80, 20),
+		want:      split(80, 20),
+	}, {
+		name:      "cap of zero removes all unready traffic",
+		limit:     intptr(0),
+		candidate: corev1.ConditionFalse,
+		traffic:   split(75, 25),
+		want:      split(100, 0),
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route := &v1.Route{Spec: v1.RouteSpec{Traffic: test.traffic}}
+			policy := &Policy{MaxUnavailablePercent: test.limit}
+			applyMaxUnavailable(context.Background(), route, revisions(test.candidate), policy)
+			if diff := cmp.Diff(test.want, route.Spec.Traffic); diff != "" {
+				t.Errorf("wrong traffic after clamping (-want, +got): %s", diff)
+			}
+		})
+	}
+}
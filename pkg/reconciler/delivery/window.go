@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+// withinWindow determines whether stage promotions are allowed at time t
+// a Policy without any windows allows promotions at all times
+// windows that fail to parse are skipped; validation should have rejected them already
+func withinWindow(p *Policy, t time.Time) bool {
+	if len(p.Windows) == 0 {
+		return true
+	}
+	for _, w := range p.Windows {
+		loc, start, end, err := parseWindow(&w)
+		if err != nil {
+			continue
+		}
+		local := t.In(loc)
+		if !dayMatches(&w, local.Weekday()) {
+			continue
+		}
+		minutes := local.Hour()*60 + local.Minute()
+		if minutes >= start && minutes < end {
+			return true
+		}
+	}
+	return false
+}
+
+// timeTillWindowOpen computes how long after t the earliest Policy window opens
+// the result is 0 if t is already within a window, and also 0 if no window can
+// ever open (which validation is supposed to prevent)
+func timeTillWindowOpen(p *Policy, t time.Time) time.Duration {
+	if withinWindow(p, t) {
+		return 0
+	}
+	result := time.Duration(0)
+	for _, w := range p.Windows {
+		loc, start, _, err := parseWindow(&w)
+		if err != nil {
+			continue
+		}
+		local := t.In(loc)
+		// check today and each of the next 7 days for the earliest opening
+		for dayOffset := 0; dayOffset <= 7; dayOffset++ {
+			candidate := time.Date(local.Year(), local.Month(), local.Day()+dayOffset, start/60, start%60, 0, 0, loc)
+			if !candidate.After(t) || !dayMatches(&w, candidate.Weekday()) {
+				continue
+			}
+			if delay := candidate.Sub(t); result == 0 || delay < result {
+				result = delay
+			}
+			break
+		}
+	}
+	return result
+}
+
+// parseWindow extracts the location and the start/end minutes-of-day from a TimeWindow
+func parseWindow(w *v1alpha1.TimeWindow) (loc *time.Location, start, end int, err error) {
+	loc = time.UTC
+	if w.TimeZone != "" {
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+	s, err := time.Parse(v1alpha1.ClockFormat, w.Start)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	e, err := time.Parse(v1alpha1.ClockFormat, w.End)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return loc, s.Hour()*60 + s.Minute(), e.Hour()*60 + e.Minute(), nil
+}
+
+// dayMatches determines whether a TimeWindow covers the given day of the week
+func dayMatches(w *v1alpha1.TimeWindow, day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day.String()[:3] {
+			return true
+		}
+	}
+	return false
+}
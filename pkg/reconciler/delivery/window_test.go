@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+)
+
+var (
+	// business hours Monday through Friday, UTC
+	businessHours = []v1alpha1.TimeWindow{{
+		Days:  []string{"Mon", "Tue", "Wed", "Thu", "Fri"},
+		Start: "09:00",
+		End:   "17:00",
+	}}
+	// 2020-07-06 is a Monday
+	mondayNoon     = time.Date(2020, 7, 6, 12, 0, 0, 0, time.UTC)
+	mondayEvening  = time.Date(2020, 7, 6, 18, 30, 0, 0, time.UTC)
+	saturdayNoon   = time.Date(2020, 7, 11, 12, 0, 0, 0, time.UTC)
+	fridayAtClose  = time.Date(2020, 7, 10, 17, 0, 0, 0, time.UTC)
+	pWindowed      = Policy{Mode: "time", Stages: []Stage{{Percent: 0}, {Percent: 50}}, DefaultThreshold: 60, Windows: businessHours}
+	pUnconstrained = Policy{Mode: "time", Stages: []Stage{{Percent: 0}, {Percent: 50}}, DefaultThreshold: 60}
+)
+
+func TestWithinWindow(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy *Policy
+		t      time.Time
+		want   bool
+	}{
+		{name: "no windows always allows", policy: &pUnconstrained, t: saturdayNoon, want: true},
+		{name: "weekday inside hours", policy: &pWindowed, t: mondayNoon, want: true},
+		{name: "weekday after hours", policy: &pWindowed, t: mondayEvening, want: false},
+		{name: "weekend is excluded", policy: &pWindowed, t: saturdayNoon, want: false},
+		{name: "window end is exclusive", policy: &pWindowed, t: fridayAtClose, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinWindow(tt.policy, tt.t); got != tt.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeTillWindowOpen(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy *Policy
+		t      time.Time
+		want   time.Duration
+	}{
+		{name: "already inside window", policy: &pWindowed, t: mondayNoon, want: 0},
+		{name: "evening waits until next morning", policy: &pWindowed, t: mondayEvening, want: 14*time.Hour + 30*time.Minute},
+		{name: "weekend waits until Monday", policy: &pWindowed, t: saturdayNoon, want: 45 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := timeTillWindowOpen(tt.policy, tt.t); got != tt.want {
+				t.Errorf("wrong answer (got %v, want %v)", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/time/rate"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+)
+
+var (
+	// throttledWriteCount counts Route updates postponed by the write rate
+	// limiter; a sustained non-zero rate means the configured limits are below
+	// what the cluster's rollout volume needs
+	throttledWriteCount = stats.Int64(
+		"route_writes_throttled",
+		"Number of Route updates postponed by the write rate limiter",
+		stats.UnitDimensionless)
+
+	// throttledNamespaceKey tags throttled writes with the namespace they were
+	// destined for, so a single noisy namespace is easy to spot
+	throttledNamespaceKey = tag.MustNewKey("namespace_name")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: throttledWriteCount.Description(),
+		Measure:     throttledWriteCount,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{throttledNamespaceKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// writeLimiter bounds how fast the reconciler writes Routes: one token bucket
+// for the whole cluster and one per namespace, both sized from config-delivery
+// so operators can tune them without a restart; a burst of Revisions across
+// many Configurations then spreads its Route updates out instead of hammering
+// the API server
+type writeLimiter struct {
+	sync.Mutex
+	globalRate    float64
+	global        *rate.Limiter
+	namespaceRate float64
+	namespaces    map[string]*rate.Limiter
+}
+
+func newWriteLimiter() *writeLimiter {
+	return &writeLimiter{namespaces: map[string]*rate.Limiter{}}
+}
+
+// throttle reserves a write slot for the namespace at the given instant; when
+// either bucket is empty it returns how long the write must wait and gives the
+// reservations back, so a postponed write consumes no tokens
+func (w *writeLimiter) throttle(now time.Time, namespace string, cfg *config.Delivery) (time.Duration, bool) {
+	w.Lock()
+	defer w.Unlock()
+	w.syncRates(cfg)
+
+	var reservations []*rate.Reservation
+	for _, limiter := range []*rate.Limiter{w.global, w.namespaceLimiter(namespace)} {
+		if limiter != nil {
+			reservations = append(reservations, limiter.ReserveN(now, 1))
+		}
+	}
+	var delay time.Duration
+	for _, r := range reservations {
+		if d := r.DelayFrom(now); d > delay {
+			delay = d
+		}
+	}
+	if delay > 0 {
+		for _, r := range reservations {
+			r.CancelAt(now)
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// syncRates rebuilds the token buckets whenever the configured rates change;
+// a rate of zero disables the corresponding bucket
+func (w *writeLimiter) syncRates(cfg *config.Delivery) {
+	if cfg.RouteWritesPerSecond != w.globalRate {
+		w.globalRate = cfg.RouteWritesPerSecond
+		w.global = newLimiter(w.globalRate)
+	}
+	if cfg.NamespaceRouteWritesPerSecond != w.namespaceRate {
+		w.namespaceRate = cfg.NamespaceRouteWritesPerSecond
+		w.namespaces = map[string]*rate.Limiter{}
+	}
+}
+
+// namespaceLimiter lazily creates the namespace's token bucket on first use;
+// it must be called under the lock
+func (w *writeLimiter) namespaceLimiter(namespace string) *rate.Limiter {
+	if w.namespaceRate <= 0 {
+		return nil
+	}
+	limiter, ok := w.namespaces[namespace]
+	if !ok {
+		limiter = newLimiter(w.namespaceRate)
+		w.namespaces[namespace] = limiter
+	}
+	return limiter
+}
+
+// newLimiter builds a token bucket for the given sustained rate, with enough
+// burst to absorb roughly one second of writes
+func newLimiter(perSecond float64) *rate.Limiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst := int(math.Ceil(perSecond))
+	if burst < 1 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), burst)
+}
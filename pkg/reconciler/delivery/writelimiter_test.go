@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+)
+
+func TestWriteLimiterUnlimited(t *testing.T) {
+	w := newWriteLimiter()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if _, throttled := w.throttle(now, "default", &config.Delivery{}); throttled {
+			t.Fatalf("write %d throttled with no limits configured", i)
+		}
+	}
+}
+
+func TestWriteLimiterGlobal(t *testing.T) {
+	w := newWriteLimiter()
+	cfg := &config.Delivery{RouteWritesPerSecond: 1}
+	now := time.Now()
+
+	if _, throttled := w.throttle(now, "default", cfg); throttled {
+		t.Fatal("the first write must pass")
+	}
+	delay, throttled := w.throttle(now, "other", cfg)
+	if !throttled || delay <= 0 {
+		t.Fatalf("the second immediate write should wait, got throttled=%t delay=%v", throttled, delay)
+	}
+	// the postponed write consumed no token, so one write fits after the delay
+	if _, throttled := w.throttle(now.Add(delay), "other", cfg); throttled {
+		t.Fatal("the retried write after the suggested delay must pass")
+	}
+}
+
+func TestWriteLimiterPerNamespace(t *testing.T) {
+	w := newWriteLimiter()
+	cfg := &config.Delivery{NamespaceRouteWritesPerSecond: 1}
+	now := time.Now()
+
+	if _, throttled := w.throttle(now, "team-a", cfg); throttled {
+		t.Fatal("team-a's first write must pass")
+	}
+	if _, throttled := w.throttle(now, "team-a", cfg); !throttled {
+		t.Fatal("team-a's second immediate write should wait")
+	}
+	// each namespace has its own bucket
+	if _, throttled := w.throttle(now, "team-b", cfg); throttled {
+		t.Fatal("team-b must not be penalized for team-a's writes")
+	}
+}
+
+func TestWriteLimiterRateChange(t *testing.T) {
+	w := newWriteLimiter()
+	now := time.Now()
+
+	slow := &config.Delivery{RouteWritesPerSecond: 1}
+	w.throttle(now, "default", slow)
+	if _, throttled := w.throttle(now, "default", slow); !throttled {
+		t.Fatal("the second write at 1/s should wait")
+	}
+	// raising the limit rebuilds the bucket and lets the write through
+	fast := &config.Delivery{RouteWritesPerSecond: 100}
+	if _, throttled := w.throttle(now, "default", fast); throttled {
+		t.Fatal("the write should pass after the limit is raised")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystate
+
+import (
+	"context"
+
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+
+	deliveryclient "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/client"
+	policystateinformer "github.com/googleinterns/knative-continuous-delivery/pkg/client/injection/informers/delivery/v1alpha1/policystate"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	servingclient "knative.dev/serving/pkg/client/injection/client"
+	routeinformer "knative.dev/serving/pkg/client/injection/informers/serving/v1/route"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingreconciler "knative.dev/serving/pkg/reconciler"
+)
+
+const (
+	controllerAgentName = "policystate-controller"
+)
+
+// NewController returns a controller that enforces PolicyState traffic specs
+// on Routes; it is called by generated knative pkg main alongside the
+// delivery controller.
+func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	ctx = servingreconciler.AnnotateLoggerWithName(ctx, controllerAgentName)
+	logger := logging.FromContext(ctx)
+	routeInformer := routeinformer.Get(ctx)
+	policystateInformer := policystateinformer.Get(ctx)
+
+	// this reconciler respects the same namespace scoping as the delivery
+	// reconciler, so it watches the same ConfigMap
+	configStore := config.NewStore(logger.Named("config-store"))
+	configStore.WatchConfigs(cmw)
+
+	r := &Reconciler{
+		client:            servingclient.Get(ctx),
+		psclient:          deliveryclient.Get(ctx),
+		routeLister:       routeInformer.Lister(),
+		policystateLister: policystateInformer.Lister(),
+		configStore:       configStore,
+	}
+	impl := controller.NewImpl(r, logger, "PolicyStates")
+
+	logger.Info("Setting up event handlers")
+
+	policystateInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue))
+
+	// a Route shares its name with its PolicyState, so every Route event is
+	// folded into the matching PolicyState key; this is what catches an
+	// operator edit or a Serving reconciler write bypassing the webhook
+	handleRouteChange := func(obj interface{}) {
+		route, ok := obj.(*v1.Route)
+		if !ok {
+			tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+			if !ok {
+				return
+			}
+			if route, ok = tombstone.Obj.(*v1.Route); !ok {
+				return
+			}
+		}
+		impl.EnqueueKey(types.NamespacedName{Namespace: route.Namespace, Name: route.Name})
+	}
+
+	routeInformer.Informer().AddEventHandler(controller.HandleAll(handleRouteChange))
+
+	return impl
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policystate holds a reconciler keyed on PolicyState that drives
+// Route.Spec.Traffic toward PolicyState.Spec.Traffic. The delivery reconciler
+// decides what the split should be and records it on the PolicyState; this
+// reconciler makes sure the Route actually carries that split, so convergence
+// no longer depends on the Route admission webhook being exercised.
+package policystate
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+	servingv1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingclientset "knative.dev/serving/pkg/client/clientset/versioned"
+	listers "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	clientset "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/delivery/config"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+// Reconciler enforces the desired traffic split recorded on a PolicyState
+type Reconciler struct {
+	client            servingclientset.Interface
+	psclient          clientset.Interface
+	routeLister       listers.RouteLister
+	policystateLister pslisters.PolicyStateLister
+	configStore       *config.Store
+}
+
+// Check that our Reconciler implements controller.Reconciler
+var _ controller.Reconciler = (*Reconciler)(nil)
+
+// Reconcile converges the same-named Route's traffic spec with the
+// PolicyState's desired split, then records the outcome on the PolicyState
+// status; it implements controller.Reconciler
+func (r *Reconciler) Reconcile(ctx context.Context, key string) error {
+	if r.configStore != nil {
+		ctx = r.configStore.ToContext(ctx)
+	}
+	logger := logging.FromContext(ctx)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		logger.Errorf("Invalid resource key: %s", key)
+		return nil
+	}
+	ps, err := r.policystateLister.PolicyStates(namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		// the PolicyState is gone; its Route belongs to the Serving reconciler again
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// an empty desired split means the delivery reconciler has not made a
+	// routing decision yet; there is nothing to enforce
+	if len(ps.Spec.Traffic) == 0 {
+		return nil
+	}
+	// a spec written under a newer semantics contract must not be interpreted;
+	// hold the Route at its current split until this controller is upgraded
+	if !ps.Spec.CompatibleWith(v1alpha1.PolicyStateFormatVersion) {
+		logger.Infof("PolicyState %s/%s spec has format version %d, newer than the supported %d; leaving the Route unchanged",
+			ps.Namespace, ps.Name, ps.Spec.FormatVersion, v1alpha1.PolicyStateFormatVersion)
+		return nil
+	}
+	if !config.FromContextOrDefaults(ctx).Delivery.NamespaceAllowed(namespace) {
+		return nil
+	}
+
+	route, err := r.routeLister.Routes(namespace).Get(name)
+	if apierrs.IsNotFound(err) {
+		// the Route has not been created yet (or was deleted); the delivery
+		// reconciler cleans the PolicyState up on its own schedule
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// the opt-out annotation releases the Route back to the Serving reconciler
+	if delivery.Disabled(route.Annotations) {
+		return nil
+	}
+
+	desired := desiredTraffic(ps)
+	if !equality.Semantic.DeepEqual(route.Spec.Traffic, desired) {
+		logger.Infof("Route %s/%s drifted from the desired traffic split, converging", namespace, name)
+		if err := r.updateRouteTraffic(ctx, route, desired); err != nil {
+			want := ps.DeepCopy()
+			want.Status.MarkRouteNotConfigured(route.Name)
+			if serr := r.updateStatus(ps, want); serr != nil {
+				logger.Errorf("Failed to record the Route write failure on PolicyState %s/%s: %v", namespace, name, serr)
+			}
+			return err
+		}
+	}
+
+	want := ps.DeepCopy()
+	want.Status.MarkRouteConfigured()
+	want.Status.Traffic = want.Spec.Traffic
+	return r.updateStatus(ps, want)
+}
+
+// desiredTraffic rebuilds the PolicyState's split through the shared
+// constructors, so this reconciler, the delivery reconciler and the webhook
+// stay on one code path for Serving's defaulting rules
+func desiredTraffic(ps *v1alpha1.PolicyState) []servingv1.TrafficTarget {
+	traffic := make([]servingv1.TrafficTarget, len(ps.Spec.Traffic))
+	for i, t := range ps.Spec.Traffic {
+		traffic[i] = traffictarget.Normalize(t)
+	}
+	return traffic
+}
+
+// updateRouteTraffic writes the desired split to the Route, retrying
+// conflicts and transient API errors in place; each retry re-reads the Route
+// so the write is never based on a stale copy
+func (r *Reconciler) updateRouteTraffic(ctx context.Context, route *servingv1.Route, desired []servingv1.TrafficTarget) error {
+	want := route.DeepCopy()
+	err := retry.OnError(retry.DefaultBackoff, retriableWriteError, func() error {
+		want.Spec.Traffic = desired
+		_, err := r.client.ServingV1().Routes(route.Namespace).Update(want)
+		if err == nil || !retriableWriteError(err) {
+			return err
+		}
+		fresh, gerr := r.client.ServingV1().Routes(route.Namespace).Get(route.Name, metav1.GetOptions{})
+		if gerr != nil {
+			return gerr
+		}
+		want = fresh.DeepCopy()
+		return err
+	})
+	if err != nil {
+		if recorder := controller.GetEventRecorder(ctx); recorder != nil {
+			recorder.Eventf(route, corev1.EventTypeWarning, "RouteUpdateFailed",
+				"Failed to write the desired traffic split to Route %q: %v", route.Name, err)
+		}
+	}
+	return err
+}
+
+// retriableWriteError reports whether a write failure is worth retrying in
+// place: optimistic-concurrency conflicts and transient API server hiccups
+// resolve on their own, anything else needs the caller's attention
+func retriableWriteError(err error) bool {
+	return apierrs.IsConflict(err) || apierrs.IsServerTimeout(err) || apierrs.IsTimeout(err) ||
+		apierrs.IsTooManyRequests(err) || apierrs.IsServiceUnavailable(err)
+}
+
+// updateStatus persists the PolicyState status when it changed; spec and
+// metadata are never written from here, those belong to the delivery reconciler
+func (r *Reconciler) updateStatus(existing, want *v1alpha1.PolicyState) error {
+	if equality.Semantic.DeepEqual(existing.Status, want.Status) {
+		return nil
+	}
+	_, err := r.psclient.DeliveryV1alpha1().PolicyStates(want.Namespace).UpdateStatus(want)
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policystate
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	servingfake "knative.dev/serving/pkg/client/clientset/versioned/fake"
+	listers "knative.dev/serving/pkg/client/listers/serving/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery/v1alpha1"
+	deliveryfake "github.com/googleinterns/knative-continuous-delivery/pkg/client/clientset/versioned/fake"
+	pslisters "github.com/googleinterns/knative-continuous-delivery/pkg/client/listers/delivery/v1alpha1"
+	. "github.com/googleinterns/knative-continuous-delivery/pkg/reconciler/testing/resources"
+	"github.com/googleinterns/knative-continuous-delivery/pkg/traffictarget"
+)
+
+// newTestReconciler wires a Reconciler around fake clients whose listers are
+// fed from plain indexers, mirroring how the other unit-level reconciler
+// tests in this repo are set up
+func newTestReconciler(routes []*v1.Route, states []*v1alpha1.PolicyState) (*Reconciler, *servingfake.Clientset, *deliveryfake.Clientset) {
+	routeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	sc := servingfake.NewSimpleClientset()
+	for _, r := range routes {
+		routeIndexer.Add(r)
+		sc.Tracker().Add(r)
+	}
+	psIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	dc := deliveryfake.NewSimpleClientset()
+	for _, ps := range states {
+		psIndexer.Add(ps)
+		dc.Tracker().Add(ps)
+	}
+	return &Reconciler{
+		client:            sc,
+		psclient:          dc,
+		routeLister:       listers.NewRouteLister(routeIndexer),
+		policystateLister: pslisters.NewPolicyStateLister(psIndexer),
+	}, sc, dc
+}
+
+func TestReconcileConvergesDriftedRoute(t *testing.T) {
+	desired := traffictarget.Pinned("cfg-00002", 100)
+	ps := PolicyState("default", "cfg", WithPSSpecTraffic(desired))
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cfg"},
+		Spec:       v1.RouteSpec{Traffic: []v1.TrafficTarget{traffictarget.Pinned("cfg-00001", 100)}},
+	}
+	r, sc, dc := newTestReconciler([]*v1.Route{route}, []*v1alpha1.PolicyState{ps})
+
+	if err := r.Reconcile(context.Background(), "default/cfg"); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	gotRoute, err := sc.ServingV1().Routes("default").Get("cfg", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []v1.TrafficTarget{traffictarget.Normalize(desired)}
+	if !equality.Semantic.DeepEqual(gotRoute.Spec.Traffic, want) {
+		t.Errorf("Route traffic = %v, want %v", gotRoute.Spec.Traffic, want)
+	}
+	gotPS, err := dc.DeliveryV1alpha1().PolicyStates("default").Get("cfg", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(gotPS.Status.Traffic, gotPS.Spec.Traffic) {
+		t.Errorf("Status traffic = %v, want the spec mirror %v", gotPS.Status.Traffic, gotPS.Spec.Traffic)
+	}
+	cond := gotPS.Status.GetCondition(v1alpha1.PolicyStateConditionRouteConfigured)
+	if cond == nil || cond.Status != corev1.ConditionTrue {
+		t.Errorf("RouteConfigured condition = %v, want True", cond)
+	}
+}
+
+func TestReconcileLeavesMatchingRouteAlone(t *testing.T) {
+	desired := traffictarget.Pinned("cfg-00002", 100)
+	ps := PolicyState("default", "cfg", WithPSSpecTraffic(desired))
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cfg"},
+		Spec:       v1.RouteSpec{Traffic: []v1.TrafficTarget{traffictarget.Normalize(desired)}},
+	}
+	r, sc, _ := newTestReconciler([]*v1.Route{route}, []*v1alpha1.PolicyState{ps})
+
+	if err := r.Reconcile(context.Background(), "default/cfg"); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	for _, action := range sc.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("unexpected Route write: %v", action)
+		}
+	}
+}
+
+func TestReconcileRespectsOptOut(t *testing.T) {
+	desired := traffictarget.Pinned("cfg-00002", 100)
+	ps := PolicyState("default", "cfg", WithPSSpecTraffic(desired))
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "cfg",
+			Annotations: map[string]string{delivery.EnabledKey: "false"},
+		},
+		Spec: v1.RouteSpec{Traffic: []v1.TrafficTarget{traffictarget.Pinned("cfg-00001", 100)}},
+	}
+	r, sc, _ := newTestReconciler([]*v1.Route{route}, []*v1alpha1.PolicyState{ps})
+
+	if err := r.Reconcile(context.Background(), "default/cfg"); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	for _, action := range sc.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("unexpected Route write for an opted-out workload: %v", action)
+		}
+	}
+}
+
+func TestReconcileSkipsQuietly(t *testing.T) {
+	// neither a missing PolicyState, a missing Route, nor an undecided
+	// (empty-traffic) PolicyState is an error
+	empty := PolicyState("default", "undecided")
+	orphan := PolicyState("default", "no-route", WithPSSpecTraffic(traffictarget.Pinned("r", 100)))
+	r, _, _ := newTestReconciler(nil, []*v1alpha1.PolicyState{empty, orphan})
+
+	for _, key := range []string{"default/missing", "default/undecided", "default/no-route"} {
+		if err := r.Reconcile(context.Background(), key); err != nil {
+			t.Errorf("Reconcile(%q) = %v, want nil", key, err)
+		}
+	}
+}
+
+func TestReconcileSkipsNewerFormatVersion(t *testing.T) {
+	ps := PolicyState("default", "cfg", WithPSSpecTraffic(traffictarget.Pinned("cfg-00002", 100)))
+	ps.Spec.FormatVersion = v1alpha1.PolicyStateFormatVersion + 1
+	route := &v1.Route{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cfg"},
+		Spec:       v1.RouteSpec{Traffic: []v1.TrafficTarget{traffictarget.Pinned("cfg-00001", 100)}},
+	}
+	r, sc, _ := newTestReconciler([]*v1.Route{route}, []*v1alpha1.PolicyState{ps})
+
+	if err := r.Reconcile(context.Background(), "default/cfg"); err != nil {
+		t.Fatalf("Reconcile() = %v", err)
+	}
+	for _, action := range sc.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("unexpected Route write under a newer spec format: %v", action)
+		}
+	}
+}
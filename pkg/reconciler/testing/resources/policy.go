@@ -53,9 +53,30 @@ func WithDefaultThreshold(defaultThreshold int) PolicyOption {
 	}
 }
 
+// WithThresholdUnit sets the Spec.ThresholdUnit of a Policy
+func WithThresholdUnit(unit string) PolicyOption {
+	return func(p *v1alpha1.Policy) {
+		p.Spec.ThresholdUnit = unit
+	}
+}
+
 // WithStages sets the Spec.Stages of a Policy
 func WithStages(stages ...v1alpha1.Stage) PolicyOption {
 	return func(p *v1alpha1.Policy) {
 		p.Spec.Stages = stages
 	}
 }
+
+// WithBoundConfigurations sets the Status.BoundConfigurations of a Policy
+func WithBoundConfigurations(cfgs ...string) PolicyOption {
+	return func(p *v1alpha1.Policy) {
+		p.Status.BoundConfigurations = cfgs
+	}
+}
+
+// WithActiveRollouts sets the Status.ActiveRollouts of a Policy
+func WithActiveRollouts(n int) PolicyOption {
+	return func(p *v1alpha1.Policy) {
+		p.Status.ActiveRollouts = n
+	}
+}
@@ -41,9 +41,11 @@ func PolicyState(namespace, name string, pso ...PolicyStateOption) *psv1alpha1.P
 	return ps
 }
 
-// WithPSSpecTraffic sets the spec traffic of a PolicyState
+// WithPSSpecTraffic sets the spec traffic of a PolicyState, stamped with the
+// current format version the way the reconciler writes it
 func WithPSSpecTraffic(traffic ...v1.TrafficTarget) PolicyStateOption {
 	return func(ps *psv1alpha1.PolicyState) {
+		ps.Spec.FormatVersion = psv1alpha1.PolicyStateFormatVersion
 		ps.Spec.Traffic = traffic
 	}
 }
@@ -61,3 +63,58 @@ func WithNextUpdateTimestamp(t time.Time) PolicyStateOption {
 		ps.Status.NextUpdateTimestamp = &metav1.Time{t}
 	}
 }
+
+// WithPhase sets the Status.Phase of a PolicyState
+func WithPhase(phase string) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.Phase = phase
+	}
+}
+
+// WithPolicySource sets the winning policy source fields of a PolicyState
+func WithPolicySource(source, name string) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.PolicySource = source
+		ps.Status.PolicyName = name
+	}
+}
+
+// WithAssignmentSeed sets the Status.AssignmentSeed of a PolicyState
+func WithAssignmentSeed(seed string) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.AssignmentSeed = seed
+	}
+}
+
+// WithMeasuredRevision sets the Status.MeasuredRevisionName of a PolicyState
+func WithMeasuredRevision(name string) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.MeasuredRevisionName = name
+	}
+}
+
+// WithFirstTraffic sets the first-traffic SLI fields of a PolicyState
+func WithFirstTraffic(t time.Time, seconds int) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.FirstTrafficTime = &metav1.Time{t}
+		ps.Status.TimeToFirstTrafficSeconds = seconds
+	}
+}
+
+// WithFullTraffic sets the full-traffic SLI fields of a PolicyState
+func WithFullTraffic(t time.Time, seconds int) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.FullTrafficTime = &metav1.Time{t}
+		ps.Status.TimeToFullTrafficSeconds = seconds
+	}
+}
+
+// WithStageSnapshot appends a stage snapshot to the Status.StageSnapshots of a PolicyState
+func WithStageSnapshot(percent int, t time.Time) PolicyStateOption {
+	return func(ps *psv1alpha1.PolicyState) {
+		ps.Status.StageSnapshots = append(ps.Status.StageSnapshots, psv1alpha1.StageSnapshot{
+			Percent:     percent,
+			EvaluatedAt: &metav1.Time{t},
+		})
+	}
+}
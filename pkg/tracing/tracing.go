@@ -0,0 +1,93 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing propagates trace context between KCD components through
+// object annotations, so that a single distributed trace spans the
+// reconciler's stage decision and the webhook's Route mutation. The wire
+// format is the W3C traceparent header, which keeps the annotation readable
+// by OpenTelemetry-based tooling as well
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/googleinterns/knative-continuous-delivery/pkg/apis/delivery"
+	"go.opencensus.io/trace"
+)
+
+// TraceContextAnnotationKey carries the trace context of the reconciler's
+// stage transition span, in W3C traceparent format
+const TraceContextAnnotationKey = delivery.GroupName + "/trace-context"
+
+// Inject records the span context in the object's annotations
+func Inject(sc trace.SpanContext, obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[TraceContextAnnotationKey] = format(sc)
+	obj.SetAnnotations(annotations)
+}
+
+// Clear removes any recorded trace context from the object's annotations
+func Clear(obj metav1.Object) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		return
+	}
+	delete(annotations, TraceContextAnnotationKey)
+	obj.SetAnnotations(annotations)
+}
+
+// Extract recovers the span context from the object's annotations
+// the second return value is false when no well-formed context is recorded
+func Extract(obj metav1.Object) (trace.SpanContext, bool) {
+	return parse(obj.GetAnnotations()[TraceContextAnnotationKey])
+}
+
+// format renders a span context as a traceparent value: version "00" followed
+// by the hex trace ID, hex span ID, and trace flags
+func format(sc trace.SpanContext) string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), int(sc.TraceOptions))
+}
+
+// parse is the inverse of format; it rejects anything that is not a
+// well-formed version 00 traceparent value
+func parse(value string) (trace.SpanContext, bool) {
+	var sc trace.SpanContext
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return sc, false
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != 16 {
+		return sc, false
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != 8 {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return sc, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.TraceOptions = trace.TraceOptions(flags[0])
+	return sc, true
+}
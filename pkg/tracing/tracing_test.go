@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"testing"
+
+	"go.opencensus.io/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInjectExtractRoundTrip(t *testing.T) {
+	sc := trace.SpanContext{
+		TraceID:      trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanID:       trace.SpanID{0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18},
+		TraceOptions: trace.TraceOptions(1),
+	}
+	obj := &metav1.ObjectMeta{}
+
+	Inject(sc, obj)
+	want := "00-0102030405060708090a0b0c0d0e0f10-1112131415161718-01"
+	if got := obj.Annotations[TraceContextAnnotationKey]; got != want {
+		t.Errorf("annotation value is incorrect (got %q, want %q)", got, want)
+	}
+
+	got, ok := Extract(obj)
+	if !ok {
+		t.Fatal("Extract failed on a value written by Inject")
+	}
+	if got != sc {
+		t.Errorf("span context did not survive the round trip (got %v, want %v)", got, sc)
+	}
+
+	Clear(obj)
+	if _, ok := Extract(obj); ok {
+		t.Error("Extract succeeded after Clear")
+	}
+}
+
+func TestExtractRejectsMalformedValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "empty value", value: ""},
+		{name: "wrong number of segments", value: "00-abc"},
+		{name: "unsupported version", value: "01-0102030405060708090a0b0c0d0e0f10-1112131415161718-01"},
+		{name: "short trace ID", value: "00-0102-1112131415161718-01"},
+		{name: "short span ID", value: "00-0102030405060708090a0b0c0d0e0f10-1112-01"},
+		{name: "non-hex flags", value: "00-0102030405060708090a0b0c0d0e0f10-1112131415161718-zz"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			obj := &metav1.ObjectMeta{Annotations: map[string]string{TraceContextAnnotationKey: test.value}}
+			if _, ok := Extract(obj); ok {
+				t.Errorf("Extract accepted malformed value %q", test.value)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package traffictarget is the single place where KCD builds Route traffic
+// targets. Serving's defaulting rules for LatestRevision, ConfigurationName and
+// tags evolve between releases; funnelling every construction through these
+// helpers means a Serving-side change requires one code path update here,
+// covered by the compatibility matrix in the package tests
+package traffictarget
+
+import (
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// Pinned builds a target that sends percent of the traffic to a specific Revision
+func Pinned(revisionName string, percent int64) v1.TrafficTarget {
+	return v1.TrafficTarget{
+		RevisionName:   revisionName,
+		LatestRevision: ptr.Bool(false),
+		Percent:        ptr.Int64(percent),
+	}
+}
+
+// Latest builds a target that follows the Configuration's latest ready Revision
+// with all of the traffic; this is the shape of a stabilized rollout
+func Latest(configurationName string) v1.TrafficTarget {
+	return v1.TrafficTarget{
+		ConfigurationName: configurationName,
+		LatestRevision:    ptr.Bool(true),
+		Percent:           ptr.Int64(100),
+	}
+}
+
+// Tagged returns the target with the given tag attached
+func Tagged(target v1.TrafficTarget, tag string) v1.TrafficTarget {
+	target.Tag = tag
+	return target
+}
+
+// Normalize rebuilds a target through the constructors above, so that traffic
+// written by the webhook follows the same defaulting policy as the reconciler
+func Normalize(target v1.TrafficTarget) v1.TrafficTarget {
+	var out v1.TrafficTarget
+	if target.LatestRevision != nil && *target.LatestRevision {
+		out = Latest(target.ConfigurationName)
+	} else {
+		var percent int64
+		if target.Percent != nil {
+			percent = *target.Percent
+		}
+		out = Pinned(target.RevisionName, percent)
+	}
+	return Tagged(out, target.Tag)
+}
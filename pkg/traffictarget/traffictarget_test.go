@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffictarget
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+)
+
+// the compatibility matrix pins down the exact target shapes KCD writes; if
+// Serving changes its defaulting rules, this is where the fallout shows up
+func TestCompatibilityMatrix(t *testing.T) {
+	var tests = []struct {
+		name string
+		got  v1.TrafficTarget
+		want v1.TrafficTarget
+	}{
+		{name: "pinned target", got: Pinned("R1", 90),
+			want: v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(90)}},
+		{name: "latest target", got: Latest("cfg"),
+			want: v1.TrafficTarget{ConfigurationName: "cfg", LatestRevision: ptr.Bool(true), Percent: ptr.Int64(100)}},
+		{name: "tagged pinned target", got: Tagged(Pinned("R2", 10), "candidate"),
+			want: v1.TrafficTarget{RevisionName: "R2", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(10), Tag: "candidate"}},
+		{name: "normalize keeps a pinned target intact",
+			got:  Normalize(v1.TrafficTarget{RevisionName: "R1", LatestRevision: ptr.Bool(false), Percent: ptr.Int64(50), Tag: "candidate"}),
+			want: Tagged(Pinned("R1", 50), "candidate")},
+		{name: "normalize fills in pinned defaults",
+			got:  Normalize(v1.TrafficTarget{RevisionName: "R1", Percent: ptr.Int64(50)}),
+			want: Pinned("R1", 50)},
+		{name: "normalize rebuilds a latest target",
+			got:  Normalize(v1.TrafficTarget{ConfigurationName: "cfg", LatestRevision: ptr.Bool(true)}),
+			want: Latest("cfg")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.got); diff != "" {
+				t.Errorf("traffic target is incorrect (-want, +got): %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watchscope narrows the informers of a KCD binary to a single
+// namespace, so that on clusters with tens of thousands of Revisions a
+// team can run their own scoped controller instead of caching everything.
+package watchscope
+
+import (
+	"context"
+	"os"
+
+	"knative.dev/pkg/injection"
+)
+
+// NamespaceEnvVar names the environment variable that opts the binary into
+// single-namespace mode; unset or empty keeps the default cluster-wide watches.
+// Note that a scoped controller cannot see Policies outside its namespace, so
+// the cluster-wide default Policy and cross-namespace policy references do not
+// apply in this mode.
+const NamespaceEnvVar = "WATCH_NAMESPACE"
+
+// FromEnv scopes every injected informer factory to the namespace named by
+// WATCH_NAMESPACE; it must run before injection sets the informers up, i.e. on
+// the context handed to sharedmain
+func FromEnv(ctx context.Context) context.Context {
+	if ns := os.Getenv(NamespaceEnvVar); ns != "" {
+		return injection.WithNamespaceScope(ctx, ns)
+	}
+	return ctx
+}
@@ -0,0 +1,38 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watchscope
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"knative.dev/pkg/injection"
+)
+
+func TestFromEnv(t *testing.T) {
+	defer os.Unsetenv(NamespaceEnvVar)
+
+	os.Unsetenv(NamespaceEnvVar)
+	if ctx := FromEnv(context.Background()); injection.HasNamespaceScope(ctx) {
+		t.Error("an unset WATCH_NAMESPACE must keep cluster-wide watches")
+	}
+
+	os.Setenv(NamespaceEnvVar, "team-a")
+	ctx := FromEnv(context.Background())
+	if got := injection.GetNamespaceScope(ctx); got != "team-a" {
+		t.Errorf("namespace scope = %q, want %q", got, "team-a")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	servinge2e "knative.dev/serving/test/e2e"
+
+	kcdtest "github.com/googleinterns/knative-continuous-delivery/test"
+	"knative.dev/pkg/ptr"
+	v1 "knative.dev/serving/pkg/apis/serving/v1"
+	"knative.dev/serving/test"
+	v1test "knative.dev/serving/test/v1"
+)
+
+// TestManualRouteEditReverted checks that a manual edit to Route.Spec.Traffic during
+// a rollout does not stick: the defaulting webhook must restore the traffic split
+// recorded in the PolicyState before the Route becomes ready again
+func TestManualRouteEditReverted(t *testing.T) {
+	t.Parallel()
+
+	clients := servinge2e.Setup(t)
+
+	var ksvcname = test.ObjectNameForTest(t)
+
+	blue := test.ResourceNames{
+		Service: ksvcname,
+		Image:   "blue",
+	}
+	green := test.ResourceNames{
+		Service: ksvcname,
+		Image:   "green",
+	}
+
+	test.EnsureTearDown(t, clients, &blue)
+	test.EnsureTearDown(t, clients, &green)
+
+	t.Log("Creating a new Service")
+	_, err := v1test.CreateServiceReady(t, clients, &blue)
+	if err != nil {
+		t.Fatalf("Failed to create initial Service: %v: %v", blue.Service, err)
+	}
+
+	t.Log("Configuring Service with new version")
+	resources, err := kcdtest.UpdateServiceReady(t, clients, &green)
+	if err != nil {
+		t.Fatalf("Failed to configure Service with new version: %v: %v", green.Service, err)
+	}
+
+	// remember the traffic split that KCD wrote, then try to clobber it by hand
+	route := resources.Route
+	want := route.Spec.DeepCopy().Traffic
+
+	t.Log("Manually patching Route.Spec.Traffic")
+	manual := route.DeepCopy()
+	manual.Spec.Traffic = []v1.TrafficTarget{{
+		ConfigurationName: route.Name,
+		LatestRevision:    ptr.Bool(true),
+		Percent:           ptr.Int64(100),
+	}}
+	if _, err := clients.ServingClient.Routes.Update(manual); err != nil {
+		t.Fatalf("Failed to manually update Route %v: %v", route.Name, err)
+	}
+
+	t.Log("Waiting for the webhook to restore the PolicyState-derived split")
+	if err := v1test.WaitForRouteState(clients.ServingClient, route.Name, func(r *v1.Route) (bool, error) {
+		return cmp.Equal(r.Spec.Traffic, want), nil
+	}, "RouteSpecTrafficRestored"); err != nil {
+		t.Fatalf("Route %s was not reverted to the rollout traffic split: %v", route.Name, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// flaky is a test image that injects errors and latency on demand, so that
+// e2e tests can exercise metric-gated promotion and rollback against real
+// traffic without depending on anything outside the cluster
+// defaults come from the ERROR_RATE (percent) and LATENCY (Go duration) env
+// vars; individual requests may override them with the X-Error-Rate and
+// X-Latency headers, and X-Fail forces a failure outright
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	errorRateEnv    = "ERROR_RATE"
+	latencyEnv      = "LATENCY"
+	errorRateHeader = "X-Error-Rate"
+	latencyHeader   = "X-Latency"
+	forceFailHeader = "X-Fail"
+)
+
+// injector spreads failures evenly over the request sequence instead of
+// rolling dice, so that a test observing N requests sees exactly N*rate/100
+// failures and flakes never come from the flakiness being itself random
+type injector struct {
+	requests uint64
+}
+
+// shouldFail reports whether the next request must fail to keep the observed
+// failure rate at the given percent
+func (i *injector) shouldFail(rate int) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 100 {
+		return true
+	}
+	n := atomic.AddUint64(&i.requests, 1)
+	return n*uint64(rate)/100 != (n-1)*uint64(rate)/100
+}
+
+// intFromRequest reads a percent from the header when present, else the env var
+func intFromRequest(r *http.Request, header, env string) int {
+	value := r.Header.Get(header)
+	if value == "" {
+		value = os.Getenv(env)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// durationFromRequest reads a duration from the header when present, else the env var
+func durationFromRequest(r *http.Request, header, env string) time.Duration {
+	value := r.Header.Get(header)
+	if value == "" {
+		value = os.Getenv(env)
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func main() {
+	inj := &injector{}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if latency := durationFromRequest(r, latencyHeader, latencyEnv); latency > 0 {
+			time.Sleep(latency)
+		}
+		if r.Header.Get(forceFailHeader) != "" || inj.shouldFail(intFromRequest(r, errorRateHeader, errorRateEnv)) {
+			http.Error(w, "injected error", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Print("flaky test image listening on port ", port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
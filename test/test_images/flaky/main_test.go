@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestShouldFailIsDeterministic(t *testing.T) {
+	tests := []struct {
+		rate     int
+		requests int
+		want     int // exact number of failures
+	}{
+		{rate: 0, requests: 100, want: 0},
+		{rate: 25, requests: 100, want: 25},
+		{rate: 25, requests: 400, want: 100},
+		{rate: 100, requests: 50, want: 50},
+	}
+	for _, test := range tests {
+		failures := func() []int {
+			inj := &injector{}
+			var failed []int
+			for n := 0; n < test.requests; n++ {
+				if inj.shouldFail(test.rate) {
+					failed = append(failed, n)
+				}
+			}
+			return failed
+		}
+		first, second := failures(), failures()
+		if len(first) != test.want {
+			t.Errorf("rate %d over %d requests produced %d failures, want exactly %d", test.rate, test.requests, len(first), test.want)
+		}
+		if len(first) != len(second) {
+			t.Errorf("two identical request sequences failed differently: %d vs %d failures", len(first), len(second))
+			continue
+		}
+		for i := range first {
+			if first[i] != second[i] {
+				t.Errorf("failure positions are not reproducible: request %d vs %d", first[i], second[i])
+				break
+			}
+		}
+	}
+}